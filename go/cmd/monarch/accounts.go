@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/notes"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+func accountsUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch accounts [<command>] [options]
+
+Commands:
+  list  List accounts with their notes and due reminders (the default)
+  set   Set the notes, review dates or rate expiration of an account
+
+Notes are kept locally, in the profile's accounts.json. Reviews and rate
+expirations that have come due are also reported by fetch.`)
+}
+
+func cmdAccounts(args []string) error {
+	if len(args) < 1 {
+		return cmdAccountsList(args)
+	}
+	switch args[0] {
+	case "list":
+		return cmdAccountsList(args[1:])
+	case "set":
+		return cmdAccountsSet(args[1:])
+	case "-h", "--help", "help":
+		accountsUsage()
+		return nil
+	default:
+		if strings.HasPrefix(args[0], "-") {
+			return cmdAccountsList(args)
+		}
+		accountsUsage()
+		return fmt.Errorf("unknown accounts command: %s", args[0])
+	}
+}
+
+// notesPath is the profile's account notes file.
+func notesPath() string {
+	return filepath.Join(prof.baseDir(), notes.File)
+}
+
+func cmdAccountsList(args []string) error {
+	fs := newFlagSet("accounts list")
+	var auth authFlags
+	auth.register(fs)
+	within := fs.Int("within", 30, "Also remind of reviews and expirations due within this many days")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch accounts [list] [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	book, err := notes.Load(notesPath())
+	if err != nil {
+		return err
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	accounts, err := fetchAccounts(c)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, a := range accounts {
+		n := book[a.ID]
+		rows = append(rows, []string{a.Name, a.TypeDisplay, fmt.Sprintf("%.2f", a.Balance),
+			n.Reviewed, n.ReviewBy, strings.TrimSpace(n.RateExpires + " " + n.Rate), n.Text})
+	}
+	report.WriteTable(stdout, []string{"account", "type", "balance", "reviewed", "review_by", "rate_expires", "notes"}, rows)
+	return writeReminders(book, accounts, *within)
+}
+
+// writeReminders prints the reminders of book due within days, naming
+// the accounts as they are called now.
+func writeReminders(book notes.Book, accounts []portfolio.AccountRecord, within int) error {
+	due, err := book.Due(now(), within)
+	if err != nil || len(due) == 0 {
+		return err
+	}
+	names := make(map[string]string, len(accounts))
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+	fmt.Fprintln(stdout)
+	for _, r := range due {
+		name := names[r.AccountID]
+		if name == "" {
+			name = r.Account
+		}
+		fmt.Fprintf(stdout, "%s %s\n", tr.Text("Reminder:"), reminderText(r, name))
+	}
+	return nil
+}
+
+// reminderText describes r for the account called name.
+func reminderText(r notes.Reminder, name string) string {
+	y, m, d := now().Date()
+	days := int(r.Due.Sub(time.Date(y, m, d, 0, 0, 0, 0, r.Due.Location())).Hours() / 24)
+	var when string
+	switch {
+	case days < 0:
+		when = fmt.Sprintf("%d days ago", -days)
+	case days == 0:
+		when = "today"
+	default:
+		when = fmt.Sprintf("in %d days", days)
+	}
+	date := r.Due.Format(time.DateOnly)
+	if r.What == "review" {
+		return fmt.Sprintf("review %s, due %s (%s)", name, date, when)
+	}
+	what := "rate"
+	if r.Detail != "" {
+		what = r.Detail
+	}
+	return fmt.Sprintf("%s of %s expires %s (%s)", what, name, date, when)
+}
+
+func cmdAccountsSet(args []string) error {
+	fs := newFlagSet("accounts set")
+	var auth authFlags
+	auth.register(fs)
+	account := fs.String("account", "", "Name or ID of the account")
+	text := fs.String("notes", "", "Free-form notes")
+	reviewed := fs.String("reviewed", "", "Date of the last review, YYYY-MM-DD or \"today\"")
+	reviewBy := fs.String("review-by", "", "Date the next review is due")
+	reviewEvery := fs.Int("review-every", 0, "Months after -reviewed when the next review is due (sets -review-by)")
+	rate := fs.String("rate", "", "Rate that expires, e.g. \"5.1% promo APY\"")
+	rateExpires := fs.String("rate-expires", "", "Date the CD matures or the promotional rate ends")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch accounts set -account <name or ID> [options]")
+		fmt.Fprintln(stderr, "\nOnly the options given are changed; give one as \"\" to clear it.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *account == "" {
+		fs.Usage()
+		return fmt.Errorf("-account is required")
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *reviewed == "today" {
+		*reviewed = now().Format(time.DateOnly)
+	}
+	for _, d := range []struct{ name, value string }{{"reviewed", *reviewed}, {"review-by", *reviewBy}, {"rate-expires", *rateExpires}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, d.value); err != nil {
+			return fmt.Errorf("-%s %q: want YYYY-MM-DD", d.name, d.value)
+		}
+	}
+
+	path := notesPath()
+	book, err := notes.Load(path)
+	if err != nil {
+		return err
+	}
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	accounts, err := fetchAccounts(c)
+	if err != nil {
+		return err
+	}
+	a, err := findAccount(accounts, *account)
+	if err != nil {
+		return err
+	}
+
+	n := book[a.ID]
+	n.Name = a.Name
+	for name, f := range map[string]struct{ dst, src *string }{
+		"notes":        {&n.Text, text},
+		"reviewed":     {&n.Reviewed, reviewed},
+		"review-by":    {&n.ReviewBy, reviewBy},
+		"rate":         {&n.Rate, rate},
+		"rate-expires": {&n.RateExpires, rateExpires},
+	} {
+		if set[name] {
+			*f.dst = *f.src
+		}
+	}
+	if *reviewEvery > 0 {
+		if n.Reviewed == "" {
+			return fmt.Errorf("-review-every needs a review date; give -reviewed")
+		}
+		last, _ := time.Parse(time.DateOnly, n.Reviewed)
+		n.ReviewBy = last.AddDate(0, *reviewEvery, 0).Format(time.DateOnly)
+	}
+	book[a.ID] = n
+	if err := notes.Save(book, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Updated the notes of %s in %s\n", a.Name, path)
+	return nil
+}
+
+// remindDue prints the reviews and rate expirations of the profile's
+// accounts that are due today or overdue.
+func remindDue(accounts []portfolio.AccountRecord) error {
+	book, err := notes.Load(notesPath())
+	if err != nil {
+		return err
+	}
+	return writeReminders(book, accounts, 0)
+}
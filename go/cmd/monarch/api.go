@@ -0,0 +1,567 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/budget"
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+const portfolioQuery = `query Web_GetPortfolio($portfolioInput: PortfolioInput, $after: String) {
+  portfolio(input: $portfolioInput) {
+    aggregateHoldings(after: $after) {
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      edges {
+        node {
+          holdings {
+            id
+            type
+            typeDisplay
+            name
+            ticker
+            closingPrice
+            closingPriceUpdatedAt
+            quantity
+            value
+            account {
+              id
+              mask
+              displayName
+              institution {
+                id
+                name
+                __typename
+              }
+              __typename
+            }
+            __typename
+          }
+          security {
+            id
+            name
+            ticker
+            currentPrice
+            currentPriceUpdatedAt
+            closingPrice
+            type
+            typeDisplay
+            __typename
+          }
+          __typename
+        }
+        __typename
+      }
+      __typename
+    }
+    __typename
+  }
+}`
+
+const accountsQuery = `query GetAccounts {
+  accounts {
+    id
+    displayName
+    mask
+    isAsset
+    isHidden
+    includeInNetWorth
+    currentBalance
+    displayLastUpdatedAt
+    type {
+      name
+      display
+      __typename
+    }
+    subtype {
+      name
+      display
+      __typename
+    }
+    institution {
+      id
+      name
+      __typename
+    }
+    __typename
+  }
+}`
+
+const transactionsQuery = `query GetTransactionsList($offset: Int, $limit: Int, $filters: TransactionFilterInput, $orderBy: TransactionOrdering) {
+  allTransactions(filters: $filters) {
+    totalCount
+    results(offset: $offset, limit: $limit, orderBy: $orderBy) {
+      id
+      amount
+      pending
+      date
+      hideFromReports
+      needsReview
+      notes
+      category {
+        id
+        name
+        group {
+          id
+          name
+          type
+          __typename
+        }
+        __typename
+      }
+      merchant {
+        id
+        name
+        __typename
+      }
+      account {
+        id
+        displayName
+        __typename
+      }
+      tags {
+        id
+        name
+        color
+        __typename
+      }
+      __typename
+    }
+    __typename
+  }
+}`
+
+const categoriesQuery = `query GetCategories {
+  categories {
+    id
+    name
+    group {
+      id
+      name
+      type
+      __typename
+    }
+    __typename
+  }
+}`
+
+const tagsQuery = `query GetHouseholdTransactionTags {
+  householdTransactionTags {
+    id
+    name
+    color
+    __typename
+  }
+}`
+
+const budgetQuery = `query GetJointPlanningData($startDate: Date!, $endDate: Date!) {
+  budgetData(startMonth: $startDate, endMonth: $endDate) {
+    monthlyAmountsByCategory {
+      category {
+        id
+        __typename
+      }
+      monthlyAmounts {
+        month
+        plannedCashFlowAmount
+        actualAmount
+        remainingAmount
+        __typename
+      }
+      __typename
+    }
+    __typename
+  }
+  categoryGroups {
+    id
+    name
+    order
+    type
+    categories {
+      id
+      name
+      order
+      __typename
+    }
+    __typename
+  }
+}`
+
+const updateBudgetItemMutation = `mutation Common_UpdateBudgetItem($input: UpdateOrCreateBudgetItemMutationInput!) {
+  updateOrCreateBudgetItem(input: $input) {
+    budgetItem {
+      id
+      budgetAmount
+      __typename
+    }
+    __typename
+  }
+}`
+
+// payloadErrorFields is selected by every mutation so failures can be reported.
+const payloadErrorFields = `
+fragment PayloadErrorFields on PayloadError {
+  fieldErrors {
+    field
+    messages
+    __typename
+  }
+  message
+  code
+  __typename
+}`
+
+const updateTransactionMutation = `mutation Web_TransactionDrawerUpdateTransaction($input: UpdateTransactionMutationInput!) {
+  updateTransaction(input: $input) {
+    transaction {
+      id
+      __typename
+    }
+    errors {
+      ...PayloadErrorFields
+      __typename
+    }
+    __typename
+  }
+}` + payloadErrorFields
+
+const setTransactionTagsMutation = `mutation Web_SetTransactionTags($input: SetTransactionTagsInput!) {
+  setTransactionTags(input: $input) {
+    transaction {
+      id
+      __typename
+    }
+    errors {
+      ...PayloadErrorFields
+      __typename
+    }
+    __typename
+  }
+}` + payloadErrorFields
+
+const splitTransactionMutation = `mutation Common_SplitTransactionMutation($input: UpdateTransactionSplitMutationInput!) {
+  updateTransactionSplit(input: $input) {
+    transaction {
+      id
+      __typename
+    }
+    errors {
+      ...PayloadErrorFields
+      __typename
+    }
+    __typename
+  }
+}` + payloadErrorFields
+
+const createManualAccountMutation = `mutation Web_CreateManualAccount($input: CreateManualAccountMutationInput!) {
+  createManualAccount(input: $input) {
+    account {
+      id
+      __typename
+    }
+    errors {
+      ...PayloadErrorFields
+      __typename
+    }
+    __typename
+  }
+}` + payloadErrorFields
+
+const updateAccountMutation = `mutation Common_UpdateAccount($input: UpdateAccountMutationInput!) {
+  updateAccount(input: $input) {
+    account {
+      id
+      displayBalance
+      __typename
+    }
+    errors {
+      ...PayloadErrorFields
+      __typename
+    }
+    __typename
+  }
+}` + payloadErrorFields
+
+const meQuery = `query Common_GetMe {
+  me {
+    id
+    name
+    email
+    timezone
+    hasMfaOn
+    __typename
+  }
+  subscription {
+    id
+    isOnFreeTrial
+    hasPremiumEntitlement
+    __typename
+  }
+}`
+
+const householdQuery = `query Common_GetMyHousehold {
+  myHousehold {
+    id
+    name
+    __typename
+  }
+}`
+
+// transactionPageSize is the number of transactions requested per page.
+const transactionPageSize = 500
+
+// fetchPortfolio fetches the portfolio from the Monarch API and returns the
+// raw JSON, with the holdings of all pages in the first page's portfolio.
+func fetchPortfolio(c client.API) (json.RawMessage, error) {
+	var portfolio, holdings map[string]json.RawMessage
+	edges := []json.RawMessage{}
+	err := client.Paginate(cmdCtx, c, "Web_GetPortfolio", portfolioQuery, map[string]any{}, "portfolio.aggregateHoldings.pageInfo", func(data map[string]json.RawMessage) error {
+		raw, ok := data["portfolio"]
+		if !ok {
+			return fmt.Errorf("portfolio key missing from GraphQL response")
+		}
+		var page, pageHoldings map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return fmt.Errorf("decode portfolio: %w", err)
+		}
+		if raw, ok := page["aggregateHoldings"]; ok {
+			var conn struct {
+				Edges []json.RawMessage `json:"edges"`
+			}
+			if err := json.Unmarshal(raw, &pageHoldings); err != nil {
+				return fmt.Errorf("decode holdings: %w", err)
+			}
+			if err := json.Unmarshal(raw, &conn); err != nil {
+				return fmt.Errorf("decode holdings: %w", err)
+			}
+			edges = append(edges, conn.Edges...)
+		}
+		if portfolio == nil {
+			portfolio, holdings = page, pageHoldings
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if holdings != nil {
+		delete(holdings, "pageInfo")
+		if holdings["edges"], err = json.Marshal(edges); err != nil {
+			return nil, err
+		}
+		if portfolio["aggregateHoldings"], err = json.Marshal(holdings); err != nil {
+			return nil, err
+		}
+	}
+	// Wrap it back in the expected {"portfolio": ...} envelope.
+	return json.Marshal(map[string]any{"portfolio": portfolio})
+}
+
+// me is the logged-in user as returned by meQuery, plus their household.
+type me struct {
+	User struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Timezone string `json:"timezone"`
+		HasMFAOn bool   `json:"hasMfaOn"`
+	} `json:"me"`
+	Subscription struct {
+		IsOnFreeTrial         bool `json:"isOnFreeTrial"`
+		HasPremiumEntitlement bool `json:"hasPremiumEntitlement"`
+	} `json:"subscription"`
+	Household struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"myHousehold"`
+}
+
+// fetchMe fetches the logged-in user, subscription and household in one
+// batch. The household is optional: if that query fails, only the user is
+// returned.
+func fetchMe(c client.API) (*me, error) {
+	results, err := c.BatchCall(cmdCtx,
+		client.Operation{Name: "Common_GetMe", Query: meQuery, Variables: map[string]any{}},
+		client.Operation{Name: "Common_GetMyHousehold", Query: householdQuery, Variables: map[string]any{}})
+	if err != nil {
+		return nil, err
+	}
+	data, err := results[0].Data, results[0].Err
+	if err != nil {
+		return nil, err
+	}
+	if results[1].Err == nil {
+		data["myHousehold"] = results[1].Data["myHousehold"]
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var m me
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("decode user: %w", err)
+	}
+	return &m, nil
+}
+
+// fetchAccounts fetches all accounts with their current balances.
+func fetchAccounts(c client.API) ([]portfolio.AccountRecord, error) {
+	data, err := c.GraphQLCall(cmdCtx, "GetAccounts", accountsQuery, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := portfolio.ParseAccounts(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode accounts: %w", err)
+	}
+	return portfolio.ExtractAccounts(resp), nil
+}
+
+// fetchTransactions fetches all transactions between from and to (inclusive),
+// optionally restricted to the given account IDs, following pagination.
+func fetchTransactions(c client.API, from, to time.Time, accountIDs []string) ([]transactions.Transaction, error) {
+	if accountIDs == nil {
+		accountIDs = []string{}
+	}
+	var all []transactions.Transaction
+	for offset := 0; ; offset += transactionPageSize {
+		data, err := c.GraphQLCall(cmdCtx, "GetTransactionsList", transactionsQuery, map[string]any{
+			"offset":  offset,
+			"limit":   transactionPageSize,
+			"orderBy": "date",
+			"filters": map[string]any{
+				"search":     "",
+				"categories": []string{},
+				"accounts":   accountIDs,
+				"tags":       []string{},
+				"startDate":  from.Format(time.DateOnly),
+				"endDate":    to.Format(time.DateOnly),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		page, err := transactions.ParsePage(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode transactions: %w", err)
+		}
+		all = append(all, page.Results...)
+		if len(page.Results) < transactionPageSize || len(all) >= page.TotalCount {
+			transactions.Sort(all)
+			return all, nil
+		}
+	}
+}
+
+// fetchCategories fetches all transaction categories.
+func fetchCategories(c client.API) ([]transactions.Category, error) {
+	data, err := c.GraphQLCall(cmdCtx, "GetCategories", categoriesQuery, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	return transactions.ParseCategories(data)
+}
+
+// fetchTags fetches all transaction tags in the household.
+func fetchTags(c client.API) ([]transactions.Tag, error) {
+	data, err := c.GraphQLCall(cmdCtx, "GetHouseholdTransactionTags", tagsQuery, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	return transactions.ParseTags(data)
+}
+
+// mutate runs a mutation and turns a non-empty errors payload under key
+// into a Go error.
+func mutate(c client.API, operation, query, key string, input map[string]any) error {
+	_, err := mutatePayload(c, operation, query, key, input)
+	return err
+}
+
+// mutatePayload is like mutate but also returns the payload under key.
+func mutatePayload(c client.API, operation, query, key string, input map[string]any) (json.RawMessage, error) {
+	data, err := c.GraphQLCall(cmdCtx, operation, query, map[string]any{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Errors *struct {
+			Message     string `json:"message"`
+			FieldErrors []struct {
+				Field    string   `json:"field"`
+				Messages []string `json:"messages"`
+			} `json:"fieldErrors"`
+		} `json:"errors"`
+	}
+	if raw, ok := data[key]; ok {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", operation, err)
+		}
+	}
+	if e := payload.Errors; e != nil && (e.Message != "" || len(e.FieldErrors) > 0) {
+		msg := e.Message
+		for _, fe := range e.FieldErrors {
+			msg += fmt.Sprintf(" %s: %v", fe.Field, fe.Messages)
+		}
+		return nil, fmt.Errorf("%s: %s", operation, msg)
+	}
+	return data[key], nil
+}
+
+// apiMutator applies staged transaction edits through the API.
+type apiMutator struct {
+	c client.API
+}
+
+func (m apiMutator) SetCategory(transactionID, categoryID string) error {
+	return mutate(m.c, "Web_TransactionDrawerUpdateTransaction", updateTransactionMutation, "updateTransaction",
+		map[string]any{"id": transactionID, "category": categoryID})
+}
+
+func (m apiMutator) SetTags(transactionID string, tagIDs []string) error {
+	if tagIDs == nil {
+		tagIDs = []string{}
+	}
+	return mutate(m.c, "Web_SetTransactionTags", setTransactionTagsMutation, "setTransactionTags",
+		map[string]any{"transactionId": transactionID, "tagIds": tagIDs})
+}
+
+func (m apiMutator) SplitTransaction(transactionID string, splits []transactions.Split) error {
+	return mutate(m.c, "Common_SplitTransactionMutation", splitTransactionMutation, "updateTransactionSplit",
+		map[string]any{"transactionId": transactionID, "splitData": splits})
+}
+
+func (m apiMutator) MarkReviewed(transactionID string) error {
+	return mutate(m.c, "Web_TransactionDrawerUpdateTransaction", updateTransactionMutation, "updateTransaction",
+		map[string]any{"id": transactionID, "needsReview": false})
+}
+
+// fetchBudget fetches budgeted and actual amounts for the month containing month.
+func fetchBudget(c client.API, month time.Time) (*budget.Data, error) {
+	start := budget.MonthStart(month)
+	data, err := c.GraphQLCall(cmdCtx, "GetJointPlanningData", budgetQuery, map[string]any{
+		"startDate": start.Format(time.DateOnly),
+		"endDate":   start.AddDate(0, 1, -1).Format(time.DateOnly),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return budget.Parse(data)
+}
+
+func (m apiMutator) SetBudget(categoryID string, month time.Time, amount float64, applyToFuture bool) error {
+	return mutate(m.c, "Common_UpdateBudgetItem", updateBudgetItemMutation, "updateOrCreateBudgetItem",
+		map[string]any{
+			"startDate":     budget.MonthStart(month).Format(time.DateOnly),
+			"timeframe":     "month",
+			"categoryId":    categoryID,
+			"amount":        amount,
+			"applyToFuture": applyToFuture,
+		})
+}
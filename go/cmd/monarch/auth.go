@@ -0,0 +1,726 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/secrets"
+)
+
+// authFlags are the authentication options shared by every command that
+// talks to the Monarch API.
+type authFlags struct {
+	credsPath      string
+	email          string
+	noSession      bool
+	token          string
+	useGoogle      bool
+	useBrowser     bool
+	useRemote      bool
+	cookies        string
+	sessionStore   string
+	noReauth       bool
+	nonInteractive bool
+	mfaCode        string
+	backupCode     string
+	household      string
+}
+
+func (a *authFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.credsPath, "c", prof.credentials, "Path to credentials JSON file")
+	fs.StringVar(&a.email, "email", "", "Monarch login whose saved session to use, to switch between logins (default the email in the credentials)")
+	fs.BoolVar(&a.noSession, "no-session", false, "Skip saved session and always re-authenticate")
+	fs.StringVar(&a.token, "token", "", "Auth token (skips login; use token from browser DevTools; also MONARCH_TOKEN)")
+	fs.BoolVar(&a.useGoogle, "google", false, "Authenticate via Google SSO (opens browser)")
+	fs.BoolVar(&a.useBrowser, "browser", false, "Log in through a Chrome window and read the token automatically")
+	fs.BoolVar(&a.useRemote, "remote", false, "Log in from a headless machine by pasting a token from a browser elsewhere (default for -google and -browser over SSH)")
+	fs.StringVar(&a.cookies, "cookies", "", "Cookie header copied from the browser (to pass Cloudflare challenges)")
+	fs.BoolVar(&a.noReauth, "no-reauth", false, "Fail instead of logging in again when the saved session has expired")
+	fs.StringVar(&a.sessionStore, "session-store", "", "Where to keep the session: file, keyring or auto (default from config, else file)")
+	fs.BoolVar(&a.nonInteractive, "non-interactive", os.Getenv("MONARCH_NON_INTERACTIVE") != "", "Fail instead of prompting for input (also MONARCH_NON_INTERACTIVE)")
+	fs.StringVar(&a.mfaCode, "mfa-code", os.Getenv("MONARCH_MFA_CODE"), "Two-factor code to use if MFA is required (also MONARCH_MFA_CODE)")
+	fs.StringVar(&a.backupCode, "backup-code", "", "MFA backup (recovery) code to use if the authenticator is unavailable")
+	fs.StringVar(&a.household, "household", os.Getenv("MONARCH_HOUSEHOLD"), "Household name or ID to act on, for logins in several households (also MONARCH_HOUSEHOLD)")
+}
+
+// args returns the flags in command-line form, for forwarding to another subcommand.
+func (a *authFlags) args() []string {
+	args := []string{"-c", a.credsPath}
+	if a.email != "" {
+		args = append(args, "-email", a.email)
+	}
+	if a.noSession {
+		args = append(args, "-no-session")
+	}
+	if a.token != "" {
+		args = append(args, "-token", a.token)
+	}
+	if a.useGoogle {
+		args = append(args, "-google")
+	}
+	if a.useBrowser {
+		args = append(args, "-browser")
+	}
+	if a.useRemote {
+		args = append(args, "-remote")
+	}
+	if a.cookies != "" {
+		args = append(args, "-cookies", a.cookies)
+	}
+	if a.sessionStore != "" {
+		args = append(args, "-session-store", a.sessionStore)
+	}
+	if a.noReauth {
+		args = append(args, "-no-reauth")
+	}
+	if a.nonInteractive {
+		args = append(args, "-non-interactive")
+	}
+	if a.mfaCode != "" {
+		args = append(args, "-mfa-code", a.mfaCode)
+	}
+	if a.backupCode != "" {
+		args = append(args, "-backup-code", a.backupCode)
+	}
+	if a.household != "" {
+		args = append(args, "-household", a.household)
+	}
+	return args
+}
+
+// reauthenticate logs in again after the saved session expired, using the
+// same method as the original login. A token given on the command line or
+// in MONARCH_TOKEN cannot be renewed. A code from -mfa-code has expired by
+// now and one from -backup-code is used up, so MFA falls back to the TOTP
+// secret or a prompt.
+func (a *authFlags) reauthenticate(ctx context.Context, c *client.Client) error {
+	if a.token != "" {
+		return fmt.Errorf("the token passed with -token has expired")
+	}
+	if os.Getenv(client.TokenEnv) != "" {
+		return fmt.Errorf("the token in %s has expired", client.TokenEnv)
+	}
+	progressln("Session expired; logging in again.")
+	if err := c.DeleteSession(ctx); err != nil {
+		return err
+	}
+	if a.webLogin() {
+		return a.browserLogin(ctx, c)
+	}
+	renew := *a
+	renew.mfaCode = ""
+	renew.backupCode = ""
+	return renew.authenticate(ctx, c, false)
+}
+
+// webLogin reports whether the token comes from the web app rather than
+// an email and password login.
+func (a *authFlags) webLogin() bool {
+	return a.useGoogle || a.useBrowser || a.useRemote
+}
+
+// browserLogin obtains a token through the web app, either automatically
+// with -browser, by the copy/paste flow of -google, or pasted from another
+// machine with -remote, and saves the session. Over SSH there is no local
+// browser to open, so the remote flow is used for all three.
+func (a *authFlags) browserLogin(ctx context.Context, c *client.Client) error {
+	var err error
+	switch {
+	case a.useRemote || overSSH():
+		err = c.LoginRemote(ctx)
+	case a.useBrowser:
+		err = c.LoginWithBrowser(ctx)
+	default:
+		err = c.LoginWithGoogle(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.SaveSession(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// overSSH reports whether the command runs in an SSH session.
+func overSSH() bool {
+	return os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != ""
+}
+
+// connect creates a client and authenticates it according to the flags.
+func (a *authFlags) connect() (*client.Client, error) {
+	c, err := newClient(a.sessionStore, sessionEmail(a.email, a.credsPath))
+	if err != nil {
+		return nil, err
+	}
+	c.SetNonInteractive(a.nonInteractive)
+	if a.cookies != "" {
+		if err := c.SetCookies(a.cookies); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case a.token != "":
+		c.SetToken(a.token)
+	case c.Token() != "":
+		// Taken from MONARCH_TOKEN.
+	case a.webLogin():
+		if !a.noSession {
+			if loaded, err := c.LoadSession(cmdCtx); err != nil {
+				return nil, fmt.Errorf("load session: %w", err)
+			} else if loaded {
+				progressln("Using saved session.")
+				break
+			}
+		}
+		if err := a.browserLogin(cmdCtx, c); err != nil {
+			return nil, err
+		}
+	default:
+		if err := a.authenticate(cmdCtx, c, !a.noSession); err != nil {
+			return nil, err
+		}
+	}
+	if !a.noReauth {
+		c.SetReauthenticate(a.reauthenticate)
+	}
+	if a.household != "" {
+		if err := selectHousehold(c, a.household); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// selectHousehold points c at the household named or identified by s.
+func selectHousehold(c *client.Client, s string) error {
+	households, err := c.Households(cmdCtx)
+	if err != nil {
+		return fmt.Errorf("list households: %w", err)
+	}
+	h, err := client.FindHousehold(households, s)
+	if err != nil {
+		return err
+	}
+	c.SetHousehold(h.ID)
+	return nil
+}
+
+// credentials loaded from a JSON file or environment variables.
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// TOTPSecret is the base32 authenticator secret; when set, MFA codes
+	// are generated instead of prompted for.
+	TOTPSecret string `json:"totp_secret,omitempty"`
+}
+
+// loadCredentials finds the login credentials: from the configured secrets
+// manager or command, the file at path, or environment variables. Failing
+// those, it prompts on the terminal if interactive is set.
+func loadCredentials(path string, interactive bool) (credentials, error) {
+	if prof.credentialsSource != "" {
+		return fetchCredentials(prof.credentialsSource)
+	}
+	if prof.credentialsCommand != "" {
+		return runCredentialsCommand(prof.credentialsCommand)
+	}
+	f, err := fsys.Open(path)
+	if err == nil {
+		defer f.Close()
+		var c credentials
+		if err := json.NewDecoder(f).Decode(&c); err != nil {
+			return credentials{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if c.Email != "" && c.Password != "" {
+			return withTOTPSecret(c), nil
+		}
+	}
+
+	// Fall back to environment variables.
+	c := credentials{
+		Email:      os.Getenv("MONARCH_EMAIL"),
+		Password:   os.Getenv("MONARCH_PASSWORD"),
+		TOTPSecret: os.Getenv("MONARCH_TOTP_SECRET"),
+	}
+	if c.Email == "" || c.Password == "" {
+		if tty, ok := stdinTerminal(); interactive && ok {
+			tr.Fprintf(stdout, "No credentials in %s or the environment; enter them to log in.\n", path)
+			return promptCredentials(c, tty)
+		}
+		return credentials{}, fmt.Errorf(
+			"credentials not found: create %s with {\"email\":...,\"password\":...} or set MONARCH_EMAIL and MONARCH_PASSWORD",
+			path,
+		)
+	}
+	return withTOTPSecret(c), nil
+}
+
+// promptCredentials asks for the email and password missing from c,
+// reading the password from the terminal tty without echoing it.
+func promptCredentials(c credentials, tty *os.File) (credentials, error) {
+	if c.Email == "" {
+		c.Email = prompt("Email: ")
+	}
+	if c.Password == "" {
+		fmt.Fprint(stdout, "Password: ")
+		pw, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(stdout)
+		if err != nil {
+			return credentials{}, fmt.Errorf("read password: %w", err)
+		}
+		c.Password = string(pw)
+	}
+	if c.Email == "" || c.Password == "" {
+		return credentials{}, fmt.Errorf("email and password are required")
+	}
+	return withTOTPSecret(c), nil
+}
+
+// runCredentialsCommand runs command through the shell and parses the
+// credentials JSON it prints. Its stdin and stderr stay attached so tools
+// like pass or op can ask to unlock.
+func runCredentialsCommand(command string) (credentials, error) {
+	cmd := shellCommand(command)
+	cmd.Stdin = stdin
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return credentials{}, fmt.Errorf("credentials command: %w", err)
+	}
+	var c credentials
+	if err := json.Unmarshal(out, &c); err != nil {
+		return credentials{}, fmt.Errorf("credentials command: output is not credentials JSON: %w", err)
+	}
+	if c.Email == "" || c.Password == "" {
+		return credentials{}, fmt.Errorf("credentials command: output lacks email or password")
+	}
+	return withTOTPSecret(c), nil
+}
+
+// shellCommand runs command with the platform's shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// fetchCredentials reads the credentials JSON from the secrets manager
+// named by source.
+func fetchCredentials(source string) (credentials, error) {
+	p, err := secrets.Open(source)
+	if err != nil {
+		return credentials{}, err
+	}
+	raw, err := p.Fetch()
+	if err != nil {
+		return credentials{}, err
+	}
+	var c credentials
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return credentials{}, fmt.Errorf("credentials from %s are not credentials JSON: %w", source, err)
+	}
+	if c.Email == "" || c.Password == "" {
+		return credentials{}, fmt.Errorf("credentials from %s lack email or password", source)
+	}
+	return withTOTPSecret(c), nil
+}
+
+// withTOTPSecret fills in the TOTP secret from the OS keyring when the
+// credentials don't include one.
+func withTOTPSecret(c credentials) credentials {
+	if c.TOTPSecret != "" || !client.KeyringAvailable() {
+		return c
+	}
+	secret, err := client.NewKeyringStore(prof.totpKeyring).Load()
+	if err != nil {
+		// A keyring that is locked or unreachable, as without a D-Bus
+		// session, mustn't stop logins that need no TOTP secret.
+		warnf("no TOTP secret from the OS keyring: %v", err)
+		return c
+	}
+	c.TOTPSecret = string(secret)
+	return c
+}
+
+// stdinTerminal returns the command's stdin if it is a terminal.
+func stdinTerminal() (*os.File, bool) {
+	f, ok := stdin.(*os.File)
+	return f, ok && term.IsTerminal(int(f.Fd()))
+}
+
+func prompt(label string) string {
+	fmt.Fprint(stdout, label)
+	sc := bufio.NewScanner(stdin)
+	sc.Scan()
+	return strings.TrimSpace(sc.Text())
+}
+
+// authenticate logs in to Monarch Money, handling MFA interactively unless
+// a code or TOTP secret is available. It tries a saved session first, then
+// falls back to email/password.
+func (a *authFlags) authenticate(ctx context.Context, c *client.Client, useSavedSession bool) error {
+	if useSavedSession {
+		loaded, err := c.LoadSession(ctx)
+		if err != nil {
+			return fmt.Errorf("load session: %w", err)
+		}
+		if loaded {
+			progressln("Using saved session.")
+			return nil
+		}
+	}
+
+	creds, err := loadCredentials(a.credsPath, !a.nonInteractive)
+	if err != nil {
+		return err
+	}
+	if a.email != "" && !strings.EqualFold(a.email, creds.Email) {
+		return fmt.Errorf("the credentials are for %s, not %s", creds.Email, a.email)
+	}
+
+	err = retryOnChallenge(ctx, c, func() error {
+		return c.Login(ctx, creds.Email, creds.Password, "")
+	})
+	if err == nil {
+		return c.SaveSession(ctx)
+	}
+	if !errors.Is(err, client.ErrMFARequired) {
+		return fmt.Errorf("login failed: %w%s", err, loginHint(err))
+	}
+
+	method := client.MFATOTP
+	var mfaErr *client.MFAError
+	if errors.As(err, &mfaErr) {
+		method = mfaErr.Method
+	}
+
+	var code string
+	backup := false
+	switch {
+	case a.backupCode != "" && method != client.MFAEmailOTP:
+		code, backup = a.backupCode, true
+	case a.mfaCode != "":
+		code = a.mfaCode
+	case method == client.MFAEmailOTP && a.nonInteractive:
+		return fmt.Errorf("%w: emailed MFA code; pass -mfa-code or set MONARCH_MFA_CODE", client.ErrInputRequired)
+	case method == client.MFAEmailOTP:
+		tr.Fprintln(stdout, "Monarch sent a one-time code to your email address.")
+		code = prompt("Email code: ")
+	case creds.TOTPSecret != "":
+		code, err = client.TOTPCode(creds.TOTPSecret, now())
+		if err != nil {
+			return err
+		}
+	case a.nonInteractive:
+		return fmt.Errorf("%w: MFA code; pass -mfa-code or -backup-code, set MONARCH_MFA_CODE or store a TOTP secret", client.ErrInputRequired)
+	default:
+		// MFA required — prompt user.
+		tr.Fprintln(stdout, "Multi-factor authentication required.")
+		code = prompt("Two-factor code (or a backup code): ")
+		backup = !isTOTPCode(code)
+	}
+	if backup {
+		err = c.LoginWithBackupCode(ctx, creds.Email, creds.Password, code)
+	} else {
+		err = c.Login(ctx, creds.Email, creds.Password, code)
+	}
+	if err != nil {
+		return fmt.Errorf("MFA login failed: %w%s", err, loginHint(err))
+	}
+	if backup {
+		tr.Fprintln(stdout, "Logged in with a backup code; it can't be used again.")
+	}
+	return c.SaveSession(ctx)
+}
+
+// isTOTPCode reports whether code looks like an authenticator code (six
+// digits) rather than a backup code.
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// loginHint suggests what to do about a failed login.
+func loginHint(err error) string {
+	switch {
+	case errors.Is(err, client.ErrCaptchaRequired):
+		return " (log in with -browser to solve the captcha)"
+	case errors.Is(err, client.ErrRateLimited):
+		return " (wait before logging in again; repeated attempts extend the lockout)"
+	case errors.Is(err, client.ErrInvalidCredentials):
+		return " (check the email, password and MFA code or secret)"
+	}
+	return ""
+}
+
+// sessionEmail returns the Monarch login whose session to use: email if
+// set, else the one in the credentials file or MONARCH_EMAIL, in the order
+// loadCredentials reads them. It is empty if the credentials come from a
+// command or secrets manager, which are only asked when logging in; the
+// profile's default session file is used then.
+func sessionEmail(email, credsPath string) string {
+	if email != "" || prof.credentialsCommand != "" || prof.credentialsSource != "" {
+		return email
+	}
+	if raw, err := fsys.ReadFile(credsPath); err == nil {
+		var c credentials
+		if json.Unmarshal(raw, &c) == nil && c.Email != "" && c.Password != "" {
+			return c.Email
+		}
+	}
+	return os.Getenv("MONARCH_EMAIL")
+}
+
+// newClient creates an API client configured from the config file. A
+// non-empty sessionStore overrides the configured session store. Sessions
+// are kept per email, so several logins can be saved at once; an empty
+// email uses the profile's default session.
+func newClient(sessionStore, email string) (*client.Client, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return nil, err
+	}
+	profile, err := client.LookupProfile(cfg.Client.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Client.UserAgent != "" {
+		profile.UserAgent = cfg.Client.UserAgent
+	}
+	if cfg.Client.ClientPlatform != "" {
+		profile.ClientPlatform = cfg.Client.ClientPlatform
+	}
+	if len(cfg.Client.Headers) > 0 {
+		extra := make(map[string]string, len(profile.Extra)+len(cfg.Client.Headers))
+		for k, v := range profile.Extra {
+			extra[k] = v
+		}
+		for k, v := range cfg.Client.Headers {
+			extra[k] = v
+		}
+		profile.Extra = extra
+	}
+
+	if sessionStore == "" {
+		sessionStore = cfg.Session.Store
+	}
+	if prof.session == client.DefaultSessionPath() {
+		from, err := client.MigrateLegacySession(prof.session)
+		if err != nil {
+			return nil, err
+		}
+		if from != "" {
+			tr.Fprintf(stderr, "Moved session from %s to %s\n", from, prof.session)
+		}
+	}
+	store, err := client.NewSessionStore(sessionStore,
+		client.SessionPathFor(prof.session, email), client.KeyringAccountFor(prof.keyring, email))
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := sessionPassphrase(cfg.Session)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != nil {
+		store = client.EncryptedStore{Store: store, Passphrase: passphrase}
+	}
+
+	device, err := client.LoadDeviceUUID(prof.device)
+	if err != nil {
+		return nil, fmt.Errorf("device UUID: %w", err)
+	}
+
+	retry, err := retryPolicy(cfg.Client.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []client.Option{
+		client.WithTerminal(stdin, stdout),
+		client.WithRetryPolicy(retry),
+		client.WithRateLimit(cfg.Client.RateLimit, cfg.Client.RateBurst),
+	}
+	cb, err := circuitBreaker(cfg.Client.CircuitBreaker)
+	if err != nil {
+		return nil, err
+	}
+	if cb != nil {
+		opts = append(opts, client.WithCircuitBreaker(cb))
+	}
+	if cfg.Client.PersistedQueries {
+		opts = append(opts, client.WithPersistedQueries())
+	}
+	if cfg.Client.CacheTTL != "" && !noCache {
+		ttl, err := time.ParseDuration(cfg.Client.CacheTTL)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("client.cacheTTL: invalid duration %q", cfg.Client.CacheTTL)
+		}
+		opts = append(opts, client.WithCache(filepath.Join(prof.baseDir(), "cache", "responses"), ttl))
+	}
+	if u := cmp.Or(os.Getenv(client.BaseURLEnv), cfg.Client.BaseURL); u != "" {
+		base, err := client.ParseBaseURL(u)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithBaseURL(base))
+	}
+	if p := cmp.Or(proxyURL, cfg.Client.Proxy); p != "" {
+		u, err := client.ParseProxy(p)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithProxy(u))
+	}
+	switch {
+	case replayDir != "":
+		cas, err := client.LoadCassette(replayDir)
+		if err != nil {
+			return nil, fmt.Errorf("--replay: %w", err)
+		}
+		opts = append(opts, client.WithReplay(cas))
+	case recordDir != "":
+		opts = append(opts, client.WithRecording(recordDir))
+	}
+	if s := os.Getenv(client.ChaosEnv); s != "" {
+		ch, err := client.ParseChaos(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", client.ChaosEnv, err)
+		}
+		warnf("%s is set: injecting faults into API requests.", client.ChaosEnv)
+		opts = append(opts, client.WithChaos(ch))
+	}
+	if logger != nil {
+		opts = append(opts, client.WithLogger(logger))
+	}
+	if debugLog != nil {
+		opts = append(opts, client.WithDebugLogging(debugLog))
+	}
+	c := client.NewFromEnv(opts...)
+	c.SetHeaderProfile(profile)
+	c.SetSessionStore(store)
+	c.SetDeviceUUID(device)
+	return c, nil
+}
+
+// circuitBreaker returns the breaker shared by the clients of a run, so
+// that the later steps of a pipeline don't call an API found down, or nil
+// if it is disabled.
+func circuitBreaker(cfg config.CircuitBreakerConfig) (*client.CircuitBreaker, error) {
+	if cfg.Failures < 0 {
+		return nil, nil
+	}
+	if apiBreaker != nil {
+		return apiBreaker, nil
+	}
+	cooldown := 30 * time.Second
+	if cfg.Cooldown != "" {
+		d, err := time.ParseDuration(cfg.Cooldown)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("client.circuitBreaker.cooldown: invalid duration %q", cfg.Cooldown)
+		}
+		cooldown = d
+	}
+	apiBreaker = client.NewCircuitBreaker(cmp.Or(cfg.Failures, 5), cooldown)
+	return apiBreaker, nil
+}
+
+// retryPolicy applies the configured retry settings to the client's
+// defaults.
+func retryPolicy(cfg config.RetryConfig) (client.RetryPolicy, error) {
+	p := client.DefaultRetryPolicy
+	if cfg.MaxAttempts < 0 {
+		return p, fmt.Errorf("client.retry.maxAttempts must be at least 1")
+	}
+	if cfg.MaxAttempts > 0 {
+		p.MaxAttempts = cfg.MaxAttempts
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"baseDelay", cfg.BaseDelay, &p.BaseDelay}, {"maxDelay", cfg.MaxDelay, &p.MaxDelay}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return p, fmt.Errorf("client.retry.%s: invalid duration %q", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	if cfg.Jitter != nil {
+		if *cfg.Jitter < 0 || *cfg.Jitter > 1 {
+			return p, fmt.Errorf("client.retry.jitter must be between 0 and 1")
+		}
+		p.Jitter = *cfg.Jitter
+	}
+	p.RetryMutations = cfg.Mutations
+	return p, nil
+}
+
+// sessionPassphrase returns the passphrase for encrypting the session, or
+// nil when encryption is off. MONARCH_SESSION_PASSPHRASE takes precedence
+// over the configured key file.
+func sessionPassphrase(cfg config.SessionConfig) ([]byte, error) {
+	if p := os.Getenv("MONARCH_SESSION_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	if cfg.KeyFile != "" {
+		raw, err := fsys.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("session key file: %w", err)
+		}
+		key := bytes.TrimSpace(raw)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("session key file %s is empty", cfg.KeyFile)
+		}
+		return key, nil
+	}
+	if cfg.Encrypt {
+		return nil, fmt.Errorf("session.encrypt is set but neither MONARCH_SESSION_PASSPHRASE nor session.keyFile is")
+	}
+	return nil, nil
+}
+
+// retryOnChallenge runs fn and, if Cloudflare challenged the request, guides
+// the user through the browser fallback and runs fn once more. Cookies from a
+// successful retry are saved with the session so later runs reuse them.
+func retryOnChallenge(ctx context.Context, c *client.Client, fn func() error) error {
+	err := fn()
+	if !errors.Is(err, client.ErrCloudflareChallenge) {
+		return err
+	}
+	if err := c.ResolveChallenge(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return c.SaveSession(ctx)
+}
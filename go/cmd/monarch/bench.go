@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// benchStage is one measured step of the benchmark. run processes the
+// dataset once and returns the number of bytes it read or wrote.
+type benchStage struct {
+	name  string
+	items int
+	run   func() (int64, error)
+}
+
+func cmdBench(args []string) (err error) {
+	fs := newFlagSet("bench")
+	holdings := fs.Int("holdings", 50000, "Number of synthetic holdings")
+	txnCount := fs.Int("transactions", 100000, "Number of synthetic transactions")
+	runs := fs.Int("runs", 3, "Runs per stage; the fastest is reported")
+	seed := fs.Uint64("seed", 1, "Random seed for the synthetic data")
+	var profiling profilingFlags
+	profiling.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch bench [options]")
+		fmt.Fprintln(stderr, "\nMeasures extraction and export throughput on synthetic data.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("-runs must be at least 1")
+	}
+
+	stop, err := profiling.start()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, stop()) }()
+
+	dir, err := os.MkdirTemp("", "monarch-bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+	portfolioJSON, err := json.Marshal(syntheticPortfolio(rng, *holdings))
+	if err != nil {
+		return err
+	}
+	txnJSON, err := json.Marshal(syntheticTransactions(rng, *txnCount))
+	if err != nil {
+		return err
+	}
+	var resp portfolio.Response
+	if err := json.Unmarshal(portfolioJSON, &resp); err != nil {
+		return err
+	}
+	records := portfolio.ExtractHoldings(&resp)
+
+	csvExport := func() (int64, error) {
+		path := filepath.Join(dir, "holdings.csv")
+		if err := portfolio.WriteCSV(records, path); err != nil {
+			return 0, err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	markdownExport := func() (int64, error) {
+		var w countingWriter
+		portfolio.WriteMarkdown(records, &w)
+		return w.n, nil
+	}
+	jsonExport := func() (int64, error) {
+		data, err := json.MarshalIndent(records, "", "    ")
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(data)), os.WriteFile(filepath.Join(dir, "holdings.json"), data, 0600)
+	}
+
+	stages := []benchStage{
+		{"decode portfolio JSON", len(records), func() (int64, error) {
+			var r portfolio.Response
+			return int64(len(portfolioJSON)), json.Unmarshal(portfolioJSON, &r)
+		}},
+		{"extract holdings", len(records), func() (int64, error) {
+			portfolio.ExtractHoldings(&resp)
+			return 0, nil
+		}},
+		{"decode transactions JSON", *txnCount, func() (int64, error) {
+			var p transactions.Page
+			return int64(len(txnJSON)), json.Unmarshal(txnJSON, &p)
+		}},
+		{"export CSV", len(records), csvExport},
+		{"export Markdown", len(records), markdownExport},
+		{"export JSON", len(records), jsonExport},
+		{"export all (sequential)", 3 * len(records), func() (int64, error) {
+			var total int64
+			for _, fn := range []func() (int64, error){csvExport, markdownExport, jsonExport} {
+				n, err := fn()
+				if err != nil {
+					return 0, err
+				}
+				total += n
+			}
+			return total, nil
+		}},
+		{"export all (parallel)", 3 * len(records), func() (int64, error) {
+			return runParallel(csvExport, markdownExport, jsonExport)
+		}},
+	}
+
+	fmt.Fprintf(stdout, "Synthetic data: %d holdings (%s JSON), %d transactions (%s JSON), best of %d runs\n\n",
+		len(records), formatBytes(int64(len(portfolioJSON))), *txnCount, formatBytes(int64(len(txnJSON))), *runs)
+
+	var rows [][]string
+	for _, st := range stages {
+		var best time.Duration
+		var size int64
+		for i := 0; i < *runs; i++ {
+			start := time.Now()
+			n, err := st.run()
+			elapsed := time.Since(start)
+			if err != nil {
+				return fmt.Errorf("%s: %w", st.name, err)
+			}
+			if i == 0 || elapsed < best {
+				best = elapsed
+			}
+			size = n
+		}
+		secs := best.Seconds()
+		mbps := "-"
+		if size > 0 {
+			mbps = fmt.Sprintf("%.1f", float64(size)/secs/(1<<20))
+		}
+		rows = append(rows, []string{
+			st.name,
+			best.Round(time.Microsecond).String(),
+			fmt.Sprintf("%.0f", float64(st.items)/secs),
+			mbps,
+		})
+	}
+	report.WriteTable(stdout, []string{"Stage", "Time", "Records/s", "MB/s"}, rows)
+	return nil
+}
+
+// runParallel runs each function in its own goroutine and returns the total
+// byte count, or the first error.
+func runParallel(fns ...func() (int64, error)) (int64, error) {
+	var wg sync.WaitGroup
+	sizes := make([]int64, len(fns))
+	errs := make([]error, len(fns))
+	for i, fn := range fns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sizes[i], errs[i] = fn()
+		}()
+	}
+	wg.Wait()
+	var total int64
+	for i := range fns {
+		if errs[i] != nil {
+			return 0, errs[i]
+		}
+		total += sizes[i]
+	}
+	return total, nil
+}
+
+// countingWriter discards what is written to it, counting the bytes.
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// syntheticPortfolio builds a portfolio response with n holdings spread over
+// a pool of securities and accounts.
+func syntheticPortfolio(rng *rand.Rand, n int) *portfolio.Response {
+	types := []string{"equity", "etf", "mutual_fund", "fixed_income", "cash"}
+	accounts := make([]portfolio.Account, max(n/200, 1))
+	for i := range accounts {
+		accounts[i] = portfolio.Account{
+			ID:          fmt.Sprintf("acct-%d", i),
+			Mask:        fmt.Sprintf("%04d", rng.IntN(10000)),
+			DisplayName: fmt.Sprintf("Brokerage %d", i),
+			Institution: portfolio.Institution{Name: fmt.Sprintf("Institution %d", i%17)},
+		}
+	}
+	perSecurity := 4
+	resp := &portfolio.Response{}
+	for made := 0; made < n; {
+		id := len(resp.Portfolio.AggregateHoldings.Edges)
+		typ := types[rng.IntN(len(types))]
+		sec := portfolio.Security{
+			ID:                    fmt.Sprintf("sec-%d", id),
+			Name:                  fmt.Sprintf("Security %d", id),
+			Ticker:                fmt.Sprintf("T%05d", id),
+			CurrentPrice:          1 + rng.Float64()*500,
+			CurrentPriceUpdatedAt: "2025-01-02T21:00:00Z",
+			Type:                  typ,
+			TypeDisplay:           typ,
+		}
+		node := portfolio.AggregateNode{Security: sec}
+		for j := 0; j < perSecurity && made < n; j++ {
+			qty := rng.Float64() * 1000
+			node.Holdings = append(node.Holdings, portfolio.Holding{
+				ID:           fmt.Sprintf("hold-%d", made),
+				Type:         typ,
+				TypeDisplay:  typ,
+				Name:         sec.Name,
+				Ticker:       sec.Ticker,
+				ClosingPrice: sec.CurrentPrice,
+				Quantity:     qty,
+				Value:        qty * sec.CurrentPrice,
+				Account:      accounts[rng.IntN(len(accounts))],
+			})
+			made++
+		}
+		resp.Portfolio.AggregateHoldings.Edges = append(resp.Portfolio.AggregateHoldings.Edges, portfolio.Edge{Node: node})
+	}
+	return resp
+}
+
+// syntheticTransactions builds a transactions page with n entries.
+func syntheticTransactions(rng *rand.Rand, n int) *transactions.Page {
+	groups := []string{transactions.GroupExpense, transactions.GroupExpense, transactions.GroupIncome, transactions.GroupTransfer}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	page := &transactions.Page{TotalCount: n, Results: make([]transactions.Transaction, n)}
+	for i := range page.Results {
+		group := groups[rng.IntN(len(groups))]
+		cat, merchant := rng.IntN(40), rng.IntN(500)
+		page.Results[i] = transactions.Transaction{
+			ID:     fmt.Sprintf("txn-%d", i),
+			Date:   start.AddDate(0, 0, rng.IntN(730)).Format(time.DateOnly),
+			Amount: float64(rng.IntN(200000)-150000) / 100,
+			Category: transactions.Category{
+				ID:    fmt.Sprintf("cat-%d", cat),
+				Name:  fmt.Sprintf("Category %d", cat),
+				Group: transactions.CategoryGroup{ID: "grp-" + group, Name: group, Type: group},
+			},
+			Merchant: transactions.Merchant{ID: fmt.Sprintf("m-%d", merchant), Name: fmt.Sprintf("Merchant %d", merchant)},
+			Account:  transactions.Account{ID: fmt.Sprintf("acct-%d", rng.IntN(12)), DisplayName: "Checking"},
+		}
+	}
+	return page
+}
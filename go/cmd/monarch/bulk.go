@@ -0,0 +1,331 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/convert"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// bulkFlags select the transactions a bulk edit goes through.
+type bulkFlags struct {
+	auth    authFlags
+	days    int
+	account string
+	dryRun  bool
+}
+
+func (b *bulkFlags) register(fs *flag.FlagSet) {
+	b.auth.register(fs)
+	fs.IntVar(&b.days, "days", 90, "Go through the transactions of this many days")
+	fs.StringVar(&b.account, "account", "", "Only go through the transactions of this account (name or ID)")
+	fs.BoolVar(&b.dryRun, "dry-run", false, "Only print the estimate, without updating Monarch")
+}
+
+// bulkEdit is a bulk edit under way: the client, the transactions it
+// goes through and the API calls made to find them.
+type bulkEdit struct {
+	*bulkFlags
+	c        *client.Client
+	txns     []transactions.Transaction
+	from, to time.Time
+
+	mu      sync.Mutex
+	calls   int
+	elapsed time.Duration
+}
+
+// start connects and fetches the transactions selected by b, counting
+// the calls made until the estimate is printed.
+func (b *bulkFlags) start() (*bulkEdit, error) {
+	if b.days <= 0 {
+		return nil, fmt.Errorf("-days must be positive")
+	}
+	c, err := b.auth.connect()
+	if err != nil {
+		return nil, err
+	}
+	e := &bulkEdit{bulkFlags: b, c: c, to: now()}
+	e.from = e.to.AddDate(0, 0, -b.days)
+	c.OnResponse(func(_ *http.Request, _ *http.Response, elapsed time.Duration, _ error) {
+		e.mu.Lock()
+		e.calls++
+		e.elapsed += elapsed
+		e.mu.Unlock()
+	})
+
+	var accountIDs []string
+	if b.account != "" {
+		accounts, err := fetchAccounts(c)
+		if err != nil {
+			return nil, err
+		}
+		a, err := findAccount(accounts, b.account)
+		if err != nil {
+			return nil, err
+		}
+		accountIDs = []string{a.ID}
+	}
+	if e.txns, err = fetchTransactions(c, e.from, e.to, accountIDs); err != nil {
+		return nil, fmt.Errorf("fetch transactions: %w", err)
+	}
+	return e, nil
+}
+
+// bulkEstimate is what applying a bulk edit is expected to cost.
+type bulkEstimate struct {
+	Matched, Total int
+	From, To       time.Time
+	// Reads are the API calls made so far to find the transactions, and
+	// Latency their average duration.
+	Reads     int
+	Latency   time.Duration
+	Mutations int
+	// RateLimit is the client's limit in requests per second, or zero.
+	RateLimit float64
+}
+
+// Duration is how long the mutations are expected to take: one after
+// the other, each as long as the reads took or as the rate limit spaces
+// them, whichever is longer.
+func (e bulkEstimate) Duration() time.Duration {
+	per := e.Latency
+	if e.RateLimit > 0 {
+		per = max(per, time.Duration(float64(time.Second)/e.RateLimit))
+	}
+	return per * time.Duration(e.Mutations)
+}
+
+// estimate returns the cost of making mutations to matched of the
+// transactions.
+func (e *bulkEdit) estimate(matched, mutations int) (bulkEstimate, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return bulkEstimate{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	est := bulkEstimate{
+		Matched: matched, Total: len(e.txns), From: e.from, To: e.to,
+		Reads: e.calls, Mutations: mutations, RateLimit: cfg.Client.RateLimit,
+	}
+	if e.calls > 0 {
+		est.Latency = e.elapsed / time.Duration(e.calls)
+	}
+	return est, nil
+}
+
+func writeEstimate(w io.Writer, est bulkEstimate) {
+	duration := "under a second"
+	if d := est.Duration(); d >= time.Second {
+		duration = "about " + d.Round(time.Second).String()
+	}
+	if est.RateLimit > 0 {
+		duration += " at the rate limit of " + strconv.FormatFloat(est.RateLimit, 'f', -1, 64) + " requests/s"
+	} else {
+		duration += " with no rate limit (client.rateLimit)"
+	}
+	fmt.Fprintf(w, "Estimate:\n")
+	fmt.Fprintf(w, "  transactions  %d of %d from %s to %s match\n", est.Matched, est.Total,
+		est.From.Format(time.DateOnly), est.To.Format(time.DateOnly))
+	fmt.Fprintf(w, "  mutations     %d\n", est.Mutations)
+	fmt.Fprintf(w, "  API calls     %d (%d made to read, %d to write)\n", est.Reads+est.Mutations, est.Reads, est.Mutations)
+	fmt.Fprintf(w, "  duration      %s\n", duration)
+}
+
+// finish prints the estimate and, unless this is a dry run, makes the
+// mutations with apply.
+func (e *bulkEdit) finish(matched int, ids []string, apply func(id string) error) error {
+	est, err := e.estimate(matched, len(ids))
+	if err != nil {
+		return err
+	}
+	writeEstimate(stdout, est)
+	if e.dryRun {
+		fmt.Fprintln(stdout, "\nDry run; nothing was updated. Narrow the selection with -days or -account if this is more than expected.")
+		return nil
+	}
+	for i, id := range ids {
+		if err := apply(id); err != nil {
+			return fmt.Errorf("update transaction %s after %d of %d updates: %w", id, i, len(ids), err)
+		}
+	}
+	fmt.Fprintf(stdout, "\nUpdated %d transactions.\n", len(ids))
+	return nil
+}
+
+func rulesUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch rules <command> [options]
+
+Commands:
+  apply  Categorize transactions by merchant with the rules of "monarch convert -to rules"
+
+Run "monarch rules <command> -h" for command options.`)
+}
+
+func cmdRules(args []string) error {
+	if len(args) < 1 {
+		rulesUsage()
+		return fmt.Errorf("missing rules command")
+	}
+	switch args[0] {
+	case "apply":
+		return cmdRulesApply(args[1:])
+	case "-h", "--help", "help":
+		rulesUsage()
+		return nil
+	default:
+		rulesUsage()
+		return fmt.Errorf("unknown rules command: %s", args[0])
+	}
+}
+
+func cmdRulesApply(args []string) error {
+	fs := newFlagSet("rules apply")
+	var bulk bulkFlags
+	bulk.register(fs)
+	rulesFile := fs.String("rules", "", "Rules file (default rules.json in the profile directory)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch rules apply [options]")
+		fmt.Fprintln(stderr, "\nSets the category of each transaction whose merchant has a rule. The API")
+		fmt.Fprintln(stderr, "calls, duration and mutations are estimated first; with -dry-run nothing")
+		fmt.Fprintln(stderr, "else happens.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path := *rulesFile
+	if path == "" {
+		path = filepath.Join(prof.baseDir(), "rules.json")
+	}
+	rules, err := convert.LoadRules(path)
+	if err != nil {
+		return err
+	}
+
+	e, err := bulk.start()
+	if err != nil {
+		return err
+	}
+	cats, err := fetchCategories(e.c)
+	if err != nil {
+		return fmt.Errorf("fetch categories: %w", err)
+	}
+	categoryIDs := make(map[string]string, len(cats))
+	for _, cat := range cats {
+		categoryIDs[strings.ToLower(cat.Name)] = cat.ID
+	}
+
+	var ids []string
+	var matched int
+	assign := make(map[string]string)
+	var rows [][]string
+	for _, r := range rules {
+		catID, ok := categoryIDs[strings.ToLower(r.Category)]
+		if !ok {
+			warnf("rule for %s: no category %q in Monarch", r.Merchant, r.Category)
+			continue
+		}
+		var n, changes int
+		for _, t := range e.txns {
+			if _, done := assign[t.ID]; done || !r.MatchesMerchant(t.Merchant.Name) {
+				continue
+			}
+			assign[t.ID] = catID
+			n++
+			if t.Category.ID != catID {
+				ids = append(ids, t.ID)
+				changes++
+			}
+		}
+		if n > 0 {
+			rows = append(rows, []string{r.Merchant, r.Category, strconv.Itoa(n), strconv.Itoa(changes)})
+		}
+		matched += n
+	}
+	if len(rows) > 0 {
+		report.WriteTable(stdout, []string{"merchant", "category", "transactions", "changes"}, rows)
+		fmt.Fprintln(stdout)
+	}
+	return e.finish(matched, ids, func(id string) error {
+		return apiMutator{c: e.c}.SetCategory(id, assign[id])
+	})
+}
+
+func cmdTag(args []string) error {
+	fs := newFlagSet("tag")
+	var bulk bulkFlags
+	bulk.register(fs)
+	tagName := fs.String("tag", "", "Name of the tag to add")
+	merchant := fs.String("merchant", "", "Only tag transactions from this merchant")
+	category := fs.String("category", "", "Only tag transactions in this category")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch tag -tag <name> [-merchant <name>] [-category <name>] [options]")
+		fmt.Fprintln(stderr, "\nAdds a tag to the selected transactions that don't have it yet. The API")
+		fmt.Fprintln(stderr, "calls, duration and mutations are estimated first; with -dry-run nothing")
+		fmt.Fprintln(stderr, "else happens.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *tagName == "" {
+		fs.Usage()
+		return fmt.Errorf("-tag is required")
+	}
+
+	e, err := bulk.start()
+	if err != nil {
+		return err
+	}
+	tags, err := fetchTags(e.c)
+	if err != nil {
+		return fmt.Errorf("fetch tags: %w", err)
+	}
+	var tag transactions.Tag
+	for _, t := range tags {
+		if strings.EqualFold(t.Name, *tagName) {
+			tag = t
+			break
+		}
+	}
+	if tag.ID == "" {
+		return fmt.Errorf("no tag named %q; create it in Monarch first", *tagName)
+	}
+
+	var ids []string
+	var matched int
+	tagIDs := make(map[string][]string)
+	for _, t := range e.txns {
+		if (*merchant != "" && !strings.EqualFold(t.Merchant.Name, *merchant)) ||
+			(*category != "" && !strings.EqualFold(t.Category.Name, *category)) {
+			continue
+		}
+		matched++
+		has := false
+		var current []string
+		for _, tt := range t.Tags {
+			has = has || tt.ID == tag.ID
+			current = append(current, tt.ID)
+		}
+		if !has {
+			ids = append(ids, t.ID)
+			tagIDs[t.ID] = append(current, tag.ID)
+		}
+	}
+	return e.finish(matched, ids, func(id string) error {
+		return apiMutator{c: e.c}.SetTags(id, tagIDs[id])
+	})
+}
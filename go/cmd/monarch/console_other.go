@@ -0,0 +1,7 @@
+//go:build !windows
+
+package main
+
+// Other terminals speak UTF-8 and escape sequences already.
+
+func setupConsole() {}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// cpUTF8 is the UTF-8 code page.
+const cpUTF8 = 65001
+
+// setupConsole switches the console to UTF-8, so that currency symbols and
+// translated messages print correctly, and turns on the escape sequences
+// the TUI draws with. Both are left alone when output is redirected.
+func setupConsole() {
+	windows.SetConsoleCP(cpUTF8)
+	windows.SetConsoleOutputCP(cpUTF8)
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		h := windows.Handle(f.Fd())
+		var mode uint32
+		if windows.GetConsoleMode(h, &mode) == nil {
+			windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+		}
+	}
+}
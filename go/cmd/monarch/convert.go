@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/heikofkoehler/monarch/internal/convert"
+)
+
+func cmdConvert(args []string) error {
+	fs := newFlagSet("convert")
+	var auth authFlags
+	auth.register(fs)
+	from := fs.String("from", "", "Source format: mint or empower")
+	to := fs.String("to", "categories", "What to produce: categories (mapping CSV) or rules (rules JSON)")
+	outFile := fs.String("o", "", "Output file (default category_mapping.csv or rules.json in the profile directory)")
+	online := fs.Bool("online", false, "Match against your Monarch categories instead of the defaults")
+	minMatches := fs.Int("min-matches", 2, "Transactions a merchant needs before a rule is created")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch convert -from mint|empower [-to categories|rules] [options] <export.csv>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *from == "" {
+		fs.Usage()
+		return fmt.Errorf("need -from and one export file")
+	}
+	if *to != "categories" && *to != "rules" {
+		return fmt.Errorf("-to must be categories or rules")
+	}
+
+	f, err := fsys.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	txns, err := convert.Read(f, *from)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	monarch := convert.DefaultMonarchCategories
+	if *online {
+		c, err := auth.connect()
+		if err != nil {
+			return err
+		}
+		cats, err := fetchCategories(c)
+		if err != nil {
+			return fmt.Errorf("fetch categories: %w", err)
+		}
+		monarch = monarch[:0:0]
+		for _, cat := range cats {
+			monarch = append(monarch, cat.Name)
+		}
+	}
+	mappings := convert.MapCategories(txns, monarch)
+
+	var unmapped int
+	for _, m := range mappings {
+		if m.Monarch == "" {
+			unmapped++
+		}
+	}
+	fmt.Fprintf(stdout, "Read %d transactions in %d categories; %d categories have no Monarch match\n",
+		len(txns), len(mappings), unmapped)
+
+	if *to == "rules" {
+		path := *outFile
+		if path == "" {
+			path = filepath.Join(prof.baseDir(), "rules.json")
+		}
+		rules := convert.Rules(txns, mappings, *minMatches)
+		if err := convert.SaveRules(rules, path); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %d rules to %s\n", len(rules), path)
+		return nil
+	}
+
+	path := *outFile
+	if path == "" {
+		path = prof.out("category_mapping.csv")
+	}
+	out, err := fsys.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := convert.WriteMappings(out, mappings); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote category mapping to %s\n", path)
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+func daemonUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch daemon <command> [options]
+
+Commands:
+  install  Write a systemd user unit or launchd agent that runs a command
+           on a schedule
+
+Run "monarch daemon install -h" for options.`)
+}
+
+func cmdDaemon(args []string) error {
+	if len(args) < 1 {
+		daemonUsage()
+		return fmt.Errorf("missing daemon command")
+	}
+	switch args[0] {
+	case "install":
+		return cmdDaemonInstall(args[1:])
+	case "-h", "--help", "help":
+		daemonUsage()
+		return nil
+	default:
+		daemonUsage()
+		return fmt.Errorf("unknown daemon command: %s", args[0])
+	}
+}
+
+// daemonEnv lists the variables passed on to the scheduled command when
+// they are set.
+var daemonEnv = []string{"MONARCH_SESSION_FILE", "MONARCH_HOUSEHOLD", "MONARCH_EMAIL", "MONARCH_BASE_URL", "MONARCH_CHAOS", "HTTPS_PROXY"}
+
+// daemonSecrets lists the variables that are never written to a unit file,
+// which other users or backups may read.
+var daemonSecrets = []string{"MONARCH_PASSWORD", "MONARCH_TOKEN", "MONARCH_TOTP_SECRET", "MONARCH_MFA_CODE"}
+
+// daemonJob is what a unit runs and when.
+type daemonJob struct {
+	name    string
+	binary  string
+	dir     string
+	args    []string
+	env     [][2]string
+	at      time.Time // time of day, if every is zero
+	every   time.Duration
+	logFile string
+}
+
+func cmdDaemonInstall(args []string) error {
+	fs := newFlagSet("daemon install")
+	target := fs.String("target", defaultDaemonTarget(), "Service manager: systemd or launchd")
+	name := fs.String("name", "", "Unit or agent name (default: monarch-<command>)")
+	at := fs.String("at", "06:00", "Run daily at this time (HH:MM)")
+	every := fs.Duration("every", 0, "Run at this interval instead of daily, e.g. 4h")
+	binary := fs.String("binary", "", "Path of the monarch binary (default: this one)")
+	dir := fs.String("dir", "", "Working directory of the command (default: the current one)")
+	outDir := fs.String("o", "", "Directory to write to (default: ~/.config/systemd/user or ~/Library/LaunchAgents)")
+	logFile := fs.String("log", "", "launchd log file (default: ~/Library/Logs/<name>.log)")
+	printOnly := fs.Bool("print", false, "Print the files instead of writing them")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch daemon install [options] [command [args]]")
+		fmt.Fprintln(stderr, "\nThe command defaults to \"pipeline\". It runs non-interactively with the")
+		fmt.Fprintln(stderr, "saved session, so log in first; passwords and tokens in the environment")
+		fmt.Fprintln(stderr, "are not written to the unit.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *target != "systemd" && *target != "launchd" {
+		fs.Usage()
+		return fmt.Errorf("-target must be systemd or launchd")
+	}
+
+	job := daemonJob{name: *name, binary: *binary, dir: *dir, args: fs.Args(), every: *every, logFile: *logFile}
+	if len(job.args) == 0 {
+		job.args = []string{"pipeline"}
+	}
+	if job.name == "" {
+		job.name = "monarch-" + job.args[0]
+	}
+	if prof.name != "" {
+		job.args = append([]string{"--profile", prof.name}, job.args...)
+	}
+	if job.every <= 0 {
+		t, err := time.Parse("15:04", *at)
+		if err != nil {
+			return fmt.Errorf("-at %q: want HH:MM", *at)
+		}
+		job.at = t
+	} else if job.every < time.Minute {
+		return fmt.Errorf("-every must be at least a minute")
+	}
+	var err error
+	if job.binary == "" {
+		if job.binary, err = os.Executable(); err != nil {
+			return fmt.Errorf("find monarch binary: %w", err)
+		}
+	}
+	if job.dir == "" {
+		job.dir = "."
+	}
+	if job.dir, err = filepath.Abs(job.dir); err != nil {
+		return err
+	}
+	job.env = [][2]string{{"MONARCH_NON_INTERACTIVE", "1"}}
+	for _, name := range daemonEnv {
+		if v := os.Getenv(name); v != "" {
+			job.env = append(job.env, [2]string{name, v})
+		}
+	}
+	for _, name := range daemonSecrets {
+		if os.Getenv(name) != "" {
+			warnf("%s is not written to the unit; log in with \"monarch login\" or use a credentials command.", name)
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	var files map[string]string
+	var enable []string
+	switch *target {
+	case "systemd":
+		if *outDir == "" {
+			*outDir = filepath.Join(home, ".config", "systemd", "user")
+		}
+		files = map[string]string{
+			job.name + ".service": systemdService(job),
+			job.name + ".timer":   systemdTimer(job),
+		}
+		enable = []string{
+			"systemctl --user daemon-reload",
+			"systemctl --user enable --now " + job.name + ".timer",
+			"loginctl enable-linger  # to run while you are logged out",
+		}
+	case "launchd":
+		if *outDir == "" {
+			*outDir = filepath.Join(home, "Library", "LaunchAgents")
+		}
+		if job.logFile == "" {
+			job.logFile = filepath.Join(home, "Library", "Logs", job.name+".log")
+		}
+		plist := job.name + ".plist"
+		files = map[string]string{plist: launchdPlist(job)}
+		enable = []string{"launchctl bootstrap gui/$(id -u) " + filepath.Join(*outDir, plist)}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	// The service sorts before the timer it belongs to.
+	sort.Strings(names)
+	if *printOnly {
+		for _, name := range names {
+			fmt.Fprintf(stdout, "--- %s ---\n%s", name, files[name])
+		}
+		return nil
+	}
+	if err := fsys.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		path := filepath.Join(*outDir, name)
+		if err := fsys.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %s\n", path)
+	}
+	fmt.Fprintln(stdout, "\nStart it with:")
+	for _, line := range enable {
+		fmt.Fprintln(stdout, "  "+line)
+	}
+	return nil
+}
+
+// defaultDaemonTarget is the service manager of the platform.
+func defaultDaemonTarget() string {
+	if runtime.GOOS == "darwin" {
+		return "launchd"
+	}
+	return "systemd"
+}
+
+func systemdService(job daemonJob) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=monarch %s\nWants=network-online.target\nAfter=network-online.target\n\n", strings.Join(job.args, " "))
+	b.WriteString("[Service]\nType=oneshot\n")
+	// WorkingDirectory takes the path as is, spaces included.
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", strings.ReplaceAll(job.dir, "%", "%%"))
+	cmd := []string{systemdQuote(job.binary)}
+	for _, a := range job.args {
+		cmd = append(cmd, systemdQuote(a))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(cmd, " "))
+	for _, kv := range job.env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv[0]+"="+kv[1]))
+	}
+	return b.String()
+}
+
+func systemdTimer(job daemonJob) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=Run %s on a schedule\n\n[Timer]\n", job.name)
+	if job.every > 0 {
+		fmt.Fprintf(&b, "OnActiveSec=1min\nOnUnitActiveSec=%s\n", systemdDuration(job.every))
+	} else {
+		fmt.Fprintf(&b, "OnCalendar=*-*-* %s:00\nPersistent=true\n", job.at.Format("15:04"))
+	}
+	b.WriteString("RandomizedDelaySec=5min\n\n[Install]\nWantedBy=timers.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes s for a unit file if it holds spaces, quotes,
+// backslashes or specifiers.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// systemdDuration formats d as a systemd time span, e.g. "4h 30min".
+func systemdDuration(d time.Duration) string {
+	var parts []string
+	for _, u := range []struct {
+		d    time.Duration
+		unit string
+	}{{time.Hour, "h"}, {time.Minute, "min"}, {time.Second, "s"}} {
+		if n := d / u.d; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.unit))
+			d -= n * u.d
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func launchdPlist(job daemonJob) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	plistString(&b, "\t", "Label", job.name)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, a := range append([]string{job.binary}, job.args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(a))
+	}
+	b.WriteString("\t</array>\n")
+	plistString(&b, "\t", "WorkingDirectory", job.dir)
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, kv := range job.env {
+		plistString(&b, "\t\t", kv[0], kv[1])
+	}
+	b.WriteString("\t</dict>\n")
+	if job.every > 0 {
+		fmt.Fprintf(&b, "\t<key>StartInterval</key>\n\t<integer>%d</integer>\n", int(job.every.Seconds()))
+	} else {
+		fmt.Fprintf(&b, "\t<key>StartCalendarInterval</key>\n\t<dict>\n\t\t<key>Hour</key>\n\t\t<integer>%d</integer>\n\t\t<key>Minute</key>\n\t\t<integer>%d</integer>\n\t</dict>\n",
+			job.at.Hour(), job.at.Minute())
+	}
+	plistString(&b, "\t", "StandardOutPath", job.logFile)
+	plistString(&b, "\t", "StandardErrorPath", job.logFile)
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistString(b *strings.Builder, indent, key, value string) {
+	fmt.Fprintf(b, "%s<key>%s</key>\n%s<string>%s</string>\n", indent, xmlEscape(key), indent, xmlEscape(value))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/export"
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/storage"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+func cmdExport(args []string) error {
+	fs := newFlagSet("export")
+	var auth authFlags
+	auth.register(fs)
+	format := fs.String("format", "", "Target format: pp (Portfolio Performance), sharesight or firefly")
+	outDir := fs.String("o", prof.out("."), "Output directory, or s3://bucket/prefix, dropbox:/folder, gdrive:folderID, sftp://host/dir or davs://host/dir")
+	rangeFlag := fs.String("range", "all", "Snapshots to include, e.g. 90d, 12m, 2y or all")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	currency := fs.String("currency", "USD", "Transaction currency written for Portfolio Performance")
+	market := fs.String("market", "NYSE", "Sharesight market code used for all instruments")
+	offline := fs.Bool("offline", false, "Only export trades from snapshots; don't fetch cash transactions")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	var outFlags outputFlags
+	outFlags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch export -format pp|sharesight|firefly [options]")
+		fmt.Fprintln(stderr, "\nFor pp and sharesight, trades are derived from quantity changes between")
+		fmt.Fprintln(stderr, "recorded snapshots; positions in the first snapshot are exported as")
+		fmt.Fprintln(stderr, "opening balances. firefly exports accounts and transactions for the")
+		fmt.Fprintln(stderr, "Firefly III Data Importer.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	from, err := report.ParseRange(*rangeFlag, now())
+	if err != nil {
+		return err
+	}
+	out, err := outFlags.outputs()
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "pp", "sharesight":
+	case "firefly":
+		return exportFirefly(&auth, out, from, *outDir, *currency)
+	default:
+		fs.Usage()
+		return fmt.Errorf("-format must be pp, sharesight or firefly")
+	}
+	snaps, err := history.Open(*historyDir).Range(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("no snapshots in %s; run \"monarch fetch\" first", *historyDir)
+	}
+	if !*includeExcluded {
+		excluded := snaps[len(snaps)-1].ExcludedAccounts()
+		for i := range snaps {
+			snaps[i] = withoutAccounts(snaps[i], excluded)
+		}
+	}
+	cash, err := cashTickers()
+	if err != nil {
+		return err
+	}
+	trades := export.Trades(snaps, cash.IsCash)
+
+	if *format == "sharesight" {
+		path, err := out.write(storage.Join(*outDir, "sharesight-trades.csv"), func(w io.Writer) error {
+			return export.WriteSharesightTrades(w, trades, *market)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %d trades to %s\n", len(trades), path)
+		return nil
+	}
+
+	path, err := out.write(storage.Join(*outDir, "pp-portfolio-transactions.csv"), func(w io.Writer) error {
+		return export.WritePortfolioPerformanceTrades(w, trades, *currency)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d trades to %s\n", len(trades), path)
+
+	if *offline {
+		return nil
+	}
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	var txns []transactions.Transaction
+	if ids := report.InvestmentAccountIDs(snaps, true); len(ids) > 0 {
+		txns, err = fetchTransactions(c, snaps[0].Time, snaps[len(snaps)-1].Time, ids)
+		if err != nil {
+			return fmt.Errorf("fetch transactions: %w", err)
+		}
+	}
+	flows := export.CashFlows(txns)
+	path, err = out.write(storage.Join(*outDir, "pp-account-transactions.csv"), func(w io.Writer) error {
+		return export.WritePortfolioPerformanceCash(w, flows, *currency)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d cash transactions to %s\n", len(flows), path)
+	return nil
+}
+
+// exportFirefly writes accounts, transactions since from and a matching
+// Data Importer configuration for Firefly III.
+func exportFirefly(auth *authFlags, out outputs, from time.Time, outDir, currency string) error {
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	accounts, err := fetchAccounts(c)
+	if err != nil {
+		return fmt.Errorf("fetch accounts: %w", err)
+	}
+	txns, err := fetchTransactions(c, from, now(), nil)
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+
+	path, err := out.write(storage.Join(outDir, "firefly-accounts.csv"), func(w io.Writer) error {
+		return export.WriteFireflyAccounts(w, accounts, currency)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d accounts to %s\n", len(accounts), path)
+
+	path, err = out.write(storage.Join(outDir, "firefly-transactions.csv"), func(w io.Writer) error {
+		return export.WriteFireflyTransactions(w, txns)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d transactions to %s\n", len(txns), path)
+
+	cfg, err := export.FireflyImportConfig(now())
+	if err != nil {
+		return err
+	}
+	path, err = out.write(storage.Join(outDir, "firefly-import-config.json"), func(w io.Writer) error {
+		_, err := w.Write(cfg)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote Data Importer configuration to %s\n", path)
+	return nil
+}
+
+// withoutAccounts returns snap with the holdings of the given accounts removed.
+func withoutAccounts(snap history.Snapshot, ids map[string]bool) history.Snapshot {
+	if len(ids) == 0 {
+		return snap
+	}
+	var holdings []portfolio.HoldingRecord
+	for _, h := range snap.Holdings {
+		if !ids[h.AccountID] {
+			holdings = append(holdings, h)
+		}
+	}
+	snap.Holdings = holdings
+	return snap
+}
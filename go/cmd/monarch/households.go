@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func cmdHouseholds(args []string) error {
+	fs := newFlagSet("households")
+	var auth authFlags
+	auth.register(fs)
+	asJSON := fs.Bool("json", false, "Print the households as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch households [options]")
+		fmt.Fprintln(stderr, "\nLists the households of the login. Pass a name or ID from this list")
+		fmt.Fprintln(stderr, "as -household to other commands to act on that household.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	households, err := c.Households(cmdCtx)
+	if err != nil {
+		return fmt.Errorf("list households: %w", err)
+	}
+	current := c.Household()
+	if current == "" {
+		if m, err := fetchMe(c); err == nil {
+			current = m.Household.ID
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(households)
+	}
+	for _, h := range households {
+		mark := " "
+		if h.ID == current {
+			mark = "*"
+		}
+		fmt.Fprintf(stdout, "%s %s\t%s\n", mark, h.ID, h.Name)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// logger, set by --log-format or --log-level, receives the progress
+// messages and warnings of commands and the client's retries and
+// re-authentications as structured records on stderr. When it is nil,
+// progress is printed to stdout and warnings to stderr as plain text. It
+// is kept after a run so that main can log the error.
+var logger *slog.Logger
+
+// newLogger returns the logger for the --log-format and --log-level
+// flags, or nil if neither is given.
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	if format == "" && level == "" {
+		return nil, nil
+	}
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("--log-level %q: want debug, info, warn or error", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("--log-format %q: want text or json", format)
+}
+
+// progressf reports a step of a command, translated on stdout or as an
+// info record.
+func progressf(format string, args ...any) {
+	if logger != nil {
+		logger.InfoContext(cmdCtx, logMessage(fmt.Sprintf(format, args...)))
+		return
+	}
+	tr.Fprintf(stdout, format, args...)
+}
+
+// progressln is progressf for a message without arguments.
+func progressln(msg string) {
+	if logger != nil {
+		logger.InfoContext(cmdCtx, logMessage(msg))
+		return
+	}
+	tr.Fprintln(stdout, msg)
+}
+
+// warnf reports a problem that doesn't stop the command, after a
+// translated "Warning:" on stderr or as a warning record.
+func warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if logger != nil {
+		logger.WarnContext(cmdCtx, msg)
+		return
+	}
+	fmt.Fprintln(stderr, tr.Text("Warning:"), msg)
+}
+
+// logMessage strips the blank lines and banners meant for terminals.
+func logMessage(s string) string {
+	return strings.Trim(s, "\n =")
+}
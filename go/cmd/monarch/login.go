@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+)
+
+func cmdLogin(args []string) error {
+	fs := newFlagSet("login")
+	var auth authFlags
+	auth.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch login [options]")
+		fmt.Fprintln(stderr, "\nLogs in and saves the session for later commands. Use -google or")
+		fmt.Fprintln(stderr, "-browser for SSO, -remote on a machine without a browser, or -token")
+		fmt.Fprintln(stderr, "to save a token copied from the browser. Each login's session is saved")
+		fmt.Fprintln(stderr, "separately; -email picks the one to use.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	// Logging in explicitly always replaces the saved session.
+	auth.noSession = true
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	if err := c.SaveSession(cmdCtx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	tr.Fprintln(stdout, "Logged in.")
+	return nil
+}
+
+func cmdLogout(args []string) error {
+	fs := newFlagSet("logout")
+	revoke := fs.Bool("revoke", false, "Also revoke the token on Monarch's servers")
+	email := fs.String("email", "", "Monarch login to log out (default the email in the credentials)")
+	sessionStore := fs.String("session-store", "", "Session store to clear: file, keyring or auto (default from config, else file)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch logout [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := newClient(*sessionStore, sessionEmail(*email, prof.credentials))
+	if err != nil {
+		return err
+	}
+	loaded, err := c.LoadSession(cmdCtx)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	if !loaded {
+		tr.Fprintln(stdout, "Not logged in.")
+		return nil
+	}
+	if *revoke {
+		if err := retryOnChallenge(cmdCtx, c, func() error { return c.Logout(cmdCtx) }); err != nil {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+		tr.Fprintln(stdout, "Revoked token.")
+	}
+	if err := c.DeleteSession(cmdCtx); err != nil {
+		return err
+	}
+	tr.Fprintln(stdout, "Logged out.")
+	return nil
+}
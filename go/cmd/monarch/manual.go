@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/valuation"
+)
+
+func manualUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch manual <command> [options]
+
+Commands:
+  add      Create a manual account for a house, vehicle or other asset
+  set      Set the value of a manual account
+  revalue  Update manual accounts as configured under "manualAssets"
+
+Run "monarch manual revalue" from cron or a systemd timer, or leave it
+running with -every, to keep illiquid assets current. Run "monarch manual <command> -h" for command options.`)
+}
+
+func cmdManual(args []string) error {
+	if len(args) < 1 {
+		manualUsage()
+		return fmt.Errorf("missing manual command")
+	}
+	switch args[0] {
+	case "add":
+		return cmdManualAdd(args[1:])
+	case "set":
+		return cmdManualSet(args[1:])
+	case "revalue":
+		return cmdManualRevalue(args[1:])
+	case "-h", "--help", "help":
+		manualUsage()
+		return nil
+	default:
+		manualUsage()
+		return fmt.Errorf("unknown manual command: %s", args[0])
+	}
+}
+
+// manualTypes maps the -type choices of "manual add" to Monarch's account
+// type and default subtype.
+var manualTypes = map[string]struct{ typ, subtype string }{
+	"property":  {"real_estate", "primary_home"},
+	"vehicle":   {"vehicle", "car"},
+	"insurance": {"other_asset", "other"},
+	"other":     {"other_asset", "other"},
+}
+
+func cmdManualAdd(args []string) error {
+	fs := newFlagSet("manual add")
+	var auth authFlags
+	auth.register(fs)
+	kind := fs.String("type", "", "Kind of asset: property, vehicle, insurance or other")
+	subtype := fs.String("subtype", "", "Monarch account subtype, e.g. vacation_home (default by -type)")
+	name := fs.String("name", "", "Account name")
+	value := fs.Float64("value", 0, "Current value")
+	exclude := fs.Bool("exclude", false, "Exclude the account from net worth")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch manual add -type <type> -name <name> -value <value> [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	t, ok := manualTypes[*kind]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("-type must be property, vehicle, insurance or other")
+	}
+	if *name == "" {
+		fs.Usage()
+		return fmt.Errorf("-name is required")
+	}
+	if *subtype != "" {
+		t.subtype = *subtype
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	raw, err := mutatePayload(c, "Web_CreateManualAccount", createManualAccountMutation, "createManualAccount",
+		map[string]any{
+			"type":              t.typ,
+			"subtype":           t.subtype,
+			"name":              *name,
+			"displayBalance":    *value,
+			"includeInNetWorth": !*exclude,
+		})
+	if err != nil {
+		return err
+	}
+	var payload struct {
+		Account struct {
+			ID string `json:"id"`
+		} `json:"account"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("decode created account: %w", err)
+	}
+	fmt.Fprintf(stdout, "Created manual account %s (%s) with value %.2f.\n", *name, payload.Account.ID, *value)
+	return nil
+}
+
+func cmdManualSet(args []string) error {
+	fs := newFlagSet("manual set")
+	var auth authFlags
+	auth.register(fs)
+	account := fs.String("account", "", "Name or ID of the account")
+	value := fs.Float64("value", math.NaN(), "New value")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch manual set -account <name or ID> -value <value> [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *account == "" || math.IsNaN(*value) {
+		fs.Usage()
+		return fmt.Errorf("-account and -value are required")
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	accounts, err := fetchAccounts(c)
+	if err != nil {
+		return err
+	}
+	a, err := findAccount(accounts, *account)
+	if err != nil {
+		return err
+	}
+	if err := setAccountValue(c, a.ID, *value); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Set %s to %.2f (was %.2f).\n", a.Name, *value, a.Balance)
+	return nil
+}
+
+func cmdManualRevalue(args []string) error {
+	fs := newFlagSet("manual revalue")
+	var auth authFlags
+	auth.register(fs)
+	dryRun := fs.Bool("dry-run", false, "Show the new values without updating Monarch")
+	every := fs.Duration("every", 0, "Keep running and revalue at this interval, e.g. 24h, until interrupted")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch manual revalue [options]")
+		fmt.Fprintln(stderr, "\nEach account under \"manualAssets\" in the config takes the value its")
+		fmt.Fprintln(stderr, "\"command\" prints, or its \"vehicle\" valuation, or else grows by its")
+		fmt.Fprintln(stderr, "yearly \"appreciation\" percentage since it was last updated.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	if len(cfg.ManualAssets) == 0 {
+		return fmt.Errorf("no manualAssets in %s", config.Path())
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	if *every <= 0 {
+		return revalueAll(c, cfg.ManualAssets, *dryRun)
+	}
+	for {
+		fmt.Fprintf(stdout, "Revaluing manual assets at %s\n\n", now().Format(time.DateTime))
+		if err := revalueAll(c, cfg.ManualAssets, *dryRun); err != nil {
+			warnf("%v", err)
+		}
+		t := time.NewTimer(*every)
+		select {
+		case <-cmdCtx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		fmt.Fprintln(stdout)
+	}
+}
+
+// revalueAll revalues the manual assets and prints the changes.
+func revalueAll(c client.API, assets []config.ManualAsset, dryRun bool) error {
+	accounts, err := fetchAccounts(c)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, asset := range assets {
+		a, err := findAccount(accounts, asset.Account)
+		if err != nil {
+			return err
+		}
+		value, method, err := revalue(a, asset)
+		if err != nil {
+			return fmt.Errorf("revalue %s: %w", a.Name, err)
+		}
+		if !dryRun && value != a.Balance {
+			if err := setAccountValue(c, a.ID, value); err != nil {
+				return err
+			}
+		}
+		rows = append(rows, []string{a.Name, fmt.Sprintf("%.2f", a.Balance), fmt.Sprintf("%.2f", value), method})
+	}
+	report.WriteTable(stdout, []string{"account", "old_value", "new_value", "method"}, rows)
+	if dryRun {
+		fmt.Fprintln(stdout, "\nDry run; nothing was updated.")
+	}
+	return nil
+}
+
+// revalue returns the current value of a manual account according to
+// asset and a description of how it was found.
+func revalue(a portfolio.AccountRecord, asset config.ManualAsset) (float64, string, error) {
+	if asset.Command != "" {
+		cmd := shellCommand(asset.Command)
+		cmd.Env = append(os.Environ(),
+			"MONARCH_ACCOUNT_ID="+a.ID,
+			"MONARCH_ACCOUNT_NAME="+a.Name,
+			"MONARCH_ACCOUNT_BALANCE="+strconv.FormatFloat(a.Balance, 'f', 2, 64))
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return 0, "", fmt.Errorf("command: %w", err)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("command printed %q, want a number", strings.TrimSpace(string(out)))
+		}
+		return v, "command", nil
+	}
+	if vc := asset.Vehicle; vc != nil {
+		purchased, err := time.Parse(time.DateOnly, vc.PurchaseDate)
+		if err != nil {
+			return 0, "", fmt.Errorf("vehicle purchaseDate %q: want YYYY-MM-DD", vc.PurchaseDate)
+		}
+		v := valuation.Vehicle{PurchasePrice: vc.PurchasePrice, Purchased: purchased, Curve: vc.Depreciation, VIN: vc.VIN, Mileage: vc.Mileage}
+		if vc.Source != "" {
+			value, err := v.Quote(cmdCtx, vc.Source)
+			return value, "vehicle quote", err
+		}
+		return v.Depreciated(now()), "vehicle depreciation since " + vc.PurchaseDate, nil
+	}
+	updated, err := time.Parse(time.RFC3339, a.LastUpdated)
+	if err != nil {
+		return 0, "", fmt.Errorf("unknown last update %q", a.LastUpdated)
+	}
+	years := max(now().Sub(updated).Hours()/24/365.25, 0)
+	v := a.Balance * math.Pow(1+asset.Appreciation/100, years)
+	return math.Round(v*100) / 100, fmt.Sprintf("%+.2f%% a year since %s", asset.Appreciation, updated.Format(time.DateOnly)), nil
+}
+
+// findAccount returns the account with the given ID or, failing that, the
+// only account with the given name.
+func findAccount(accounts []portfolio.AccountRecord, key string) (portfolio.AccountRecord, error) {
+	var matches []portfolio.AccountRecord
+	for _, a := range accounts {
+		if a.ID == key {
+			return a, nil
+		}
+		if strings.EqualFold(a.Name, key) {
+			matches = append(matches, a)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return portfolio.AccountRecord{}, fmt.Errorf("no account named %q", key)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, a := range matches {
+		ids[i] = a.ID
+	}
+	sort.Strings(ids)
+	return portfolio.AccountRecord{}, fmt.Errorf("%d accounts are named %q; use one of the IDs %s", len(matches), key, strings.Join(ids, ", "))
+}
+
+// setAccountValue sets the balance of a manual account.
+func setAccountValue(c client.API, id string, value float64) error {
+	return mutate(c, "Common_UpdateAccount", updateAccountMutation, "updateAccount",
+		map[string]any{"id": id, "displayBalance": value})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+
+	"github.com/heikofkoehler/monarch/internal/sign"
+	"github.com/heikofkoehler/monarch/internal/storage"
+)
+
+// outputFlags select how output files are protected: age recipients given
+// with -encrypt and a signing key given with -sign.
+type outputFlags struct {
+	encrypt []string
+	signKey string
+}
+
+func (f *outputFlags) register(fs *flag.FlagSet) {
+	fs.Func("encrypt", "Encrypt output files with age to this recipient (age1..., an SSH public key or a recipients file); repeatable. Encrypted files get a .age suffix", func(s string) error {
+		f.encrypt = append(f.encrypt, s)
+		return nil
+	})
+	fs.StringVar(&f.signKey, "sign", "", "Write a detached signature next to each output file with this SSH or minisign secret key")
+}
+
+// args returns the flags in command-line form, for forwarding to another subcommand.
+func (f outputFlags) args() []string {
+	var args []string
+	for _, r := range f.encrypt {
+		args = append(args, "-encrypt", r)
+	}
+	if f.signKey != "" {
+		args = append(args, "-sign", f.signKey)
+	}
+	return args
+}
+
+// outputs returns the writer for the command's output files.
+func (f outputFlags) outputs() (outputs, error) {
+	var out outputs
+	for _, s := range f.encrypt {
+		r, err := storage.ParseRecipient(s)
+		if err != nil {
+			return outputs{}, err
+		}
+		out.recipients = append(out.recipients, r...)
+	}
+	if f.signKey != "" {
+		s, err := sign.Load(f.signKey)
+		if err != nil {
+			return outputs{}, err
+		}
+		out.signer = s
+	}
+	return out, nil
+}
+
+// outputs writes export files, locally or to remote storage, encrypting
+// them if recipients are set and signing them if a signer is.
+type outputs struct {
+	recipients []age.Recipient
+	signer     sign.Signer
+}
+
+// path returns where a file requested at path is actually written.
+func (o outputs) path(path string) string {
+	if len(o.recipients) > 0 {
+		return path + ".age"
+	}
+	return path
+}
+
+// write creates o.path(path) and passes it to fn, returning the path
+// written. The signature, if any, covers the file as stored.
+func (o outputs) write(path string, fn func(io.Writer) error) (string, error) {
+	path = o.path(path)
+	// Create's errors name the destination, with any password redacted.
+	w, err := storage.Create(path)
+	if err != nil {
+		return "", err
+	}
+	var stored bytes.Buffer
+	if o.signer != nil {
+		w = teeCloser{w, &stored}
+	}
+	if len(o.recipients) > 0 {
+		if w, err = storage.Encrypt(w, o.recipients); err != nil {
+			return "", err
+		}
+	}
+	if err := fn(w); err != nil {
+		w.Abort()
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if o.signer != nil {
+		sig, err := o.signer.Sign(stored.Bytes())
+		if err != nil {
+			return "", fmt.Errorf("sign %s: %w", path, err)
+		}
+		sw, err := storage.Create(path + o.signer.Ext())
+		if err != nil {
+			return "", err
+		}
+		if _, err := sw.Write(sig); err != nil {
+			sw.Abort()
+			return "", err
+		}
+		if err := sw.Close(); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// teeCloser copies what is written to a storage.Writer into a second
+// writer.
+type teeCloser struct {
+	storage.Writer
+	copy io.Writer
+}
+
+func (t teeCloser) Write(p []byte) (int, error) {
+	t.copy.Write(p)
+	return t.Writer.Write(p)
+}
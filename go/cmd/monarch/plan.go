@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/heikofkoehler/monarch/internal/sinking"
+)
+
+func planUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch plan <plan> [options]
+
+Plans:
+  sinking  Track set-asides for annual and semiannual expenses
+
+Run "monarch plan <plan> -h" for plan-specific options.`)
+}
+
+func cmdPlan(args []string) error {
+	if len(args) < 1 {
+		planUsage()
+		return fmt.Errorf("missing plan name")
+	}
+	switch args[0] {
+	case "sinking":
+		return cmdPlanSinking(args[1:])
+	case "-h", "--help", "help":
+		planUsage()
+		return nil
+	default:
+		planUsage()
+		return fmt.Errorf("unknown plan: %s", args[0])
+	}
+}
+
+func cmdPlanSinking(args []string) error {
+	fs := newFlagSet("plan sinking")
+	var auth authFlags
+	auth.register(fs)
+	configPath := fs.String("config", "sinking.yaml", "Sinking fund items")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch plan sinking [-config sinking.yaml] [options]")
+		fmt.Fprintln(stderr, `
+Each item is an expense due every year, half year, quarter or month:
+
+  items:
+    - name: Car insurance
+      amount: 1200
+      every: semiannual
+      due: 2025-06-15
+    - name: Property tax
+      amount: 4800
+      due: 2025-11-01
+      tag: Escrow
+
+Money set aside for an item is the deposits tagged with its tag (default:
+its name) since it was last due; tag the receiving side of each transfer.`)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	plan, err := sinking.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	today := now()
+	txns, err := fetchTransactions(c, plan.Since(today), today, nil)
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+	sinking.Write(stdout, plan.Progress(txns, today), today)
+	return nil
+}
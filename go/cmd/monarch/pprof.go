@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// profilingFlags are the optional profiling outputs of long-running commands.
+type profilingFlags struct {
+	cpuProfile string
+	memProfile string
+	pprofAddr  string
+	// pprofPublic allows pprofAddr to be reachable from other machines.
+	pprofPublic bool
+}
+
+func (p *profilingFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+	fs.StringVar(&p.memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+	fs.StringVar(&p.pprofAddr, "pprof", "", "Serve net/http/pprof on this loopback address while running (e.g. localhost:6060)")
+	fs.BoolVar(&p.pprofPublic, "pprof-public", false, "Allow -pprof on an address other machines can reach; profiles reveal memory contents such as tokens")
+}
+
+// start begins the requested profiling. The returned function stops it and
+// writes the profiles; it must be called before the command returns.
+func (p *profilingFlags) start() (stop func() error, err error) {
+	var cpu io.WriteCloser
+	if p.cpuProfile != "" {
+		cpu, err = fsys.Create(p.cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("create CPU profile: %w", err)
+		}
+		if err := rpprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("start CPU profile: %w", err)
+		}
+	}
+
+	var srv *http.Server
+	if p.pprofAddr != "" {
+		ln, err := p.listen()
+		if err != nil {
+			if cpu != nil {
+				rpprof.StopCPUProfile()
+				cpu.Close()
+			}
+			return nil, err
+		}
+		// The command line isn't served: it may hold a token.
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		srv = &http.Server{Handler: mux}
+		go srv.Serve(ln)
+		fmt.Fprintf(stderr, "Serving pprof on http://%s/debug/pprof/\n", ln.Addr())
+	}
+
+	return func() error {
+		if srv != nil {
+			srv.Close()
+		}
+		if cpu != nil {
+			rpprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return fmt.Errorf("write CPU profile: %w", err)
+			}
+		}
+		if p.memProfile != "" {
+			f, err := fsys.Create(p.memProfile)
+			if err != nil {
+				return fmt.Errorf("create heap profile: %w", err)
+			}
+			runtime.GC()
+			if err := rpprof.WriteHeapProfile(f); err != nil {
+				f.Close()
+				return fmt.Errorf("write heap profile: %w", err)
+			}
+			return f.Close()
+		}
+		return nil
+	}, nil
+}
+
+// listen opens the pprof listener, refusing an address other machines can
+// reach unless -pprof-public is set.
+func (p *profilingFlags) listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", p.pprofAddr)
+	if err != nil {
+		return nil, fmt.Errorf("pprof listener: %w", err)
+	}
+	if host, _, _ := net.SplitHostPort(ln.Addr().String()); !p.pprofPublic && !net.ParseIP(host).IsLoopback() {
+		ln.Close()
+		return nil, fmt.Errorf("-pprof %s is reachable from other machines; use a loopback address such as localhost:6060, or add -pprof-public", p.pprofAddr)
+	}
+	return ln, nil
+}
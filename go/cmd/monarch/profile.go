@@ -0,0 +1,386 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+)
+
+// profilesDir holds the data directories of named profiles.
+const profilesDir = ".mm/profiles"
+
+// profileName matches valid profile names, which name a directory under
+// profilesDir.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// profileFiles are the files and directories the tool creates in a profile's
+// data directory, besides its sessions. Purging a profile deletes only
+// these, so a -dir shared with other files keeps them.
+var profileFiles = append([]string{"history", "history.key", "device_id", publishedFile}, purgeDirs...)
+
+// profilePaths are the default file locations for the active profile. The
+// default profile keeps the historical layout: credentials.json and outputs
+// in the working directory, session and history under .mm.
+type profilePaths struct {
+	name        string
+	dir         string
+	credentials string
+	session     string
+	keyring     string
+	totpKeyring string
+	device      string
+	history     string
+	// historyKey keys the history's manifest; see history.OpenKeyed.
+	historyKey string
+	// credentialsCommand, if set, prints the credentials instead of
+	// reading them from the credentials file.
+	credentialsCommand string
+	// credentialsSource, if set, names a secrets manager holding the
+	// credentials; it takes precedence over credentialsCommand.
+	credentialsSource string
+}
+
+// prof is the active profile, set in main before any command runs.
+var prof = defaultProfile()
+
+func defaultProfile() profilePaths {
+	return profilePaths{
+		credentials: "credentials.json",
+		session:     client.DefaultSessionPath(),
+		keyring:     client.DefaultKeyringAccount,
+		totpKeyring: "totp",
+		device:      ".mm/device_id",
+		history:     ".mm/history",
+		historyKey:  ".mm/history.key",
+	}
+}
+
+// loadProfile returns the paths for the named profile, which must exist in
+// the config. An empty name selects the default profile.
+func loadProfile(name string) (profilePaths, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return profilePaths{}, err
+	}
+	if name == "" || name == "default" {
+		p := defaultProfile()
+		p.credentialsCommand = cfg.CredentialsCommand
+		p.credentialsSource = cfg.CredentialsSource
+		return p, nil
+	}
+	pc, ok := cfg.Profiles[name]
+	if !ok {
+		return profilePaths{}, fmt.Errorf("unknown profile %q (see \"monarch profile list\")", name)
+	}
+	if !profileName.MatchString(name) {
+		return profilePaths{}, fmt.Errorf("invalid profile name %q: use letters, digits, - and _", name)
+	}
+	p := namedProfile(name, pc)
+	p.credentialsCommand = cmp.Or(pc.CredentialsCommand, cfg.CredentialsCommand)
+	p.credentialsSource = cmp.Or(pc.CredentialsSource, cfg.CredentialsSource)
+	return p, nil
+}
+
+func namedProfile(name string, pc config.ProfileConfig) profilePaths {
+	dir := pc.Dir
+	if dir == "" {
+		dir = filepath.Join(profilesDir, name)
+	}
+	creds := pc.Credentials
+	if creds == "" {
+		creds = filepath.Join(dir, "credentials.json")
+	}
+	return profilePaths{
+		name:        name,
+		dir:         dir,
+		credentials: creds,
+		session:     filepath.Join(dir, "session.json"),
+		keyring:     client.DefaultKeyringAccount + "/" + name,
+		totpKeyring: "totp/" + name,
+		device:      filepath.Join(dir, "device_id"),
+		history:     filepath.Join(dir, "history"),
+		historyKey:  filepath.Join(dir, "history.key"),
+	}
+}
+
+// baseDir returns the directory holding the profile's local data.
+func (p profilePaths) baseDir() string {
+	if p.dir == "" {
+		return ".mm"
+	}
+	return p.dir
+}
+
+// out returns the default location of an output file for the profile.
+func (p profilePaths) out(name string) string {
+	if p.dir == "" {
+		return name
+	}
+	return filepath.Join(p.dir, name)
+}
+
+// globalFlags are the options given before the command name.
+type globalFlags struct {
+	profile     string
+	sessionFile string
+	// debug logs HTTP exchanges to debugFile, or stderr if it is empty.
+	debug     bool
+	debugFile string
+	proxy     string
+	noCache   bool
+	logFormat string
+	logLevel  string
+	// record and replay are cassette directories of HTTP exchanges.
+	record string
+	replay string
+}
+
+// globalFlagNames are the flags splitGlobalFlags accepts.
+var globalFlagNames = []string{"profile", "session-file", "debug", "debug-file", "proxy", "no-cache", "log-format", "log-level", "record", "replay"}
+
+// splitGlobalFlags removes leading --profile, --session-file, --debug,
+// --debug-file, --proxy, --no-cache, --log-format, --log-level, --record
+// and --replay flags from args. The MONARCH_PROFILE and MONARCH_SESSION_FILE environment
+// variables are used when the flags are absent.
+func splitGlobalFlags(args []string) (g globalFlags, rest []string, err error) {
+	g.profile = os.Getenv("MONARCH_PROFILE")
+	g.sessionFile = os.Getenv("MONARCH_SESSION_FILE")
+	for len(args) > 0 {
+		arg := args[0]
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || !slices.Contains(globalFlagNames, name) {
+			break
+		}
+		if (name == "debug" || name == "no-cache") && !hasValue {
+			g.debug = g.debug || name == "debug"
+			g.noCache = g.noCache || name == "no-cache"
+			args = args[1:]
+			continue
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return globalFlags{}, nil, fmt.Errorf("flag %s needs a value", arg)
+			}
+			value = args[1]
+			args = args[1:]
+		}
+		switch name {
+		case "profile":
+			g.profile = value
+		case "session-file":
+			g.sessionFile = value
+		case "debug":
+			if g.debug, err = strconv.ParseBool(value); err != nil {
+				return globalFlags{}, nil, fmt.Errorf("invalid value %q for %s", value, arg)
+			}
+		case "debug-file":
+			g.debug, g.debugFile = true, value
+		case "proxy":
+			g.proxy = value
+		case "log-format":
+			g.logFormat = value
+		case "log-level":
+			g.logLevel = value
+		case "record":
+			g.record = value
+		case "replay":
+			g.replay = value
+		case "no-cache":
+			if g.noCache, err = strconv.ParseBool(value); err != nil {
+				return globalFlags{}, nil, fmt.Errorf("invalid value %q for %s", value, arg)
+			}
+		}
+		args = args[1:]
+	}
+	return g, args, nil
+}
+
+func profileUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch profile <command> [options]
+
+Commands:
+  list                 List configured profiles
+  add <name>           Add a profile (options: -dir, -c)
+  remove <name>        Remove a profile (option: -purge deletes its data)`)
+}
+
+func cmdProfile(args []string) error {
+	if len(args) < 1 {
+		profileUsage()
+		return fmt.Errorf("missing profile command")
+	}
+	switch args[0] {
+	case "list":
+		return cmdProfileList()
+	case "add":
+		return cmdProfileAdd(args[1:])
+	case "remove":
+		return cmdProfileRemove(args[1:])
+	case "-h", "--help", "help":
+		profileUsage()
+		return nil
+	default:
+		profileUsage()
+		return fmt.Errorf("unknown profile command: %s", args[0])
+	}
+}
+
+func cmdProfileList() error {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(stdout, "default")
+	for _, name := range names {
+		p := namedProfile(name, cfg.Profiles[name])
+		fmt.Fprintf(stdout, "%s\t%s\n", name, p.dir)
+	}
+	return nil
+}
+
+// parseNamed parses flags that may appear before or after a single
+// positional name argument.
+func parseNamed(fs *flag.FlagSet, args []string) (string, error) {
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return "", fmt.Errorf("missing profile name")
+	}
+	name := fs.Arg(0)
+	if err := parseFlags(fs, fs.Args()[1:]); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func cmdProfileAdd(args []string) error {
+	fs := newFlagSet("profile add")
+	dir := fs.String("dir", "", "Data directory (default .mm/profiles/<name>)")
+	creds := fs.String("c", "", "Credentials JSON file (default <dir>/credentials.json)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch profile add <name> [options]")
+		fs.PrintDefaults()
+	}
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	if name == "default" || !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, - and _", name)
+	}
+
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Profiles[name]; ok {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]config.ProfileConfig)
+	}
+	pc := config.ProfileConfig{Dir: *dir, Credentials: *creds}
+	cfg.Profiles[name] = pc
+	p := namedProfile(name, pc)
+	if err := fsys.MkdirAll(p.dir, 0700); err != nil {
+		return err
+	}
+	if err := config.Save(cfg, config.Path()); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Added profile %q in %s\n", name, p.dir)
+	fmt.Fprintf(stdout, "Put its credentials in %s, then run: monarch --profile %s fetch\n", p.credentials, name)
+	return nil
+}
+
+func cmdProfileRemove(args []string) error {
+	fs := newFlagSet("profile remove")
+	purge := fs.Bool("purge", false, "Also delete the profile's session, history, caches and logs")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch profile remove <name> [options]")
+		fs.PrintDefaults()
+	}
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	pc, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	if *purge && !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: remove it without -purge", name)
+	}
+	delete(cfg.Profiles, name)
+	if err := config.Save(cfg, config.Path()); err != nil {
+		return err
+	}
+	if *purge {
+		p := namedProfile(name, pc)
+		if err := purgeProfile(p); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Removed profile %q and deleted its data in %s\n", name, p.dir)
+		return nil
+	}
+	fmt.Fprintf(stdout, "Removed profile %q\n", name)
+	return nil
+}
+
+// purgeProfile deletes the files the tool created in the profile's data
+// directory, then the directory itself if nothing else is left in it, and
+// the profile's keyring entries.
+func purgeProfile(p profilePaths) error {
+	sessions, err := client.SessionPaths(p.session)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, path := range sessions {
+		paths = append(paths, path, client.SessionLockPath(path))
+	}
+	for _, name := range profileFiles {
+		paths = append(paths, filepath.Join(p.dir, name))
+	}
+	for _, path := range paths {
+		if err := fsys.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	if entries, err := fsys.ReadDir(p.dir); err == nil && len(entries) == 0 {
+		if err := fsys.Remove(p.dir); err != nil {
+			return err
+		}
+	}
+	if client.KeyringAvailable() {
+		for _, account := range []string{p.keyring, p.totpKeyring} {
+			if err := client.NewKeyringStore(account).Delete(); err != nil {
+				return fmt.Errorf("delete keyring entry %s: %w", account, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+)
+
+func proxyUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch proxy [options]
+       monarch proxy key
+
+Serves Monarch's GraphQL API at /graphql for local tools, which then share
+this tool's session and rate limit instead of logging in themselves. Only
+the read operations in "proxy.operations" in the config are passed on
+(by default the queries this tool uses), and their responses are cached.
+
+Clients send "Authorization: Bearer <key>" with a key whose hash is in
+"proxy.apiKeysSHA256"; "monarch proxy key" creates one. Without keys the
+proxy only listens on localhost.
+
+Prometheus can scrape the requests, errors and latencies of each operation
+sent to Monarch at /metrics, with the same key.`)
+}
+
+// defaultProxyOperations are the queries the proxy passes on unless the
+// config lists others.
+var defaultProxyOperations = []string{
+	"Common_GetHouseholds", "Common_GetMe", "Common_GetMyHousehold", "GetAccounts", "GetCategories",
+	"GetHouseholdTransactionTags", "GetJointPlanningData", "GetTransactionsList", "Web_GetPortfolio",
+}
+
+func cmdProxy(args []string) (err error) {
+	if len(args) > 0 && args[0] == "key" {
+		return cmdProxyKey(args[1:])
+	}
+	fs := newFlagSet("proxy")
+	var auth authFlags
+	auth.register(fs)
+	listen := fs.String("listen", "localhost:8081", "Address to listen on")
+	ttl := fs.Duration("ttl", 5*time.Minute, "How long to answer a repeated query from the cache (0 to not cache)")
+	var profiling profilingFlags
+	profiling.register(fs)
+	fs.Usage = func() {
+		proxyUsage()
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	ops := cfg.Proxy.Operations
+	if len(ops) == 0 {
+		ops = defaultProxyOperations
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if host, _, _ := net.SplitHostPort(ln.Addr().String()); len(cfg.Proxy.APIKeysSHA256) == 0 && !net.ParseIP(host).IsLoopback() {
+		return fmt.Errorf("listening on %s needs API keys in proxy.apiKeysSHA256 in %s; add them with \"monarch proxy key\"", *listen, config.Path())
+	}
+	stop, err := profiling.start()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, stop()) }()
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	h, err := newGraphQLProxy(c, ops, cfg.Proxy.APIKeysSHA256, *ttl)
+	if err != nil {
+		return err
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c.Metrics())
+	h.metrics = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	fmt.Fprintf(stdout, "Proxying %d operations on http://%s/graphql\n", len(ops), ln.Addr())
+	select {
+	case err := <-done:
+		return err
+	case <-cmdCtx.Done():
+		return srv.Close()
+	}
+}
+
+func cmdProxyKey(args []string) error {
+	fs := newFlagSet("proxy key")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch proxy key")
+		fmt.Fprintln(stderr, "\nPrints a new API key and the hash to add to \"proxy.apiKeysSHA256\" in the config.")
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	key := newAPIKey()
+	fmt.Fprintf(stdout, "API key (shown only once): %s\n\nAdd to \"proxy.apiKeysSHA256\" in %s:\n%q\n", key, config.Path(), hashAPIKey(key))
+	return nil
+}
+
+// graphqlProxy passes allowed queries on to the API through one client.
+type graphqlProxy struct {
+	api     client.API
+	allowed map[string]bool
+	keys    [][]byte
+	ttl     time.Duration
+	// metrics, if set, serves the client's metrics at /metrics.
+	metrics http.Handler
+
+	// mu serializes the calls to the API, which share the client's
+	// session, and guards cache.
+	mu    sync.Mutex
+	cache map[string]proxyEntry
+}
+
+// proxyEntry is a cached response.
+type proxyEntry struct {
+	data    map[string]json.RawMessage
+	expires time.Time
+}
+
+// otherOperations matches the keywords of operations that aren't queries.
+var otherOperations = regexp.MustCompile(`\b(mutation|subscription)\b`)
+
+// namedQuery reports whether the GraphQL document doc starts with the
+// query called name, rather than one whose name merely begins with it.
+func namedQuery(doc, name string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(doc), "query")
+	if !ok || strings.TrimLeft(rest, " \t\r\n") == rest {
+		return false
+	}
+	rest, ok = strings.CutPrefix(strings.TrimLeft(rest, " \t\r\n"), name)
+	return ok && rest != "" && strings.ContainsRune("({ \t\r\n", rune(rest[0]))
+}
+
+func newGraphQLProxy(api client.API, ops, keyHashes []string, ttl time.Duration) (*graphqlProxy, error) {
+	p := &graphqlProxy{api: api, allowed: map[string]bool{}, ttl: ttl, cache: map[string]proxyEntry{}}
+	for _, op := range ops {
+		p.allowed[op] = true
+	}
+	for _, k := range keyHashes {
+		hash, err := hex.DecodeString(k)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("proxy.apiKeysSHA256: %q is not a hex SHA-256 hash", k)
+		}
+		p.keys = append(p.keys, hash)
+	}
+	return p, nil
+}
+
+// authorized reports whether r carries one of the keys, if any are set.
+// Clients may send it as Monarch's "Token" as well as "Bearer".
+func (p *graphqlProxy) authorized(r *http.Request) bool {
+	if len(p.keys) == 0 {
+		return true
+	}
+	h := r.Header.Get("Authorization")
+	key, ok := strings.CutPrefix(h, "Bearer ")
+	if !ok {
+		key, ok = strings.CutPrefix(h, "Token ")
+	}
+	if !ok || key == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range p.keys {
+		if subtle.ConstantTimeCompare(sum[:], k) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *graphqlProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/metrics" && p.metrics != nil {
+		if !p.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="monarch"`)
+			http.Error(w, "missing or unknown API key", http.StatusUnauthorized)
+			return
+		}
+		p.metrics.ServeHTTP(w, r)
+		return
+	}
+	if r.URL.Path != "/graphql" && r.URL.Path != "/graphql/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if !p.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="monarch"`)
+		writeGraphQLError(w, http.StatusUnauthorized, "missing or unknown API key")
+		return
+	}
+	var req struct {
+		OperationName string         `json:"operationName"`
+		Query         string         `json:"query"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, "want a JSON object with operationName, query and variables: "+err.Error())
+		return
+	}
+	if !p.allowed[req.OperationName] {
+		writeGraphQLError(w, http.StatusForbidden, fmt.Sprintf("operation %q is not in proxy.operations", req.OperationName))
+		return
+	}
+	// Requiring the document to be just the named query keeps a mutation
+	// from riding along under an allowed name.
+	if !namedQuery(req.Query, req.OperationName) || otherOperations.MatchString(req.Query) {
+		writeGraphQLError(w, http.StatusForbidden, "only queries named by operationName are passed on")
+		return
+	}
+
+	vars, _ := json.Marshal(req.Variables)
+	sum := sha256.Sum256([]byte(req.OperationName + "\x00" + req.Query + "\x00" + string(vars)))
+	key := string(sum[:])
+
+	p.mu.Lock()
+	entry, ok := p.cache[key]
+	hit := ok && time.Now().Before(entry.expires)
+	var err error
+	if !hit {
+		entry.data, err = p.api.GraphQLCall(r.Context(), req.OperationName, req.Query, req.Variables)
+		if err == nil && p.ttl > 0 {
+			for k, e := range p.cache {
+				if !time.Now().Before(e.expires) {
+					delete(p.cache, k)
+				}
+			}
+			entry.expires = time.Now().Add(p.ttl)
+			p.cache[key] = entry
+		}
+	}
+	p.mu.Unlock()
+
+	var gerr *client.GraphQLError
+	switch {
+	case errors.As(err, &gerr) && !errors.Is(err, client.ErrTokenExpired):
+		writeJSON(w, http.StatusOK, map[string]any{"errors": gerr.Errors})
+	case err != nil:
+		writeGraphQLError(w, http.StatusBadGateway, err.Error())
+	default:
+		w.Header().Set("X-Cache", map[bool]string{true: "HIT", false: "MISS"}[hit])
+		writeJSON(w, http.StatusOK, map[string]any{"data": entry.data})
+	}
+}
+
+// writeGraphQLError answers with a GraphQL error response.
+func writeGraphQLError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{"errors": []client.GraphQLErrorItem{{Message: msg}}})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+)
+
+// purgeDirs are the profile subdirectories holding caches and logs, purged
+// by file modification time.
+var purgeDirs = []string{"cache", "logs"}
+
+func cmdPurge(args []string) error {
+	fs := newFlagSet("purge")
+	before := fs.String("before", "", "Delete local data older than this date (YYYY-MM-DD)")
+	all := fs.Bool("all", false, "Delete all local data, including the saved session")
+	dryRun := fs.Bool("dry-run", false, "List what would be deleted without deleting it")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch purge (-before YYYY-MM-DD | -all) [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	switch {
+	case *all && *before != "":
+		return fmt.Errorf("use either -before or -all, not both")
+	case *before != "":
+		t, err := time.ParseInLocation(time.DateOnly, *before, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid -before date: %w", err)
+		}
+		cutoff = t
+	case !*all:
+		fs.Usage()
+		return fmt.Errorf("one of -before or -all is required")
+	}
+
+	store, err := openHistory(*historyDir, false)
+	if err != nil {
+		return err
+	}
+	snapshots, err := store.FilesBefore(cutoff)
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	sessions, err := client.SessionPaths(prof.session)
+	if err != nil {
+		return err
+	}
+	var roots []string
+	for _, path := range sessions {
+		roots = append(roots, path, client.SessionLockPath(path))
+	}
+	if *all {
+		roots = append(roots, prof.device)
+	}
+	for _, sub := range purgeDirs {
+		roots = append(roots, filepath.Join(prof.baseDir(), sub))
+	}
+	for _, root := range roots {
+		files, err := filesModifiedBefore(root, cutoff)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, files...)
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	// Snapshots go through the store so the integrity manifest stays valid.
+	for _, path := range snapshots {
+		if !*dryRun {
+			if err := store.Delete(path); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(stdout, "%s %s\n", verb, path)
+	}
+	for _, path := range targets {
+		if !*dryRun {
+			if err := fsys.Remove(path); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(stdout, "%s %s\n", verb, path)
+	}
+	if *all && client.KeyringAvailable() {
+		// Keyring entries can't be listed; those of other logins than the
+		// current one are left behind.
+		accounts := []string{prof.keyring}
+		if email := sessionEmail("", prof.credentials); email != "" {
+			accounts = append(accounts, client.KeyringAccountFor(prof.keyring, email))
+		}
+		for _, account := range accounts {
+			if !*dryRun {
+				if err := client.NewKeyringStore(account).Delete(); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(stdout, "%s keyring entry %s\n", verb, account)
+		}
+	}
+	fmt.Fprintf(stdout, "%s %d files.\n", verb, len(snapshots)+len(targets))
+	return nil
+}
+
+// filesModifiedBefore returns regular files under root (a file or
+// directory) last modified before cutoff; a zero cutoff matches everything.
+func filesModifiedBefore(root string, cutoff time.Time) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if cutoff.IsZero() || info.ModTime().Before(cutoff) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
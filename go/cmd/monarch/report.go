@@ -0,0 +1,586 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/budget"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/events"
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/notify"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+func reportUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch report <report> [options]
+
+Reports:
+  performance  Time-weighted returns per account or account type
+  growth       Net-worth change split into contributions and market growth
+  cash         Total cash across banks and brokerage sweep funds
+  movers       Holdings and accounts that changed most since the last snapshot
+  risk         Volatility and drawdowns per account and in total
+  correlation  Return correlations between the largest holdings, as CSV and HTML
+  glidepath    Equity share per account type against an age-based target
+  estate       Inventory of all accounts, institutions and owners for survivors
+  ladder       Maturities of CDs, treasuries and bonds by month
+  pace         This month's spending per category projected to the month's end
+  income-smoothing
+               Rolling average and variance of earned income, and a baseline salary
+
+Run "monarch report <report> -h" for report-specific options.`)
+}
+
+func cmdReport(args []string) error {
+	if len(args) < 1 {
+		reportUsage()
+		return fmt.Errorf("missing report name")
+	}
+	switch args[0] {
+	case "performance":
+		return cmdReportPerformance(args[1:])
+	case "growth":
+		return cmdReportGrowth(args[1:])
+	case "cash":
+		return cmdReportCash(args[1:])
+	case "movers":
+		return cmdReportMovers(args[1:])
+	case "risk":
+		return cmdReportRisk(args[1:])
+	case "correlation":
+		return cmdReportCorrelation(args[1:])
+	case "glidepath":
+		return cmdReportGlidePath(args[1:])
+	case "estate":
+		return cmdReportEstate(args[1:])
+	case "ladder":
+		return cmdReportLadder(args[1:])
+	case "pace":
+		return cmdReportPace(args[1:])
+	case "income-smoothing":
+		return cmdReportIncomeSmoothing(args[1:])
+	case "-h", "--help", "help":
+		reportUsage()
+		return nil
+	default:
+		reportUsage()
+		return fmt.Errorf("unknown report: %s", args[0])
+	}
+}
+
+func cmdReportPerformance(args []string) error {
+	fs := newFlagSet("report performance")
+	var auth authFlags
+	auth.register(fs)
+	by := fs.String("by", report.ByAccount, "Group by: account or account-type")
+	rangeFlag := fs.String("range", "all", "Lookback window, e.g. 90d, 12m, 2y or all")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report performance [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	now := now()
+	from, err := report.ParseRange(*rangeFlag, now)
+	if err != nil {
+		return err
+	}
+	snaps, err := history.Open(*historyDir).Range(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	if len(snaps) < 2 {
+		return fmt.Errorf("performance needs at least two snapshots in %s (found %d); run \"monarch fetch\" periodically to record them", *historyDir, len(snaps))
+	}
+	first, last := snaps[0].Time, snaps[len(snaps)-1].Time
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	txns, err := fetchTransactions(c, first, last, report.InvestmentAccountIDs(snaps, *includeExcluded))
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+
+	rows, err := report.Performance(snaps, txns, *by, *includeExcluded)
+	if err != nil {
+		return err
+	}
+	report.WritePerformance(stdout, rows, first, last)
+	return nil
+}
+
+func cmdReportGrowth(args []string) error {
+	fs := newFlagSet("report growth")
+	var auth authFlags
+	auth.register(fs)
+	rangeFlag := fs.String("range", "12m", "Lookback window, e.g. 90d, 12m, 2y or all")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report growth [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	from, err := report.ParseRange(*rangeFlag, now())
+	if err != nil {
+		return err
+	}
+	snaps, err := history.Open(*historyDir).Range(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	snaps = report.MonthlySnapshots(snaps)
+	if len(snaps) < 2 {
+		return fmt.Errorf("growth needs at least two snapshots in %s (found %d); run \"monarch fetch\" periodically to record them", *historyDir, len(snaps))
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	txns, err := fetchTransactions(c, snaps[0].Time, snaps[len(snaps)-1].Time, nil)
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+
+	rows, err := report.Growth(snaps, txns, *includeExcluded)
+	if err != nil {
+		return err
+	}
+	report.WriteGrowth(stdout, rows)
+	return nil
+}
+
+// cashTickers returns the configured sweep tickers, or the defaults.
+func cashTickers() (portfolio.CashTickers, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return nil, err
+	}
+	tickers := cfg.Cash.SweepTickers
+	if len(tickers) == 0 {
+		tickers = portfolio.DefaultSweepTickers
+	}
+	return portfolio.NewCashTickers(tickers), nil
+}
+
+// latestSnapshot returns the most recent snapshot in dir.
+func latestSnapshot(dir string) (history.Snapshot, error) {
+	snaps, err := history.Open(dir).List()
+	if err != nil {
+		return history.Snapshot{}, err
+	}
+	if len(snaps) == 0 {
+		return history.Snapshot{}, fmt.Errorf("no snapshots in %s; run \"monarch fetch\" first", dir)
+	}
+	return snaps[len(snaps)-1], nil
+}
+
+func cmdReportCash(args []string) error {
+	fs := newFlagSet("report cash")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report cash [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	snap, err := latestSnapshot(*historyDir)
+	if err != nil {
+		return err
+	}
+	cash, err := cashTickers()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Cash as of %s\n\n", snap.Time.Format(time.DateOnly))
+	report.WriteCash(stdout, report.Cash(snap, cash, *includeExcluded))
+	portfolio.WriteWarnings(portfolio.Validate(snap.Holdings), stdout)
+	return nil
+}
+
+func cmdReportLadder(args []string) error {
+	fs := newFlagSet("report ladder")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	overridesPath := fs.String("overrides", portfolio.DefaultOverridesPath, "Security overrides JSON file with maturity dates")
+	remind := fs.Int("remind", 30, "Remind to reinvest what matures within this many days")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report ladder [options]")
+		fmt.Fprintln(stderr, "\nMaturity dates come from the \"maturity\" of a security override, or else")
+		fmt.Fprintln(stderr, "from a date in the name of a CD, treasury or bond, e.g. \"CD 4.5% 09/15/2026\".")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	snap, err := latestSnapshot(*historyDir)
+	if err != nil {
+		return err
+	}
+	overrides, err := portfolio.LoadOverrides(*overridesPath)
+	if err != nil {
+		return err
+	}
+	l, err := report.BuildLadder(snap, overrides)
+	if err != nil {
+		return err
+	}
+	report.WriteLadder(stdout, l, now(), *remind)
+	return nil
+}
+
+func cmdReportPace(args []string) error {
+	fs := newFlagSet("report pace")
+	var auth authFlags
+	auth.register(fs)
+	date := fs.String("date", "", "Project from this day, YYYY-MM-DD (default today)")
+	months := fs.Int("months", 6, "Number of earlier months to learn the spending curves from")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report pace [options]")
+		fmt.Fprintln(stderr, "\nProjects each expense category's spending to the end of the month from")
+		fmt.Fprintln(stderr, "the month to date and how much of a month's spending usually falls by the")
+		fmt.Fprintln(stderr, "same day, and flags categories on pace to exceed their budget.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *months < 1 {
+		return fmt.Errorf("-months must be at least 1")
+	}
+	asOf := now().UTC()
+	if *date != "" {
+		var err error
+		if asOf, err = time.Parse(time.DateOnly, *date); err != nil {
+			return fmt.Errorf("-date %q: want YYYY-MM-DD", *date)
+		}
+	}
+	asOf = time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	from := budget.MonthStart(asOf).AddDate(0, -*months, 0)
+	txns, err := fetchTransactions(c, from, asOf, nil)
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+	b, err := fetchBudget(c, asOf)
+	if err != nil {
+		return fmt.Errorf("fetch budget: %w", err)
+	}
+	report.WritePace(stdout, report.BuildPace(txns, asOf, b.Lines(asOf)))
+	return nil
+}
+
+func cmdReportIncomeSmoothing(args []string) error {
+	fs := newFlagSet("report income-smoothing")
+	var auth authFlags
+	auth.register(fs)
+	windowFlag := fs.String("window", "6m", "Months to average over, e.g. 6m or 1y")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report income-smoothing [options]")
+		fmt.Fprintln(stderr, "\nAverages the earned income of the last complete months, that is income")
+		fmt.Fprintln(stderr, "categories other than interest and dividends and uncategorized deposits")
+		fmt.Fprintln(stderr, "from payroll providers and freelance platforms, and recommends a monthly")
+		fmt.Fprintln(stderr, "baseline salary to pay yourself from a variable income.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	window, err := report.ParseMonths(*windowFlag)
+	if err != nil {
+		return err
+	}
+
+	c, err := auth.connect()
+	if err != nil {
+		return err
+	}
+	// The last complete month, and twice the window for rolling averages.
+	month := budget.MonthStart(now().UTC())
+	last := month.AddDate(0, -1, 0)
+	txns, err := fetchTransactions(c, last.AddDate(0, 1-2*window, 0), month.AddDate(0, 0, -1), nil)
+	if err != nil {
+		return fmt.Errorf("fetch transactions: %w", err)
+	}
+	report.WriteIncomeSmoothing(stdout, report.BuildIncomeSmoothing(txns, last, window))
+	return nil
+}
+
+func cmdReportMovers(args []string) error {
+	fs := newFlagSet("report movers")
+	n := fs.Int("n", 5, "Number of holdings and accounts to list in each ranking")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report movers [options]")
+		fmt.Fprintln(stderr, "\nCompares the two most recent snapshots.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *n < 1 {
+		return fmt.Errorf("-n must be at least 1")
+	}
+
+	snaps, err := history.Open(*historyDir).List()
+	if err != nil {
+		return err
+	}
+	if len(snaps) < 2 {
+		return fmt.Errorf("movers needs at least two snapshots in %s (found %d); run \"monarch fetch\" periodically to record them", *historyDir, len(snaps))
+	}
+	prev, cur := snaps[len(snaps)-2], snaps[len(snaps)-1]
+	holdings, accounts := report.Movers(prev, cur, *n, *includeExcluded)
+	report.WriteMovers(stdout, prev.Time, cur.Time, holdings, accounts)
+	return nil
+}
+
+func cmdReportRisk(args []string) error {
+	fs := newFlagSet("report risk")
+	windows := fs.String("windows", "3m,12m,all", "Comma-separated lookback windows, e.g. 90d,12m,2y,all")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report risk [options]")
+		fmt.Fprintln(stderr, "\nVolatility is annualized from the returns between snapshots; record")
+		fmt.Fprintln(stderr, "snapshots at a regular interval for meaningful figures.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	now := now()
+	store := history.Open(*historyDir)
+	reported := 0
+	for i, window := range strings.Split(*windows, ",") {
+		window = strings.TrimSpace(window)
+		from, err := report.ParseRange(window, now)
+		if err != nil {
+			return err
+		}
+		snaps, err := store.Range(from, time.Time{})
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		if len(snaps) < 2 {
+			fmt.Fprintf(stdout, "Risk over %s: needs at least two snapshots (found %d)\n", window, len(snaps))
+			continue
+		}
+		rows, err := report.Risk(snaps, *includeExcluded)
+		if err != nil {
+			return err
+		}
+		report.WriteRisk(stdout, window, snaps[0].Time, snaps[len(snaps)-1].Time, rows)
+		reported++
+	}
+	if reported == 0 {
+		return fmt.Errorf("risk needs at least two snapshots in %s; run \"monarch fetch\" periodically to record them", *historyDir)
+	}
+	return nil
+}
+
+func cmdReportCorrelation(args []string) error {
+	fs := newFlagSet("report correlation")
+	rangeFlag := fs.String("range", "1y", "Lookback window, e.g. 90d, 12m, 2y or all")
+	n := fs.Int("n", 10, "Number of holdings to compare, largest first")
+	csvFile := fs.String("csv", prof.out("correlation.csv"), "Output CSV filename for the matrix, local or s3://, dropbox:, gdrive:, sftp://, davs://")
+	htmlFile := fs.String("html", prof.out("correlation.html"), "Output HTML filename for the heatmap, local or s3://, dropbox:, gdrive:, sftp://, davs://")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report correlation [options]")
+		fmt.Fprintln(stderr, "\nCorrelates the price returns between snapshots; holdings that move")
+		fmt.Fprintln(stderr, "together closely may be redundant.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *n < 2 {
+		return fmt.Errorf("-n must be at least 2")
+	}
+
+	from, err := report.ParseRange(*rangeFlag, now())
+	if err != nil {
+		return err
+	}
+	snaps, err := history.Open(*historyDir).Range(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	cash, err := cashTickers()
+	if err != nil {
+		return err
+	}
+	m, err := report.Correlations(snaps, *n, cash, *includeExcluded)
+	if err != nil {
+		return fmt.Errorf("correlation in %s: %w; run \"monarch fetch\" periodically to record snapshots", *historyDir, err)
+	}
+	first, last := snaps[0].Time.Format(time.DateOnly), snaps[len(snaps)-1].Time.Format(time.DateOnly)
+	fmt.Fprintf(stdout, "Return correlation %s → %s (%d snapshots)\n\n", first, last, len(snaps))
+	report.WriteCorrelation(stdout, m)
+	fmt.Fprintln(stdout)
+
+	var out outputs
+	path, err := out.write(*csvFile, func(w io.Writer) error {
+		return report.WriteCorrelationCSV(w, m)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote correlation matrix to %s\n", path)
+	title := fmt.Sprintf("Return correlation %s to %s", first, last)
+	path, err = out.write(*htmlFile, func(w io.Writer) error {
+		return report.WriteCorrelationHTML(w, title, m)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote heatmap to %s\n", path)
+	return nil
+}
+
+func cmdReportEstate(args []string) error {
+	fs := newFlagSet("report estate")
+	infoPath := fs.String("info", report.DefaultEstatePath, "JSON file with institution contacts and account owners")
+	outFile := fs.String("o", "", "Write the inventory to this file instead of stdout, local or s3://, dropbox:, gdrive:, sftp://, davs://")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	var outFlags outputFlags
+	outFlags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report estate [options]")
+		fmt.Fprintln(stderr, "\nLists every account in the latest snapshot by institution, with the")
+		fmt.Fprintln(stderr, "contacts, owners and beneficiaries from the -info file. Consider -encrypt")
+		fmt.Fprintln(stderr, "when writing the inventory to a file.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	snap, err := latestSnapshot(*historyDir)
+	if err != nil {
+		return err
+	}
+	if len(snap.Accounts) == 0 {
+		return fmt.Errorf("the latest snapshot has no accounts; run \"monarch fetch\" to record them")
+	}
+	info, err := report.LoadEstateInfo(*infoPath)
+	if err != nil {
+		return err
+	}
+	insts := report.Estate(snap, info)
+	if *outFile == "" {
+		report.WriteEstate(stdout, snap.Time, insts, *infoPath)
+		return nil
+	}
+	out, err := outFlags.outputs()
+	if err != nil {
+		return err
+	}
+	path, err := out.write(*outFile, func(w io.Writer) error {
+		report.WriteEstate(w, snap.Time, insts, *infoPath)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote estate inventory to %s\n", path)
+	return nil
+}
+
+// defaultGlideRule is the glide path used when neither -rule nor the
+// config sets one.
+const defaultGlideRule = "110-age"
+
+func cmdReportGlidePath(args []string) error {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	gp := cfg.Events.GlidePath
+	fs := newFlagSet("report glidepath")
+	birthYear := fs.Int("birthyear", gp.BirthYear, "Year of birth the target is based on (default from events.glidePath in the config)")
+	ruleFlag := fs.String("rule", cmp.Or(gp.Rule, defaultGlideRule), "Target equity percentage: N-age, e.g. 110-age, or a fixed N such as 60")
+	tolerance := fs.Float64("tolerance", cmp.Or(gp.Tolerance, report.DefaultDriftTolerance), "Drift from the target, in percentage points, that triggers a warning")
+	notifyFlag := fs.Bool("notify", false, "Also send drift warnings to the notification channels in the config")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch report glidepath -birthyear <year> [options]")
+		fmt.Fprintln(stderr, "\nEquities are all holdings other than cash, sweep funds and bonds typed")
+		fmt.Fprintln(stderr, "fixed income; classify others with holding overrides.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *birthYear <= 0 {
+		fs.Usage()
+		return fmt.Errorf("-birthyear is required")
+	}
+	rule, err := report.ParseGlideRule(*ruleFlag)
+	if err != nil {
+		return err
+	}
+
+	snap, err := latestSnapshot(*historyDir)
+	if err != nil {
+		return err
+	}
+	cash, err := cashTickers()
+	if err != nil {
+		return err
+	}
+	age := now().Year() - *birthYear
+	rows := report.GlidePath(snap, cash, rule.Target(age), *includeExcluded)
+	report.WriteGlidePath(stdout, rule, age, rows, *tolerance)
+
+	if !*notifyFlag {
+		return nil
+	}
+	bus := events.NewBus()
+	for _, url := range cfg.Events.Notify {
+		n, err := notify.Parse(url)
+		if err != nil {
+			return err
+		}
+		bus.Subscribe(events.AllocationDrift, events.Notify(n))
+	}
+	var errs []error
+	for _, r := range rows {
+		if r.Drifted(*tolerance) {
+			p := events.DriftPayload{Rule: rule.String(), Age: age, Group: r.Group, Equity: r.Equity, Target: r.Target}
+			errs = append(errs, bus.Publish(events.AllocationDrift, p))
+		}
+	}
+	return errors.Join(errs...)
+}
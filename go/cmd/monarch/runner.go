@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/i18n"
+)
+
+// The command environment. Commands read and write these instead of the
+// process's streams, clock and files, so that tests can substitute their
+// own. It is package state that runner.run swaps in for a command, so
+// commands run one at a time: tests of them can't use t.Parallel.
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+	now              = time.Now
+	// fsys holds the files commands open themselves.
+	fsys fileSystem = osFS{}
+	// debugLog receives the client's HTTP exchanges when --debug is given.
+	debugLog io.Writer
+	// proxyURL is the proxy given with --proxy, overriding the config.
+	proxyURL string
+	// noCache is set by --no-cache to bypass the response cache.
+	noCache bool
+	// recordDir and replayDir are the cassettes of --record and --replay.
+	recordDir, replayDir string
+	// apiBreaker is the circuit breaker of the run's clients.
+	apiBreaker *client.CircuitBreaker
+	// tr translates messages into the user's language. It is kept after a
+	// run so that main can report the error in the same language.
+	tr i18n.Printer
+)
+
+// runMu serializes runs, which share the package environment.
+var runMu sync.Mutex
+
+// runner runs a command line in an environment.
+type runner struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	now    func() time.Time
+	// ctx cancels the command; nil means it runs to completion.
+	ctx context.Context
+	// fsys is the filesystem of the command's own files; nil means the
+	// OS's.
+	fsys fileSystem
+}
+
+// run parses the global flags, loads the profile and runs the command in
+// args, with the package environment set to r's for its duration. A run
+// waits for any other to finish first.
+func (r runner) run(args []string) error {
+	runMu.Lock()
+	defer runMu.Unlock()
+	saved := runner{stdin, stdout, stderr, now, cmdCtx, fsys}
+	savedDebugLog, savedProxy, savedNoCache := debugLog, proxyURL, noCache
+	savedRecord, savedReplay, savedBreaker := recordDir, replayDir, apiBreaker
+	stdin, stdout, stderr, now = r.stdin, r.stdout, r.stderr, r.now
+	cmdCtx = r.ctx
+	if cmdCtx == nil {
+		cmdCtx = context.Background()
+	}
+	fsys = r.fsys
+	if fsys == nil {
+		fsys = osFS{}
+	}
+	savedProf := prof
+	defer func() {
+		stdin, stdout, stderr, now = saved.stdin, saved.stdout, saved.stderr, saved.now
+		cmdCtx, fsys = saved.ctx, saved.fsys
+		debugLog, proxyURL, noCache = savedDebugLog, savedProxy, savedNoCache
+		recordDir, replayDir, apiBreaker = savedRecord, savedReplay, savedBreaker
+		prof = savedProf
+	}()
+
+	tr, logger = i18n.New(language()), nil
+	global, args, err := splitGlobalFlags(args)
+	if err != nil {
+		return err
+	}
+	if logger, err = newLogger(stderr, global.logFormat, global.logLevel); err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		usage()
+		return errUsage
+	}
+	if prof, err = loadProfile(global.profile); err != nil {
+		return err
+	}
+	if global.sessionFile != "" {
+		prof.session = global.sessionFile
+	}
+	proxyURL, noCache = global.proxy, global.noCache
+	if global.record != "" && global.replay != "" {
+		return fmt.Errorf("--record and --replay can't be combined")
+	}
+	recordDir, replayDir = global.record, global.replay
+	apiBreaker = nil
+	if global.debug {
+		debugLog = stderr
+		if global.debugFile != "" {
+			f, err := fsys.OpenFile(global.debugFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+			if err != nil {
+				return fmt.Errorf("debug log: %w", err)
+			}
+			defer f.Close()
+			debugLog = f
+		}
+	}
+	shutdown, err := setupTracing()
+	if err != nil {
+		return err
+	}
+
+	err = step("monarch "+args[0], func() error { return run(args) })
+	if serr := shutdown(); serr != nil {
+		warnf("export traces: %v", serr)
+	}
+	return err
+}
+
+// language is the configured language, or else the locale's.
+func language() string {
+	if cfg, err := config.Load(config.Path()); err == nil && cfg.Language != "" {
+		return cfg.Language
+	}
+	return i18n.Detect()
+}
+
+// errUsage reports a command line error whose explanation, usually the
+// command's usage, has already been printed.
+var errUsage = errors.New("usage error")
+
+// newFlagSet returns the flag set for a subcommand. Parse errors are
+// returned rather than exiting the process.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseFlags parses args with fs. The flag package has already reported a
+// parse error with the usage, so it is returned as errUsage; -h returns
+// flag.ErrHelp.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errUsage
+	}
+	return err
+}
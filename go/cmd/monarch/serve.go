@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+func serveUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch serve [options]
+       monarch serve key
+
+Serves the recorded snapshots as a read-only JSON API for dashboards:
+
+  GET /api/me        The user and their scope
+  GET /api/accounts  Accounts in the latest snapshot
+  GET /api/holdings  Holdings in the latest snapshot
+  GET /api/networth  Net worth of each snapshot
+
+Each user under "serve.users" in the config sends their API key as
+"Authorization: Bearer <key>" and sees either their own accounts or the
+whole household's. "monarch serve key" creates a key.`)
+}
+
+// Scopes of serve users.
+const (
+	scopeOwn       = "own"
+	scopeHousehold = "household"
+)
+
+func cmdServe(args []string) (err error) {
+	if len(args) > 0 && args[0] == "key" {
+		return cmdServeKey(args[1:])
+	}
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", "localhost:8765", "Address to listen on")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	var profiling profilingFlags
+	profiling.register(fs)
+	fs.Usage = func() {
+		serveUsage()
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	stop, err := profiling.start()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, stop()) }()
+	h, err := newServeHandler(cfg.Serve.Users, history.Open(*historyDir))
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	fmt.Fprintf(stdout, "Serving %d users on http://%s/api/\n", len(cfg.Serve.Users), ln.Addr())
+	select {
+	case err := <-done:
+		return err
+	case <-cmdCtx.Done():
+		return srv.Close()
+	}
+}
+
+func cmdServeKey(args []string) error {
+	fs := newFlagSet("serve key")
+	name := fs.String("name", "", "Name of the user")
+	scope := fs.String("scope", scopeOwn, "Scope of the user: own or household")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch serve key -name <name> [-scope own|household]")
+		fmt.Fprintln(stderr, "\nPrints a new API key and the entry to add to \"serve.users\" in the config.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *name == "" {
+		fs.Usage()
+		return fmt.Errorf("-name is required")
+	}
+	if *scope != scopeOwn && *scope != scopeHousehold {
+		return fmt.Errorf("-scope must be own or household")
+	}
+	apiKey := newAPIKey()
+	user := config.ServeUser{Name: *name, APIKeySHA256: hashAPIKey(apiKey), Scope: *scope}
+	if *scope == scopeOwn {
+		user.Accounts = []string{}
+	}
+	entry, err := json.MarshalIndent(user, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "API key for %s (shown only once): %s\n\nAdd to \"serve.users\" in %s:\n%s\n", *name, apiKey, config.Path(), entry)
+	if *scope == scopeOwn {
+		fmt.Fprintln(stdout, "\nList the names or IDs of the user's accounts under \"accounts\".")
+	}
+	return nil
+}
+
+// newAPIKey returns a random key for "monarch serve" or "monarch proxy".
+func newAPIKey() string {
+	key := make([]byte, 24)
+	rand.Read(key)
+	return "mk_" + hex.EncodeToString(key)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// serveUser is a configured user with their key hash decoded.
+type serveUser struct {
+	config.ServeUser
+	hash []byte
+}
+
+// sees reports whether the user may see the account with the given ID
+// and name.
+func (u serveUser) sees(id, name string) bool {
+	if u.Scope == scopeHousehold {
+		return true
+	}
+	for _, key := range u.Accounts {
+		if key == id || strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the part of snap the user may see.
+func (u serveUser) filter(snap history.Snapshot) history.Snapshot {
+	out := history.Snapshot{Time: snap.Time, Accounts: []portfolio.AccountRecord{}, Holdings: []portfolio.HoldingRecord{}}
+	for _, a := range snap.Accounts {
+		if u.sees(a.ID, a.Name) {
+			out.Accounts = append(out.Accounts, a)
+		}
+	}
+	for _, h := range snap.Holdings {
+		if u.sees(h.AccountID, h.AccountName) {
+			out.Holdings = append(out.Holdings, h)
+		}
+	}
+	return out
+}
+
+// newServeHandler checks users and returns the API serving them the
+// snapshots in store.
+func newServeHandler(cfgUsers []config.ServeUser, store *history.Store) (http.Handler, error) {
+	if len(cfgUsers) == 0 {
+		return nil, fmt.Errorf("no serve.users in %s; add them with \"monarch serve key\"", config.Path())
+	}
+	users := make([]serveUser, len(cfgUsers))
+	for i, u := range cfgUsers {
+		hash, err := hex.DecodeString(u.APIKeySHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("serve user %q: apiKeySHA256 must be a hex SHA-256 hash", u.Name)
+		}
+		if u.Scope == "" {
+			u.Scope = scopeOwn
+		}
+		if u.Scope != scopeOwn && u.Scope != scopeHousehold {
+			return nil, fmt.Errorf("serve user %q: scope must be own or household", u.Name)
+		}
+		users[i] = serveUser{ServeUser: u, hash: hash}
+	}
+
+	// authenticate returns the user whose key the request carries.
+	authenticate := func(r *http.Request) (serveUser, bool) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || key == "" {
+			return serveUser{}, false
+		}
+		sum := sha256.Sum256([]byte(key))
+		for _, u := range users {
+			if subtle.ConstantTimeCompare(sum[:], u.hash) == 1 {
+				return u, true
+			}
+		}
+		return serveUser{}, false
+	}
+	handle := func(fn func(serveUser) (any, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			u, ok := authenticate(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="monarch"`)
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or unknown API key"})
+				return
+			}
+			v, err := fn(u)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, v)
+		}
+	}
+	latest := func(u serveUser) (history.Snapshot, error) {
+		snap, err := latestSnapshot(store.Dir())
+		if err != nil {
+			return history.Snapshot{}, err
+		}
+		return u.filter(snap), nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/me", handle(func(u serveUser) (any, error) {
+		return map[string]string{"name": u.Name, "scope": u.Scope}, nil
+	}))
+	mux.Handle("GET /api/accounts", handle(func(u serveUser) (any, error) {
+		snap, err := latest(u)
+		return map[string]any{"time": snap.Time, "accounts": snap.Accounts}, err
+	}))
+	mux.Handle("GET /api/holdings", handle(func(u serveUser) (any, error) {
+		snap, err := latest(u)
+		return map[string]any{"time": snap.Time, "holdings": snap.Holdings}, err
+	}))
+	mux.Handle("GET /api/networth", handle(func(u serveUser) (any, error) {
+		snaps, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		type point struct {
+			Time     time.Time `json:"time"`
+			NetWorth float64   `json:"net_worth"`
+		}
+		points := make([]point, 0, len(snaps))
+		for _, snap := range snaps {
+			points = append(points, point{snap.Time, u.filter(snap).NetWorth(false)})
+		}
+		return points, nil
+	}))
+	return mux, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
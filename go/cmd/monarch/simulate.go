@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/heikofkoehler/monarch/internal/config"
+
+	"github.com/heikofkoehler/monarch/internal/simulate"
+)
+
+func simulateUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch simulate <simulation> [options]
+
+Simulations:
+  retirement  Monte Carlo odds that the portfolio covers yearly spending
+
+Run "monarch simulate <simulation> -h" for simulation-specific options.`)
+}
+
+func cmdSimulate(args []string) error {
+	if len(args) < 1 {
+		simulateUsage()
+		return fmt.Errorf("missing simulation name")
+	}
+	switch args[0] {
+	case "retirement":
+		return cmdSimulateRetirement(args[1:])
+	case "-h", "--help", "help":
+		simulateUsage()
+		return nil
+	default:
+		simulateUsage()
+		return fmt.Errorf("unknown simulation: %s", args[0])
+	}
+}
+
+func cmdSimulateRetirement(args []string) error {
+	fs := newFlagSet("simulate retirement")
+	spend := fs.Float64("spend", 0, "Yearly spending in today's money (required)")
+	years := fs.Int("years", 30, "Years the money has to last")
+	runs := fs.Int("runs", 10000, "Number of simulated retirements")
+	seed := fs.Uint64("seed", 1, "Random seed; the same seed gives the same result")
+	balance := fs.Float64("balance", 0, "Starting balance, split by the current allocation (default the latest snapshot's holdings)")
+	// Assumptions are given in percent.
+	type classFlags struct{ ret, vol *float64 }
+	assumptions := make(map[string]classFlags, len(simulate.Classes))
+	for _, class := range simulate.Classes {
+		a := simulate.DefaultAssumptions[class]
+		assumptions[class] = classFlags{
+			ret: fs.Float64(class+"-return", math.Round(a.Return*1e4)/100, "Expected real yearly return of "+class+", in percent"),
+			vol: fs.Float64(class+"-volatility", math.Round(a.Volatility*1e4)/100, "Yearly volatility of "+class+", in percent"),
+		}
+	}
+	inflation := fs.Float64("inflation", 2.5, "Yearly inflation devaluing fixed incomes, in percent")
+	noIncome := fs.Bool("no-income", false, "Ignore the income streams in the config file")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	includeExcluded := fs.Bool("include-excluded", false, "Include accounts excluded from net worth in Monarch")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch simulate retirement -spend <amount> [options]")
+		fmt.Fprintln(stderr, "\nStarts from the allocation in the latest snapshot. Returns are after")
+		fmt.Fprintln(stderr, "inflation, so the spending and balances are in today's money. Income")
+		fmt.Fprintln(stderr, "streams such as Social Security or pensions listed under \"income\" in")
+		fmt.Fprintln(stderr, "the config file pay towards the spending.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *spend <= 0 {
+		fs.Usage()
+		return fmt.Errorf("-spend must be positive")
+	}
+
+	snap, err := latestSnapshot(*historyDir)
+	if err != nil {
+		return err
+	}
+	cash, err := cashTickers()
+	if err != nil {
+		return err
+	}
+	alloc := simulate.CurrentAllocation(snap, cash, *includeExcluded)
+	if *balance > 0 && alloc.Total() > 0 {
+		scale := *balance / alloc.Total()
+		for class := range alloc {
+			alloc[class] *= scale
+		}
+	}
+	r := simulate.Retirement{
+		Allocation:  alloc,
+		Assumptions: make(map[string]simulate.Assumption, len(assumptions)),
+		Spend:       *spend,
+		Years:       *years,
+		Runs:        *runs,
+		Seed:        *seed,
+		Year:        now().Year(),
+		Inflation:   *inflation / 100,
+	}
+	if !*noIncome {
+		cfg, err := config.Load(config.Path())
+		if err != nil {
+			return err
+		}
+		for _, inc := range cfg.Income {
+			r.Income = append(r.Income, simulate.Income{
+				Name: inc.Name, Amount: inc.Amount, Start: inc.StartYear, End: inc.EndYear, Indexed: inc.Indexed,
+			})
+		}
+	}
+	for class, f := range assumptions {
+		r.Assumptions[class] = simulate.Assumption{Return: *f.ret / 100, Volatility: *f.vol / 100}
+	}
+	res, err := r.Run()
+	if err != nil {
+		return err
+	}
+	simulate.WriteRetirement(stdout, r, res)
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/broker"
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/events"
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/layout"
+	"github.com/heikofkoehler/monarch/internal/notify"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+// defaultStaleAfterDays is used when the config doesn't set events.staleAfterDays.
+const defaultStaleAfterDays = 7
+
+// sinkOptions selects the file outputs subscribed to the fetch events.
+type sinkOptions struct {
+	csvFile          string
+	transactionsFile string
+	// historyDir is the history store holding the milestones reached.
+	historyDir string
+	layout     layoutFlags
+	out        outputs
+}
+
+// layoutFlags select the column layout of CSV exports.
+type layoutFlags struct {
+	preset       string
+	columns      string
+	pctPrecision int
+}
+
+func (l *layoutFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&l.preset, "preset", "", "CSV layout preset: tiller or lunchmoney")
+	fs.StringVar(&l.columns, "columns", "", "CSV column mapping, e.g. \"Date=date,Payee=merchant,Amount=amount\"")
+	fs.IntVar(&l.pctPrecision, "pct-precision", portfolio.DefaultPctPrecision, "Decimal places of the pct_portfolio and pct_account holding columns")
+}
+
+// percentages recomputes the percentage columns of records at the
+// requested precision.
+func (l *layoutFlags) percentages(records []portfolio.HoldingRecord) error {
+	if l.pctPrecision < 0 || l.pctPrecision > 10 {
+		return fmt.Errorf("-pct-precision must be between 0 and 10")
+	}
+	portfolio.SetPercentages(records, l.pctPrecision)
+	return nil
+}
+
+// layout returns the layout for kind ("transactions" or "holdings"), or nil
+// for the default holdings CSV.
+func (l *layoutFlags) layout(kind string) (layout.Layout, error) {
+	sel, err := layout.Select(l.preset, l.columns, kind)
+	if err != nil {
+		return nil, err
+	}
+	if sel == nil && kind == "transactions" {
+		sel = layout.DefaultTransactions
+	}
+	if sel != nil {
+		if err := sel.Validate(kind); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// writeHoldingsCSV writes records to path in layout l, or the default
+// holdings CSV if l is nil, and returns the path written.
+func writeHoldingsCSV(out outputs, records []portfolio.HoldingRecord, path string, l layout.Layout) (string, error) {
+	return out.write(path, func(w io.Writer) error {
+		if l == nil {
+			return portfolio.EncodeCSV(w, records)
+		}
+		return layout.WriteHoldings(w, l, records)
+	})
+}
+
+// publishedFile, in the profile's directory, holds the IDs of the
+// transactions already published to brokers.
+const publishedFile = "published-transactions.json"
+
+// newBus wires the standard subscribers: file sinks, stale-account and
+// glide path alerts, broker publishing, and configured webhooks.
+func newBus(opts sinkOptions) (*events.Bus, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return nil, err
+	}
+	bus := events.NewBus()
+
+	if opts.csvFile != "" {
+		l, err := opts.layout.layout("holdings")
+		if err != nil {
+			return nil, err
+		}
+		bus.Subscribe(events.HoldingsFetched, csvSink(opts.out, opts.csvFile, l))
+	}
+	if opts.transactionsFile != "" {
+		var l layout.Layout
+		if strings.EqualFold(filepath.Ext(opts.transactionsFile), ".csv") {
+			if l, err = opts.layout.layout("transactions"); err != nil {
+				return nil, err
+			}
+		}
+		bus.Subscribe(events.TransactionsUpdated, transactionsSink(opts.out, opts.transactionsFile, l))
+	}
+
+	staleDays := cfg.Events.StaleAfterDays
+	if staleDays == 0 {
+		staleDays = defaultStaleAfterDays
+	}
+	if staleDays > 0 {
+		bus.Subscribe(events.SnapshotCreated, events.StaleDetector(bus, time.Duration(staleDays)*24*time.Hour))
+		bus.Subscribe(events.AccountStale, events.StaleAlert(stdout))
+	}
+
+	if gp := cfg.Events.GlidePath; gp.BirthYear > 0 {
+		rule, err := report.ParseGlideRule(cmp.Or(gp.Rule, defaultGlideRule))
+		if err != nil {
+			return nil, fmt.Errorf("events.glidePath: %w", err)
+		}
+		cash, err := cashTickers()
+		if err != nil {
+			return nil, err
+		}
+		tolerance := cmp.Or(gp.Tolerance, report.DefaultDriftTolerance)
+		bus.Subscribe(events.SnapshotCreated, events.GlideDetector(bus, rule, gp.BirthYear, cash, tolerance))
+		bus.Subscribe(events.AllocationDrift, events.DriftAlert(stdout))
+	}
+
+	if ms := cfg.Events.Milestones; ms.Every != 0 || len(ms.Targets) > 0 {
+		if ms.Every < 0 {
+			return nil, fmt.Errorf("events.milestones.every: %v is negative", ms.Every)
+		}
+		bus.Subscribe(events.SnapshotCreated, events.MilestoneDetector(bus, history.Open(opts.historyDir), ms.Every, ms.Targets))
+		bus.Subscribe(events.MilestoneReached, events.MilestoneAlert(stdout))
+	}
+
+	if len(cfg.Events.Publish) > 0 {
+		bus.Subscribe(events.SnapshotCreated, events.BalanceDetector(bus))
+		bus.Subscribe(events.TransactionsUpdated, events.TransactionDetector(bus, filepath.Join(prof.baseDir(), publishedFile)))
+	}
+	for _, url := range cfg.Events.Publish {
+		pub, prefix, err := broker.Open(url)
+		if err != nil {
+			return nil, fmt.Errorf("events.publish: %w", err)
+		}
+		h := events.Broker(pub, prefix)
+		bus.Subscribe(events.SnapshotCreated, h)
+		bus.Subscribe(events.BalanceChanged, h)
+		bus.Subscribe(events.TransactionNew, h)
+	}
+	for _, url := range cfg.Events.Webhooks {
+		bus.SubscribeAll(events.Webhook(url))
+	}
+	for _, url := range cfg.Events.Notify {
+		n, err := notify.Parse(url)
+		if err != nil {
+			return nil, err
+		}
+		bus.Subscribe(events.SnapshotCreated, events.Notify(n))
+		bus.Subscribe(events.AccountStale, events.Notify(n))
+		bus.Subscribe(events.AllocationDrift, events.Notify(n))
+		bus.Subscribe(events.MilestoneReached, events.Notify(n))
+	}
+	return bus, nil
+}
+
+// csvSink writes the fetched holdings to path in layout l.
+func csvSink(out outputs, path string, l layout.Layout) events.Handler {
+	return func(e events.Event) error {
+		p := e.Payload.(events.HoldingsPayload)
+		var written string
+		err := step("export csv", func() error {
+			var err error
+			written, err = writeHoldingsCSV(out, p.Holdings, path, l)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("write CSV: %w", err)
+		}
+		fmt.Fprintf(stdout, "Wrote %d holdings to %s\n", len(p.Holdings), written)
+		return nil
+	}
+}
+
+// transactionsSink writes fetched transactions to path as CSV in layout l,
+// or as JSON if l is nil.
+func transactionsSink(out outputs, path string, l layout.Layout) events.Handler {
+	return func(e events.Event) error {
+		p := e.Payload.(events.TransactionsPayload)
+		var written string
+		err := step("export transactions", func() error {
+			var err error
+			written, err = out.write(path, func(w io.Writer) error {
+				if l != nil {
+					return layout.WriteTransactions(w, l, p.Transactions)
+				}
+				data, err := json.MarshalIndent(p.Transactions, "", "    ")
+				if err != nil {
+					return err
+				}
+				_, err = w.Write(data)
+				return err
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("write transactions: %w", err)
+		}
+		fmt.Fprintf(stdout, "Wrote %d transactions to %s\n", len(p.Transactions), written)
+		return nil
+	}
+}
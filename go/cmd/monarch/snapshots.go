@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+func snapshotsUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch snapshots <command> [options]
+
+Commands:
+  list    List recorded snapshots
+  verify  Check snapshots for corruption and tampering
+  import  Record snapshots from JSON dumps of the Python monarchmoney library`)
+}
+
+func cmdSnapshots(args []string) error {
+	if len(args) < 1 {
+		snapshotsUsage()
+		return fmt.Errorf("missing snapshots command")
+	}
+	switch args[0] {
+	case "list":
+		return cmdSnapshotsList(args[1:])
+	case "verify":
+		return cmdSnapshotsVerify(args[1:])
+	case "import":
+		return cmdSnapshotsImport(args[1:])
+	case "-h", "--help", "help":
+		snapshotsUsage()
+		return nil
+	default:
+		snapshotsUsage()
+		return fmt.Errorf("unknown snapshots command: %s", args[0])
+	}
+}
+
+func cmdSnapshotsList(args []string) error {
+	fs := newFlagSet("snapshots list")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	snaps, err := history.Open(*historyDir).List()
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		fmt.Fprintf(stdout, "%s  %3d accounts  %4d holdings  net worth %.2f\n",
+			snap.Time.Local().Format(time.DateTime), len(snap.Accounts), len(snap.Holdings), snap.NetWorth(false))
+	}
+	return nil
+}
+
+func cmdSnapshotsVerify(args []string) error {
+	fs := newFlagSet("snapshots verify")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	adopt := fs.Bool("adopt", false, "Record snapshots missing from the manifest and chain it with the history key (e.g. for snapshots from before either existed)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch snapshots verify [options]")
+		fmt.Fprintln(stderr, "\nChecks every snapshot against the hashes in the history's manifest. The")
+		fmt.Fprintf(stderr, "manifest is chained with a secret key kept in %s, outside the\n", prof.historyKey)
+		fmt.Fprintln(stderr, "history, so that snapshots rewritten along with the manifest are caught by")
+		fmt.Fprintln(stderr, "anyone who can't read the key. Without the key file, only corruption is")
+		fmt.Fprintln(stderr, "detected.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	store, err := openHistory(*historyDir, *adopt)
+	if err != nil {
+		return err
+	}
+	if *adopt {
+		adopted, err := store.Adopt()
+		if err != nil {
+			return err
+		}
+		for _, name := range adopted {
+			fmt.Fprintf(stdout, "Recorded %s\n", name)
+		}
+	}
+
+	problems, err := store.Verify()
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "%s: %s\n", p.File, p.Message)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d integrity problems in %s", len(problems), *historyDir)
+	}
+	fmt.Fprintf(stdout, "All snapshots in %s verified.\n", *historyDir)
+	return nil
+}
+
+func cmdSnapshotsImport(args []string) error {
+	fs := newFlagSet("snapshots import")
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	at := fs.String("time", "", "Time of the snapshot, YYYY-MM-DD or RFC 3339 (default: the date in each file name, else its modification time)")
+	force := fs.Bool("force", false, "Replace snapshots already recorded at the same time")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch snapshots import [options] <file>...")
+		fmt.Fprintln(stderr, "\nEach file holds the JSON of get_accounts, get_portfolio or")
+		fmt.Fprintln(stderr, "get_account_holdings from the Python monarchmoney library. Files of the")
+		fmt.Fprintln(stderr, "same time, such as accounts-2024-06-30.json and portfolio-2024-06-30.json,")
+		fmt.Fprintln(stderr, "are combined into one snapshot.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files to import")
+	}
+	var fixed time.Time
+	if *at != "" {
+		var err error
+		if fixed, err = parseSnapshotTime(*at); err != nil {
+			return fmt.Errorf("-time: %w", err)
+		}
+	}
+
+	snaps := map[time.Time]*history.Snapshot{}
+	for _, path := range fs.Args() {
+		raw, err := fsys.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		accounts, holdings, err := portfolio.ParseDump(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		t := fixed
+		if t.IsZero() {
+			if t, err = dumpTime(path); err != nil {
+				return err
+			}
+		}
+		snap := snaps[t]
+		if snap == nil {
+			snap = &history.Snapshot{Time: t}
+			snaps[t] = snap
+		}
+		snap.Accounts = append(snap.Accounts, accounts...)
+		snap.Holdings = append(snap.Holdings, holdings...)
+	}
+
+	store, err := openHistory(*historyDir, true)
+	if err != nil {
+		return err
+	}
+	existing, err := store.List()
+	if err != nil {
+		return err
+	}
+	recorded := map[time.Time]bool{}
+	for _, snap := range existing {
+		recorded[snap.Time.UTC().Truncate(time.Second)] = true
+	}
+	times := slices.SortedFunc(maps.Keys(snaps), time.Time.Compare)
+	for _, t := range times {
+		snap := snaps[t]
+		if recorded[t.UTC().Truncate(time.Second)] && !*force {
+			fmt.Fprintf(stdout, "Skipped %s: a snapshot is already recorded then (use -force to replace it)\n", t.Format(time.DateTime))
+			continue
+		}
+		if _, err := store.Save(*snap); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Imported %s  %3d accounts  %4d holdings\n", t.Format(time.DateTime), len(snap.Accounts), len(snap.Holdings))
+	}
+	return nil
+}
+
+// dumpDate finds a date such as 2024-06-30 or 20240630 in a file name.
+var dumpDate = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+// dumpTime returns when the dump at path was taken: midnight of the date
+// in its name, or else its modification time.
+func dumpTime(path string) (time.Time, error) {
+	if m := dumpDate.FindStringSubmatch(filepath.Base(path)); m != nil {
+		if t, err := time.ParseInLocation(time.DateOnly, m[1]+"-"+m[2]+"-"+m[3], time.Local); err == nil {
+			return t, nil
+		}
+	}
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime().Truncate(time.Second), nil
+}
+
+// parseSnapshotTime parses a date or an RFC 3339 time.
+func parseSnapshotTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither YYYY-MM-DD nor RFC 3339", s)
+	}
+	return t, nil
+}
+
+// openHistory opens the snapshot history in dir keyed with the profile's
+// history key. Commands that add snapshots pass write, creating the key
+// if there is none yet.
+func openHistory(dir string, write bool) (*history.Store, error) {
+	key, err := history.LoadKey(prof.historyKey, write)
+	if err != nil {
+		return nil, fmt.Errorf("history key: %w", err)
+	}
+	return history.OpenKeyed(dir, key), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+)
+
+func cmdToken(args []string) error {
+	fs := newFlagSet("token")
+	header := fs.Bool("header", false, "Print an Authorization header instead of the bare token")
+	export := fs.Bool("export", false, "Print a shell command setting "+client.TokenEnv)
+	check := fs.Bool("check", false, "Verify the token with Monarch before printing it")
+	email := fs.String("email", "", "Monarch login whose token to print (default the email in the credentials)")
+	sessionStore := fs.String("session-store", "", "Where the session is kept: file, keyring or auto (default from config, else file)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch token [options]")
+		fmt.Fprintln(stderr, "\nPrints the active token (from MONARCH_TOKEN or the saved session) for")
+		fmt.Fprintln(stderr, "use in other tools, e.g.")
+		fmt.Fprintln(stderr, "  curl -H \"$(monarch token -header)\" https://api.monarch.com/graphql ...")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *header && *export {
+		fs.Usage()
+		return fmt.Errorf("-header and -export are mutually exclusive")
+	}
+
+	c, err := savedClient(*sessionStore, *email, "")
+	if err != nil {
+		return err
+	}
+	if *check {
+		_, err := fetchMe(c)
+		if errors.Is(err, client.ErrTokenExpired) {
+			return fmt.Errorf("token is no longer valid; run \"monarch login\"")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case *header:
+		fmt.Fprintf(stdout, "Authorization: Token %s\n", c.Token())
+	case *export:
+		fmt.Fprintf(stdout, "export %s=%s\n", client.TokenEnv, c.Token())
+	default:
+		fmt.Fprintln(stdout, c.Token())
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+)
+
+func totpUsage() {
+	fmt.Fprintln(stderr, `Usage: monarch totp <command>
+
+Commands:
+  store   Save the authenticator secret in the OS keyring (read from stdin)
+  remove  Delete the stored secret from the OS keyring
+  code    Print the current code, to check it matches your authenticator app
+
+The secret can also be set as "totp_secret" in the credentials file or in
+the MONARCH_TOTP_SECRET environment variable.`)
+}
+
+func cmdTOTP(args []string) error {
+	if len(args) < 1 {
+		totpUsage()
+		return fmt.Errorf("missing totp command")
+	}
+	switch args[0] {
+	case "store":
+		if !client.KeyringAvailable() {
+			return fmt.Errorf("no OS keyring available; put totp_secret in %s instead", prof.credentials)
+		}
+		secret := prompt("Authenticator secret: ")
+		if _, err := client.TOTPCode(secret, now()); err != nil {
+			return err
+		}
+		if err := client.NewKeyringStore(prof.totpKeyring).Save([]byte(secret)); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Stored TOTP secret in the OS keyring.")
+		return nil
+	case "remove":
+		if !client.KeyringAvailable() {
+			return fmt.Errorf("no OS keyring available")
+		}
+		return client.NewKeyringStore(prof.totpKeyring).Delete()
+	case "code":
+		creds, err := loadCredentials(prof.credentials, false)
+		if err != nil {
+			return err
+		}
+		if creds.TOTPSecret == "" {
+			return fmt.Errorf("no TOTP secret configured")
+		}
+		code, err := client.TOTPCode(creds.TOTPSecret, now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, code)
+		return nil
+	case "-h", "--help", "help":
+		totpUsage()
+		return nil
+	default:
+		totpUsage()
+		return fmt.Errorf("unknown totp command: %s", args[0])
+	}
+}
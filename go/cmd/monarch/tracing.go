@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/config"
+	"github.com/heikofkoehler/monarch/internal/tracing"
+)
+
+// cmdCtx is the context of the running command: it is cancelled on
+// interrupt and carries the span of the step currently running, so nested
+// steps appear as its children.
+var cmdCtx = context.Background()
+
+// tracingShutdownTimeout bounds how long exiting waits to flush spans.
+const tracingShutdownTimeout = 5 * time.Second
+
+// setupTracing enables OpenTelemetry export if configured. The returned
+// function flushes pending spans.
+func setupTracing() (func() error, error) {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return nil, err
+	}
+	shutdown, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		return shutdown(ctx)
+	}, nil
+}
+
+// step runs fn in a span named name, nested under the running step.
+func step(name string, fn func() error) error {
+	parent := cmdCtx
+	ctx, span := tracing.Start(parent, name)
+	cmdCtx = ctx
+	err := fn()
+	cmdCtx = parent
+	tracing.End(span, err)
+	return err
+}
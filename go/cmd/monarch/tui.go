@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/budget"
+	"github.com/heikofkoehler/monarch/internal/client"
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+	"github.com/heikofkoehler/monarch/internal/tui"
+)
+
+// tuiTransactionDays is how far back the TUI loads investment transactions.
+const tuiTransactionDays = 90
+
+func cmdTUI(args []string) (err error) {
+	fs := newFlagSet("tui")
+	var auth authFlags
+	auth.register(fs)
+	var profiling profilingFlags
+	profiling.register(fs)
+	historyDir := fs.String("history", prof.history, "Snapshot history directory")
+	offline := fs.Bool("offline", false, "Only use recorded snapshots; don't fetch transactions")
+	triageDays := fs.Int("triage-days", 30, "Days of transactions shown in the triage screen")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch tui [options]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	stop, err := profiling.start()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, stop()) }()
+
+	snaps, err := history.Open(*historyDir).List()
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("no snapshots in %s; run \"monarch fetch\" first", *historyDir)
+	}
+
+	var txns []transactions.Transaction
+	var c *client.Client
+	if !*offline {
+		c, err = auth.connect()
+		if err != nil {
+			return err
+		}
+		to := now()
+		txns, err = fetchTransactions(c, to.AddDate(0, 0, -tuiTransactionDays), to, report.InvestmentAccountIDs(snaps, true))
+		if err != nil {
+			return fmt.Errorf("fetch transactions: %w", err)
+		}
+	}
+
+	explorer := &tui.Explorer{Snapshots: snaps, Transactions: txns}
+	home := &tui.List{
+		Heading: "Monarch",
+		Items: []tui.Item{
+			{Label: "Holdings explorer", Open: func() (tui.View, error) { return explorer.AccountsView(), nil }},
+		},
+	}
+	if c != nil {
+		home.Items = append(home.Items, tui.Item{
+			Label: "Transaction triage",
+			Open:  func() (tui.View, error) { return triageView(c, *triageDays) },
+		}, tui.Item{
+			Label: "Budget",
+			Open: func() (tui.View, error) {
+				load := func(month time.Time) (*budget.Data, error) { return fetchBudget(c, month) }
+				return tui.NewBudget(load, apiMutator{c: c}, now())
+			},
+		})
+	}
+	return tui.Run(home)
+}
+
+// triageView loads recent transactions, categories and tags for the triage screen.
+func triageView(c client.API, days int) (tui.View, error) {
+	to := now()
+	txns, err := fetchTransactions(c, to.AddDate(0, 0, -days), to, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch transactions: %w", err)
+	}
+	cats, err := fetchCategories(c)
+	if err != nil {
+		return nil, fmt.Errorf("fetch categories: %w", err)
+	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i].Name < cats[j].Name })
+	tags, err := fetchTags(c)
+	if err != nil {
+		return nil, fmt.Errorf("fetch tags: %w", err)
+	}
+	return &tui.Triage{
+		Transactions: txns,
+		Categories:   cats,
+		Tags:         tags,
+		Mutator:      apiMutator{c: c},
+	}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+func cmdValidate(args []string) error {
+	fs := newFlagSet("validate")
+	inFile := fs.String("i", prof.out("portfolio.json"), "Portfolio JSON file the CSV was written from")
+	overridesPath := fs.String("overrides", portfolio.DefaultOverridesPath, "Security type overrides JSON file")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch validate [options] [portfolio_holdings.csv]")
+		fmt.Fprintln(stderr, "\nReads back a holdings CSV written by \"monarch parse\" and compares it with")
+		fmt.Fprintln(stderr, "the portfolio JSON: row counts, value totals, and each field for numbers")
+		fmt.Fprintln(stderr, "that lost precision and text that was truncated, e.g. by a spreadsheet.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	path := prof.out("portfolio_holdings.csv")
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+
+	source, err := loadHoldings(*inFile, *overridesPath)
+	if err != nil {
+		return err
+	}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	exported, err := portfolio.DecodeCSV(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var sourceTotal, exportedTotal float64
+	for _, r := range source {
+		sourceTotal += r.Value
+	}
+	for _, r := range exported {
+		exportedTotal += r.Value
+	}
+	fmt.Fprintf(stdout, "Validated %s against %s\n\n", path, *inFile)
+	fmt.Fprintf(stdout, "  rows         %d of %d\n", len(exported), len(source))
+	fmt.Fprintf(stdout, "  total value  %.2f of %.2f\n\n", exportedTotal, sourceTotal)
+
+	problems := portfolio.RoundTrip(source, exported)
+	if len(problems) == 0 {
+		fmt.Fprintln(stdout, "No lossy conversions found.")
+		return nil
+	}
+	var rows [][]string
+	for _, p := range problems {
+		rows = append(rows, []string{p.Holding, p.Field, p.Source, p.Exported, p.Problem})
+	}
+	report.WriteTable(stdout, []string{"holding", "field", "source", "csv", "problem"}, rows)
+	return fmt.Errorf("%d problems in %s", len(problems), path)
+}
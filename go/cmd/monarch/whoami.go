@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+)
+
+func cmdWhoami(args []string) error {
+	fs := newFlagSet("whoami")
+	token := fs.String("token", "", "Check this token instead of the saved session (also MONARCH_TOKEN)")
+	email := fs.String("email", "", "Monarch login whose session to check (default the email in the credentials)")
+	sessionStore := fs.String("session-store", "", "Where the session is kept: file, keyring or auto (default from config, else file)")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: monarch whoami [options]")
+		fmt.Fprintln(stderr, "\nChecks the saved session without logging in. Exits non-zero if it is")
+		fmt.Fprintln(stderr, "missing or no longer valid.")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := savedClient(*sessionStore, *email, *token)
+	if err != nil {
+		return err
+	}
+
+	m, err := fetchMe(c)
+	if errors.Is(err, client.ErrTokenExpired) {
+		return fmt.Errorf("session is no longer valid; run \"monarch login\"")
+	}
+	if err != nil {
+		return err
+	}
+
+	quota, hasQuota := c.Quota()
+	if *asJSON {
+		result := map[string]any{
+			"valid":     true,
+			"profile":   prof.name,
+			"email":     m.User.Email,
+			"name":      m.User.Name,
+			"household": m.Household.Name,
+			"mfa":       m.User.HasMFAOn,
+			"premium":   m.Subscription.HasPremiumEntitlement,
+			"trial":     m.Subscription.IsOnFreeTrial,
+		}
+		if hasQuota {
+			q := map[string]any{"remaining": quota.Remaining}
+			if quota.Limit >= 0 {
+				q["limit"] = quota.Limit
+			}
+			if !quota.Reset.IsZero() {
+				q["reset"] = quota.Reset.UTC()
+			}
+			result["quota"] = q
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	tr.Fprintf(stdout, "Logged in as %s (%s)\n", m.User.Name, m.User.Email)
+	if m.Household.Name != "" {
+		tr.Fprintf(stdout, "Household:    %s\n", m.Household.Name)
+	}
+	plan := "free"
+	switch {
+	case m.Subscription.IsOnFreeTrial:
+		plan = "trial"
+	case m.Subscription.HasPremiumEntitlement:
+		plan = "premium"
+	}
+	tr.Fprintf(stdout, "Subscription: %s\n", plan)
+	tr.Fprintf(stdout, "MFA enabled:  %t\n", m.User.HasMFAOn)
+	tr.Fprintln(stdout, "Token:        valid")
+	if hasQuota {
+		tr.Fprintf(stdout, "API quota:    %d requests left\n", quota.Remaining)
+		if !quota.Reset.IsZero() {
+			tr.Fprintf(stdout, "Quota resets: %s\n", quota.Reset.Local().Format(time.DateTime))
+		}
+	}
+	return nil
+}
+
+// savedClient returns a client with the token to use without logging in:
+// token if set, else the one in MONARCH_TOKEN or the saved session of the
+// login email. It never prompts.
+func savedClient(sessionStore, email, token string) (*client.Client, error) {
+	c, err := newClient(sessionStore, sessionEmail(email, prof.credentials))
+	if err != nil {
+		return nil, err
+	}
+	c.SetNonInteractive(true)
+	if token != "" {
+		c.SetToken(token)
+	} else if c.Token() == "" {
+		loaded, err := c.LoadSession(cmdCtx)
+		if err != nil {
+			return nil, fmt.Errorf("load session: %w", err)
+		}
+		if !loaded {
+			return nil, fmt.Errorf("not logged in; run \"monarch login\"")
+		}
+	}
+	return c, nil
+}
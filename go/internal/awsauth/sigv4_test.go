@@ -0,0 +1,94 @@
+package awsauth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignSuite signs requests from the AWS Signature Version 4 test suite
+// and compares the signatures with the suite's.
+func TestSignSuite(t *testing.T) {
+	creds := Credentials{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name, method, url string
+		contentType, body string
+		sessionToken      string
+		signedHeaders     string
+		signature         string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-query-order-key", "GET", "https://example.amazonaws.com/?Param1=value2&Param1=Value1", "", "", "",
+			"host;x-amz-date", "eedbc4e291e521cf13422ffca22be7d2eb8146eecf653089df300a15b2382bd1"},
+		{"get-vanilla-query-order-value", "GET", "https://example.amazonaws.com/?Param1=value2&Param1=value1", "", "", "",
+			"host;x-amz-date", "5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		{"get-vanilla-query-unreserved", "GET",
+			"https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+			"", "", "", "host;x-amz-date", "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197"},
+		{"get-vanilla-utf8-query", "GET", "https://example.amazonaws.com/?ሴ=bar", "", "", "",
+			"host;x-amz-date", "2cdec8eed098649ff3a119c94853b13c643bcf08f8b0a1d91e12c9027818dd04"},
+		{"get-vanilla-empty-query-key", "GET", "https://example.amazonaws.com/?Param1=value1", "", "", "",
+			"host;x-amz-date", "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "", "", "",
+			"host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-vanilla-query", "POST", "https://example.amazonaws.com/?Param1=value1", "", "", "",
+			"host;x-amz-date", "28038455d6de14eafc1f9222cf5aa6f1a96197d7deb8263271d420d138af7f11"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+		{"post-sts-header-before", "POST", "https://example.amazonaws.com/", "", "",
+			"AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA==",
+			"host;x-amz-date;x-amz-security-token", "85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead"},
+	} {
+		req, err := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		c := creds
+		c.SessionToken = tc.sessionToken
+		c.Sign(req, []byte(tc.body), "service", now)
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" +
+			tc.signedHeaders + ", Signature=" + tc.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: got %s\nwant %s", tc.name, got, want)
+		}
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, service, want string
+	}{
+		{"", "s3", "/"},
+		{"/exports/2025 Q1/a+b (1).csv", "s3", "/exports/2025%20Q1/a%2Bb%20%281%29.csv"},
+		{"/ሴ", "s3", "/%E1%88%B4"},
+		{"/a b", "secretsmanager", "/a%2520b"},
+	} {
+		if got := canonicalPath(&url.URL{Path: tc.path}, tc.service); got != tc.want {
+			t.Errorf("%s %q: got %s, want %s", tc.service, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                       "",
+		"b=2&a=1":                "a=1&b=2",
+		"a-b=2&a=1":              "a=1&a-b=2",
+		"uploads":                "uploads=",
+		"prefix=a%20b&delim=%2F": "delim=%2F&prefix=a%20b",
+		"q=a+b":                  "q=a%20b",
+	} {
+		if got := canonicalQuery(raw); got != want {
+			t.Errorf("%q: got %s, want %s", raw, got, want)
+		}
+	}
+}
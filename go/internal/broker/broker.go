@@ -0,0 +1,74 @@
+// Package broker publishes messages to Kafka or NATS, chosen by URL:
+// "kafka://host:9092/prefix" or "nats://[user:pass@]host:4222/prefix"
+// ("tls://" for NATS over TLS). Kafka is spoken in plaintext without
+// authentication only, for a broker on the same machine or a trusted
+// network. Each Publish connects, sends its messages and disconnects,
+// which suits a command run on a schedule.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Message is sent to Topic, a Kafka topic or NATS subject. Key selects the
+// Kafka partition; NATS ignores it.
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+}
+
+// Publisher sends messages to a broker.
+type Publisher interface {
+	Publish(ctx context.Context, msgs []Message) error
+}
+
+// DefaultPrefix starts topic names when the URL has no path.
+const DefaultPrefix = "monarch"
+
+// timeout bounds each network exchange with a broker.
+const timeout = 10 * time.Second
+
+// Open returns the publisher for rawURL and the topic prefix in its path.
+func Open(rawURL string) (Publisher, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("%s: want kafka://host:port/prefix or nats://host:port/prefix", u.Redacted())
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	switch u.Scheme {
+	case "kafka":
+		if u.User != nil || u.RawQuery != "" {
+			return nil, "", fmt.Errorf("%s: Kafka credentials and options aren't supported; only plaintext brokers without authentication are", u.Redacted())
+		}
+		return &kafka{bootstrap: hostPort(u, "9092")}, prefix, nil
+	case "kafkas", "kafka+ssl", "kafka+tls", "kafka+sasl":
+		return nil, "", fmt.Errorf("%s: Kafka over TLS or SASL isn't supported, only plaintext kafka:// on the same machine or a trusted network; use tls:// with NATS for an encrypted connection", u.Redacted())
+	case "nats", "tls":
+		n := &nats{addr: hostPort(u, "4222"), host: u.Hostname(), tls: u.Scheme == "tls"}
+		if pass, ok := u.User.Password(); ok {
+			n.user, n.pass = u.User.Username(), pass
+		} else if u.User != nil {
+			n.token = u.User.Username()
+		}
+		return n, prefix, nil
+	}
+	return nil, "", fmt.Errorf("%s: unknown broker scheme %q, want kafka, nats or tls", u.Redacted(), u.Scheme)
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return u.Host + ":" + port
+}
@@ -0,0 +1,447 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// kafka produces to a Kafka cluster with the plain binary protocol:
+// Metadata (version 4) finds the leader of each partition, and Produce
+// (version 3, the oldest Kafka 4 accepts) sends an uncompressed record
+// batch to each leader, waiting for the leader's acknowledgment. Topics
+// are created on first use if the brokers allow it.
+//
+// Only plaintext listeners without authentication are supported, not TLS
+// or SASL: messages, balances included, cross the network unencrypted to
+// any broker that answers. It is meant for a broker on the same machine
+// or a trusted network.
+type kafka struct {
+	bootstrap string
+}
+
+// Kafka API keys and the versions used.
+const (
+	apiProduce  = 0
+	apiMetadata = 3
+
+	produceVersion  = 3
+	metadataVersion = 4
+)
+
+// errLeaderNotAvailable is returned while a new topic is being created.
+const errLeaderNotAvailable = 5
+
+const clientID = "monarch"
+
+// errNotPlaintext explains the answers of a listener that expects TLS,
+// which replies with an alert, or SASL, which hangs up.
+var errNotPlaintext = errors.New("the broker closed the connection or sent no Kafka response; only plaintext listeners without authentication are supported, not TLS or SASL")
+
+// kafkaError is an error code in a Kafka response.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	switch e {
+	case 3:
+		return "unknown topic or partition"
+	case errLeaderNotAvailable:
+		return "leader not available"
+	case 6:
+		return "not leader for partition"
+	case 7:
+		return "request timed out"
+	case 10:
+		return "message too large"
+	case 17:
+		return "invalid topic"
+	case 29:
+		return "topic authorization failed"
+	}
+	return "error code " + strconv.Itoa(int(e))
+}
+
+// partitions maps a topic to the leaders of its partitions, by index.
+type partitions map[string][]string
+
+func (k *kafka) Publish(ctx context.Context, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	var topics []string
+	seen := map[string]bool{}
+	for _, m := range msgs {
+		if !seen[m.Topic] {
+			seen[m.Topic] = true
+			topics = append(topics, m.Topic)
+		}
+	}
+	leaders, err := k.metadata(ctx, topics)
+	if err != nil {
+		return fmt.Errorf("kafka %s: %w", k.bootstrap, err)
+	}
+
+	// Group the messages by leader, topic and partition, keeping their order.
+	type target struct {
+		topic     string
+		partition int32
+	}
+	batches := map[string]map[target][]Message{}
+	var order []string
+	for _, m := range msgs {
+		parts := leaders[m.Topic]
+		p := int32(partition(m.Key, len(parts)))
+		leader := parts[p]
+		if batches[leader] == nil {
+			batches[leader] = map[target][]Message{}
+			order = append(order, leader)
+		}
+		t := target{m.Topic, p}
+		batches[leader][t] = append(batches[leader][t], m)
+	}
+	var errs []error
+	for _, leader := range order {
+		var req produceRequest
+		for t, msgs := range batches[leader] {
+			req.add(t.topic, t.partition, msgs)
+		}
+		if err := k.produce(ctx, leader, req); err != nil {
+			errs = append(errs, fmt.Errorf("kafka %s: %w", leader, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// metadata returns the partition leaders of topics, waiting briefly for
+// topics that are being created.
+func (k *kafka) metadata(ctx context.Context, topics []string) (partitions, error) {
+	for attempt := 0; ; attempt++ {
+		var b encoder
+		b.int32(int32(len(topics)))
+		for _, t := range topics {
+			b.string(t)
+		}
+		b.int8(1) // allow_auto_topic_creation
+		resp, err := k.roundTrip(ctx, k.bootstrap, apiMetadata, metadataVersion, b.buf)
+		if err != nil {
+			return nil, err
+		}
+		leaders, err := parseMetadata(resp)
+		var kerr kafkaError
+		if errors.As(err, &kerr) && kerr == errLeaderNotAvailable && attempt < 5 {
+			select {
+			case <-time.After(500 * time.Millisecond):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return leaders, err
+	}
+}
+
+func parseMetadata(resp []byte) (partitions, error) {
+	d := decoder{buf: resp}
+	d.int32() // throttle_time_ms
+	brokers := map[int32]string{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id, host, port := d.int32(), d.string(), d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster_id
+	d.int32()  // controller_id
+	leaders := partitions{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code, name := kafkaError(d.int16()), d.string()
+		d.int8() // is_internal
+		if code != 0 {
+			return nil, fmt.Errorf("topic %s: %w", name, code)
+		}
+		count := d.int32()
+		if count < 0 || count > 1<<16 {
+			return nil, fmt.Errorf("topic %s: invalid partition count %d", name, count)
+		}
+		parts := make([]string, count)
+		for i := int32(0); i < count; i++ {
+			code, index, leader := kafkaError(d.int16()), d.int32(), d.int32()
+			d.int32s() // replica_nodes
+			d.int32s() // isr_nodes
+			if code != 0 && code != errLeaderNotAvailable {
+				return nil, fmt.Errorf("topic %s partition %d: %w", name, index, code)
+			}
+			addr, ok := brokers[leader]
+			if code == errLeaderNotAvailable || !ok {
+				return nil, fmt.Errorf("topic %s partition %d: %w", name, index, kafkaError(errLeaderNotAvailable))
+			}
+			if index < 0 || index >= count {
+				return nil, fmt.Errorf("topic %s: partition %d of %d", name, index, count)
+			}
+			parts[index] = addr
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("topic %s: %w", name, kafkaError(errLeaderNotAvailable))
+		}
+		leaders[name] = parts
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", d.err)
+	}
+	return leaders, nil
+}
+
+// produceRequest is the body of a Produce request.
+type produceRequest struct {
+	topics []string
+	parts  map[string][]encoder // partition index and record batch
+}
+
+func (r *produceRequest) add(topic string, partition int32, msgs []Message) {
+	if r.parts == nil {
+		r.parts = map[string][]encoder{}
+	}
+	if _, ok := r.parts[topic]; !ok {
+		r.topics = append(r.topics, topic)
+	}
+	var p encoder
+	p.int32(partition)
+	p.bytes(recordBatch(msgs, time.Now()))
+	r.parts[topic] = append(r.parts[topic], p)
+}
+
+func (r produceRequest) encode() []byte {
+	var b encoder
+	b.int16(-1) // transactional_id
+	b.int16(1)  // acks: the leader
+	b.int32(int32(timeout / time.Millisecond))
+	b.int32(int32(len(r.topics)))
+	for _, t := range r.topics {
+		b.string(t)
+		b.int32(int32(len(r.parts[t])))
+		for _, p := range r.parts[t] {
+			b.buf = append(b.buf, p.buf...)
+		}
+	}
+	return b.buf
+}
+
+func (k *kafka) produce(ctx context.Context, leader string, req produceRequest) error {
+	resp, err := k.roundTrip(ctx, leader, apiProduce, produceVersion, req.encode())
+	if err != nil {
+		return err
+	}
+	d := decoder{buf: resp}
+	var errs []error
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		name := d.string()
+		for n := d.int32(); n > 0 && d.err == nil; n-- {
+			index, code := d.int32(), kafkaError(d.int16())
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+			if code != 0 && d.err == nil {
+				errs = append(errs, fmt.Errorf("topic %s partition %d: %w", name, index, code))
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("decode produce response: %w", d.err)
+	}
+	return errors.Join(errs...)
+}
+
+// recordBatch encodes msgs as a record batch (magic 2) without
+// compression or idempotence.
+func recordBatch(msgs []Message, t time.Time) []byte {
+	ts := t.UnixMilli()
+	var records []byte
+	for i, m := range msgs {
+		var r []byte
+		r = append(r, 0)                     // attributes
+		r = binary.AppendVarint(r, 0)        // timestamp delta
+		r = binary.AppendVarint(r, int64(i)) // offset delta
+		if m.Key == nil {
+			r = binary.AppendVarint(r, -1)
+		} else {
+			r = binary.AppendVarint(r, int64(len(m.Key)))
+			r = append(r, m.Key...)
+		}
+		r = binary.AppendVarint(r, int64(len(m.Value)))
+		r = append(r, m.Value...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// The CRC covers everything from the attributes on.
+	var tail encoder
+	tail.int16(0) // attributes
+	tail.int32(int32(len(msgs) - 1))
+	tail.int64(ts)
+	tail.int64(ts)
+	tail.int64(-1) // producer_id
+	tail.int16(-1) // producer_epoch
+	tail.int32(-1) // base_sequence
+	tail.int32(int32(len(msgs)))
+	tail.buf = append(tail.buf, records...)
+
+	var b encoder
+	b.int64(0)                                // base_offset
+	b.int32(int32(4 + 1 + 4 + len(tail.buf))) // batch_length
+	b.int32(-1)                               // partition_leader_epoch
+	b.int8(2)                                 // magic
+	b.buf = binary.BigEndian.AppendUint32(b.buf, crc32.Checksum(tail.buf, crc32.MakeTable(crc32.Castagnoli)))
+	b.buf = append(b.buf, tail.buf...)
+	return b.buf
+}
+
+// partition picks the partition for key as Kafka's default partitioner
+// does, so that messages with the same key land where other producers
+// would put them. Messages without a key go to partition 0.
+func partition(key []byte, n int) int {
+	if key == nil || n <= 1 {
+		return 0
+	}
+	return int(murmur2(key)&0x7fffffff) % n
+}
+
+// murmur2 is the hash of Kafka's default partitioner.
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+	)
+	h := uint32(seed) ^ uint32(len(data))
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+		data = data[4:]
+	}
+	switch len(data) {
+	case 3:
+		h ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// roundTrip sends one request to addr and returns the response body after
+// the correlation ID.
+func (k *kafka) roundTrip(ctx context.Context, addr string, api, version int16, body []byte) ([]byte, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout + 5*time.Second))
+
+	const correlationID = 1
+	var req encoder
+	req.int32(0) // size, set below
+	req.int16(api)
+	req.int16(version)
+	req.int32(correlationID)
+	req.string(clientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+	if _, err := conn.Write(req.buf); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %v", errNotPlaintext, err)
+		}
+		return nil, err
+	}
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("%w: invalid response size %d", errNotPlaintext, size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(r, resp); err != nil {
+		return nil, err
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != correlationID {
+		return nil, fmt.Errorf("response to request %d, want %d", id, correlationID)
+	}
+	return resp[4:], nil
+}
+
+// encoder appends Kafka's big-endian primitive types.
+type encoder struct{ buf []byte }
+
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder reads Kafka's primitive types, remembering the first error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, max(n, 0))
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8   { return int8(d.next(1)[0]) }
+func (d *decoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.next(2))) }
+func (d *decoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.next(4))) }
+func (d *decoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.next(8))) }
+
+// string reads a string, or a null string as "".
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) int32s() {
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.int32()
+	}
+}
@@ -0,0 +1,372 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRecordBatch compares an encoded record batch with the bytes
+// segmentio/kafka-go encodes for the same records.
+func TestRecordBatch(t *testing.T) {
+	msgs := []Message{
+		{Key: []byte("acc-1"), Value: []byte(`{"a":1}`)},
+		{Value: []byte(`{}`)},
+	}
+	got := recordBatch(msgs, time.UnixMilli(1743508800000))
+	want := "00000000000000000000004dffffffff02c9b4ab2400000000000100000195f139020000000195f1390200" +
+		"ffffffffffffffffffffffffffff00000002240000000a6163632d310e7b2261223a317d001000000201047b7d00"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("got  %x\nwant %s", got, want)
+	}
+}
+
+// TestPartition checks partitions against those of Kafka's default
+// partitioner, as computed by segmentio/kafka-go's Murmur2Balancer.
+func TestPartition(t *testing.T) {
+	for _, tc := range []struct {
+		key  string
+		want [3]int // for 3, 7 and 100 partitions
+	}{
+		{"21", [3]int{0, 3, 40}},
+		{"foobar", [3]int{0, 0, 66}},
+		{"a-little-bit-long-string", [3]int{2, 1, 12}},
+		{"a-little-bit-longer-string", [3]int{2, 0, 19}},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", [3]int{2, 3, 77}},
+		{"abc", [3]int{0, 4, 7}},
+		{"acc-brk", [3]int{0, 5, 0}},
+		{"2025-04-01T12:00:00Z", [3]int{2, 4, 54}},
+	} {
+		for i, n := range []int{3, 7, 100} {
+			if got := partition([]byte(tc.key), n); got != tc.want[i] {
+				t.Errorf("%q of %d: got %d, want %d", tc.key, n, got, tc.want[i])
+			}
+		}
+	}
+	if got := partition(nil, 7); got != 0 {
+		t.Errorf("nil key: got %d, want 0", got)
+	}
+}
+
+// produced is a record as a fake broker received it.
+type produced struct {
+	broker     int
+	topic      string
+	partition  int32
+	key, value string
+}
+
+// fakeKafka is a cluster of plaintext brokers answering Metadata v4 and
+// Produce v3 requests. Partition p of every topic is led by broker
+// p % len(brokers).
+type fakeKafka struct {
+	brokers    []net.Listener
+	partitions int32
+
+	mu sync.Mutex
+	// topicErrors are the topic error codes of the next Metadata responses.
+	topicErrors  []int16
+	produceError int16
+	requests     []string
+	records      []produced
+}
+
+func newFakeKafka(t *testing.T, brokers int, partitions int32) *fakeKafka {
+	t.Helper()
+	k := &fakeKafka{partitions: partitions}
+	for id := range brokers {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		k.brokers = append(k.brokers, ln)
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go k.serve(t, id, conn)
+			}
+		}()
+	}
+	return k
+}
+
+func (k *fakeKafka) bootstrap() string { return k.brokers[0].Addr().String() }
+
+func (k *fakeKafka) serve(t *testing.T, broker int, conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := decoder{buf: req}
+		api, version, correlationID, client := d.int16(), d.int16(), d.int32(), d.string()
+		if client != clientID {
+			t.Errorf("got client ID %q", client)
+		}
+		var resp encoder
+		resp.int32(correlationID)
+		k.mu.Lock()
+		k.requests = append(k.requests, fmt.Sprintf("%d:%d v%d", broker, api, version))
+		switch {
+		case api == apiMetadata && version == metadataVersion:
+			k.metadata(&d, &resp)
+		case api == apiProduce && version == produceVersion:
+			k.produce(t, broker, &d, &resp)
+		default:
+			t.Errorf("unexpected request: API %d version %d", api, version)
+		}
+		k.mu.Unlock()
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(resp.buf)))
+		if _, err := conn.Write(append(out, resp.buf...)); err != nil {
+			return
+		}
+	}
+}
+
+func (k *fakeKafka) metadata(d *decoder, resp *encoder) {
+	var topics []string
+	for n := d.int32(); n > 0; n-- {
+		topics = append(topics, d.string())
+	}
+	var topicError int16
+	if len(k.topicErrors) > 0 {
+		topicError, k.topicErrors = k.topicErrors[0], k.topicErrors[1:]
+	}
+
+	resp.int32(0) // throttle_time_ms
+	resp.int32(int32(len(k.brokers)))
+	for id, ln := range k.brokers {
+		host, port, _ := net.SplitHostPort(ln.Addr().String())
+		p, _ := strconv.Atoi(port)
+		resp.int32(int32(id))
+		resp.string(host)
+		resp.int32(int32(p))
+		resp.int16(-1) // rack
+	}
+	resp.string("cluster")
+	resp.int32(0) // controller_id
+	resp.int32(int32(len(topics)))
+	for _, topic := range topics {
+		resp.int16(topicError)
+		resp.string(topic)
+		resp.int8(0)
+		if topicError != 0 {
+			resp.int32(0)
+			continue
+		}
+		resp.int32(k.partitions)
+		for p := range k.partitions {
+			resp.int16(0)
+			resp.int32(p)
+			resp.int32(p % int32(len(k.brokers))) // leader
+			resp.int32(0)                         // replica_nodes
+			resp.int32(0)                         // isr_nodes
+		}
+	}
+}
+
+func (k *fakeKafka) produce(t *testing.T, broker int, d *decoder, resp *encoder) {
+	if id := d.int16(); id != -1 {
+		t.Errorf("got transactional ID length %d, want null", id)
+	}
+	if acks := d.int16(); acks != 1 {
+		t.Errorf("got acks %d, want 1", acks)
+	}
+	d.int32() // timeout_ms
+	topics := d.int32()
+	resp.int32(topics)
+	for range topics {
+		topic := d.string()
+		resp.string(topic)
+		parts := d.int32()
+		resp.int32(parts)
+		for range parts {
+			p := d.int32()
+			batch := d.next(int(d.int32()))
+			code := k.produceError
+			if err := k.decodeBatch(broker, topic, p, batch); err != nil {
+				t.Errorf("topic %s partition %d: %v", topic, p, err)
+				code = 2 // corrupt message
+			}
+			if int(p) >= int(k.partitions) || int(p)%len(k.brokers) != broker {
+				t.Errorf("topic %s partition %d sent to broker %d", topic, p, broker)
+				code = 6 // not leader
+			}
+			resp.int32(p)
+			resp.int16(code)
+			resp.int64(0)  // base_offset
+			resp.int64(-1) // log_append_time_ms
+		}
+	}
+	resp.int32(0) // throttle_time_ms
+	if d.err != nil || len(d.buf) > 0 {
+		t.Errorf("decode produce request: %v, %d bytes left", d.err, len(d.buf))
+	}
+}
+
+// decodeBatch checks a record batch's length, magic byte and CRC, then
+// records its records.
+func (k *fakeKafka) decodeBatch(broker int, topic string, partition int32, batch []byte) error {
+	d := decoder{buf: batch}
+	d.int64() // base_offset
+	if n := d.int32(); int(n) != len(batch)-12 {
+		return fmt.Errorf("batch length %d, want %d", n, len(batch)-12)
+	}
+	d.int32() // partition_leader_epoch
+	if magic := d.int8(); magic != 2 {
+		return fmt.Errorf("magic %d", magic)
+	}
+	crc := uint32(d.int32())
+	if want := crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)); crc != want {
+		return fmt.Errorf("CRC %08x, want %08x", crc, want)
+	}
+	if attrs := d.int16(); attrs != 0 {
+		return fmt.Errorf("attributes %d", attrs)
+	}
+	lastOffsetDelta := d.int32()
+	d.int64() // first_timestamp
+	d.int64() // max_timestamp
+	d.next(8 + 2 + 4)
+	count := d.int32()
+	if lastOffsetDelta != count-1 {
+		return fmt.Errorf("last offset delta %d of %d records", lastOffsetDelta, count)
+	}
+	varint := func() int64 {
+		v, n := binary.Varint(d.buf)
+		d.next(max(n, 1))
+		return v
+	}
+	bytesField := func() (string, bool) {
+		n := varint()
+		if n < 0 {
+			return "", false
+		}
+		return string(d.next(int(n))), true
+	}
+	for i := range count {
+		length := int(varint())
+		end := len(d.buf) - length
+		d.int8() // attributes
+		varint() // timestamp_delta
+		if off := varint(); off != int64(i) {
+			return fmt.Errorf("record %d has offset delta %d", i, off)
+		}
+		key, _ := bytesField()
+		value, _ := bytesField()
+		if headers := varint(); headers != 0 {
+			return fmt.Errorf("record %d has %d headers", i, headers)
+		}
+		if len(d.buf) != end {
+			return fmt.Errorf("record %d length is off by %d", i, len(d.buf)-end)
+		}
+		k.records = append(k.records, produced{broker, topic, partition, key, value})
+	}
+	if d.err != nil || len(d.buf) > 0 {
+		return fmt.Errorf("decode records: %v, %d bytes left", d.err, len(d.buf))
+	}
+	return nil
+}
+
+func TestKafkaPublish(t *testing.T) {
+	cluster := newFakeKafka(t, 2, 7)
+	k := &kafka{bootstrap: cluster.bootstrap()}
+	var msgs []Message
+	var want []produced
+	// Partitions of 7 from TestPartition.
+	for i, tc := range []struct {
+		key       string
+		partition int32
+	}{
+		{"21", 3}, {"foobar", 0}, {"a-little-bit-long-string", 1}, {"abc", 4},
+		{"acc-brk", 5}, {"2025-04-01T12:00:00Z", 4}, {"21", 3},
+	} {
+		topic := "monarch.balance.changed"
+		if i%2 == 1 {
+			topic = "monarch.transaction.new"
+		}
+		value := fmt.Sprintf(`{"n":%d}`, i)
+		msgs = append(msgs, Message{Topic: topic, Key: []byte(tc.key), Value: []byte(value)})
+		want = append(want, produced{int(tc.partition % 2), topic, tc.partition, tc.key, value})
+	}
+	msgs = append(msgs, Message{Topic: "monarch.snapshot.complete", Value: []byte("{}")})
+	want = append(want, produced{0, "monarch.snapshot.complete", 0, "", "{}"})
+
+	if err := k.Publish(context.Background(), msgs); err != nil {
+		t.Fatal(err)
+	}
+	cluster.mu.Lock()
+	defer cluster.mu.Unlock()
+	// Batches may be sent in any order, but each keeps its messages' order.
+	for _, w := range want {
+		i := -1
+		for j, r := range cluster.records {
+			if r.topic == w.topic && r.partition == w.partition {
+				i = j
+				break
+			}
+		}
+		if i < 0 || cluster.records[i] != w {
+			t.Errorf("want %+v, got records %+v", w, cluster.records)
+			return
+		}
+		cluster.records = append(cluster.records[:i], cluster.records[i+1:]...)
+	}
+	if len(cluster.records) > 0 {
+		t.Errorf("unexpected records %+v", cluster.records)
+	}
+	requests := strings.Join(cluster.requests, " ")
+	if !strings.HasPrefix(requests, "0:3 v4 ") || strings.Count(requests, ":0 v3") != 2 {
+		t.Errorf("got requests %s, want Metadata, then one Produce to each broker", requests)
+	}
+}
+
+// TestKafkaNewTopic checks that Publish waits for a topic being created.
+func TestKafkaNewTopic(t *testing.T) {
+	cluster := newFakeKafka(t, 1, 1)
+	cluster.topicErrors = []int16{errLeaderNotAvailable}
+	k := &kafka{bootstrap: cluster.bootstrap()}
+	if err := k.Publish(context.Background(), []Message{{Topic: "monarch.new", Value: []byte("{}")}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0:3 v4", "0:3 v4", "0:0 v3"}; strings.Join(cluster.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("got requests %v, want %v", cluster.requests, want)
+	}
+}
+
+func TestKafkaErrors(t *testing.T) {
+	cluster := newFakeKafka(t, 1, 1)
+	cluster.produceError = 10
+	k := &kafka{bootstrap: cluster.bootstrap()}
+	err := k.Publish(context.Background(), []Message{{Topic: "monarch.big", Value: bytes.Repeat([]byte("x"), 100)}})
+	var kerr kafkaError
+	if !errors.As(err, &kerr) || !strings.Contains(err.Error(), "topic monarch.big partition 0: message too large") {
+		t.Errorf("got %v, want message too large", err)
+	}
+
+	cluster.produceError = 0
+	cluster.topicErrors = []int16{29}
+	err = k.Publish(context.Background(), []Message{{Topic: "monarch.secret", Value: []byte("{}")}})
+	if err == nil || !strings.Contains(err.Error(), "topic monarch.secret: topic authorization failed") {
+		t.Errorf("got %v, want topic authorization failed", err)
+	}
+}
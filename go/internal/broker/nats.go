@@ -0,0 +1,104 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// nats publishes with the NATS client protocol: after the server's INFO
+// and the client's CONNECT, each message is a PUB, and a final PING is
+// answered with PONG once the server has processed them, or -ERR.
+type nats struct {
+	addr, host string
+	tls        bool
+	// user and pass, or token, authenticate the connection.
+	user, pass, token string
+}
+
+func (n *nats) Publish(ctx context.Context, msgs []Message) error {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return fmt.Errorf("nats %s: %w", n.addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := n.publish(conn, msgs); err != nil {
+		return fmt.Errorf("nats %s: %w", n.addr, err)
+	}
+	return nil
+}
+
+func (n *nats) publish(conn net.Conn, msgs []Message) error {
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	infoJSON, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("decode INFO: %w", err)
+	}
+	if n.tls || info.TLSRequired {
+		tc := tls.Client(conn, &tls.Config{ServerName: n.host})
+		if err := tc.Handshake(); err != nil {
+			return err
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "lang": "go", "version": "monarch", "protocol": 0}
+	if n.user != "" {
+		opts["user"], opts["pass"] = n.user, n.pass
+	}
+	if n.token != "" {
+		opts["auth_token"] = n.token
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\n", connect)
+	for _, m := range msgs {
+		if strings.ContainsAny(m.Topic, " \t\r\n") {
+			return fmt.Errorf("invalid subject %q", m.Topic)
+		}
+		fmt.Fprintf(w, "PUB %s %d\r\n", m.Topic, len(m.Value))
+		w.Write(m.Value)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		case line == "PING":
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		}
+	}
+}
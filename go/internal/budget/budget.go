@@ -0,0 +1,118 @@
+// Package budget provides data structures for Monarch Money budgets.
+package budget
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+)
+
+// --- JSON data structures ---
+
+type Data struct {
+	BudgetData     BudgetData `json:"budgetData"`
+	CategoryGroups []Group    `json:"categoryGroups"`
+}
+
+type BudgetData struct {
+	MonthlyAmountsByCategory []CategoryAmounts `json:"monthlyAmountsByCategory"`
+}
+
+type CategoryAmounts struct {
+	Category struct {
+		ID string `json:"id"`
+	} `json:"category"`
+	MonthlyAmounts []MonthlyAmount `json:"monthlyAmounts"`
+}
+
+type MonthlyAmount struct {
+	Month                 string  `json:"month"`
+	PlannedCashFlowAmount float64 `json:"plannedCashFlowAmount"`
+	ActualAmount          float64 `json:"actualAmount"`
+	RemainingAmount       float64 `json:"remainingAmount"`
+}
+
+type Group struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Order      int        `json:"order"`
+	Categories []Category `json:"categories"`
+}
+
+type Category struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+}
+
+// Line is one category's budget and actual amount for a month.
+type Line struct {
+	GroupName    string
+	GroupType    string
+	CategoryID   string
+	CategoryName string
+	Planned      float64
+	Actual       float64
+	Remaining    float64
+}
+
+// Setter persists a category's budgeted amount.
+type Setter interface {
+	SetBudget(categoryID string, month time.Time, amount float64, applyToFuture bool) error
+}
+
+// Parse decodes the data object of a GetJointPlanningData GraphQL response.
+func Parse(data map[string]json.RawMessage) (*Data, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var d Data
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// MonthStart returns midnight UTC on the first day of t's month.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Lines returns one line per category for the given month, in Monarch's
+// group and category order.
+func (d *Data) Lines(month time.Time) []Line {
+	key := MonthStart(month).Format(time.DateOnly)
+	amounts := make(map[string]MonthlyAmount)
+	for _, ca := range d.BudgetData.MonthlyAmountsByCategory {
+		for _, m := range ca.MonthlyAmounts {
+			if m.Month == key {
+				amounts[ca.Category.ID] = m
+			}
+		}
+	}
+
+	groups := slices.Clone(d.CategoryGroups)
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Order < groups[j].Order })
+
+	var lines []Line
+	for _, g := range groups {
+		cats := slices.Clone(g.Categories)
+		sort.SliceStable(cats, func(i, j int) bool { return cats[i].Order < cats[j].Order })
+		for _, c := range cats {
+			m := amounts[c.ID]
+			lines = append(lines, Line{
+				GroupName:    g.Name,
+				GroupType:    g.Type,
+				CategoryID:   c.ID,
+				CategoryName: c.Name,
+				Planned:      m.PlannedCashFlowAmount,
+				Actual:       m.ActualAmount,
+				Remaining:    m.RemainingAmount,
+			})
+		}
+	}
+	return lines
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// API is what callers need of a Monarch client: logging in, keeping the
+// session and calling the GraphQL API. *Client implements it; code taking
+// an API can be tested against clienttest.Fake instead.
+type API interface {
+	Login(ctx context.Context, email, password, code string) error
+	Logout(ctx context.Context) error
+	Token() string
+	SetToken(token string)
+	SaveSession(ctx context.Context) error
+	LoadSession(ctx context.Context) (bool, error)
+	DeleteSession(ctx context.Context) error
+	GraphQLCall(ctx context.Context, operationName, query string, variables map[string]any) (map[string]json.RawMessage, error)
+	BatchCall(ctx context.Context, ops ...Operation) ([]BatchResult, error)
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+)
+
+// WithPersistedQueries sends GraphQL queries as Automatic Persisted
+// Queries, as Monarch's web client does: each request first carries only
+// the query's SHA-256 hash, and the full query is sent only when the
+// server doesn't know the hash yet. If the server doesn't support
+// persisted queries, the client goes back to sending full queries.
+func WithPersistedQueries() Option {
+	return func(c *Client) {
+		c.apq = new(atomic.Bool)
+		c.apq.Store(true)
+	}
+}
+
+// graphqlExtensions are the protocol extensions of a GraphQL request.
+type graphqlExtensions struct {
+	PersistedQuery *persistedQuery `json:"persistedQuery,omitempty"`
+}
+
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// Errors a server answers a hash-only request with.
+const (
+	persistedQueryNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	persistedQueryNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+)
+
+// persistedQueryError reports whether err says the server lacks the
+// query with the given code, which servers put in extensions.code or,
+// in older versions, in the message as e.g. "PersistedQueryNotFound".
+func persistedQueryError(err error, code, message string) bool {
+	var gerr *GraphQLError
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	for _, item := range gerr.Errors {
+		if item.Code() == code || item.Message == message {
+			return true
+		}
+	}
+	return false
+}
+
+// postPersisted sends req as a persisted query if enabled, falling back
+// to the full query when the server asks for it.
+func (c *Client) postPersisted(ctx context.Context, req graphqlRequest) (map[string]json.RawMessage, error) {
+	if c.apq == nil || !c.apq.Load() {
+		return c.postGraphQL(ctx, req)
+	}
+	full, hashOnly := persisted(req)
+	data, err := c.postGraphQL(ctx, hashOnly)
+	switch {
+	case persistedQueryError(err, persistedQueryNotFound, "PersistedQueryNotFound"):
+		c.logger.DebugContext(ctx, "persisted query not found; sending the full query", "operation", req.OperationName)
+		return c.postGraphQL(ctx, full)
+	case persistedQueryError(err, persistedQueryNotSupported, "PersistedQueryNotSupported"):
+		c.logger.InfoContext(ctx, "server doesn't support persisted queries; sending full queries")
+		c.apq.Store(false)
+		return c.postGraphQL(ctx, req)
+	}
+	return data, err
+}
+
+// persisted returns req with the hash of its query, once with the query
+// and once without it.
+func persisted(req graphqlRequest) (full, hashOnly graphqlRequest) {
+	sum := sha256.Sum256([]byte(req.Query))
+	full = req
+	full.Extensions = &graphqlExtensions{PersistedQuery: &persistedQuery{Version: 1, SHA256Hash: hex.EncodeToString(sum[:])}}
+	hashOnly = full
+	hashOnly.Query = ""
+	return full, hashOnly
+}
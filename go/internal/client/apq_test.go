@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// apqServer answers GraphQL requests as a server with persisted queries
+// does, or one without them if unsupported is set, counting the full and
+// hash-only requests.
+type apqServer struct {
+	unsupported bool
+	known       map[string]bool
+	full, hash  int
+}
+
+func (s *apqServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Query != "" {
+		s.full++
+	} else {
+		s.hash++
+	}
+	switch pq := req.Extensions; {
+	case pq == nil || pq.PersistedQuery == nil:
+	case s.unsupported:
+		w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotSupported"}]}`))
+		return
+	case req.Query != "":
+		sum := sha256.Sum256([]byte(req.Query))
+		if hex.EncodeToString(sum[:]) != pq.PersistedQuery.SHA256Hash {
+			http.Error(w, `{"errors":[{"message":"provided sha does not match query"}]}`, http.StatusBadRequest)
+			return
+		}
+		s.known[pq.PersistedQuery.SHA256Hash] = true
+	case !s.known[pq.PersistedQuery.SHA256Hash]:
+		w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+		return
+	}
+	if req.Query == "" && (req.Extensions == nil || req.Extensions.PersistedQuery == nil) {
+		http.Error(w, `{"errors":[{"message":"no query"}]}`, http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(`{"data": {"me": {"id": "u1"}}}`))
+}
+
+func TestPersistedQueries(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		unsupported bool
+		// full and hash are the requests of each kind for three calls.
+		full, hash int
+	}{
+		{"supported", false, 1, 3},
+		{"unsupported", true, 3, 1},
+	} {
+		srv := &apqServer{unsupported: tc.unsupported, known: map[string]bool{}}
+		c := newTestClient(t, srv.ServeHTTP, WithPersistedQueries())
+		for range 3 {
+			data, err := c.GraphQLCall(context.Background(), "Me", "query Me { me { id } }", nil)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if string(data["me"]) != `{"id": "u1"}` {
+				t.Errorf("%s: got data %s", tc.name, data["me"])
+			}
+		}
+		if srv.full != tc.full || srv.hash != tc.hash {
+			t.Errorf("%s: got %d full and %d hash-only requests, want %d and %d", tc.name, srv.full, srv.hash, tc.full, tc.hash)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/heikofkoehler/monarch/internal/tracing"
+)
+
+// Operation is one GraphQL operation of a batch.
+type Operation struct {
+	Name      string
+	Query     string
+	Variables map[string]any
+}
+
+// BatchResult is the outcome of one operation of a batch: its "data"
+// object, or the error the server returned for it.
+type BatchResult struct {
+	Data map[string]json.RawMessage
+	Err  error
+}
+
+// errBatchUnsupported means the server didn't answer a batch with an
+// array of results.
+var errBatchUnsupported = errors.New("server doesn't support batched requests")
+
+// BatchCall sends ops in a single HTTP request, as a JSON array, and
+// returns their results in the same order. An operation that fails has
+// its error in its result; the error returned is for the request as a
+// whole. Retries and expired tokens are handled as by GraphQLCall. If
+// the server doesn't accept batches, the operations are sent one by one.
+func (c *Client) BatchCall(ctx context.Context, ops ...Operation) (results []BatchResult, err error) {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+	}
+	ctx, span := tracing.Start(ctx, "graphql batch",
+		attribute.StringSlice("graphql.operation.names", names))
+	defer func() { tracing.End(span, err) }()
+
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	// Only the operations not answered by the cache are sent.
+	results = make([]BatchResult, len(ops))
+	var misses []Operation
+	var at []int
+	for i, op := range ops {
+		if data, ok := c.cached(ctx, op); ok {
+			results[i].Data = data
+			c.metrics.cacheHits.WithLabelValues(op.Name).Inc()
+			continue
+		}
+		misses = append(misses, op)
+		at = append(at, i)
+	}
+	if len(misses) < len(ops) {
+		span.AddEvent("cache hit", trace.WithAttributes(attribute.Int("graphql.cached", len(ops)-len(misses))))
+	}
+	if len(misses) == 0 {
+		return results, nil
+	}
+	fetched, err := c.batchCall(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range at {
+		results[i] = fetched[j]
+		if fetched[j].Err == nil {
+			c.remember(ctx, ops[i], fetched[j].Data)
+		}
+	}
+	return results, nil
+}
+
+// batchCall sends ops as one batch, or one by one if the server doesn't
+// take batches.
+func (c *Client) batchCall(ctx context.Context, ops []Operation) (results []BatchResult, err error) {
+	span := trace.SpanFromContext(ctx)
+	start := time.Now()
+	err = c.authenticated(ctx, "batch", func() error {
+		mutation := slices.ContainsFunc(ops, func(op Operation) bool { return isMutation(op.Query) })
+		return c.withRetry(ctx, mutation, func() error {
+			var err error
+			results, err = c.batchAttempt(ctx, ops)
+			return err
+		})
+	})
+	if !errors.Is(err, errBatchUnsupported) {
+		for i, op := range ops {
+			opErr := err
+			if err == nil {
+				opErr = results[i].Err
+			}
+			c.metrics.observe(op.Name, start, opErr)
+		}
+		return results, err
+	}
+	// The operations sent one by one count themselves.
+	span.AddEvent("unbatch")
+	c.logger.InfoContext(ctx, "server doesn't support batches; sending the operations one by one", "err", err)
+	results = make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i].Data, results[i].Err = c.GraphQLCall(ctx, op.Name, op.Query, op.Variables)
+		if errors.Is(results[i].Err, ErrTokenExpired) || ctx.Err() != nil {
+			return nil, results[i].Err
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) batchAttempt(ctx context.Context, ops []Operation) ([]BatchResult, error) {
+	if c.token == "" {
+		return nil, fmt.Errorf("not authenticated: call Login() first or load a session")
+	}
+	reqs := make([]graphqlRequest, len(ops))
+	for i, op := range ops {
+		reqs[i] = graphqlRequest{Query: op.Query, OperationName: op.Name, Variables: op.Variables}
+	}
+	if c.apq == nil || !c.apq.Load() {
+		return c.postBatch(ctx, reqs)
+	}
+
+	// As with single requests, send the hashes first and then, in a
+	// second batch, the queries the server didn't know.
+	full := make([]graphqlRequest, len(reqs))
+	hashOnly := make([]graphqlRequest, len(reqs))
+	for i, req := range reqs {
+		full[i], hashOnly[i] = persisted(req)
+	}
+	results, err := c.postBatch(ctx, hashOnly)
+	if err != nil {
+		return nil, err
+	}
+	var missing []graphqlRequest
+	var at []int
+	for i, r := range results {
+		switch {
+		case persistedQueryError(r.Err, persistedQueryNotSupported, "PersistedQueryNotSupported"):
+			c.logger.InfoContext(ctx, "server doesn't support persisted queries; sending full queries")
+			c.apq.Store(false)
+			return c.postBatch(ctx, reqs)
+		case persistedQueryError(r.Err, persistedQueryNotFound, "PersistedQueryNotFound"):
+			missing = append(missing, full[i])
+			at = append(at, i)
+		}
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+	c.logger.DebugContext(ctx, "persisted queries not found; sending the full queries", "count", len(missing))
+	again, err := c.postBatch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range at {
+		results[i] = again[j]
+	}
+	return results, nil
+}
+
+// postBatch sends reqs as one batch and returns their results.
+func (c *Client) postBatch(ctx context.Context, reqs []graphqlRequest) ([]BatchResult, error) {
+	payload, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.post(ctx, payload)
+	var gerr *GraphQLError
+	switch {
+	case errors.As(err, &gerr) && !errors.Is(err, ErrTokenExpired):
+		// Servers without batching reject the array as a bad request.
+		return nil, fmt.Errorf("%w: %v", errBatchUnsupported, err)
+	case err != nil:
+		return nil, err
+	}
+	if t := bytes.TrimSpace(b); len(t) == 0 || t[0] != '[' {
+		if gerr := parseGraphQLError(http.StatusOK, b); gerr != nil && errors.Is(gerr, ErrTokenExpired) {
+			return nil, gerr
+		}
+		return nil, errBatchUnsupported
+	}
+
+	var bodies []json.RawMessage
+	if err := json.Unmarshal(b, &bodies); err != nil {
+		return nil, fmt.Errorf("decode graphql batch response: %w", err)
+	}
+	if len(bodies) != len(reqs) {
+		return nil, fmt.Errorf("graphql batch of %d operations got %d results", len(reqs), len(bodies))
+	}
+	results := make([]BatchResult, len(reqs))
+	for i, body := range bodies {
+		if gerr := parseGraphQLError(http.StatusOK, body); gerr != nil {
+			if errors.Is(gerr, ErrTokenExpired) {
+				return nil, gerr
+			}
+			results[i].Err = gerr
+			continue
+		}
+		var envelope struct {
+			Data map[string]json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			results[i].Err = fmt.Errorf("decode graphql response: %w", err)
+			continue
+		}
+		results[i].Data = envelope.Data
+	}
+	return results, nil
+}
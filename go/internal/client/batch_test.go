@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// batchServer answers each operation with its name, or with an error for
+// operations named "Fail". It takes batches unless noBatches is set, and
+// counts the HTTP requests.
+type batchServer struct {
+	noBatches bool
+	requests  int
+	token     string
+}
+
+func (s *batchServer) answer(req graphqlRequest) string {
+	if req.OperationName == "Fail" {
+		return `{"errors":[{"message":"Not allowed","extensions":{"code":"FORBIDDEN"}}]}`
+	}
+	return fmt.Sprintf(`{"data":{"name":%q}}`, req.OperationName)
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	if r.Header.Get("Authorization") != "Token "+s.token {
+		w.Write([]byte(`{"errors":[{"message":"Token expired","extensions":{"code":"UNAUTHENTICATED"}}]}`))
+		return
+	}
+	dec := json.NewDecoder(r.Body)
+	if s.noBatches {
+		var req graphqlRequest
+		if err := dec.Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Expected an object"}]}`))
+			return
+		}
+		w.Write([]byte(s.answer(req)))
+		return
+	}
+	var reqs []graphqlRequest
+	if err := dec.Decode(&reqs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	answers := make([]string, len(reqs))
+	for i, req := range reqs {
+		answers[i] = s.answer(req)
+	}
+	w.Write([]byte("[" + strings.Join(answers, ",") + "]"))
+}
+
+func TestBatchCall(t *testing.T) {
+	ops := []Operation{
+		{Name: "GetMe", Query: "query GetMe { me { id } }"},
+		{Name: "Fail", Query: "query Fail { secret }"},
+		{Name: "GetHousehold", Query: "query GetHousehold { household { id } }"},
+	}
+	for _, tc := range []struct {
+		name      string
+		noBatches bool
+		requests  int
+	}{
+		{"batched", false, 1},
+		{"one by one", true, 4},
+	} {
+		srv := &batchServer{noBatches: tc.noBatches, token: "test"}
+		c := newTestClient(t, srv.ServeHTTP)
+		results, err := c.BatchCall(context.Background(), ops...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got []string
+		for _, r := range results {
+			if r.Err != nil {
+				got = append(got, r.Err.Error())
+			} else {
+				got = append(got, string(r.Data["name"]))
+			}
+		}
+		want := []string{`"GetMe"`, "graphql error: Not allowed (FORBIDDEN)", `"GetHousehold"`}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s: got %q, want %q", tc.name, got, want)
+		}
+		if srv.requests != tc.requests {
+			t.Errorf("%s: got %d requests, want %d", tc.name, srv.requests, tc.requests)
+		}
+	}
+
+	srv := &batchServer{token: "fresh"}
+	c := newTestClient(t, srv.ServeHTTP)
+	if _, err := c.BatchCall(context.Background(), ops...); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("got error %v, want the whole batch to fail with ErrTokenExpired", err)
+	}
+}
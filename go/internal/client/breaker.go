@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without contacting the API, while a circuit
+// breaker is open. The returned error is a *CircuitOpenError telling
+// until when.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned instead of sending a request after the API
+// failed too often in a row. It matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	Failures int
+	// Until is when the breaker lets a probe request through.
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: the API failed %d times in a row; not calling it again until %s",
+		ErrCircuitOpen, e.Failures, e.Until.Format(time.TimeOnly))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreaker stops requests to an API that keeps failing. After
+// Failures consecutive failures, that is network errors and 5xx statuses,
+// it opens: requests fail at once with a *CircuitOpenError. Once Cooldown
+// has passed it lets one probe request through; the breaker closes again
+// if the probe succeeds and stays open for another Cooldown if it fails.
+//
+// A breaker may be shared by several clients, e.g. the steps of a
+// pipeline, so that they all stop once the API is down.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	// until is when an open breaker lets the next probe through; zero
+	// while it is closed.
+	until   time.Time
+	probing bool
+}
+
+// NewCircuitBreaker returns a closed breaker opening after failures
+// consecutive failures, for cooldown at a time.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: max(failures, 1), cooldown: cooldown}
+}
+
+// WithCircuitBreaker guards the client's GraphQL requests with cb.
+func WithCircuitBreaker(cb *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = cb
+	}
+}
+
+// allow reports whether a request may be sent now, returning a
+// *CircuitOpenError if not.
+func (cb *CircuitBreaker) allow(now time.Time) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch {
+	case cb.until.IsZero():
+		return nil
+	case now.Before(cb.until) || cb.probing:
+		return &CircuitOpenError{Failures: cb.failures, Until: cb.until}
+	default:
+		cb.probing = true
+		return nil
+	}
+}
+
+// record notes the outcome of a request let through by allow.
+func (cb *CircuitBreaker) record(now time.Time, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+	if !failed {
+		cb.failures, cb.until = 0, time.Time{}
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.until = now.Add(cb.cooldown)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(2, time.Minute)
+	for i, step := range []struct {
+		// at is the time of the step after start.
+		at time.Duration
+		// allowed is whether a request may be sent; if so, failed is its
+		// outcome.
+		allowed, failed bool
+	}{
+		{0, true, true},
+		{0, true, false}, // a success resets the count
+		{0, true, true},
+		{0, true, true}, // the second failure in a row opens the breaker
+		{30 * time.Second, false, false},
+		{time.Minute, true, true}, // the probe fails: open for another minute
+		{90 * time.Second, false, false},
+		{2 * time.Minute, true, false}, // the probe succeeds: closed
+		{2 * time.Minute, true, true},
+		{2 * time.Minute, true, false},
+	} {
+		now := start.Add(step.at)
+		err := cb.allow(now)
+		if (err == nil) != step.allowed {
+			t.Fatalf("step %d: got %v, want allowed %t", i, err, step.allowed)
+		}
+		if err == nil {
+			cb.record(now, step.failed)
+		}
+	}
+}
+
+// TestCircuitBreakerProbe checks that an open breaker lets a single probe
+// through at a time.
+func TestCircuitBreakerProbe(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(1, time.Minute)
+	cb.record(now, true)
+	now = now.Add(time.Minute)
+	if err := cb.allow(now); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	var open *CircuitOpenError
+	if err := cb.allow(now); !errors.As(err, &open) || !errors.Is(err, ErrCircuitOpen) || open.Failures != 1 {
+		t.Errorf("got %v during the probe, want the breaker open", err)
+	}
+	if cb := NewCircuitBreaker(0, time.Minute); cb.threshold != 1 {
+		t.Errorf("got threshold %d for 0 failures, want 1", cb.threshold)
+	}
+}
+
+// TestCircuitBreaker checks that a client's breaker counts 5xx statuses
+// but not other errors, and stops sending requests once open.
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusBadGateway
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"errors": [{"message": "failed"}]}`))
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithCircuitBreaker(NewCircuitBreaker(2, time.Hour)))
+	call := func() error {
+		_, err := c.GraphQLCall(context.Background(), "GetAccounts", "query GetAccounts { accounts { id } }", nil)
+		return err
+	}
+
+	status = http.StatusBadRequest
+	for range 3 {
+		if err := call(); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("breaker opened on client errors: %v", err)
+		}
+	}
+	status = http.StatusBadGateway
+	for range 2 {
+		if err := call(); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("breaker open after fewer than 2 failures: %v", err)
+		}
+	}
+	var open *CircuitOpenError
+	if err := call(); !errors.As(err, &open) || open.Failures != 2 {
+		t.Fatalf("got error %v, want the breaker open after 2 failures", err)
+	}
+	if requests != 5 {
+		t.Errorf("the API got %d requests, want none once the breaker opened", requests)
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// browserLoginTimeout bounds how long LoginWithBrowser waits for the user
+// to finish logging in.
+const browserLoginTimeout = 5 * time.Minute
+
+// LoginWithBrowser opens app.monarch.com in a Chrome window controlled over
+// the DevTools protocol, waits for the user to log in (with any method the
+// web app supports, including Google SSO and MFA) and reads the token from
+// localStorage. Requires Chrome or Chromium to be installed.
+func (c *Client) LoginWithBrowser(ctx context.Context) error {
+	if c.nonInteractive {
+		return fmt.Errorf("%w: browser login needs a user; pass a token with -token", ErrInputRequired)
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", false),
+	)
+	if ua := c.browserUserAgent(); ua != "" {
+		opts = append(opts, chromedp.UserAgent(ua))
+	}
+	actx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+	bctx, cancelBrowser := chromedp.NewContext(actx)
+	defer cancelBrowser()
+	bctx, cancelTimeout := context.WithTimeout(bctx, browserLoginTimeout)
+	defer cancelTimeout()
+
+	fmt.Fprintln(c.stdout, "Opening app.monarch.com in Chrome. Log in there; the window closes once the token is read.")
+	if err := chromedp.Run(bctx, chromedp.Navigate("https://app.monarch.com")); err != nil {
+		return fmt.Errorf("start browser: %w", err)
+	}
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		// Evaluation fails while the page navigates between login steps,
+		// so errors just mean polling again.
+		var token string
+		if err := chromedp.Run(bctx, chromedp.Evaluate(tokenExpr, &token)); err == nil && token != "" {
+			c.token = token
+			return nil
+		}
+		select {
+		case <-bctx.Done():
+			if errors.Is(bctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for login", browserLoginTimeout)
+			}
+			return fmt.Errorf("browser closed before login completed")
+		case <-tick.C:
+		}
+	}
+}
+
+// browserUserAgent is the User-Agent LoginWithBrowser gives Chrome: that of
+// the header profile if it is a browser's, so that the Cloudflare cookies
+// of the login match the API requests after it, or "" to keep Chrome's own
+// rather than announce an API client to the web app.
+func (c *Client) browserUserAgent() string {
+	if strings.HasPrefix(c.headers.UserAgent, "Mozilla/") {
+		return c.headers.UserAgent
+	}
+	return ""
+}
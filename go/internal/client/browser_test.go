@@ -0,0 +1,24 @@
+package client
+
+import "testing"
+
+func TestBrowserUserAgent(t *testing.T) {
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	for _, tc := range []struct {
+		name    string
+		profile HeaderProfile
+		want    string
+	}{
+		{"default", Profiles["default"], ""},
+		{"web", Profiles["web"], Profiles["web"].UserAgent},
+		{"mobile", Profiles["mobile"], ""},
+		{"custom browser", HeaderProfile{UserAgent: firefox}, firefox},
+		{"custom tool", HeaderProfile{UserAgent: "finance-sync/2.0"}, ""},
+	} {
+		c := New()
+		c.SetHeaderProfile(tc.profile)
+		if got := c.browserUserAgent(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
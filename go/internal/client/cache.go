@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WithCache keeps the responses to queries in dir and, for ttl, answers the
+// same query with the same variables from there instead of the API. The
+// cache is per server, household and token. Mutations are never cached and
+// empty the cache, as they may change what queries return.
+func WithCache(dir string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = nil
+		if ttl > 0 {
+			c.cache = &responseCache{dir: dir, ttl: ttl}
+		}
+	}
+}
+
+// responseCache stores one file of JSON per query and variables.
+type responseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cacheEntry is the content of a cache file.
+type cacheEntry struct {
+	Time      time.Time                  `json:"time"`
+	Operation string                     `json:"operation"`
+	Data      map[string]json.RawMessage `json:"data"`
+}
+
+func isMutation(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(query), "mutation")
+}
+
+// cachePath returns the file caching op for the client's current token.
+func (c *Client) cachePath(op Operation) string {
+	// Maps marshal with sorted keys, so equal variables give equal keys.
+	key, _ := json.Marshal(map[string]any{
+		"url": c.baseURL, "household": c.household, "token": c.token,
+		"operation": op.Name, "query": op.Query, "variables": op.Variables,
+	})
+	sum := sha256.Sum256(key)
+	return filepath.Join(c.cache.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cached returns the data of op from the cache, if it is a query cached
+// less than the TTL ago.
+func (c *Client) cached(ctx context.Context, op Operation) (map[string]json.RawMessage, bool) {
+	if c.cache == nil || isMutation(op.Query) {
+		return nil, false
+	}
+	raw, err := os.ReadFile(c.cachePath(op))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(raw, &e); err != nil || time.Since(e.Time) >= c.cache.ttl || e.Data == nil {
+		return nil, false
+	}
+	c.logger.DebugContext(ctx, "answered from the cache", "operation", op.Name, "age", time.Since(e.Time).Round(time.Second))
+	return e.Data, true
+}
+
+// remember caches the data of a query, or empties the cache after a
+// mutation. Failures are logged rather than returned, as the response
+// itself was fine.
+func (c *Client) remember(ctx context.Context, op Operation, data map[string]json.RawMessage) {
+	if c.cache == nil {
+		return
+	}
+	if isMutation(op.Query) {
+		if err := c.cache.clear(); err != nil {
+			c.logger.WarnContext(ctx, "empty the cache", "err", err)
+		}
+		return
+	}
+	err := func() error {
+		raw, err := json.Marshal(cacheEntry{Time: time.Now().UTC(), Operation: op.Name, Data: data})
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.cache.dir, 0700); err != nil {
+			return err
+		}
+		path := c.cachePath(op)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, raw, 0600); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}()
+	if err != nil {
+		c.logger.WarnContext(ctx, "write the cache", "operation", op.Name, "err", err)
+	}
+}
+
+// clear deletes the entries the cache wrote, files named by the hash of
+// their query, leaving anything else in its directory alone.
+func (rc *responseCache) clear() error {
+	paths, err := filepath.Glob(filepath.Join(rc.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		key, err := hex.DecodeString(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil || len(key) != sha256.Size {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheInvalidatedByMutation(t *testing.T) {
+	dir := t.TempDir()
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"data": {"accounts": []}}`))
+	}, WithCache(dir, time.Hour))
+	for _, name := range []string{"notes.txt", "other.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	query := func() {
+		t.Helper()
+		if _, err := c.GraphQLCall(ctx, "GetAccounts", "query GetAccounts { accounts { id } }", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query()
+	query()
+	if requests != 1 {
+		t.Fatalf("got %d requests for a repeated query, want 1", requests)
+	}
+	if _, err := c.GraphQLCall(ctx, "Update", "mutation Update { updateAccount { ok } }", nil); err != nil {
+		t.Fatal(err)
+	}
+	query()
+	if requests != 3 {
+		t.Errorf("got %d requests, want the query sent again after the mutation", requests)
+	}
+	for _, name := range []string{"notes.txt", "other.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("mutation deleted a file the cache didn't write: %v", err)
+		}
+	}
+}
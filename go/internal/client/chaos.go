@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChaosEnv is the environment variable commands read a Chaos from.
+const ChaosEnv = "MONARCH_CHAOS"
+
+// Chaos sets the faults WithChaos injects, for testing how scripts and
+// alerting built on the client cope with an unreliable API. Rates are
+// fractions of requests between 0 and 1.
+type Chaos struct {
+	// Latency is the longest random delay added before each request.
+	Latency time.Duration
+	// RateLimit is the rate of requests answered with 429 Too Many
+	// Requests and ServerError of those answered with a 5xx status,
+	// without reaching the API.
+	RateLimit, ServerError float64
+	// Truncate is the rate of responses whose body is cut off halfway.
+	Truncate float64
+	// Seed makes the faults repeatable; zero picks a random seed.
+	Seed uint64
+}
+
+// ParseChaos parses a comma-separated list of faults such as
+// "latency=2s,429=0.1,5xx=5%,truncate=0.05,seed=42".
+func ParseChaos(s string) (Chaos, error) {
+	var ch Chaos
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Chaos{}, fmt.Errorf("chaos %q: want name=value", field)
+		}
+		var err error
+		switch name {
+		case "latency":
+			ch.Latency, err = time.ParseDuration(value)
+		case "429":
+			ch.RateLimit, err = parseRate(value)
+		case "5xx":
+			ch.ServerError, err = parseRate(value)
+		case "truncate":
+			ch.Truncate, err = parseRate(value)
+		case "seed":
+			ch.Seed, err = strconv.ParseUint(value, 10, 64)
+		default:
+			return Chaos{}, fmt.Errorf("chaos %q: unknown fault %q, want latency, 429, 5xx, truncate or seed", field, name)
+		}
+		if err != nil {
+			return Chaos{}, fmt.Errorf("chaos %q: %w", field, err)
+		}
+	}
+	if ch.RateLimit+ch.ServerError > 1 {
+		return Chaos{}, fmt.Errorf("chaos %q: the 429 and 5xx rates add up to more than 1", s)
+	}
+	return ch, nil
+}
+
+// parseRate parses a fraction such as "0.1" or a percentage such as "10%".
+func parseRate(s string) (float64, error) {
+	pct, isPct := strings.CutSuffix(s, "%")
+	r, err := strconv.ParseFloat(pct, 64)
+	if err != nil {
+		return 0, err
+	}
+	if isPct {
+		r /= 100
+	}
+	if r < 0 || r > 1 {
+		return 0, fmt.Errorf("rate %s is not between 0 and 1", s)
+	}
+	return r, nil
+}
+
+// WithChaos injects the faults in ch into the client's HTTP exchanges.
+// It is meant for tests only. Injected responses carry an
+// X-Monarch-Chaos header naming the fault; the debug log shows them, but
+// a recording doesn't.
+func WithChaos(ch Chaos) Option {
+	return func(c *Client) {
+		c.chaos = &ch
+	}
+}
+
+// chaosTransport injects faults into the exchanges of the transport it
+// wraps.
+type chaosTransport struct {
+	chaos Chaos
+	base  http.RoundTripper
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// chaosServerErrors are the statuses of injected server errors.
+var chaosServerErrors = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	delay := time.Duration(t.rng.Float64() * float64(t.chaos.Latency))
+	fault := t.rng.Float64()
+	truncate := t.rng.Float64() < t.chaos.Truncate
+	status := chaosServerErrors[t.rng.IntN(len(chaosServerErrors))]
+	t.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	switch {
+	case fault < t.chaos.RateLimit:
+		resp := chaosResponse(req, http.StatusTooManyRequests, "429")
+		resp.Header.Set("Retry-After", "1")
+		return resp, nil
+	case fault < t.chaos.RateLimit+t.chaos.ServerError:
+		return chaosResponse(req, status, "5xx"), nil
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || !truncate {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body[:len(body)/2]), errReader{io.ErrUnexpectedEOF}))
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("X-Monarch-Chaos", "truncate")
+	return resp, nil
+}
+
+// chaosResponse is an injected error response to req.
+func chaosResponse(req *http.Request, status int, fault string) *http.Response {
+	body := fmt.Sprintf(`{"errors":[{"message":"injected fault: HTTP %d"}]}`, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, "X-Monarch-Chaos": {fault}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// errReader fails every read with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
@@ -6,26 +6,84 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/heikofkoehler/monarch/internal/tracing"
 )
 
+// DefaultBaseURL is the Monarch API the client talks to unless WithBaseURL
+// selects another.
+const DefaultBaseURL = "https://api.monarch.com"
+
+// API paths, relative to the base URL.
 const (
-	baseURL     = "https://api.monarch.com"
-	loginURL    = baseURL + "/auth/login/"
-	graphqlURL  = baseURL + "/graphql"
-	sessionFile = ".mm/session.json"
-	userAgent   = "MonarchMoneyAPI (https://github.com/hammem/monarchmoney)"
+	loginPath   = "/auth/login/"
+	logoutPath  = "/auth/logout/"
+	graphqlPath = "/graphql"
 )
 
-// consoleSnippet extracts the Monarch session token and copies it to the clipboard.
-const consoleSnippet = `(function(){
+// HeaderProfile is the set of identifying headers sent with every request.
+type HeaderProfile struct {
+	UserAgent      string
+	ClientPlatform string
+	// Extra holds additional headers, e.g. Origin for browser-like profiles.
+	Extra map[string]string
+}
+
+// Profiles are the built-in header profiles, selectable by name.
+var Profiles = map[string]HeaderProfile{
+	"default": {
+		UserAgent:      "MonarchMoneyAPI (https://github.com/hammem/monarchmoney)",
+		ClientPlatform: "web",
+	},
+	"web": {
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+		ClientPlatform: "web",
+		Extra: map[string]string{
+			"Origin":  "https://app.monarch.com",
+			"Referer": "https://app.monarch.com/",
+		},
+	},
+	"mobile": {
+		UserAgent:      "MonarchMoney/1.0 (iPhone; iOS 17.5; Scale/3.00)",
+		ClientPlatform: "ios",
+	},
+}
+
+// LookupProfile returns the built-in profile with the given name.
+// "custom" is accepted as an alias for "default" so callers can layer
+// their own overrides on top of it.
+func LookupProfile(name string) (HeaderProfile, error) {
+	if name == "" || name == "custom" {
+		name = "default"
+	}
+	p, ok := Profiles[name]
+	if !ok {
+		return HeaderProfile{}, fmt.Errorf("unknown header profile %q", name)
+	}
+	return p, nil
+}
+
+// tokenExpr is a JavaScript expression that evaluates to the Monarch session
+// token stored in the web app's localStorage, or "" when not logged in.
+const tokenExpr = `(function(){
   let token = "";
   try {
     const root = JSON.parse(localStorage.getItem("persist:root") || "{}");
@@ -40,6 +98,12 @@ const consoleSnippet = `(function(){
       }
     }
   }
+  return token;
+})()`
+
+// consoleSnippet extracts the Monarch session token and copies it to the clipboard.
+const consoleSnippet = `(function(){
+  const token = ` + tokenExpr + `;
   if (!token) { console.error("Token not found — are you logged in?"); return; }
   navigator.clipboard.writeText(token).then(
     () => console.log("Token copied to clipboard!"),
@@ -51,13 +115,140 @@ const consoleSnippet = `(function(){
 type Client struct {
 	token      string
 	httpClient *http.Client
+	jar        http.CookieJar
+	baseURL    string
+	logger     *slog.Logger
+	headers    HeaderProfile
+	sessions   SessionStore
+	retry      RetryPolicy
+	limiter    *limiter
+	// apq, if set, holds whether queries are sent as persisted queries.
+	apq *atomic.Bool
+	// cache, if set, answers repeated queries.
+	cache *responseCache
+	// requestHooks and responseHooks run around each HTTP exchange.
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
+	metrics       *metrics
+	// breaker, if set, stops requests while the API keeps failing.
+	breaker *CircuitBreaker
+	// quota is the rate limit last reported by the API.
+	quota atomic.Pointer[Quota]
+
+	// timeout, if set, replaces the HTTP client's. The fields after it are
+	// the layers of its transport; see transport.
+	timeout   *time.Duration
+	proxy     *url.URL
+	replay    *Cassette
+	recordDir string
+	chaos     *Chaos
+	debug     io.Writer
+
+	reauthenticate func(context.Context, *Client) error
+	// sessionToken is the token last loaded from or saved to the session
+	// store, to tell whether the current token came from there.
+	sessionToken   string
+	nonInteractive bool
+	// stdin and stdout are the terminal that interactive login steps
+	// prompt on; input reads lines from stdin.
+	stdin      io.Reader
+	input      *bufio.Reader
+	stdout     io.Writer
+	deviceUUID string
+	household  string
+	// mfaMethod is the MFA method the last login attempt asked for; the
+	// next Login sends its code in the matching field.
+	mfaMethod MFAMethod
 }
 
-// New creates a new Client with a default 30-second timeout.
-func New() *Client {
-	return &Client{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+// New creates a new Client with a default 30-second timeout, tuned by
+// opts. Cookies set by the API (including Cloudflare clearance
+// cookies) are kept in an in-memory jar and persisted alongside the session
+// token.
+func New(opts ...Option) *Client {
+	jar, _ := cookiejar.New(nil)
+	c := &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second, Jar: jar},
+		jar:        jar,
+		baseURL:    DefaultBaseURL,
+		logger:     slog.New(slog.DiscardHandler),
+		headers:    Profiles["default"],
+		sessions:   FileStore{Path: DefaultSessionPath()},
+		retry:      DefaultRetryPolicy,
+		metrics:    newMetrics(),
+		stdin:      os.Stdin,
+		stdout:     os.Stdout,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.input = bufio.NewReader(c.stdin)
+	if c.timeout != nil {
+		c.httpClient.Timeout = *c.timeout
+	}
+	c.httpClient.Transport = c.transport(c.httpClient.Transport)
+	return c
+}
+
+// TokenEnv is the environment variable NewFromEnv reads the auth token from.
+const TokenEnv = "MONARCH_TOKEN"
+
+// BaseURLEnv is the environment variable NewFromEnv reads the API's base
+// URL from.
+const BaseURLEnv = "MONARCH_BASE_URL"
+
+// NewFromEnv is like New, but authenticates with the token in $MONARCH_TOKEN
+// if it is set, so containers and CI jobs need no session file. A token
+// loaded later with LoadSession or SetToken replaces it. $MONARCH_BASE_URL,
+// if set, overrides WithBaseURL, to point the client at a mock server or
+// a new domain.
+func NewFromEnv(opts ...Option) *Client {
+	c := New(opts...)
+	c.token = strings.TrimSpace(os.Getenv(TokenEnv))
+	if u := strings.TrimSpace(os.Getenv(BaseURLEnv)); u != "" {
+		WithBaseURL(u)(c)
+	}
+	return c
+}
+
+// SetReauthenticate registers fn to log in again when the API reports the
+// token as expired. fn must leave a fresh token on the client.
+func (c *Client) SetReauthenticate(fn func(context.Context, *Client) error) {
+	c.reauthenticate = fn
+}
+
+// SetNonInteractive makes steps that would prompt on stdin, such as the
+// Cloudflare fallback and Google SSO, fail with ErrInputRequired instead.
+func (c *Client) SetNonInteractive(v bool) {
+	c.nonInteractive = v
+}
+
+// SetDeviceUUID identifies this installation to Monarch. With a device UUID
+// set, Login asks Monarch to trust the device so that later logins from it
+// can skip MFA, as the web client does.
+func (c *Client) SetDeviceUUID(id string) {
+	c.deviceUUID = id
+}
+
+// SetSessionStore changes where SaveSession and LoadSession keep the session.
+func (c *Client) SetSessionStore(s SessionStore) {
+	c.sessions = s
+}
+
+// SetSessionFile keeps the session in the file at path instead of the
+// default location.
+func (c *Client) SetSessionFile(path string) {
+	c.sessions = FileStore{Path: path}
+}
+
+// SetHeaderProfile replaces the identifying headers sent with each request.
+func (c *Client) SetHeaderProfile(p HeaderProfile) {
+	c.headers = p
+}
+
+// HeaderProfile returns the identifying headers currently in use.
+func (c *Client) HeaderProfile() HeaderProfile {
+	return c.headers
 }
 
 // SetToken sets the auth token directly (e.g. loaded from a session file).
@@ -76,6 +267,8 @@ type loginRequest struct {
 	TrustedDevice bool   `json:"trusted_device"`
 	Username      string `json:"username"`
 	TOTP          string `json:"totp,omitempty"`
+	EmailOTP      string `json:"email_otp,omitempty"`
+	RecoveryCode  string `json:"recovery_code,omitempty"`
 }
 
 type loginResponse struct {
@@ -83,20 +276,43 @@ type loginResponse struct {
 }
 
 type sessionData struct {
-	Token string `json:"token"`
+	Token   string       `json:"token"`
+	Cookies []cookieData `json:"cookies,omitempty"`
+}
+
+// cookieData is the on-disk form of a cookie stored in the session file.
+type cookieData struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// LoginWithBackupCode completes an MFA login with one of the single-use
+// backup codes shown when MFA was set up, for when the authenticator is
+// unavailable.
+func (c *Client) LoginWithBackupCode(ctx context.Context, email, password, code string) error {
+	c.mfaMethod = MFABackupCode
+	return c.Login(ctx, email, password, code)
 }
 
 // Login authenticates with Monarch Money using email and password.
-// If the server responds with 403, it returns ErrMFARequired.
-func (c *Client) Login(email, password, totp string) error {
+// If the server responds with 403, it returns ErrMFARequired. Other
+// failures are reported as ErrInvalidCredentials, ErrCaptchaRequired or a
+// *RateLimitError where the response says so.
+func (c *Client) Login(ctx context.Context, email, password, code string) error {
 	req := loginRequest{
 		Password:      password,
 		SupportsMFA:   true,
-		TrustedDevice: false,
+		TrustedDevice: c.deviceUUID != "",
 		Username:      email,
 	}
-	if totp != "" {
-		req.TOTP = totp
+	switch {
+	case code == "":
+	case c.mfaMethod == MFAEmailOTP:
+		req.EmailOTP = code
+	case c.mfaMethod == MFABackupCode:
+		req.RecoveryCode = code
+	default:
+		req.TOTP = code
 	}
 
 	body, err := json.Marshal(req)
@@ -104,28 +320,38 @@ func (c *Client) Login(email, password, totp string) error {
 		return err
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, loginURL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+loginPath, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	c.setHeaders(httpReq)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.send(httpReq)
 	if err != nil {
 		return fmt.Errorf("login request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read login response: %w", err)
+	}
+	if isCloudflareChallenge(resp, b) {
+		return fmt.Errorf("%w (HTTP %d)", ErrCloudflareChallenge, resp.StatusCode)
+	}
+	if err := loginError(resp, b); err != nil {
+		return err
+	}
 	if resp.StatusCode == http.StatusForbidden {
-		return ErrMFARequired
+		c.mfaMethod = detectMFAMethod(b)
+		return &MFAError{Method: c.mfaMethod}
 	}
 	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("login failed (HTTP %d): %s", resp.StatusCode, strings.TrimSpace(string(b)))
 	}
 
 	var lr loginResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+	if err := json.Unmarshal(b, &lr); err != nil {
 		return fmt.Errorf("decode login response: %w", err)
 	}
 	if lr.Token == "" {
@@ -135,38 +361,311 @@ func (c *Client) Login(email, password, totp string) error {
 	return nil
 }
 
-// ErrMFARequired is returned by Login when MFA is required.
+// Logout revokes the current token on the server. A token the server
+// already rejects counts as logged out.
+func (c *Client) Logout(ctx context.Context) error {
+	if c.token == "" {
+		return fmt.Errorf("not authenticated")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+logoutPath, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("logout request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if isCloudflareChallenge(resp, b) {
+		return fmt.Errorf("%w (HTTP %d)", ErrCloudflareChallenge, resp.StatusCode)
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("logout failed (HTTP %d): %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	c.token = ""
+	return nil
+}
+
+// ErrMFARequired is returned by Login when MFA is required. The returned
+// error is an *MFAError telling which kind of code to supply.
 var ErrMFARequired = fmt.Errorf("multi-factor authentication required")
 
+// MFAMethod is the kind of one-time code Monarch asks for.
+type MFAMethod string
+
+const (
+	// MFATOTP is a code from an authenticator app.
+	MFATOTP MFAMethod = "totp"
+	// MFAEmailOTP is a code Monarch emails to the account address.
+	MFAEmailOTP MFAMethod = "email_otp"
+	// MFABackupCode is a single-use recovery code, accepted instead of a
+	// TOTP code.
+	MFABackupCode MFAMethod = "recovery_code"
+)
+
+// MFAError is returned by Login when a one-time code is required. It
+// matches ErrMFARequired with errors.Is.
+type MFAError struct {
+	Method MFAMethod
+}
+
+func (e *MFAError) Error() string {
+	if e.Method == MFAEmailOTP {
+		return ErrMFARequired.Error() + " (code sent by email)"
+	}
+	return ErrMFARequired.Error()
+}
+
+func (e *MFAError) Unwrap() error {
+	return ErrMFARequired
+}
+
+// detectMFAMethod reads the MFA method from the body of a 403 login
+// response. Anything that doesn't mention email is treated as TOTP.
+func detectMFAMethod(body []byte) MFAMethod {
+	var r struct {
+		ErrorCode string `json:"error_code"`
+		Detail    string `json:"detail"`
+		MFAMethod string `json:"mfa_method"`
+	}
+	if json.Unmarshal(body, &r) == nil {
+		for _, s := range []string{r.MFAMethod, r.ErrorCode, r.Detail} {
+			if strings.Contains(strings.ToLower(s), "email") {
+				return MFAEmailOTP
+			}
+		}
+	}
+	return MFATOTP
+}
+
+// ErrInvalidCredentials is returned by Login when the email or password is
+// wrong, or the MFA code was rejected.
+var ErrInvalidCredentials = fmt.Errorf("invalid credentials")
+
+// ErrCaptchaRequired is returned by Login when Monarch wants a captcha
+// solved, which only a browser login can do.
+var ErrCaptchaRequired = fmt.Errorf("captcha required")
+
+// ErrRateLimited is returned when Monarch throttles requests. The returned
+// error is a *RateLimitError telling when to try again.
+var ErrRateLimited = fmt.Errorf("rate limited")
+
+// RateLimitError is returned when the server answers 429 Too Many
+// Requests. It matches ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	// RetryAfter is how long the server asked to wait, or zero if it
+	// didn't say.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s; retry after %s", ErrRateLimited, e.RetryAfter)
+	}
+	return ErrRateLimited.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// loginResponseError is the error body of a failed login.
+type loginResponseError struct {
+	ErrorCode      string   `json:"error_code"`
+	Detail         string   `json:"detail"`
+	NonFieldErrors []string `json:"non_field_errors"`
+}
+
+func (e loginResponseError) message() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return strings.Join(e.NonFieldErrors, " ")
+}
+
+// loginError classifies a failed login response, returning nil if it is
+// not a rate limit, captcha or credentials failure.
+func loginError(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return rateLimitError(resp, time.Now())
+	}
+	if resp.StatusCode/100 != 4 {
+		return nil
+	}
+	var e loginResponseError
+	json.Unmarshal(body, &e)
+	msg := strings.ToLower(e.ErrorCode + " " + e.message())
+	switch {
+	case strings.Contains(msg, "captcha"):
+		return fmt.Errorf("%w: %s", ErrCaptchaRequired, e.message())
+	case strings.Contains(msg, "throttled"):
+		wait := rateLimitWait(resp.Header, time.Now())
+		if m := throttledWait.FindStringSubmatch(e.message()); wait == 0 && m != nil {
+			wait = retryAfter(m[1], time.Now())
+		}
+		return &RateLimitError{RetryAfter: wait}
+	case resp.StatusCode == http.StatusUnauthorized,
+		strings.Contains(msg, "credentials"),
+		strings.Contains(msg, "password"),
+		strings.Contains(msg, "invalid") && strings.Contains(msg, "code"):
+		if m := e.message(); m != "" {
+			return fmt.Errorf("%w: %s", ErrInvalidCredentials, m)
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// throttledWait finds the wait in a throttling message such as "Request was
+// throttled. Expected available in 52 seconds."
+var throttledWait = regexp.MustCompile(`available in (\d+) second`)
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date, returning zero if it is absent or invalid.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now).Round(time.Second)
+	}
+	return 0
+}
+
+// ErrTokenExpired is returned by GraphQLCall when the API rejects the auth
+// token, typically because the session has expired or been revoked.
+var ErrTokenExpired = fmt.Errorf("auth token expired or invalid")
+
+// authFailureDetail matches the messages the API sends with a 403 when the
+// token is missing, expired or revoked, as opposed to a denied permission.
+var authFailureDetail = regexp.MustCompile(`(?i)authentication credentials|invalid token|token (has )?expired|not authenticated`)
+
+// isAuthFailure reports whether a GraphQL response with the given status and
+// body rejects the auth token, so that logging in again could help.
+func isAuthFailure(status int, body []byte) bool {
+	switch status {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusForbidden:
+		var e loginResponseError
+		if json.Unmarshal(body, &e) != nil {
+			return false
+		}
+		return authFailureDetail.MatchString(e.Detail)
+	}
+	return false
+}
+
+// ErrCloudflareChallenge is returned when Cloudflare answers a request with a
+// browser challenge page instead of forwarding it to the Monarch API.
+var ErrCloudflareChallenge = fmt.Errorf("request blocked by Cloudflare challenge")
+
+// ErrInputRequired is returned instead of prompting when the client is in
+// non-interactive mode and a step needs input from the user.
+var ErrInputRequired = fmt.Errorf("interactive input required")
+
+// isCloudflareChallenge reports whether resp is a Cloudflare interstitial
+// (JS/captcha challenge) rather than a response from the API itself.
+func isCloudflareChallenge(resp *http.Response, body []byte) bool {
+	if resp.Header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return false
+	}
+	if !strings.EqualFold(resp.Header.Get("Server"), "cloudflare") {
+		return false
+	}
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		bytes.Contains(body, []byte("cf-chl")) ||
+		bytes.Contains(body, []byte("Just a moment..."))
+}
+
+// ResolveChallenge walks the user through passing a Cloudflare challenge in
+// their browser and copies the resulting cookies into the client's jar.
+// Cloudflare binds the clearance cookie to the browser's User-Agent, so the
+// user is reminded to run with a matching header profile.
+func (c *Client) ResolveChallenge() error {
+	if c.nonInteractive {
+		return fmt.Errorf("%w: Cloudflare challenge; pass browser cookies with -cookies", ErrInputRequired)
+	}
+	fmt.Fprintln(c.stdout, "Cloudflare is challenging requests from this network.")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, "Opening app.monarch.com in your browser. Once the page has loaded:")
+	fmt.Fprintln(c.stdout, "  1. Open DevTools → Network and reload the page")
+	fmt.Fprintln(c.stdout, "  2. Select any request to api.monarch.com")
+	fmt.Fprintln(c.stdout, "  3. Copy the value of the \"Cookie\" request header")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, "The cookies only work with the browser's User-Agent. If this keeps failing,")
+	fmt.Fprintln(c.stdout, "set client.profile to \"custom\" and client.userAgent in the config file.")
+	fmt.Fprintln(c.stdout)
+
+	_ = openBrowser("https://app.monarch.com")
+
+	header := c.prompt("Paste Cookie header here: ")
+	if header == "" {
+		return fmt.Errorf("no cookies provided")
+	}
+	return c.SetCookies(header)
+}
+
+// SetCookies parses a Cookie request header ("name=value; name2=value2") and
+// adds the cookies to the client's jar for the API host.
+func (c *Client) SetCookies(header string) error {
+	cookies, err := http.ParseCookie(header)
+	if err != nil {
+		return fmt.Errorf("parse cookies: %w", err)
+	}
+	c.jar.SetCookies(c.apiURL(), cookies)
+	return nil
+}
+
+func (c *Client) apiURL() *url.URL {
+	u, _ := url.Parse(c.baseURL)
+	return u
+}
+
 // LoginWithGoogle opens app.monarch.com in Chrome, prints a JavaScript snippet
 // the user runs in the browser console to copy their Monarch token to the clipboard,
-// then reads the token automatically from the clipboard via pbpaste.
+// then reads the token automatically from the clipboard.
 func (c *Client) LoginWithGoogle(ctx context.Context) error {
-	fmt.Println("Opening app.monarch.com in Chrome...")
-	fmt.Println()
-	fmt.Println("Once the page loads:")
-	fmt.Println("  1. Open the browser console  (Cmd+Option+J)")
-	fmt.Println("  2. Paste the snippet below and press Enter")
-	fmt.Println("     → It will copy your Monarch token to the clipboard")
-	fmt.Println()
-	fmt.Println(consoleSnippet)
-	fmt.Println()
+	if c.nonInteractive {
+		return fmt.Errorf("%w: Google SSO needs a browser; pass a token with -token", ErrInputRequired)
+	}
+	fmt.Fprintln(c.stdout, "Opening app.monarch.com in Chrome...")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, "Once the page loads:")
+	fmt.Fprintf(c.stdout, "  1. Open the browser console  (%s)\n", consoleShortcut())
+	fmt.Fprintln(c.stdout, "  2. Paste the snippet below and press Enter")
+	fmt.Fprintln(c.stdout, "     → It will copy your Monarch token to the clipboard")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, consoleSnippet)
+	fmt.Fprintln(c.stdout)
 
 	_ = openBrowser("https://app.monarch.com")
 
-	prompt("Press Enter after the console says \"Token copied to clipboard!\"...")
+	c.prompt("Press Enter after the console says \"Token copied to clipboard!\"...")
 
-	out, err := exec.Command("pbpaste").Output()
+	out, err := readClipboard()
 	if err != nil {
-		// pbpaste not available (non-macOS) — fall back to manual paste.
-		token := prompt("Paste token here: ")
+		// No clipboard command (or no display) — fall back to manual paste.
+		token := c.prompt("Paste token here: ")
 		if token == "" {
 			return fmt.Errorf("no token provided")
 		}
 		c.token = token
 		return nil
 	}
-	token := strings.TrimSpace(string(out))
+	token := strings.TrimSpace(out)
 	if token == "" {
 		return fmt.Errorf("clipboard is empty — did the snippet run successfully?")
 	}
@@ -174,117 +673,278 @@ func (c *Client) LoginWithGoogle(ctx context.Context) error {
 	return nil
 }
 
-// SaveSession writes the auth token to disk.
-func (c *Client) SaveSession() error {
-	if err := os.MkdirAll(".mm", 0700); err != nil {
+// SaveSession writes the auth token and cookies to the session store. The
+// session methods don't touch the store once ctx is done.
+func (c *Client) SaveSession(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	data, err := json.Marshal(sessionData{Token: c.token})
+	sd := sessionData{Token: c.token}
+	for _, ck := range c.jar.Cookies(c.apiURL()) {
+		sd.Cookies = append(sd.Cookies, cookieData{Name: ck.Name, Value: ck.Value})
+	}
+	data, err := json.Marshal(sd)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(sessionFile, data, 0600)
+	if err := c.sessions.Save(data); err != nil {
+		return err
+	}
+	c.sessionToken = c.token
+	return nil
 }
 
-// LoadSession reads a previously saved auth token from disk.
-// Returns false if no session file exists.
-func (c *Client) LoadSession() (bool, error) {
-	raw, err := os.ReadFile(sessionFile)
-	if os.IsNotExist(err) {
-		return false, nil
+// LoadSession reads a previously saved auth token from the session store.
+// Returns false if no session has been saved.
+func (c *Client) LoadSession(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
+	raw, err := c.sessions.Load()
 	if err != nil {
 		return false, err
 	}
+	if raw == nil {
+		return false, nil
+	}
 	var sd sessionData
 	if err := json.Unmarshal(raw, &sd); err != nil {
 		return false, err
 	}
+	var cookies []*http.Cookie
+	for _, cd := range sd.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: cd.Name, Value: cd.Value})
+	}
+	c.jar.SetCookies(c.apiURL(), cookies)
 	if sd.Token == "" {
 		return false, nil
 	}
 	c.token = sd.Token
+	c.sessionToken = sd.Token
 	return true, nil
 }
 
-// DeleteSession removes the session file.
-func (c *Client) DeleteSession() error {
-	err := os.Remove(sessionFile)
-	if os.IsNotExist(err) {
-		return nil
+// reloadSession picks up a session saved by another process since this one
+// loaded its own, such as a concurrent command that already logged in
+// again. It reports whether the token changed.
+func (c *Client) reloadSession(ctx context.Context) bool {
+	old := c.token
+	if old == "" || old != c.sessionToken {
+		return false
 	}
-	return err
+	if loaded, err := c.LoadSession(ctx); err != nil || !loaded || c.token == old {
+		c.token, c.sessionToken = old, old
+		return false
+	}
+	return true
+}
+
+// DeleteSession removes the saved session.
+func (c *Client) DeleteSession(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.sessions.Delete()
 }
 
 // graphqlRequest is the payload sent to the GraphQL endpoint.
 type graphqlRequest struct {
-	Query         string         `json:"query"`
-	OperationName string         `json:"operationName"`
-	Variables     map[string]any `json:"variables"`
+	Query         string             `json:"query,omitempty"`
+	OperationName string             `json:"operationName"`
+	Variables     map[string]any     `json:"variables"`
+	Extensions    *graphqlExtensions `json:"extensions,omitempty"`
 }
 
 // GraphQLCall sends a GraphQL query to Monarch Money and returns the parsed "data" object.
-func (c *Client) GraphQLCall(operationName, query string, variables map[string]any) (map[string]json.RawMessage, error) {
+// Rate limits and server errors are retried according to the client's
+// RetryPolicy.
+// If the token has expired, a newer session saved by another process is
+// tried first; failing that, a reauthenticate function, if set, is called
+// once and the query retried with the new token.
+func (c *Client) GraphQLCall(ctx context.Context, operationName, query string, variables map[string]any) (data map[string]json.RawMessage, err error) {
+	ctx, span := tracing.Start(ctx, "graphql "+operationName,
+		attribute.String("graphql.operation.name", operationName))
+	defer func() { tracing.End(span, err) }()
+
+	op := Operation{Name: operationName, Query: query, Variables: variables}
+	if data, ok := c.cached(ctx, op); ok {
+		span.AddEvent("cache hit")
+		c.metrics.cacheHits.WithLabelValues(operationName).Inc()
+		return data, nil
+	}
+	start := time.Now()
+	err = c.authenticated(ctx, operationName, func() error {
+		var err error
+		data, err = c.graphQLCall(ctx, operationName, query, variables)
+		return err
+	})
+	c.metrics.observe(operationName, start, err)
+	if err == nil {
+		c.remember(ctx, op, data)
+	}
+	return data, err
+}
+
+// authenticated runs call and, if the token has expired, runs it again
+// with a newer saved session or, failing that, after reauthenticating.
+func (c *Client) authenticated(ctx context.Context, operation string, call func() error) error {
+	span := trace.SpanFromContext(ctx)
+	err := call()
+	if errors.Is(err, ErrTokenExpired) && c.reloadSession(ctx) {
+		span.AddEvent("reload session")
+		c.logger.InfoContext(ctx, "token expired; using the session saved by another process", "operation", operation)
+		err = call()
+	}
+	if errors.Is(err, ErrTokenExpired) && c.reauthenticate != nil {
+		span.AddEvent("reauthenticate")
+		c.logger.InfoContext(ctx, "token expired; logging in again", "operation", operation)
+		if rerr := c.reauthenticate(ctx, c); rerr != nil {
+			return fmt.Errorf("%w; re-authentication failed: %v", err, rerr)
+		}
+		return call()
+	}
+	return err
+}
+
+func (c *Client) graphQLCall(ctx context.Context, operationName, query string, variables map[string]any) (data map[string]json.RawMessage, err error) {
+	err = c.withRetry(ctx, isMutation(query), func() error {
+		data, err = c.graphQLAttempt(ctx, operationName, query, variables)
+		return err
+	})
+	return data, err
+}
+
+func (c *Client) graphQLAttempt(ctx context.Context, operationName, query string, variables map[string]any) (map[string]json.RawMessage, error) {
 	if c.token == "" {
 		return nil, fmt.Errorf("not authenticated: call Login() first or load a session")
 	}
 
-	payload, err := json.Marshal(graphqlRequest{
+	return c.postPersisted(ctx, graphqlRequest{
 		Query:         query,
 		OperationName: operationName,
 		Variables:     variables,
 	})
+}
+
+// postGraphQL sends one GraphQL request and returns its data.
+func (c *Client) postGraphQL(ctx context.Context, gr graphqlRequest) (map[string]json.RawMessage, error) {
+	payload, err := json.Marshal(gr)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.postStream(ctx, payload)
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
+	data, err := decodeGraphQL(body)
+	if err != nil {
+		var gerr *GraphQLError
+		if errors.As(err, &gerr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("decode graphql response: %w", err)
+	}
+	return data, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, graphqlURL, bytes.NewReader(payload))
+// post sends payload to the GraphQL endpoint and returns the body of a
+// 200 OK response. Other responses become the matching error.
+func (c *Client) post(ctx context.Context, payload []byte) ([]byte, error) {
+	body, err := c.postStream(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read graphql response: %w", err)
+	}
+	return b, nil
+}
+
+// postStream is post returning the decompressed body of a 200 OK
+// response unread. The caller must close it.
+func (c *Client) postStream(ctx context.Context, payload []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+graphqlPath, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
 	c.setHeaders(req)
+	acceptGzip(req)
 
-	resp, err := c.httpClient.Do(req)
+	if c.breaker != nil {
+		if err := c.breaker.allow(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	// The API counts as failing for the breaker if it can't be reached
+	// or answers with a 5xx status; any other answer shows it is up.
+	failed := true
+	defer func() {
+		if c.breaker != nil {
+			// A canceled request says nothing about the API.
+			c.breaker.record(time.Now(), failed && ctx.Err() == nil)
+		}
+	}()
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("graphql request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("read graphql response: %w", err)
+	}
+	failed = resp.StatusCode/100 == 5
 
 	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
+		b, _ := io.ReadAll(body)
+		body.Close()
+		if isCloudflareChallenge(resp, b) {
+			return nil, fmt.Errorf("%w (HTTP %d)", ErrCloudflareChallenge, resp.StatusCode)
+		}
+		if isAuthFailure(resp.StatusCode, b) {
+			return nil, fmt.Errorf("%w (HTTP %d)", ErrTokenExpired, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, rateLimitError(resp, time.Now())
+		}
+		if resp.StatusCode/100 == 5 {
+			return nil, &ServerError{StatusCode: resp.StatusCode, Status: resp.Status, Body: b}
+		}
+		if gerr := parseGraphQLError(resp.StatusCode, b); gerr != nil {
+			return nil, gerr
+		}
 		return nil, fmt.Errorf("graphql HTTP %d: %s\n%s", resp.StatusCode, resp.Status, b)
 	}
-
-	var envelope struct {
-		Data   map[string]json.RawMessage `json:"data"`
-		Errors []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
-		return nil, fmt.Errorf("decode graphql response: %w", err)
-	}
-	if len(envelope.Errors) > 0 {
-		return nil, fmt.Errorf("graphql error: %s", envelope.Errors[0].Message)
-	}
-	return envelope.Data, nil
+	return body, nil
 }
 
 func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Client-Platform", "web")
+	req.Header.Set("Client-Platform", c.headers.ClientPlatform)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("User-Agent", c.headers.UserAgent)
+	if c.deviceUUID != "" {
+		req.Header.Set("Device-UUID", c.deviceUUID)
+	}
+	for k, v := range c.headers.Extra {
+		req.Header.Set(k, v)
+	}
+	if c.household != "" {
+		req.Header.Set(householdHeader, c.household)
+	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Token "+c.token)
 	}
 }
 
-func prompt(label string) string {
-	fmt.Fprint(os.Stdout, label)
-	sc := bufio.NewScanner(os.Stdin)
-	sc.Scan()
-	return strings.TrimSpace(sc.Text())
+// prompt prints label and reads a line from the terminal.
+func (c *Client) prompt(label string) string {
+	fmt.Fprint(c.stdout, label)
+	line, _ := c.input.ReadString('\n')
+	return strings.TrimSpace(line)
 }
 
 // openBrowser opens the given URL, preferring Chrome on macOS.
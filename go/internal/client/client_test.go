@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client with a token talking to a test server
+// that answers with handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := New(append([]Option{WithBaseURL(srv.URL)}, opts...)...)
+	c.SetToken("test")
+	return c
+}
+
+// decodeRequest decodes the JSON body of a request to a test server.
+func decodeRequest(t *testing.T, r *http.Request, v any) {
+	t.Helper()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		t.Errorf("decode request: %v", err)
+	}
+}
+
+// TestLoginErrors checks how Login classifies failed login responses.
+func TestLoginErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   error
+		// wait is the RetryAfter of a *RateLimitError; msg is expected in
+		// the error's text.
+		wait time.Duration
+		msg  string
+	}{
+		{"captcha code", http.StatusBadRequest, nil, `{"error_code":"CAPTCHA_REQUIRED","detail":"Please verify you are human."}`,
+			ErrCaptchaRequired, 0, "verify you are human"},
+		{"captcha detail", http.StatusForbidden, nil, `{"detail":"Captcha validation failed"}`, ErrCaptchaRequired, 0, ""},
+		{"unauthorized", http.StatusUnauthorized, nil, `{}`, ErrInvalidCredentials, 0, ""},
+		{"non-field error", http.StatusBadRequest, nil, `{"non_field_errors":["Unable to log in with provided credentials."]}`,
+			ErrInvalidCredentials, 0, "Unable to log in"},
+		{"password", http.StatusBadRequest, nil, `{"detail":"Incorrect password."}`, ErrInvalidCredentials, 0, "Incorrect password."},
+		{"MFA code", http.StatusForbidden, nil, `{"detail":"Invalid MFA code, try again."}`, ErrInvalidCredentials, 0, "Invalid MFA code"},
+		{"429", http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, ``, ErrRateLimited, 30 * time.Second, ""},
+		{"429 without a wait", http.StatusTooManyRequests, nil, `slow down`, ErrRateLimited, 0, ""},
+		{"throttled", http.StatusBadRequest, nil, `{"detail":"Request was throttled. Expected available in 52 seconds."}`,
+			ErrRateLimited, 52 * time.Second, ""},
+		{"throttled with header", http.StatusForbidden, http.Header{"Retry-After": {"10"}},
+			`{"detail":"Request was throttled. Expected available in 52 seconds."}`, ErrRateLimited, 10 * time.Second, ""},
+		{"MFA", http.StatusForbidden, nil, `{"detail":"Multi-Factor Auth Required"}`, ErrMFARequired, 0, ""},
+		{"unknown 400", http.StatusBadRequest, nil, `{"detail":"Something else."}`, nil, 0, "login failed (HTTP 400)"},
+	} {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range tc.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		})
+		err := c.Login(context.Background(), "user@example.com", "hunter2", "")
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+			continue
+		}
+		var rl *RateLimitError
+		if errors.As(err, &rl) && rl.RetryAfter != tc.wait {
+			t.Errorf("%s: got RetryAfter %s, want %s", tc.name, rl.RetryAfter, tc.wait)
+		}
+		for _, other := range []error{ErrCaptchaRequired, ErrInvalidCredentials, ErrRateLimited, ErrMFARequired} {
+			if other != tc.want && errors.Is(err, other) {
+				t.Errorf("%s: %v also matches %v", tc.name, err, other)
+			}
+		}
+		if !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("%s: got %q, want it to mention %q", tc.name, err, tc.msg)
+		}
+	}
+}
+
+// TestLoginMFAMethod checks that Login reads the MFA method from Monarch's
+// response and sends the next code in the field for it.
+func TestLoginMFAMethod(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		want       MFAMethod
+		// field is where the next login sends the code.
+		field string
+		msg   string
+	}{
+		{"detail", `{"detail":"Multi-Factor Auth Required"}`, MFATOTP, "totp", "multi-factor authentication required"},
+		{"totp method", `{"mfa_method":"totp","detail":"Multi-Factor Auth Required"}`, MFATOTP, "totp", ""},
+		{"email method", `{"mfa_method":"email_otp","detail":"Multi-Factor Auth Required"}`, MFAEmailOTP, "email_otp", "code sent by email"},
+		{"email code", `{"error_code":"EMAIL_OTP_REQUIRED"}`, MFAEmailOTP, "email_otp", ""},
+		{"email detail", `{"detail":"Enter the code we emailed you."}`, MFAEmailOTP, "email_otp", ""},
+		{"not JSON", `Forbidden`, MFATOTP, "totp", ""},
+	} {
+		var fields []map[string]any
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]any
+			decodeRequest(t, r, &req)
+			fields = append(fields, req)
+			if len(fields) == 1 {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(tc.body))
+				return
+			}
+			w.Write([]byte(`{"token":"fresh"}`))
+		})
+		err := c.Login(context.Background(), "user@example.com", "hunter2", "")
+		var mfa *MFAError
+		if !errors.As(err, &mfa) || mfa.Method != tc.want || !errors.Is(err, ErrMFARequired) {
+			t.Errorf("%s: got %v, want an MFAError for %s", tc.name, err, tc.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("%s: got %q, want it to mention %q", tc.name, err, tc.msg)
+		}
+		if err := c.Login(context.Background(), "user@example.com", "hunter2", "123456"); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := fields[1]; got[tc.field] != "123456" || len(got) != 5 {
+			t.Errorf("%s: sent %v, want the code as %s", tc.name, got, tc.field)
+		}
+	}
+
+	// A backup code goes in its own field, whatever Monarch asked for.
+	var sent map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		decodeRequest(t, r, &sent)
+		w.Write([]byte(`{"token":"fresh"}`))
+	})
+	if err := c.LoginWithBackupCode(context.Background(), "user@example.com", "hunter2", "abcd-efgh"); err != nil {
+		t.Fatal(err)
+	}
+	if sent["recovery_code"] != "abcd-efgh" || sent["totp"] != nil {
+		t.Errorf("sent %v, want the code as recovery_code", sent)
+	}
+}
+
+// TestLookupProfile checks the named header profiles and that a client
+// sends the one it is given.
+func TestLookupProfile(t *testing.T) {
+	for _, tc := range []struct {
+		name, userAgent, platform, origin string
+	}{
+		{"", "MonarchMoneyAPI", "web", ""},
+		{"default", "MonarchMoneyAPI", "web", ""},
+		{"custom", "MonarchMoneyAPI", "web", ""},
+		{"web", "Mozilla/5.0", "web", "https://app.monarch.com"},
+		{"mobile", "MonarchMoney/", "ios", ""},
+	} {
+		p, err := LookupProfile(tc.name)
+		if err != nil {
+			t.Errorf("%q: %v", tc.name, err)
+			continue
+		}
+		var got http.Header
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header
+			w.Write([]byte(`{"data":{}}`))
+		})
+		c.SetHeaderProfile(p)
+		if _, err := c.GraphQLCall(context.Background(), "GetMe", "query GetMe { me { id } }", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got.Get("User-Agent"), tc.userAgent) || got.Get("Client-Platform") != tc.platform || got.Get("Origin") != tc.origin {
+			t.Errorf("%q: sent User-Agent %q, Client-Platform %q, Origin %q, want %s…, %s, %q",
+				tc.name, got.Get("User-Agent"), got.Get("Client-Platform"), got.Get("Origin"), tc.userAgent, tc.platform, tc.origin)
+		}
+	}
+	if _, err := LookupProfile("desktop"); err == nil || !strings.Contains(err.Error(), `unknown header profile "desktop"`) {
+		t.Errorf("got %v, want an unknown profile", err)
+	}
+}
+
+func TestIsCloudflareChallenge(t *testing.T) {
+	htmlPage := http.Header{"Server": {"cloudflare"}, "Content-Type": {"text/html; charset=UTF-8"}}
+	apiJSON := http.Header{"Server": {"cloudflare"}, "Content-Type": {"application/json"}}
+	for _, tc := range []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   bool
+	}{
+		{"cf-mitigated", http.StatusOK, http.Header{"Cf-Mitigated": {"challenge"}}, ``, true},
+		{"403 page", http.StatusForbidden, htmlPage, `<html>...</html>`, true},
+		{"503 page", http.StatusServiceUnavailable, htmlPage, `<title>Just a moment...</title>`, true},
+		{"429 marker", http.StatusTooManyRequests, apiJSON, `{"cf-chl":"x"}`, true},
+		{"just a moment", http.StatusForbidden, http.Header{"Server": {"Cloudflare"}}, `Just a moment...`, true},
+		{"API 403 through cloudflare", http.StatusForbidden, apiJSON, `{"detail":"Multi-Factor Auth Required"}`, false},
+		{"API 429 through cloudflare", http.StatusTooManyRequests, apiJSON, `{"detail":"Request was throttled."}`, false},
+		{"other server", http.StatusForbidden, http.Header{"Server": {"nginx"}, "Content-Type": {"text/html"}}, `<html>cf-chl</html>`, false},
+		{"200 page", http.StatusOK, htmlPage, `<html>cf-chl</html>`, false},
+		{"500 page", http.StatusInternalServerError, htmlPage, `<html>cf-chl</html>`, false},
+	} {
+		resp := &http.Response{StatusCode: tc.status, Header: tc.header}
+		if got := isCloudflareChallenge(resp, []byte(tc.body)); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cf-Mitigated", "challenge")
+		w.WriteHeader(http.StatusForbidden)
+	})
+	if err := c.Login(context.Background(), "user@example.com", "hunter2", ""); !errors.Is(err, ErrCloudflareChallenge) {
+		t.Errorf("Login: got %v, want ErrCloudflareChallenge", err)
+	}
+	if _, err := c.GraphQLCall(context.Background(), "GetMe", "query GetMe { me { id } }", nil); !errors.Is(err, ErrCloudflareChallenge) {
+		t.Errorf("GraphQLCall: got %v, want ErrCloudflareChallenge", err)
+	}
+}
+
+// TestSessionCookies checks that cookies pasted with SetCookies or set by
+// the API are sent with requests and kept with the saved session.
+func TestSessionCookies(t *testing.T) {
+	var cookies []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for _, ck := range r.Cookies() {
+			names = append(names, ck.Name+"="+ck.Value)
+		}
+		cookies = append(cookies, strings.Join(names, "; "))
+		http.SetCookie(w, &http.Cookie{Name: "__cf_bm", Value: "from-api", Path: "/"})
+		w.Write([]byte(`{"data":{}}`))
+	}
+	call := func(c *Client) {
+		t.Helper()
+		if _, err := c.GraphQLCall(context.Background(), "GetMe", "query GetMe { me { id } }", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	c := New(WithBaseURL(srv.URL))
+	c.SetSessionFile(path)
+	c.SetToken("saved-token")
+	if err := c.SetCookies("cf_clearance=pasted; theme=dark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCookies("no-equals-sign"); err == nil {
+		t.Error("SetCookies accepted a malformed header")
+	}
+	call(c)
+	if cookies[0] != "cf_clearance=pasted; theme=dark" {
+		t.Errorf("sent cookies %q, want the pasted ones", cookies[0])
+	}
+	if err := c.SaveSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(WithBaseURL(srv.URL))
+	loaded.SetSessionFile(path)
+	if ok, err := loaded.LoadSession(context.Background()); !ok || err != nil {
+		t.Fatalf("LoadSession: %v, %v", ok, err)
+	}
+	if loaded.Token() != "saved-token" {
+		t.Errorf("loaded token %q", loaded.Token())
+	}
+	call(loaded)
+	got := strings.Split(cookies[1], "; ")
+	sort.Strings(got)
+	if want := []string{"__cf_bm=from-api", "cf_clearance=pasted", "theme=dark"}; strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("sent cookies %q after loading the session, want %q", got, want)
+	}
+
+	// A session without cookies leaves the jar alone.
+	if err := (FileStore{Path: path}).Save([]byte(`{"token":"bare"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := loaded.LoadSession(context.Background()); !ok || err != nil || loaded.Token() != "bare" {
+		t.Errorf("LoadSession: %v, %v, token %q", ok, err, loaded.Token())
+	}
+}
@@ -0,0 +1,181 @@
+// Package clienttest provides an in-memory Monarch API for tests of code
+// that takes a client.API.
+package clienttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/heikofkoehler/monarch/internal/client"
+)
+
+// Call is a GraphQL operation the fake received.
+type Call struct {
+	Operation string
+	Variables map[string]any
+}
+
+// Fake answers GraphQL operations with canned "data" objects. Its zero
+// value answers nothing; set Responses or use LoadDir. It is safe for
+// concurrent use.
+type Fake struct {
+	// Responses are the data objects by operation name.
+	Responses map[string]json.RawMessage
+	// Errors fail operations by name, taking precedence over Responses.
+	Errors map[string]error
+	// Email and Password, if set, are the only credentials Login accepts.
+	Email, Password string
+
+	mu      sync.Mutex
+	token   string
+	session string
+	calls   []Call
+}
+
+// LoadDir returns a fake answering each operation with the data object in
+// dir/<operationName>.json.
+func LoadDir(dir string) (*Fake, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	f := &Fake{Responses: make(map[string]json.RawMessage, len(files))}
+	for _, path := range files {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("%s: invalid JSON", path)
+		}
+		f.Responses[strings.TrimSuffix(filepath.Base(path), ".json")] = raw
+	}
+	return f, nil
+}
+
+// Calls returns the operations received so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Login sets the token "fake-token" if the credentials match.
+func (f *Fake) Login(ctx context.Context, email, password, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if (f.Email != "" && email != f.Email) || (f.Password != "" && password != f.Password) {
+		return fmt.Errorf("login failed: HTTP 401")
+	}
+	f.token = "fake-token"
+	return nil
+}
+
+// Logout clears the token.
+func (f *Fake) Logout(ctx context.Context) error {
+	f.SetToken("")
+	return ctx.Err()
+}
+
+// Token returns the current token.
+func (f *Fake) Token() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.token
+}
+
+// SetToken replaces the token.
+func (f *Fake) SetToken(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = token
+}
+
+// SaveSession keeps the token in memory.
+func (f *Fake) SaveSession(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.session = f.token
+	return nil
+}
+
+// LoadSession restores the token saved last.
+func (f *Fake) LoadSession(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.session == "" {
+		return false, nil
+	}
+	f.token = f.session
+	return true, nil
+}
+
+// DeleteSession forgets the saved token.
+func (f *Fake) DeleteSession(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.session = ""
+	return nil
+}
+
+// GraphQLCall records the call and returns the canned data object for
+// operationName. Unknown operations fail with a GraphQL validation error,
+// as they would against Monarch.
+func (f *Fake) GraphQLCall(ctx context.Context, operationName, query string, variables map[string]any) (map[string]json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Operation: operationName, Variables: variables})
+	if f.token == "" {
+		return nil, errors.New("not authenticated: call Login() first or load a session")
+	}
+	if err := f.Errors[operationName]; err != nil {
+		return nil, err
+	}
+	raw, ok := f.Responses[operationName]
+	if !ok {
+		return nil, &client.GraphQLError{StatusCode: 400, Errors: []client.GraphQLErrorItem{{
+			Message:    fmt.Sprintf("no response for %s", operationName),
+			Extensions: map[string]any{"code": client.CodeValidationFailed},
+		}}}
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("decode graphql response: %w", err)
+	}
+	return data, nil
+}
+
+// BatchCall answers each operation as GraphQLCall does.
+func (f *Fake) BatchCall(ctx context.Context, ops ...client.Operation) ([]client.BatchResult, error) {
+	results := make([]client.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i].Data, results[i].Err = f.GraphQLCall(ctx, op.Name, op.Query, op.Variables)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+var _ client.API = (*Fake)(nil)
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// clipboardCommands lists the commands that print the clipboard, in order
+// of preference, for each platform. Linux has one per display server.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell", "-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard -Raw"}},
+	"linux": {
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-selection", "clipboard", "-o"},
+		{"xsel", "--clipboard", "--output"},
+	},
+}
+
+// errNoClipboard means no clipboard command is available, so the user has
+// to paste by hand.
+var errNoClipboard = errors.New("no clipboard command available")
+
+// readClipboard returns the text on the system clipboard.
+func readClipboard() (string, error) {
+	for _, args := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+	return "", errNoClipboard
+}
+
+// consoleShortcut is the key combination that opens Chrome's JavaScript
+// console.
+func consoleShortcut() string {
+	if runtime.GOOS == "darwin" {
+		return "Cmd+Option+J"
+	}
+	return "Ctrl+Shift+J"
+}
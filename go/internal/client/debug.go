@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithDebugLogging writes every request and response, with headers and
+// bodies, to w, to diagnose changes in Monarch's API. Tokens, cookies,
+// passwords and one-time codes are redacted.
+func WithDebugLogging(w io.Writer) Option {
+	return func(c *Client) {
+		c.debug = w
+	}
+}
+
+// debugTransport logs the exchanges of the transport it wraps.
+type debugTransport struct {
+	mu   sync.Mutex
+	w    io.Writer
+	base http.RoundTripper
+}
+
+// redactedHeaders are logged without their values.
+var redactedHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Set-Cookie": true}
+
+// secretFields matches JSON string fields holding credentials, in login
+// requests and responses.
+var secretFields = regexp.MustCompile(`"(password|totp|email_otp|recovery_code|token)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %s %s\n", req.Method, req.URL)
+	writeHeaders(&b, req.Header)
+	writeBody(&b, reqBody)
+	if err != nil {
+		fmt.Fprintf(&b, "<-- error after %s: %v\n\n", elapsed, err)
+		t.write(b.String())
+		return nil, err
+	}
+	respBody, rerr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	fmt.Fprintf(&b, "<-- %d %s (%s)\n", resp.StatusCode, http.StatusText(resp.StatusCode), elapsed)
+	writeHeaders(&b, resp.Header)
+	writeBody(&b, decompressed(resp.Header, respBody))
+	t.write(b.String())
+	if rerr != nil {
+		return nil, rerr
+	}
+	return resp, nil
+}
+
+func (t *debugTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, s)
+}
+
+func writeHeaders(b *strings.Builder, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if redactedHeaders[name] {
+				v = "[REDACTED]"
+			}
+			fmt.Fprintf(b, "%s: %s\n", name, v)
+		}
+	}
+}
+
+func writeBody(b *strings.Builder, body []byte) {
+	b.WriteString("\n")
+	if len(body) > 0 {
+		b.Write(secretFields.ReplaceAll(bytes.TrimSpace(body), []byte(`"$1"$2"[REDACTED]"`)))
+		b.WriteString("\n\n")
+	}
+}
+
+// decompressed returns body as sent, before any gzip compression.
+func decompressed(h http.Header, body []byte) []byte {
+	if !strings.EqualFold(h.Get("Content-Encoding"), "gzip") {
+		return body
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	if plain, err := io.ReadAll(zr); err == nil {
+		return plain
+	}
+	return body
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+// Sessions asking for more than maxPBKDF2Iterations are rejected rather
+// than left to stall every command.
+const (
+	pbkdf2Iterations    = 600_000
+	maxPBKDF2Iterations = 10 * pbkdf2Iterations
+)
+
+// ErrWrongPassphrase is returned when an encrypted session cannot be
+// decrypted with the given passphrase.
+var ErrWrongPassphrase = errors.New("cannot decrypt session: wrong passphrase or corrupted file")
+
+// EncryptedStore encrypts the session with AES-256-GCM before handing it
+// to Store, using a key derived from Passphrase with PBKDF2.
+type EncryptedStore struct {
+	Store      SessionStore
+	Passphrase []byte
+}
+
+// encryptedSession is the envelope written to the underlying store.
+type encryptedSession struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (e EncryptedStore) Load() ([]byte, error) {
+	raw, err := e.Store.Load()
+	if err != nil || raw == nil {
+		return raw, err
+	}
+	var env encryptedSession
+	if err := json.Unmarshal(raw, &env); err != nil || env.Ciphertext == nil {
+		// A plaintext session from before encryption was enabled; encrypt
+		// it now rather than leave it readable until the next login.
+		if err := e.Save(raw); err != nil {
+			return nil, fmt.Errorf("encrypt plaintext session: %w", err)
+		}
+		return raw, nil
+	}
+	if env.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported session key derivation %q", env.KDF)
+	}
+	if env.Iterations < 1 || env.Iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("session key derivation: %d iterations is out of range", env.Iterations)
+	}
+	gcm, err := e.cipher(env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	data, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
+
+func (e EncryptedStore) Save(data []byte) error {
+	env := encryptedSession{
+		KDF:        "pbkdf2-sha256",
+		Iterations: pbkdf2Iterations,
+		Salt:       make([]byte, 16),
+	}
+	rand.Read(env.Salt)
+	gcm, err := e.cipher(env.Salt, env.Iterations)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	rand.Read(env.Nonce)
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, data, nil)
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return e.Store.Save(raw)
+}
+
+func (e EncryptedStore) Delete() error {
+	return e.Store.Delete()
+}
+
+func (e EncryptedStore) cipher(salt []byte, iterations int) (cipher.AEAD, error) {
+	if len(e.Passphrase) == 0 {
+		return nil, fmt.Errorf("session encryption needs a passphrase")
+	}
+	key, err := pbkdf2.Key(sha256.New, string(e.Passphrase), salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	file := FileStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	store := EncryptedStore{Store: file, Passphrase: []byte("correct horse")}
+	session := []byte(`{"token":"secret-token"}`)
+	if err := store.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("secret-token")) {
+		t.Errorf("session saved in plaintext: %s", raw)
+	}
+	got, err := store.Load()
+	if err != nil || !bytes.Equal(got, session) {
+		t.Errorf("got %s, %v, want %s", got, err, session)
+	}
+
+	wrong := EncryptedStore{Store: file, Passphrase: []byte("wrong horse")}
+	if _, err := wrong.Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("got %v, want ErrWrongPassphrase", err)
+	}
+
+	// Tampered envelopes fail without trying to decrypt them for long.
+	for name, tamper := range map[string]func(*encryptedSession){
+		"iterations": func(env *encryptedSession) { env.Iterations = 1 << 40 },
+		"zero":       func(env *encryptedSession) { env.Iterations = 0 },
+		"nonce":      func(env *encryptedSession) { env.Nonce = env.Nonce[:4] },
+		"kdf":        func(env *encryptedSession) { env.KDF = "scrypt" },
+	} {
+		var env encryptedSession
+		if err := json.Unmarshal(raw, &env); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&env)
+		data, _ := json.Marshal(env)
+		if err := file.Save(data); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Load(); err == nil {
+			t.Errorf("%s: loaded %s from a tampered session", name, got)
+		}
+	}
+}
+
+// TestEncryptedStorePlaintext checks that a session saved before
+// encryption was enabled is loaded and encrypted in place.
+func TestEncryptedStorePlaintext(t *testing.T) {
+	file := FileStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	session := []byte(`{"token":"secret-token"}`)
+	if err := file.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	store := EncryptedStore{Store: file, Passphrase: []byte("correct horse")}
+	got, err := store.Load()
+	if err != nil || !bytes.Equal(got, session) {
+		t.Fatalf("got %s, %v, want %s", got, err, session)
+	}
+	raw, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"kdf":"pbkdf2-sha256"`) || bytes.Contains(raw, []byte("secret-token")) {
+		t.Errorf("plaintext session left as %s", raw)
+	}
+	if got, err := store.Load(); err != nil || !bytes.Equal(got, session) {
+		t.Errorf("reloaded %s, %v, want %s", got, err, session)
+	}
+
+	// Without a passphrase the plaintext is left alone.
+	if err := file.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (EncryptedStore{Store: file}).Load(); err == nil {
+		t.Error("loaded a plaintext session without a passphrase to encrypt it")
+	}
+	if raw, _ := os.ReadFile(file.Path); !bytes.Equal(raw, session) {
+		t.Errorf("session changed to %s", raw)
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error codes Monarch's GraphQL server puts in extensions.code.
+const (
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+)
+
+// GraphQLError is returned by GraphQLCall when the response lists errors.
+// An error with the code CodeUnauthenticated matches ErrTokenExpired with
+// errors.Is, so it triggers the same session reload and reauthentication
+// as an HTTP 401.
+type GraphQLError struct {
+	// StatusCode is the HTTP status of the response: 200 for errors
+	// raised while executing the query, usually 400 for a query the
+	// server refused.
+	StatusCode int
+	Errors     []GraphQLErrorItem
+	// Data holds the fields of the response's data that did resolve, if
+	// any, as GraphQLCall would have returned them.
+	Data map[string]json.RawMessage
+	// Body is the raw response, or its first maxErrorBody bytes for a 200
+	// response, which is decoded as it is read.
+	Body []byte
+}
+
+// GraphQLErrorItem is one entry of the "errors" list of a response.
+type GraphQLErrorItem struct {
+	Message string `json:"message"`
+	// Path leads to the field that failed, as names and list indexes.
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// Code returns extensions.code, or "" if the server didn't set one.
+func (e GraphQLErrorItem) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// PathString returns Path joined with dots, e.g. "accounts.2.balance".
+func (e GraphQLErrorItem) PathString() string {
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		switch p := p.(type) {
+		case float64:
+			parts[i] = strconv.Itoa(int(p))
+		default:
+			parts[i] = fmt.Sprint(p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (e GraphQLErrorItem) String() string {
+	s := e.Message
+	if p := e.PathString(); p != "" {
+		s += " at " + p
+	}
+	if code := e.Code(); code != "" {
+		s += " (" + code + ")"
+	}
+	return s
+}
+
+func (e *GraphQLError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, item := range e.Errors {
+		msgs[i] = item.String()
+	}
+	return "graphql error: " + strings.Join(msgs, "; ")
+}
+
+// HasCode reports whether any of the errors has the given code.
+func (e *GraphQLError) HasCode(code string) bool {
+	for _, item := range e.Errors {
+		if item.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *GraphQLError) Is(target error) bool {
+	return target == ErrTokenExpired && e.HasCode(CodeUnauthenticated)
+}
+
+// parseGraphQLError returns the errors listed in body, or nil if it lists
+// none.
+func parseGraphQLError(status int, body []byte) *GraphQLError {
+	var envelope struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []GraphQLErrorItem         `json:"errors"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || len(envelope.Errors) == 0 {
+		return nil
+	}
+	return &GraphQLError{StatusCode: status, Errors: envelope.Errors, Data: envelope.Data, Body: body}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLErrorItem(t *testing.T) {
+	for _, tc := range []struct {
+		item GraphQLErrorItem
+		want string
+	}{
+		{GraphQLErrorItem{Message: "Boom"}, "Boom"},
+		{GraphQLErrorItem{Message: "Unknown field", Path: []any{"portfolio", "holdings", float64(2)}}, "Unknown field at portfolio.holdings.2"},
+		{GraphQLErrorItem{Message: "Not allowed", Extensions: map[string]any{"code": CodeForbidden}}, "Not allowed (FORBIDDEN)"},
+		{GraphQLErrorItem{Message: "Odd code", Extensions: map[string]any{"code": 3}}, "Odd code"},
+	} {
+		if got := tc.item.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+// TestGraphQLError checks that errors listed by a refused query come back
+// whole, with their path and code, and that only an unauthenticated one
+// counts as an expired token.
+func TestGraphQLError(t *testing.T) {
+	body := `{"data":null,"errors":[` +
+		`{"message":"Unknown field","path":["portfolio","holdings",2],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}},` +
+		`{"message":"Not allowed","extensions":{"code":"FORBIDDEN"}}]}`
+	status := http.StatusBadRequest
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+	call := func() error {
+		_, err := c.GraphQLCall(context.Background(), "Web_GetPortfolio", "query Web_GetPortfolio { portfolio { id } }", nil)
+		return err
+	}
+
+	err := call()
+	var gerr *GraphQLError
+	if !errors.As(err, &gerr) {
+		t.Fatalf("got %v, want a GraphQLError", err)
+	}
+	want := "graphql error: Unknown field at portfolio.holdings.2 (GRAPHQL_VALIDATION_FAILED); Not allowed (FORBIDDEN)"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if gerr.StatusCode != status || len(gerr.Errors) != 2 || !gerr.HasCode(CodeForbidden) || gerr.HasCode(CodeUnauthenticated) {
+		t.Errorf("got %+v, want both of the response's errors", gerr)
+	}
+	if string(gerr.Body) != body {
+		t.Errorf("got body %s, want the raw response", gerr.Body)
+	}
+	if errors.Is(err, ErrTokenExpired) {
+		t.Error("a validation error matches ErrTokenExpired")
+	}
+
+	status = http.StatusOK
+	body = `{"errors":[{"message":"Token expired","extensions":{"code":"UNAUTHENTICATED"}}]}`
+	if err := call(); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("got %v, want an unauthenticated error to match ErrTokenExpired", err)
+	}
+	if err := call(); !strings.Contains(err.Error(), "Token expired (UNAUTHENTICATED)") {
+		t.Errorf("got %q, want the server's message", err)
+	}
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptGzip asks for a gzip-compressed response. Setting the header
+// turns off the transparent decompression of http.Transport, which other
+// transports lack anyway, so the response is read with responseBody.
+func acceptGzip(req *http.Request) {
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// responseBody returns the body of resp, decompressed if the server
+// compressed it.
+func responseBody(resp *http.Response) (io.ReadCloser, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return gzipBody{zr, resp.Body}, nil
+}
+
+// gzipBody reads the decompressed body and closes the compressed one.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
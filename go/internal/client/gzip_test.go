@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestGzip checks that responses are requested compressed and
+// decompressed, with and without debug logging.
+func TestGzip(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		var log bytes.Buffer
+		var opts []Option
+		if debug {
+			opts = append(opts, WithDebugLogging(&log))
+		}
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				t.Errorf("debug=%t: request accepts %q, want gzip", debug, r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte(`{"data": {"me": {"id": "u1"}}}`))
+			zw.Close()
+		}, opts...)
+		data, err := c.GraphQLCall(context.Background(), "Me", "query Me { me { id } }", nil)
+		if err != nil {
+			t.Fatalf("debug=%t: %v", debug, err)
+		}
+		if string(data["me"]) != `{"id": "u1"}` {
+			t.Errorf("debug=%t: got %s", debug, data["me"])
+		}
+		if debug && !strings.Contains(log.String(), `"me"`) {
+			t.Errorf("debug log lacks the decompressed response:\n%s", log.String())
+		}
+	}
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not gzip"))
+	})
+	if _, err := c.GraphQLCall(context.Background(), "Me", "query Me { me { id } }", nil); err == nil {
+		t.Error("no error for a response that isn't gzip")
+	}
+}
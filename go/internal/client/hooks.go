@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestHook is called with each HTTP request just before it is sent,
+// retries included, and may change it: add headers, start a trace span or
+// sign it. An error aborts the request with that error.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook is called after each HTTP exchange with the request, the
+// response or the error sending it, and the time it took, e.g. to record
+// metrics. It must not read or close resp.Body, which the client still
+// needs.
+type ResponseHook func(req *http.Request, resp *http.Response, elapsed time.Duration, err error)
+
+// OnRequest adds h to the hooks run before each request, after those
+// added earlier. Add hooks before using the client from several
+// goroutines.
+func (c *Client) OnRequest(h RequestHook) {
+	c.requestHooks = append(c.requestHooks, h)
+}
+
+// OnResponse adds h to the hooks run after each response, after those
+// added earlier.
+func (c *Client) OnResponse(h ResponseHook) {
+	c.responseHooks = append(c.responseHooks, h)
+}
+
+// do sends req through the hooks.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	for _, h := range c.requestHooks {
+		if err := h(req); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err == nil {
+		c.noteQuota(resp)
+	}
+	for _, h := range c.responseHooks {
+		h(req, resp, time.Since(start), err)
+	}
+	return resp, err
+}
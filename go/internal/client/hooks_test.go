@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestClientHooks checks that request hooks run in order before every
+// attempt, that response hooks see every status, and that a failing
+// request hook aborts the request.
+func TestClientHooks(t *testing.T) {
+	failures := 1
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			http.Error(w, "maintenance", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"data": {"accounts": []}}`))
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	var headers []string
+	var statuses []int
+	c.OnRequest(func(req *http.Request) error {
+		req.Header.Set("X-Signature", "signed")
+		return nil
+	})
+	c.OnRequest(func(req *http.Request) error {
+		headers = append(headers, req.Header.Get("X-Signature"))
+		return nil
+	})
+	c.OnResponse(func(req *http.Request, resp *http.Response, elapsed time.Duration, err error) {
+		if err != nil {
+			t.Errorf("response hook got error %v", err)
+			return
+		}
+		statuses = append(statuses, resp.StatusCode)
+	})
+	call := func() error {
+		_, err := c.GraphQLCall(context.Background(), "GetAccounts", "query GetAccounts { accounts { id } }", nil)
+		return err
+	}
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(headers, []string{"signed", "signed"}) || !slices.Equal(statuses, []int{http.StatusBadGateway, http.StatusOK}) {
+		t.Errorf("got headers %q and statuses %v, want both attempts signed and seen", headers, statuses)
+	}
+
+	errVeto := errors.New("vetoed")
+	c.OnRequest(func(*http.Request) error { return errVeto })
+	if err := call(); !errors.Is(err, errVeto) {
+		t.Errorf("got error %v, want the request hook's", err)
+	}
+}
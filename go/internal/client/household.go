@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// householdHeader selects the household a request acts on. Without it the
+// API uses the login's current household.
+const householdHeader = "Monarch-Household-Id"
+
+const householdsQuery = `query Common_GetHouseholds {
+  me {
+    id
+    households {
+      id
+      name
+      __typename
+    }
+    __typename
+  }
+}`
+
+// Household is one of the households a login is a member of.
+type Household struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SetHousehold makes later API calls act on the household with the given
+// ID. An empty ID selects the login's current household.
+func (c *Client) SetHousehold(id string) {
+	c.household = id
+}
+
+// Household returns the household ID set with SetHousehold.
+func (c *Client) Household() string {
+	return c.household
+}
+
+// Households lists the households the logged-in user belongs to.
+func (c *Client) Households(ctx context.Context) ([]Household, error) {
+	data, err := c.GraphQLCall(ctx, "Common_GetHouseholds", householdsQuery, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	var me struct {
+		Households []Household `json:"households"`
+	}
+	if err := json.Unmarshal(data["me"], &me); err != nil {
+		return nil, fmt.Errorf("decode households: %w", err)
+	}
+	return me.Households, nil
+}
+
+// FindHousehold returns the household whose ID or name (ignoring case)
+// matches s.
+func FindHousehold(households []Household, s string) (Household, error) {
+	for _, h := range households {
+		if h.ID == s {
+			return h, nil
+		}
+	}
+	var found []Household
+	for _, h := range households {
+		if strings.EqualFold(h.Name, s) {
+			found = append(found, h)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Household{}, fmt.Errorf("no household %q", s)
+	case 1:
+		return found[0], nil
+	}
+	return Household{}, fmt.Errorf("several households are named %q; use its ID", s)
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	keyringService = "monarch"
+	// DefaultKeyringAccount is the keyring entry used for the default session.
+	DefaultKeyringAccount = "session"
+
+	// securityNotFound is the exit status of security for a missing entry
+	// (errSecItemNotFound).
+	securityNotFound = 44
+)
+
+// KeyringStore keeps the session in the OS credential store: the macOS
+// Keychain (via security), libsecret on Linux (via secret-tool) or the
+// Windows Credential Manager (via PowerShell's PasswordVault).
+type KeyringStore struct {
+	Service string
+	Account string
+}
+
+// NewKeyringStore returns a KeyringStore for the given account entry.
+func NewKeyringStore(account string) KeyringStore {
+	return KeyringStore{Service: keyringService, Account: account}
+}
+
+// KeyringAvailable reports whether the OS keyring tool for this platform is installed.
+func KeyringAvailable() bool {
+	tool := map[string]string{
+		"darwin":  "security",
+		"linux":   "secret-tool",
+		"windows": "powershell",
+	}[runtime.GOOS]
+	if tool == "" {
+		return false
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+func (k KeyringStore) Load() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", k.Service, "-a", k.Account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", k.Service, "account", k.Account)
+	case "windows":
+		cmd = powershell(`$v = New-Object Windows.Security.Credentials.PasswordVault
+try { $c = $v.Retrieve($env:MM_SERVICE, $env:MM_ACCOUNT) } catch { exit 0 }
+$c.RetrievePassword(); [Console]::Out.Write($c.Password)`, k)
+	default:
+		return nil, fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w%s", err, toolOutput(err))
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func (k KeyringStore) Save(data []byte) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// The secret goes through stdin, hex-encoded with -X, to keep it out
+		// of the process list; -U updates an existing entry instead of
+		// failing.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+			securityQuote(k.Service), securityQuote(k.Account), hex.EncodeToString(data)))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=Monarch session", "service", k.Service, "account", k.Account)
+		cmd.Stdin = bytes.NewReader(data)
+	case "windows":
+		cmd = powershell(`$v = New-Object Windows.Security.Credentials.PasswordVault
+$secret = [Console]::In.ReadToEnd()
+$v.Add((New-Object Windows.Security.Credentials.PasswordCredential($env:MM_SERVICE, $env:MM_ACCOUNT, $secret)))`, k)
+		cmd.Stdin = bytes.NewReader(data)
+	default:
+		return fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("write keyring: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (k KeyringStore) Delete() error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", k.Service, "-a", k.Account)
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", k.Service, "account", k.Account)
+	case "windows":
+		cmd = powershell(`$v = New-Object Windows.Security.Credentials.PasswordVault
+try { $c = $v.Retrieve($env:MM_SERVICE, $env:MM_ACCOUNT) } catch { exit 0 }
+$v.Remove($c)`, k)
+	default:
+		return fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+	// A missing entry is not an error; secret-tool clear and the
+	// PowerShell script exit 0 for one.
+	if _, err := cmd.Output(); err != nil && !notFound(err) {
+		return fmt.Errorf("delete keyring entry: %w%s", err, toolOutput(err))
+	}
+	return nil
+}
+
+// notFound reports whether err is the keyring tool's exit status for a
+// missing entry: 44 from security, or 1 without an error message from
+// secret-tool lookup.
+func notFound(err error) bool {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return exit.ExitCode() == securityNotFound
+	case "linux":
+		return exit.ExitCode() == 1 && len(bytes.TrimSpace(exit.Stderr)) == 0
+	}
+	return false
+}
+
+// toolOutput returns the error message a keyring tool printed before
+// failing, for appending to an error.
+func toolOutput(err error) string {
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if msg := strings.TrimSpace(string(exit.Stderr)); msg != "" {
+			return ": " + msg
+		}
+	}
+	return ""
+}
+
+// securityQuote quotes s for a command line read by security -i.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// powershell runs script with the PasswordVault type loaded. The service and
+// account are passed through the environment to avoid quoting issues.
+func powershell(script string, k KeyringStore) *exec.Cmd {
+	script = "[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]\n" + script
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.Env = append(cmd.Environ(), "MM_SERVICE="+k.Service, "MM_ACCOUNT="+k.Account)
+	return cmd
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics counts the client's GraphQL operations by name. The client
+// records them whether or not anyone collects them.
+type metrics struct {
+	requests  *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	cacheHits *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monarch_client_requests_total",
+			Help: "GraphQL operations sent to the API, by operation name.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monarch_client_request_errors_total",
+			Help: "GraphQL operations that failed after retries, by operation name and reason.",
+		}, []string{"operation", "reason"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monarch_client_cache_hits_total",
+			Help: "GraphQL operations answered from the response cache, by operation name.",
+		}, []string{"operation"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "monarch_client_request_duration_seconds",
+			Help:    "Time taken by GraphQL operations, retries and rate limiting included, by operation name.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+	}
+}
+
+// Metrics returns a collector of the client's request counts, errors and
+// latencies per GraphQL operation, to register with a Prometheus
+// registry. An operation sent in a batch counts the whole batch's time.
+func (c *Client) Metrics() prometheus.Collector {
+	return c.metrics
+}
+
+func (m *metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.errors.Describe(ch)
+	m.cacheHits.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+func (m *metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.errors.Collect(ch)
+	m.cacheHits.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// observe records an operation sent at start that ended with err.
+func (m *metrics) observe(operation string, start time.Time, err error) {
+	m.requests.WithLabelValues(operation).Inc()
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation, errorReason(err)).Inc()
+	}
+}
+
+// errorReason classifies err for the reason label.
+func errorReason(err error) string {
+	var gerr *GraphQLError
+	var serr *ServerError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrTokenExpired):
+		return "auth"
+	case errors.As(err, &gerr):
+		return "graphql"
+	case errors.As(err, &serr):
+		return "server"
+	default:
+		return "other"
+	}
+}
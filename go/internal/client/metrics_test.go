@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestClientMetrics checks that the client counts requests, errors and
+// latencies by operation.
+func TestClientMetrics(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		decodeRequest(t, r, &req)
+		if req.OperationName == "GetNothing" {
+			w.Write([]byte(`{"errors": [{"message": "no such field"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"accounts": []}}`))
+	})
+	for range 2 {
+		if _, err := c.GraphQLCall(context.Background(), "GetAccounts", "query GetAccounts { accounts { id } }", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.GraphQLCall(context.Background(), "GetNothing", "query GetNothing { nothing }", nil); err == nil {
+		t.Fatal("no error for a failed operation")
+	}
+	want := `
+# HELP monarch_client_request_errors_total GraphQL operations that failed after retries, by operation name and reason.
+# TYPE monarch_client_request_errors_total counter
+monarch_client_request_errors_total{operation="GetNothing",reason="graphql"} 1
+# HELP monarch_client_requests_total GraphQL operations sent to the API, by operation name.
+# TYPE monarch_client_requests_total counter
+monarch_client_requests_total{operation="GetAccounts"} 2
+monarch_client_requests_total{operation="GetNothing"} 1
+`
+	if err := testutil.CollectAndCompare(c.Metrics(), strings.NewReader(want),
+		"monarch_client_requests_total", "monarch_client_request_errors_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c.Metrics(), "monarch_client_request_duration_seconds"); n != 2 {
+		t.Errorf("got %d latency histograms, want one per operation", n)
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{context.Canceled, "canceled"},
+		{fmt.Errorf("graphql request failed: %w", context.DeadlineExceeded), "canceled"},
+		{&CircuitOpenError{}, "circuit_open"},
+		{&RateLimitError{}, "rate_limited"},
+		{fmt.Errorf("%w (HTTP 401)", ErrTokenExpired), "auth"},
+		{&GraphQLError{Errors: []GraphQLErrorItem{{Extensions: map[string]any{"code": CodeUnauthenticated}}}}, "auth"},
+		{&GraphQLError{}, "graphql"},
+		{&ServerError{StatusCode: 502}, "server"},
+		{errors.New("boom"), "other"},
+	} {
+		if got := errorReason(tc.err); got != tc.want {
+			t.Errorf("errorReason(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
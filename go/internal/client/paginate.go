@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// pageInfo is the Relay pagination state of a connection.
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Paginate runs a query over a cursor-paginated connection and calls fn
+// with the data of each page in turn. pageInfoPath is the dotted path of
+// the connection's pageInfo in the data, e.g.
+// "portfolio.aggregateHoldings.pageInfo"; the query must select its
+// hasNextPage and endCursor and take the cursor as the $after variable.
+// Paging stops after a page without a next one or without pageInfo, or
+// when fn returns an error, which Paginate returns.
+func (c *Client) Paginate(ctx context.Context, operationName, query string, variables map[string]any, pageInfoPath string, fn func(data map[string]json.RawMessage) error) error {
+	return Paginate(ctx, c, operationName, query, variables, pageInfoPath, fn)
+}
+
+// Paginate is Client.Paginate for any API.
+func Paginate(ctx context.Context, api API, operationName, query string, variables map[string]any, pageInfoPath string, fn func(data map[string]json.RawMessage) error) error {
+	vars := maps.Clone(variables)
+	if vars == nil {
+		vars = map[string]any{}
+	}
+	seen := map[string]bool{}
+	for page := 1; ; page++ {
+		data, err := api.GraphQLCall(ctx, operationName, query, vars)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		info, err := findPageInfo(data, pageInfoPath)
+		if err != nil {
+			return fmt.Errorf("%s page %d: %w", operationName, page, err)
+		}
+		if info == nil || !info.HasNextPage {
+			return nil
+		}
+		if info.EndCursor == "" || seen[info.EndCursor] {
+			return fmt.Errorf("%s page %d: hasNextPage without a new endCursor", operationName, page)
+		}
+		seen[info.EndCursor] = true
+		vars["after"] = info.EndCursor
+	}
+}
+
+// findPageInfo decodes the pageInfo at the dotted path in data, or returns
+// nil if any part of the path is missing or null.
+func findPageInfo(data map[string]json.RawMessage, path string) (*pageInfo, error) {
+	keys := strings.Split(path, ".")
+	raw, ok := data[keys[0]]
+	for _, key := range keys[1:] {
+		if !ok || string(raw) == "null" {
+			return nil, nil
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		raw, ok = obj[key]
+	}
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	var info pageInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &info, nil
+}
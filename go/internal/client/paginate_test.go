@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// pagedServer answers with the pages, each the JSON of a data object,
+// choosing the page by the $after cursor "pN" and recording the cursors.
+func pagedServer(pages []string, cursors *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		after, _ := req.Variables["after"].(string)
+		*cursors = append(*cursors, after)
+		page := 0
+		fmt.Sscanf(after, "p%d", &page)
+		fmt.Fprintf(w, `{"data": %s}`, pages[page])
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	const path = "items.pageInfo"
+	page := func(id string, next string) string {
+		info := `{"hasNextPage": false, "endCursor": null}`
+		if next != "" {
+			info = fmt.Sprintf(`{"hasNextPage": true, "endCursor": %q}`, next)
+		}
+		return fmt.Sprintf(`{"items": {"edges": [%q], "pageInfo": %s}}`, id, info)
+	}
+	errStop := errors.New("stop")
+	for _, tc := range []struct {
+		name  string
+		pages []string
+		path  string
+		// failAt makes the callback fail on that page, counting from 1.
+		failAt  int
+		want    []string
+		cursors []string
+		err     string
+	}{
+		{name: "multi-page", pages: []string{page("a", "p1"), page("b", "p2"), page("c", "")}, path: path,
+			want: []string{"a", "b", "c"}, cursors: []string{"", "p1", "p2"}},
+		{name: "no next page", pages: []string{page("a", ""), page("b", "")}, path: path,
+			want: []string{"a"}, cursors: []string{""}},
+		{name: "missing pageInfo", pages: []string{page("a", "p1"), page("b", "")}, path: "items.other.pageInfo",
+			want: []string{"a"}, cursors: []string{""}},
+		{name: "null connection", pages: []string{`{"items": null}`}, path: path,
+			want: []string{""}, cursors: []string{""}},
+		{name: "callback error", pages: []string{page("a", "p1"), page("b", "p2"), page("c", "")}, path: path, failAt: 2,
+			want: []string{"a", "b"}, cursors: []string{"", "p1"}, err: "stop"},
+		{name: "repeated cursor", pages: []string{page("a", "p1"), page("b", "p1")}, path: path,
+			want: []string{"a", "b"}, cursors: []string{"", "p1"}, err: "Items page 2: hasNextPage without a new endCursor"},
+		{name: "pageInfo not an object", pages: []string{`{"items": {"pageInfo": 3}}`}, path: path,
+			want: []string{""}, cursors: []string{""}, err: "Items page 1: items.pageInfo: json: cannot unmarshal number"},
+	} {
+		var cursors, got []string
+		c := newTestClient(t, pagedServer(tc.pages, &cursors))
+		err := c.Paginate(context.Background(), "Items", "query Items($after: String) { items(after: $after) { edges } }", nil, tc.path,
+			func(data map[string]json.RawMessage) error {
+				var items struct {
+					Edges []string `json:"edges"`
+				}
+				json.Unmarshal(data["items"], &items)
+				got = append(got, strings.Join(items.Edges, ","))
+				if len(got) == tc.failAt {
+					return errStop
+				}
+				return nil
+			})
+		switch {
+		case tc.err == "" && err != nil, tc.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.err)):
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		case tc.failAt > 0 && !errors.Is(err, errStop):
+			t.Errorf("%s: got error %v, want the callback's", tc.name, err)
+		}
+		if !slices.Equal(got, tc.want) || !slices.Equal(cursors, tc.cursors) {
+			t.Errorf("%s: got pages %q after cursors %q, want %q after %q", tc.name, got, cursors, tc.want, tc.cursors)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithRateLimit makes the client send at most n requests per second on
+// average, with bursts of up to burst requests, so that scripts making
+// many calls throttle themselves instead of tripping Monarch's limits.
+// Requests wait for their turn or until their context is done. An n of
+// zero or less removes the limit.
+func WithRateLimit(n float64, burst int) Option {
+	return func(c *Client) {
+		c.limiter = nil
+		if n > 0 {
+			c.limiter = newLimiter(n, burst)
+		}
+	}
+}
+
+// limiter is a token bucket holding up to burst tokens, refilled at rate
+// tokens per second; each request takes one.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	b := float64(max(burst, 1))
+	return &limiter{rate: rate, burst: b, tokens: b, last: time.Now(), now: time.Now}
+}
+
+// reserve takes a token and returns how long to wait until it is
+// available.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait takes a token, waiting until one is available. A request whose
+// context ends first gives its token back.
+func (l *limiter) wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// send sends req once the rate limit, if any, and the API's quota allow
+// it.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if err := c.waitForQuota(req.Context()); err != nil {
+		return nil, err
+	}
+	if c.limiter != nil {
+		if err := c.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return c.do(req)
+}
+
+// Quota is the API's rate limit as reported by the headers of its last
+// response, e.g. X-RateLimit-Remaining or RateLimit.
+type Quota struct {
+	// Limit is the number of requests allowed per window, or -1 if not
+	// reported.
+	Limit     int
+	Remaining int
+	// Reset is when the window starts over, or zero if not reported.
+	Reset time.Time
+}
+
+// resetEpoch tells reset headers giving a Unix time from those giving
+// seconds from now.
+const resetEpoch = 1e9
+
+// parseQuota reads the rate limit headers of a response received at now:
+// RateLimit-* and X-RateLimit-* with a limit, remaining and reset each, or
+// a combined RateLimit header such as "limit=100, remaining=5, reset=30"
+// or `"default";r=5;t=30`. ok is false if the remaining count is missing.
+func parseQuota(h http.Header, now time.Time) (q Quota, ok bool) {
+	fields := map[string]string{}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		for _, name := range []string{"Limit", "Remaining", "Reset"} {
+			if v := h.Get(prefix + name); v != "" && fields[name] == "" {
+				fields[name] = v
+			}
+		}
+	}
+	// The combined header, in its older and newer drafts.
+	for _, item := range strings.FieldsFunc(h.Get("RateLimit"), func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		name := map[string]string{"limit": "Limit", "remaining": "Remaining", "r": "Remaining", "reset": "Reset", "t": "Reset"}[key]
+		if name != "" && fields[name] == "" {
+			fields[name] = value
+		}
+	}
+
+	remaining, err := strconv.Atoi(fields["Remaining"])
+	if err != nil {
+		return Quota{}, false
+	}
+	q = Quota{Limit: -1, Remaining: remaining}
+	if limit, err := strconv.Atoi(fields["Limit"]); err == nil {
+		q.Limit = limit
+	}
+	if reset, err := strconv.ParseFloat(fields["Reset"], 64); err == nil && reset >= 0 {
+		if reset >= resetEpoch {
+			q.Reset = time.Unix(int64(reset), 0)
+		} else {
+			q.Reset = now.Add(time.Duration(reset * float64(time.Second)))
+		}
+	}
+	return q, true
+}
+
+// noteQuota remembers the quota reported by a response, if any.
+func (c *Client) noteQuota(resp *http.Response) {
+	if q, ok := parseQuota(resp.Header, time.Now()); ok {
+		c.quota.Store(&q)
+	}
+}
+
+// Quota returns the rate limit reported by the API's last response that
+// had rate limit headers; ok is false if none had.
+func (c *Client) Quota() (q Quota, ok bool) {
+	if p := c.quota.Load(); p != nil {
+		return *p, true
+	}
+	return Quota{}, false
+}
+
+// rateLimitError returns the error for a 429 response received at now,
+// waiting as long as its Retry-After header says, or else until its
+// quota resets.
+func rateLimitError(resp *http.Response, now time.Time) *RateLimitError {
+	return &RateLimitError{RetryAfter: rateLimitWait(resp.Header, now)}
+}
+
+// rateLimitWait returns how long a throttled response asks to wait: its
+// Retry-After or, failing that, the time until its quota resets; zero if
+// it doesn't say.
+func rateLimitWait(h http.Header, now time.Time) time.Duration {
+	if wait := retryAfter(h.Get("Retry-After"), now); wait > 0 {
+		return wait
+	}
+	if q, ok := parseQuota(h, now); ok && q.Reset.After(now) {
+		return q.Reset.Sub(now)
+	}
+	return 0
+}
+
+// waitForQuota waits until the quota resets if the last response said no
+// requests are left, unless that is further off than the retry policy
+// would wait; the API then gets to answer the request itself.
+func (c *Client) waitForQuota(ctx context.Context) error {
+	q, ok := c.Quota()
+	if !ok || q.Remaining > 0 {
+		return nil
+	}
+	wait := time.Until(q.Reset)
+	if wait <= 0 || wait > c.retry.MaxDelay {
+		return nil
+	}
+	c.logger.InfoContext(ctx, "rate limit quota used up; waiting for it to reset", "wait", wait.Round(time.Millisecond))
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(rate float64, burst int) (*limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)}
+	l := newLimiter(rate, burst)
+	l.now, l.last = clock.now, clock.t
+	return l, clock
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	l, clock := newTestLimiter(2, 3)
+	for _, step := range []struct {
+		advance time.Duration
+		want    time.Duration
+	}{
+		// The burst goes through at once, then requests queue half a
+		// second apart.
+		{0, 0}, {0, 0}, {0, 0},
+		{0, 500 * time.Millisecond},
+		{0, time.Second},
+		// Two seconds refill four tokens, two of them owed.
+		{2 * time.Second, 0},
+		{0, 0},
+		{0, 500 * time.Millisecond},
+		// A long pause refills no more than the burst.
+		{time.Minute, 0}, {0, 0}, {0, 0},
+		{0, 500 * time.Millisecond},
+	} {
+		clock.advance(step.advance)
+		if got := l.reserve(); got != step.want {
+			t.Fatalf("at %s: got delay %s, want %s", clock.t.Format(time.TimeOnly), got, step.want)
+		}
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	// Had the cancelled request kept its token, this would wait 2s.
+	if got := l.reserve(); got != time.Second {
+		t.Errorf("got delay %s after a cancelled wait, want 1s", got)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	if c := New(WithRateLimit(0, 5)); c.limiter != nil {
+		t.Error("a rate of 0 set a limiter")
+	}
+	c := New(WithRateLimit(10, 0))
+	if c.limiter == nil || c.limiter.burst != 1 || c.limiter.rate != 10 {
+		t.Errorf("got limiter %+v, want rate 10 and a burst of at least 1", c.limiter)
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	epoch := now.Add(time.Hour).Unix()
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   Quota
+		ok     bool
+	}{
+		{"none", http.Header{}, Quota{}, false},
+		{"prefixed", http.Header{"X-Ratelimit-Limit": {"10"}, "X-Ratelimit-Remaining": {"9"}, "X-Ratelimit-Reset": {"30"}},
+			Quota{Limit: 10, Remaining: 9, Reset: now.Add(30 * time.Second)}, true},
+		{"unprefixed", http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1.5"}},
+			Quota{Limit: -1, Remaining: 0, Reset: now.Add(1500 * time.Millisecond)}, true},
+		{"unix reset", http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Reset": {strconv.FormatInt(epoch, 10)}},
+			Quota{Limit: -1, Remaining: 3, Reset: time.Unix(epoch, 0)}, true},
+		{"prefixed first", http.Header{"X-Ratelimit-Remaining": {"1"}, "Ratelimit-Remaining": {"2"}},
+			Quota{Limit: -1, Remaining: 1}, true},
+		{"combined", http.Header{"Ratelimit": {"limit=100, remaining=5, reset=30"}},
+			Quota{Limit: 100, Remaining: 5, Reset: now.Add(30 * time.Second)}, true},
+		{"structured", http.Header{"Ratelimit": {`"default";r=5;t=30`}},
+			Quota{Limit: -1, Remaining: 5, Reset: now.Add(30 * time.Second)}, true},
+		{"no remaining", http.Header{"X-Ratelimit-Limit": {"10"}}, Quota{}, false},
+		{"bad remaining", http.Header{"X-Ratelimit-Remaining": {"many"}}, Quota{}, false},
+		{"bad reset", http.Header{"X-Ratelimit-Remaining": {"1"}, "X-Ratelimit-Reset": {"-1"}},
+			Quota{Limit: -1, Remaining: 1}, true},
+	} {
+		got, ok := parseQuota(tc.header, now)
+		if ok != tc.ok || got.Limit != tc.want.Limit || got.Remaining != tc.want.Remaining || !got.Reset.Equal(tc.want.Reset) {
+			t.Errorf("%s: got %+v, %t, want %+v, %t", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// TestRateLimitHeaders checks that a throttled request is retried once
+// the quota in the rate limit headers resets, that the quota of the last
+// response is kept for callers, and that a used-up quota delays the next
+// request.
+func TestRateLimitHeaders(t *testing.T) {
+	type response struct {
+		status int
+		header http.Header
+	}
+	var responses []response
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[0]
+		responses = responses[1:]
+		for k, v := range resp.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.status)
+		w.Write([]byte(`{"data": {"accounts": []}}`))
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, MaxDelay: time.Second}))
+	call := func() error {
+		_, err := c.GraphQLCall(context.Background(), "GetAccounts", "query GetAccounts { accounts { id } }", nil)
+		return err
+	}
+
+	reset := time.Now().Add(time.Hour).Unix()
+	responses = []response{
+		{http.StatusTooManyRequests, http.Header{"Ratelimit": {"limit=10, remaining=0, reset=0.05"}}},
+		{http.StatusOK, http.Header{"X-Ratelimit-Limit": {"10"}, "X-Ratelimit-Remaining": {"9"}, "X-Ratelimit-Reset": {strconv.FormatInt(reset, 10)}}},
+	}
+	if err := call(); err != nil {
+		t.Fatalf("got %v, want a retry once the quota reset", err)
+	}
+	if q, ok := c.Quota(); !ok || q.Limit != 10 || q.Remaining != 9 || q.Reset.Unix() != reset {
+		t.Errorf("got quota %+v (%t), want the last response's", q, ok)
+	}
+
+	responses = []response{{http.StatusOK, http.Header{"Ratelimit": {`"default";r=0;t=0.2`}}}, {http.StatusOK, nil}}
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("request after the quota ran out was sent after %s, want a wait for the reset", elapsed)
+	}
+
+	responses = []response{{http.StatusTooManyRequests, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"30"}}}}
+	var rl *RateLimitError
+	if err := call(); !errors.As(err, &rl) || rl.RetryAfter < 29*time.Second {
+		t.Errorf("got error %v, want a rate limit error waiting for the reset in 30s", err)
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// remoteLoginTimeout bounds how long LoginRemote waits for a token, like
+// the expiry of a device code.
+const remoteLoginTimeout = 10 * time.Minute
+
+// LoginRemote logs in from a machine without a browser, such as over SSH.
+// Monarch has no device authorization endpoint, so it prints the login URL
+// and the console snippet for the user to run in a browser on any other
+// machine, then reads the token pasted back into the terminal. Each token
+// is checked against the API before it is accepted; invalid ones are asked
+// for again until ctx is done or remoteLoginTimeout passes.
+func (c *Client) LoginRemote(ctx context.Context) error {
+	if c.nonInteractive {
+		return fmt.Errorf("%w: remote login needs a user; pass a token with -token", ErrInputRequired)
+	}
+	ctx, cancel := context.WithTimeout(ctx, remoteLoginTimeout)
+	defer cancel()
+
+	fmt.Fprintln(c.stdout, "On any computer with a browser:")
+	fmt.Fprintln(c.stdout, "  1. Log in at https://app.monarch.com")
+	fmt.Fprintln(c.stdout, "  2. Open the browser console and run the snippet below")
+	fmt.Fprintln(c.stdout, "     → It copies your Monarch token to that computer's clipboard")
+	fmt.Fprintln(c.stdout, "  3. Paste the token here")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, consoleSnippet)
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintf(c.stdout, "Waiting up to %s for the token.\n", remoteLoginTimeout)
+
+	lines := make(chan string)
+	stop := c.readLines(lines)
+	defer stop()
+	for {
+		fmt.Fprint(c.stdout, "Paste token here: ")
+		var line string
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(c.stdout)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for a token", remoteLoginTimeout)
+			}
+			return ctx.Err()
+		case l, ok := <-lines:
+			if !ok {
+				return fmt.Errorf("no token provided")
+			}
+			line = l
+		}
+		token := pastedToken(line)
+		if token == "" {
+			continue
+		}
+		c.token = token
+		_, err := c.graphQLCall(ctx, "Common_GetHouseholds", householdsQuery, nil)
+		if err == nil {
+			return nil
+		}
+		c.token = ""
+		if !errors.Is(err, ErrTokenExpired) {
+			return fmt.Errorf("check token: %w", err)
+		}
+		fmt.Fprintln(c.stdout, "Monarch rejected that token; copy it again and paste the whole value.")
+	}
+}
+
+// readLines sends each line of the terminal's input to lines, closing it
+// at the end of input, until stop is called. stop interrupts a Read in
+// progress if the input has deadlines, as pipes and sockets do, and waits
+// for the reader to finish; a terminal without them has the reader exit
+// once its Read returns, without taking another line.
+func (c *Client) readLines(lines chan<- string) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer close(lines)
+		for {
+			line, err := c.input.ReadString('\n')
+			if line != "" {
+				select {
+				case lines <- strings.TrimRight(line, "\r\n"):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		d, ok := c.stdin.(interface{ SetReadDeadline(time.Time) error })
+		if !ok || d.SetReadDeadline(time.Now()) != nil {
+			return
+		}
+		<-exited
+		d.SetReadDeadline(time.Time{})
+	}
+}
+
+// pastedToken extracts the token from a pasted line, which may carry the
+// quotes of a console string or the snippet's "token is:" output.
+func pastedToken(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Trim(fields[len(fields)-1], `"'`)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// remoteLoginAPI accepts only the token "good-token" and counts the tokens
+// it was asked to check.
+func remoteLoginAPI(checked *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		*checked = append(*checked, auth)
+		if auth != "Token good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":{"households":[]}}`))
+	}
+}
+
+func TestLoginRemote(t *testing.T) {
+	var checked []string
+	var out strings.Builder
+	// Blank lines are asked for again without checking them; the console
+	// string's quotes and the snippet's prefix are dropped.
+	in := strings.NewReader("\n   \nyour token is: bad-token\r\n\"good-token\"\nunread\n")
+	c := newTestClient(t, remoteLoginAPI(&checked), WithTerminal(in, &out))
+	c.SetToken("")
+	if err := c.LoginRemote(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() != "good-token" {
+		t.Errorf("got token %q", c.Token())
+	}
+	if want := []string{"Token bad-token", "Token good-token"}; strings.Join(checked, ",") != strings.Join(want, ",") {
+		t.Errorf("checked %q, want %q", checked, want)
+	}
+	if n := strings.Count(out.String(), "Paste token here: "); n != 4 {
+		t.Errorf("prompted %d times, want 4:\n%s", n, out.String())
+	}
+	if n := strings.Count(out.String(), "Monarch rejected that token"); n != 1 {
+		t.Errorf("rejected %d tokens, want 1:\n%s", n, out.String())
+	}
+
+	checked = nil
+	c = newTestClient(t, remoteLoginAPI(&checked), WithTerminal(strings.NewReader("bad-token\n"), &out))
+	if err := c.LoginRemote(context.Background()); err == nil || err.Error() != "no token provided" {
+		t.Errorf("got %v at the end of input, want no token provided", err)
+	}
+	if c.Token() != "" {
+		t.Errorf("kept rejected token %q", c.Token())
+	}
+
+	c = newTestClient(t, remoteLoginAPI(&checked), WithTerminal(strings.NewReader("good-token\n"), &out))
+	c.SetNonInteractive(true)
+	if err := c.LoginRemote(context.Background()); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("got %v, want ErrInputRequired", err)
+	}
+}
+
+// TestLoginRemoteCancel checks that giving up on the token stops reading
+// the terminal.
+func TestLoginRemoteCancel(t *testing.T) {
+	var checked []string
+	term, user := net.Pipe()
+	defer user.Close()
+	c := newTestClient(t, remoteLoginAPI(&checked), WithTerminal(term, &strings.Builder{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := c.LoginRemote(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	// Nobody reads what is typed afterwards.
+	user.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := user.Write([]byte("good-token\n")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got %v, want the line left unread", err)
+	}
+	// The terminal still works for the next prompt.
+	user.SetWriteDeadline(time.Time{})
+	go user.Write([]byte("next\n"))
+	if got := c.prompt("? "); got != "next" {
+		t.Errorf("prompt read %q, want next", got)
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RetryPolicy controls how GraphQL calls are retried when Monarch answers
+// 429 Too Many Requests or a 5xx status, as it does during maintenance.
+// Mutations are retried only after a 429, which the server answers before
+// doing anything: after a 5xx it may have applied the change, and doing so
+// twice could, say, create a transaction twice.
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each
+	// further one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter spreads each wait randomly by up to this fraction of it, so
+	// that concurrent clients don't retry in lockstep.
+	Jitter float64
+	// RetryMutations also retries mutations after a 5xx, for callers whose
+	// mutations are safe to repeat.
+	RetryMutations bool
+}
+
+// DefaultRetryPolicy retries three times over about seven seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.2,
+}
+
+// ServerError is returned by GraphQLCall when the API answers with a 5xx
+// status.
+type ServerError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("graphql HTTP %d: %s\n%s", e.StatusCode, e.Status, e.Body)
+}
+
+// backoff returns how long to wait before retry number n (1 for the first)
+// after err, and whether to retry at all. A Retry-After longer than
+// MaxDelay is not waited for.
+func (p RetryPolicy) backoff(n int, err error, mutation bool) (time.Duration, bool) {
+	if n >= p.MaxAttempts {
+		return 0, false
+	}
+	var rl *RateLimitError
+	var se *ServerError
+	switch {
+	case errors.As(err, &rl):
+		if rl.RetryAfter > 0 {
+			return rl.RetryAfter, rl.RetryAfter <= p.MaxDelay
+		}
+	case errors.As(err, &se):
+		if mutation && !p.RetryMutations {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	d := p.BaseDelay << (n - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d, true
+}
+
+// withRetry calls fn until it succeeds, fails permanently or the policy
+// gives up, waiting between attempts as the policy says. mutation tells
+// whether fn sends a mutation.
+func (c *Client) withRetry(ctx context.Context, mutation bool, fn func() error) error {
+	for n := 1; ; n++ {
+		err := fn()
+		wait, ok := c.retry.backoff(n, err, mutation)
+		if !ok {
+			return err
+		}
+		trace.SpanFromContext(ctx).AddEvent(fmt.Sprintf("retry in %s: %v", wait.Round(time.Millisecond), err))
+		c.logger.WarnContext(ctx, "retrying request", "attempt", n+1, "wait", wait, "err", err)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
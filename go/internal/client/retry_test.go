@@ -0,0 +1,50 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	server := &ServerError{StatusCode: 503}
+	for _, tc := range []struct {
+		name     string
+		policy   RetryPolicy
+		n        int
+		err      error
+		mutation bool
+		want     time.Duration
+		ok       bool
+	}{
+		{"first retry", p, 1, server, false, time.Second, true},
+		{"doubled", p, 2, server, false, 2 * time.Second, true},
+		{"capped", p, 3, server, false, 3 * time.Second, true},
+		{"out of attempts", p, 4, server, false, 0, false},
+		{"wrapped", p, 1, fmt.Errorf("fetch: %w", server), false, time.Second, true},
+		{"retry after", p, 1, &RateLimitError{RetryAfter: 2 * time.Second}, false, 2 * time.Second, true},
+		{"retry after too long", p, 1, &RateLimitError{RetryAfter: time.Minute}, false, time.Minute, false},
+		{"rate limit without retry after", p, 2, &RateLimitError{}, false, 2 * time.Second, true},
+		{"other error", p, 1, errors.New("boom"), false, 0, false},
+		{"mutation after server error", p, 1, server, true, 0, false},
+		{"mutation after rate limit", p, 1, &RateLimitError{}, true, time.Second, true},
+		{"mutation retries on", RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second, RetryMutations: true}, 1, server, true, time.Second, true},
+	} {
+		got, ok := tc.policy.backoff(tc.n, tc.err, tc.mutation)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %s, %t, want %s, %t", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 0.2}
+	for range 100 {
+		d, ok := p.backoff(1, &ServerError{}, false)
+		if !ok || d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("got %s, %t, want within 20%% of 1s", d, ok)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/heikofkoehler/monarch/internal/filelock"
+)
+
+// LegacySessionFile is where sessions were kept before they moved to the
+// user's state directory. It is relative to the working directory, so every
+// directory the tool ran in could hold its own session.
+const LegacySessionFile = ".mm/session.json"
+
+// DefaultSessionPath returns where FileStore keeps the session by default:
+// $XDG_STATE_HOME/monarch/session.json, or ~/.local/state on Unix,
+// ~/Library/Application Support on macOS and %AppData% on Windows. It
+// falls back to LegacySessionFile if no home directory is known.
+func DefaultSessionPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(dir) {
+		dir = ""
+	}
+	if dir == "" {
+		var err error
+		switch runtime.GOOS {
+		case "windows", "darwin", "ios":
+			dir, err = os.UserConfigDir()
+		default:
+			var home string
+			home, err = os.UserHomeDir()
+			dir = filepath.Join(home, ".local", "state")
+		}
+		if err != nil {
+			return LegacySessionFile
+		}
+	}
+	return filepath.Join(dir, "monarch", "session.json")
+}
+
+// SessionPathFor returns the session file for the Monarch login email, next
+// to the default file at path: session.json becomes
+// session-alice@example.com.json. Each login keeps its own file, so
+// switching between them doesn't overwrite the other's token. An empty
+// email returns path itself.
+func SessionPathFor(path, email string) string {
+	if email == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + sessionKey(email) + ext
+}
+
+// KeyringAccountFor is SessionPathFor for keyring entries.
+func KeyringAccountFor(account, email string) string {
+	if email == "" {
+		return account
+	}
+	return account + "/" + sessionKey(email)
+}
+
+// SessionPaths returns path and the per-login session files next to it
+// that exist.
+func SessionPaths(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	matches, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{path}, matches...), nil
+}
+
+// sessionKey makes email safe to use in a file name.
+func sessionKey(email string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', strings.ContainsRune("@._+-", r):
+			return r
+		}
+		return '_'
+	}, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// legacySessionFiles returns where earlier versions kept the session:
+// LegacySessionFile and, on Windows, %LocalAppData%.
+func legacySessionFiles() []string {
+	files := []string{LegacySessionFile}
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			files = append(files, filepath.Join(dir, "monarch", "session.json"))
+		}
+	}
+	return files
+}
+
+// MigrateLegacySession moves a session left where earlier versions kept it
+// to path, unless path already holds one. It returns the file the session
+// was moved from, or "" if none was moved.
+func MigrateLegacySession(path string) (string, error) {
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, old := range legacySessionFiles() {
+		if old == path {
+			continue
+		}
+		data, err := os.ReadFile(old)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("move session from %s: %w", old, err)
+		}
+		if err := (FileStore{Path: path}).Save(data); err != nil {
+			return "", fmt.Errorf("move session from %s: %w", old, err)
+		}
+		return old, os.Remove(old)
+	}
+	return "", nil
+}
+
+// SessionStore persists the serialized session (token and cookies).
+type SessionStore interface {
+	// Load returns the saved session, or nil if none has been saved.
+	Load() ([]byte, error)
+	Save(data []byte) error
+	Delete() error
+}
+
+// FileStore keeps the session in a plaintext file readable only by the owner.
+// Concurrent commands coordinate through an advisory lock on a file next to
+// it (see SessionLockPath), and writes replace the file atomically, so a
+// reader never sees a half-written session.
+type FileStore struct {
+	Path string
+}
+
+func (f FileStore) Load() ([]byte, error) {
+	if _, err := os.Stat(filepath.Dir(f.Path)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	unlock, err := lockSession(f.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	raw, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return raw, err
+}
+
+func (f FileStore) Save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+	unlock, err := lockSession(f.Path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeFileAtomic(f.Path, data)
+}
+
+func (f FileStore) Delete() error {
+	if _, err := os.Stat(filepath.Dir(f.Path)); os.IsNotExist(err) {
+		return nil
+	}
+	unlock, err := lockSession(f.Path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = os.Remove(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// SessionLockPath returns the lock file guarding the session file at path.
+func SessionLockPath(path string) string {
+	return path + ".lock"
+}
+
+// lockSession takes an advisory lock on the lock file of the session at
+// path, shared for reading and exclusive for writing, and returns a
+// function that releases it. The lock file itself is left in place.
+func lockSession(path string, exclusive bool) (func(), error) {
+	return filelock.Lock(SessionLockPath(path), exclusive)
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in
+// the same directory and renaming it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Session store names accepted by NewSessionStore.
+const (
+	StoreFile    = "file"
+	StoreKeyring = "keyring"
+	StoreAuto    = "auto"
+)
+
+// NewSessionStore returns the store with the given name. "auto" uses the OS
+// keyring when one is available and falls back to the file at path. The
+// keyring entry is stored under account.
+func NewSessionStore(name, path, account string) (SessionStore, error) {
+	file := FileStore{Path: path}
+	switch name {
+	case "", StoreFile:
+		return file, nil
+	case StoreKeyring:
+		if !KeyringAvailable() {
+			return nil, fmt.Errorf("no OS keyring available (need security, secret-tool or PowerShell)")
+		}
+		return NewKeyringStore(account), nil
+	case StoreAuto:
+		if KeyringAvailable() {
+			return NewKeyringStore(account), nil
+		}
+		return file, nil
+	}
+	return nil, fmt.Errorf("unknown session store %q: want file, keyring or auto", name)
+}
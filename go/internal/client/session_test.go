@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFileStoreConcurrent checks that loaders racing savers only ever see
+// a whole session.
+func TestFileStoreConcurrent(t *testing.T) {
+	store := FileStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	const savers, saves, size = 4, 20, 256 << 10
+	session := func(i int) []byte { return bytes.Repeat([]byte{byte('a' + i)}, size) }
+	if err := store.Save(session(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, savers*saves*2)
+	for i := range savers {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range saves {
+				if err := store.Save(session(i)); err != nil {
+					errs <- err
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range saves {
+				data, err := store.Load()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if len(data) != size || !bytes.Equal(data, bytes.Repeat(data[:1], size)) {
+					t.Errorf("loaded a partial session of %d bytes", len(data))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if tmp, _ := filepath.Glob(store.Path + ".tmp*"); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+// TestFileStoreSaveError checks that a failed save releases the lock and
+// cleans up.
+func TestFileStoreSaveError(t *testing.T) {
+	store := FileStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	// A directory in the session's place makes the final rename fail.
+	if err := os.Mkdir(store.Path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Path, "keep"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save([]byte("session")); err == nil {
+		t.Fatal("saving over a directory succeeded")
+	}
+	if tmp, _ := filepath.Glob(store.Path + ".tmp*"); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+
+	locked := make(chan error, 1)
+	go func() {
+		unlock, err := lockSession(store.Path, true)
+		if err == nil {
+			unlock()
+		}
+		locked <- err
+	}()
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock is still held after the failed save")
+	}
+}
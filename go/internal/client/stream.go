@@ -0,0 +1,118 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxErrorBody is how much of a response a GraphQLError keeps in Body.
+const maxErrorBody = 1 << 20
+
+// decodeGraphQL decodes a GraphQL response while reading it, keeping each
+// field of its data as raw JSON. Portfolio and transaction responses can be
+// tens of megabytes; unlike reading the body and then unmarshalling it,
+// this never holds the body and the decoded fields at once, only the field
+// being decoded next to those before it and the first maxErrorBody bytes
+// of the body, in case it lists errors.
+func decodeGraphQL(r io.Reader) (map[string]json.RawMessage, error) {
+	raw := &prefixWriter{max: maxErrorBody}
+	r = io.TeeReader(r, raw)
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	var data map[string]json.RawMessage
+	var errs []GraphQLErrorItem
+	for dec.More() {
+		key, err := objectKey(dec)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "data":
+			data, err = decodeData(dec)
+		case "errors":
+			err = dec.Decode(&errs)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		// The decoder may not have read to the end of the body yet.
+		_, _ = io.Copy(io.Discard, r)
+		return nil, &GraphQLError{StatusCode: http.StatusOK, Errors: errs, Data: data, Body: raw.buf}
+	}
+	return data, nil
+}
+
+// prefixWriter keeps the first max bytes written to it and drops the rest.
+type prefixWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if n := min(len(p), w.max-len(w.buf)); n > 0 {
+		w.buf = append(w.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// decodeData decodes the data object of a response field by field.
+func decodeData(dec *json.Decoder) (map[string]json.RawMessage, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("got %v, want an object", tok)
+	}
+	data := make(map[string]json.RawMessage)
+	for dec.More() {
+		key, err := objectKey(dec)
+		if err != nil {
+			return nil, err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		data[key] = v
+	}
+	return data, expectDelim(dec, '}')
+}
+
+func objectKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("got %v, want an object key", tok)
+	}
+	return key, nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("got %v, want %v", tok, want)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodeGraphQL(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		// want is the data fields, or the error.
+		want string
+	}{
+		{"data", `{"data": {"a": 1, "b": {"c": [2, 3]}}}`, `a=1 b={"c": [2, 3]}`},
+		{"null data", `{"data": null}`, ``},
+		{"extensions skipped", `{"extensions": {"cost": 5}, "data": {"a": true}}`, `a=true`},
+		{"errors", `{"errors": [{"message": "Not allowed", "extensions": {"code": "FORBIDDEN"}}], "data": null}`,
+			`graphql error: Not allowed (FORBIDDEN)`},
+		{"errors after data", `{"data": {"a": 1}, "errors": [{"message": "Not allowed"}]}`, `graphql error: Not allowed`},
+		{"not an object", `[1]`, `got [, want {`},
+		{"data not an object", `{"data": 3}`, `data: got 3, want an object`},
+		{"truncated", `{"data": {"a": 1`, `data: unexpected end of JSON input`},
+	} {
+		data, err := decodeGraphQL(strings.NewReader(tc.body))
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			var fields []string
+			for _, key := range []string{"a", "b"} {
+				if v, ok := data[key]; ok {
+					fields = append(fields, fmt.Sprintf("%s=%s", key, v))
+				}
+			}
+			got = strings.Join(fields, " ")
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestDecodeGraphQLPartial checks that an error keeps the raw body and the
+// data that did resolve.
+func TestDecodeGraphQLPartial(t *testing.T) {
+	body := `{"data": {"accounts": [], "portfolio": null}, "errors": [{"message": "Timeout", "path": ["portfolio"]}], "extensions": {"traceId": "t1"}}` + "\n"
+	_, err := decodeGraphQL(strings.NewReader(body))
+	var gerr *GraphQLError
+	if !errors.As(err, &gerr) {
+		t.Fatalf("got %v, want a GraphQLError", err)
+	}
+	if string(gerr.Body) != body {
+		t.Errorf("got body %q, want the raw response", gerr.Body)
+	}
+	if string(gerr.Data["accounts"]) != "[]" {
+		t.Errorf("got data %v, want the accounts that resolved", gerr.Data)
+	}
+
+	long := `{"errors": [{"message": "` + strings.Repeat("x", 2*maxErrorBody) + `"}]}`
+	_, err = decodeGraphQL(strings.NewReader(long))
+	if !errors.As(err, &gerr) || len(gerr.Body) != maxErrorBody || string(gerr.Body) != long[:maxErrorBody] {
+		t.Errorf("got %d bytes of body, want the first %d", len(gerr.Body), maxErrorBody)
+	}
+}
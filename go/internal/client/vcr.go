@@ -0,0 +1,289 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A cassette is a directory of HTTP exchanges recorded by WithRecording,
+// one JSON file each, numbered in the order they happened. Credentials,
+// cookies and email addresses are redacted before anything is written, so
+// that cassettes can be checked in as test fixtures.
+
+// interaction is one recorded exchange.
+type interaction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		// JSON holds JSON bodies as they are, Text any other body.
+		JSON json.RawMessage `json:"json,omitempty"`
+		Text string          `json:"text,omitempty"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int             `json:"status"`
+		Header     http.Header     `json:"header,omitempty"`
+		JSON       json.RawMessage `json:"json,omitempty"`
+		Text       string          `json:"text,omitempty"`
+	} `json:"response"`
+}
+
+// cassetteSecrets matches the JSON string fields redacted in cassettes:
+// the credentials the debug log hides and the login's email address.
+var cassetteSecrets = regexp.MustCompile(`"(password|totp|email_otp|recovery_code|token|email|username)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// droppedHeaders aren't recorded: cookies, which are secret, and the
+// encoding of the body, which is recorded decoded.
+var droppedHeaders = []string{"Set-Cookie", "Content-Encoding", "Content-Length"}
+
+// sanitize redacts body and splits it into JSON, compacted, or text.
+func sanitize(body []byte) (json.RawMessage, string) {
+	body = cassetteSecrets.ReplaceAll(bytes.TrimSpace(body), []byte(`"$1"$2"[REDACTED]"`))
+	if len(body) == 0 {
+		return nil, ""
+	}
+	var b bytes.Buffer
+	if json.Compact(&b, body) == nil {
+		return b.Bytes(), ""
+	}
+	return nil, string(body)
+}
+
+// WithRecording records every exchange in the cassette directory dir,
+// numbering them after any recorded before. A failure to write one fails
+// its request.
+func WithRecording(dir string) Option {
+	return func(c *Client) {
+		c.recordDir = dir
+	}
+}
+
+// recordingTransport records the exchanges of the transport it wraps.
+type recordingTransport struct {
+	dir  string
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// next is the number of the next file, or -1 before the directory
+	// has been read.
+	next int
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var in interaction
+	in.Request.Method, in.Request.URL = req.Method, req.URL.String()
+	in.Request.JSON, in.Request.Text = sanitize(reqBody)
+	in.Response.StatusCode = resp.StatusCode
+	in.Response.Header = resp.Header.Clone()
+	for _, name := range droppedHeaders {
+		in.Response.Header.Del(name)
+	}
+	in.Response.JSON, in.Response.Text = sanitize(decompressed(resp.Header, respBody))
+	if err := t.write(in, operationOf(req.URL.Path, reqBody)); err != nil {
+		return nil, fmt.Errorf("record %s %s: %w", req.Method, req.URL, err)
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) write(in interaction, name string) error {
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next < 0 {
+		if err := os.MkdirAll(t.dir, 0700); err != nil {
+			return err
+		}
+		recorded, err := filepath.Glob(filepath.Join(t.dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		t.next = len(recorded) + 1
+	}
+	path := filepath.Join(t.dir, fmt.Sprintf("%03d-%s.json", t.next, name))
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	t.next++
+	return nil
+}
+
+// unsafeName matches what may not go into a cassette file name.
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// operationOf names an exchange for its file: the GraphQL operation, with
+// "batch" for a batch, or else the URL path.
+func operationOf(path string, body []byte) string {
+	var req struct {
+		OperationName string `json:"operationName"`
+	}
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")):
+		return "batch"
+	case json.Unmarshal(body, &req) == nil && req.OperationName != "":
+		return unsafeName.ReplaceAllString(req.OperationName, "_")
+	}
+	if name := strings.Trim(unsafeName.ReplaceAllString(path, "_"), "_"); name != "" {
+		return name
+	}
+	return "request"
+}
+
+// Cassette holds recorded exchanges to replay with WithReplay.
+type Cassette struct {
+	mu sync.Mutex
+	// queues are the responses for each request, by matchKey, in the
+	// order they were recorded.
+	queues map[string][]interaction
+	size   int
+}
+
+// LoadCassette reads the exchanges recorded in dir.
+func LoadCassette(dir string) (*Cassette, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recorded exchanges in %s", dir)
+	}
+	sort.Strings(files)
+	cas := &Cassette{queues: make(map[string][]interaction), size: len(files)}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var in interaction
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		body := []byte(in.Request.JSON)
+		if body == nil {
+			body = []byte(in.Request.Text)
+		}
+		key, err := matchKey(in.Request.Method, in.Request.URL, body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cas.queues[key] = append(cas.queues[key], in)
+	}
+	return cas, nil
+}
+
+// Len returns the number of recorded exchanges.
+func (cas *Cassette) Len() int {
+	return cas.size
+}
+
+// matchKey identifies a request for replay by its method, URL path and
+// redacted body, so that tokens and hosts may differ from the recording.
+func matchKey(method, rawURL string, body []byte) (string, error) {
+	path := rawURL
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		path = rawURL[i+3:]
+		if j := strings.IndexByte(path, '/'); j >= 0 {
+			path = path[j:]
+		} else {
+			path = "/"
+		}
+	}
+	js, text := sanitize(body)
+	if js != nil {
+		// Decoding and encoding again sorts the keys of objects.
+		var v any
+		if err := json.Unmarshal(js, &v); err != nil {
+			return "", err
+		}
+		js, _ = json.Marshal(v)
+		text = string(js)
+	}
+	return method + " " + path + "\n" + text, nil
+}
+
+// WithReplay answers requests with the responses in cas instead of
+// sending them. Requests recorded more than once get the responses in
+// order, the last one again after that; a request not recorded fails.
+func WithReplay(cas *Cassette) Option {
+	return func(c *Client) {
+		c.replay = cas
+	}
+}
+
+// RoundTrip answers req from the cassette.
+func (cas *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	key, err := matchKey(req.Method, req.URL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	cas.mu.Lock()
+	queue := cas.queues[key]
+	if len(queue) > 1 {
+		cas.queues[key] = queue[1:]
+	}
+	cas.mu.Unlock()
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("replay: no recorded response to %s %s (%s)", req.Method, req.URL.Path, operationOf(req.URL.Path, body))
+	}
+
+	in := queue[0]
+	respBody := []byte(in.Response.JSON)
+	if respBody == nil {
+		respBody = []byte(in.Response.Text)
+	}
+	header := in.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
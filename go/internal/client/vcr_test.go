@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestRecordReplay checks that exchanges are recorded without secrets and
+// replayed in order without a server.
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == loginPath {
+			w.Write([]byte(`{"token": "secret-token"}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"accounts":[{"id":"` + strings.Repeat("a", requests) + `"}]}}`))
+	}, WithRecording(dir))
+	ctx := context.Background()
+	if err := c.Login(ctx, "user@example.com", "hunter2", ""); err != nil {
+		t.Fatal(err)
+	}
+	var recorded []string
+	for range 2 {
+		data, err := c.GraphQLCall(ctx, "Get Accounts", "query { accounts { id } }", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, string(data["accounts"]))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+		data, _ := os.ReadFile(f)
+		for _, secret := range []string{"secret-token", "hunter2", "user@example.com"} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("%s holds %q", f, secret)
+			}
+		}
+	}
+	if want := []string{"001-auth_login.json", "002-Get_Accounts.json", "003-Get_Accounts.json"}; !slices.Equal(names, want) {
+		t.Errorf("recorded %q, want %q", names, want)
+	}
+
+	cas, err := LoadCassette(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := New(WithBaseURL("http://offline.invalid"), WithReplay(cas))
+	if err := replay.Login(ctx, "someone@example.com", "other", ""); err != nil {
+		t.Fatalf("replay login: %v", err)
+	}
+	// The last recorded response is replayed again once the rest are used.
+	for i, want := range append(recorded, recorded[1]) {
+		data, err := replay.GraphQLCall(ctx, "Get Accounts", "query { accounts { id } }", nil)
+		if err != nil {
+			t.Fatalf("replay %d: %v", i, err)
+		}
+		var got bytes.Buffer
+		json.Compact(&got, data["accounts"])
+		if got.String() != want {
+			t.Errorf("replay %d: got %s, want %s", i, got.String(), want)
+		}
+	}
+	if _, err := replay.GraphQLCall(ctx, "Other", "query Other { other }", nil); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("got error %v for a request not recorded", err)
+	}
+	if _, err := LoadCassette(t.TempDir()); err == nil {
+		t.Error("loading an empty cassette succeeded")
+	}
+}
@@ -0,0 +1,318 @@
+// Package config loads optional user settings for the monarch CLI.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultPath is where the CLI looks for its config file first.
+const DefaultPath = ".mm/config.json"
+
+// UserPath returns the per-user config file, monarch/config.json in
+// os.UserConfigDir: ~/.config on Unix, ~/Library/Application Support on
+// macOS and %AppData% on Windows. It returns "" if no home directory is
+// known.
+func UserPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "monarch", "config.json")
+}
+
+// Path returns the config file the CLI uses: DefaultPath if it exists in
+// the working directory, else UserPath if that exists, else DefaultPath.
+func Path() string {
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	if p := UserPath(); p != "" {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return DefaultPath
+}
+
+// Config is the top-level structure of the config file.
+type Config struct {
+	Client  ClientConfig  `json:"client,omitzero"`
+	Cash    CashConfig    `json:"cash,omitzero"`
+	Session SessionConfig `json:"session,omitzero"`
+	Events  EventsConfig  `json:"events,omitzero"`
+	Tracing TracingConfig `json:"tracing,omitzero"`
+	Serve   ServeConfig   `json:"serve,omitzero"`
+	Proxy   ProxyConfig   `json:"proxy,omitzero"`
+	// Language selects the language of messages, such as "de", "es" or
+	// "fr"; by default it follows LC_ALL, LC_MESSAGES or LANG.
+	Language string `json:"language,omitempty"`
+	// Income lists expected income besides the portfolio, such as Social
+	// Security, pensions and annuities, for projections.
+	Income []IncomeStream `json:"income,omitempty"`
+	// ManualAssets are revalued by "monarch manual revalue".
+	ManualAssets []ManualAsset `json:"manualAssets,omitempty"`
+	// CredentialsCommand is a shell command printing the credentials JSON
+	// ({"email", "password", "totp_secret"}) on stdout, e.g. from pass or
+	// the 1Password CLI. When set it replaces the credentials file.
+	CredentialsCommand string `json:"credentialsCommand,omitempty"`
+	// CredentialsSource reads the credentials JSON from a secrets manager
+	// instead: "vault://mount/path" or "awssm://secret-name". It takes
+	// precedence over CredentialsCommand.
+	CredentialsSource string `json:"credentialsSource,omitempty"`
+	// Profiles are named sets of credentials and data for separate
+	// Monarch logins, selected with --profile.
+	Profiles map[string]ProfileConfig `json:"profiles,omitempty"`
+}
+
+// ProfileConfig overrides the locations used by one profile. Empty fields
+// fall back to files inside the profile's directory.
+type ProfileConfig struct {
+	Dir         string `json:"dir,omitempty"`
+	Credentials string `json:"credentials,omitempty"`
+	// CredentialsCommand and CredentialsSource override the top-level
+	// settings for this profile.
+	CredentialsCommand string `json:"credentialsCommand,omitempty"`
+	CredentialsSource  string `json:"credentialsSource,omitempty"`
+}
+
+// IncomeStream is a yearly income expected now or in the future.
+type IncomeStream struct {
+	Name string `json:"name"`
+	// Amount is paid each year, in today's money if Indexed is set (as with
+	// Social Security), else as a fixed nominal amount.
+	Amount float64 `json:"amount"`
+	// StartYear and EndYear are the first and last calendar years paid;
+	// zero means already paying and for life, respectively.
+	StartYear int  `json:"startYear,omitempty"`
+	EndYear   int  `json:"endYear,omitempty"`
+	Indexed   bool `json:"indexed,omitempty"`
+}
+
+// ManualAsset tells how to keep the value of a manual account, such as a
+// house or car, current.
+type ManualAsset struct {
+	// Account is the account's name or ID.
+	Account string `json:"account"`
+	// Appreciation is the yearly change in value in percent, negative for
+	// assets that depreciate.
+	Appreciation float64 `json:"appreciation,omitempty"`
+	// Command, if set, prints the current value instead, e.g. from a
+	// valuation service. It gets the account in MONARCH_ACCOUNT_ID,
+	// MONARCH_ACCOUNT_NAME and MONARCH_ACCOUNT_BALANCE.
+	Command string `json:"command,omitempty"`
+	// Vehicle values a car instead, from a valuation service or along a
+	// depreciation curve.
+	Vehicle *VehicleConfig `json:"vehicle,omitempty"`
+}
+
+// VehicleConfig describes a car valued by "monarch manual revalue".
+type VehicleConfig struct {
+	PurchasePrice float64 `json:"purchasePrice"`
+	// PurchaseDate is in YYYY-MM-DD form.
+	PurchaseDate string `json:"purchaseDate"`
+	// Depreciation lists the yearly loss in value in percent for each year
+	// of ownership; the last rate repeats. Empty means a typical curve.
+	Depreciation []float64 `json:"depreciation,omitempty"`
+	// Source is a valuation service URL with {vin} and {mileage}
+	// placeholders, answering {"value": ...}. The curve is used when it is
+	// unset.
+	Source  string `json:"source,omitempty"`
+	VIN     string `json:"vin,omitempty"`
+	Mileage int    `json:"mileage,omitempty"`
+}
+
+// ClientConfig controls how the API client presents itself to Monarch.
+type ClientConfig struct {
+	// Profile names a built-in header profile ("default", "web", "mobile")
+	// or "custom" to start from the default and override fields below.
+	Profile        string            `json:"profile,omitempty"`
+	UserAgent      string            `json:"userAgent,omitempty"`
+	ClientPlatform string            `json:"clientPlatform,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Retry          RetryConfig       `json:"retry,omitzero"`
+	// RateLimit caps the requests sent per second; zero means no limit.
+	// RateBurst allows short bursts above it (default 1).
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	RateBurst int     `json:"rateBurst,omitempty"`
+	// Proxy is an http://, https:// or socks5:// proxy URL, optionally with
+	// credentials, used instead of HTTP_PROXY and HTTPS_PROXY.
+	Proxy string `json:"proxy,omitempty"`
+	// PersistedQueries sends queries by hash first, as the web client
+	// does, and in full only when the server asks for them.
+	PersistedQueries bool `json:"persistedQueries,omitempty"`
+	// BaseURL is the API's address, e.g. a mock server's; MONARCH_BASE_URL
+	// overrides it. The default is https://api.monarch.com.
+	BaseURL string `json:"baseURL,omitempty"`
+	// CacheTTL, a duration such as "15m", lets repeated queries within it
+	// be answered from a cache on disk instead of the API; see --no-cache.
+	CacheTTL string `json:"cacheTTL,omitempty"`
+	// CircuitBreaker stops calling the API after repeated failures.
+	CircuitBreaker CircuitBreakerConfig `json:"circuitBreaker,omitzero"`
+}
+
+// CircuitBreakerConfig tunes when the client stops calling an API that
+// keeps failing. Zero fields keep the defaults.
+type CircuitBreakerConfig struct {
+	// Failures is the number of consecutive failures, counting retries,
+	// that opens the breaker (default 5); a negative number disables it.
+	Failures int `json:"failures,omitempty"`
+	// Cooldown, a duration such as "30s" (the default), is how long the
+	// breaker stays open before a probe request is let through.
+	Cooldown string `json:"cooldown,omitempty"`
+}
+
+// RetryConfig tunes how API calls are retried after rate limits and server
+// errors. Zero fields keep the defaults.
+type RetryConfig struct {
+	// MaxAttempts counts the first call; 1 disables retries.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// BaseDelay and MaxDelay are durations such as "500ms" or "1m".
+	BaseDelay string `json:"baseDelay,omitempty"`
+	MaxDelay  string `json:"maxDelay,omitempty"`
+	// Jitter is the random spread of each wait as a fraction of it.
+	Jitter *float64 `json:"jitter,omitempty"`
+	// Mutations also retries mutations after server errors, which may
+	// apply a change twice; they are otherwise retried only after rate
+	// limits.
+	Mutations bool `json:"mutations,omitempty"`
+}
+
+// SessionConfig controls where the auth session is kept.
+type SessionConfig struct {
+	// Store is "file" (default), "keyring" or "auto" (keyring if available).
+	Store string `json:"store,omitempty"`
+	// Encrypt encrypts the saved session with a passphrase from the
+	// MONARCH_SESSION_PASSPHRASE environment variable or KeyFile.
+	Encrypt bool `json:"encrypt,omitempty"`
+	// KeyFile contains the passphrase; setting it implies Encrypt.
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+// ServeConfig configures "monarch serve".
+type ServeConfig struct {
+	// Users are the people the server answers, each with their own API
+	// key and scope.
+	Users []ServeUser `json:"users,omitempty"`
+}
+
+// ProxyConfig configures "monarch proxy".
+type ProxyConfig struct {
+	// Operations are the names of the queries passed on to the API; by
+	// default the queries the tool itself sends.
+	Operations []string `json:"operations,omitempty"`
+	// APIKeysSHA256 are the hex SHA-256 hashes of the keys clients must
+	// send. Without keys the proxy only listens on localhost.
+	APIKeysSHA256 []string `json:"apiKeysSHA256,omitempty"`
+}
+
+// ServeUser is a user of "monarch serve".
+type ServeUser struct {
+	Name string `json:"name"`
+	// APIKeySHA256 is the hex SHA-256 hash of the user's API key, as
+	// printed by "monarch serve key"; the key itself isn't stored.
+	APIKeySHA256 string `json:"apiKeySHA256"`
+	// Scope is "own" (the default) to see only Accounts, or "household"
+	// to see every account.
+	Scope string `json:"scope,omitempty"`
+	// Accounts are the names or IDs of the user's own accounts.
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// EventsConfig controls reactions to fetch events.
+type EventsConfig struct {
+	// Webhooks receive every event as a JSON POST of its kind, time and
+	// payload, e.g. {"kind": "snapshot.created", "time": "...",
+	// "payload": {"path": "...", "snapshot": {...}}}. The payloads hold
+	// account balances, holdings and transactions, so use HTTPS endpoints
+	// you trust. With webhooks, fetch always fetches the accounts for
+	// snapshot.created.
+	Webhooks []string `json:"webhooks,omitempty"`
+	// Notify lists Apprise-style URLs (e.g. "pover://user@token",
+	// "tgram://bottoken/chat_id") told about syncs and stale accounts.
+	Notify []string `json:"notify,omitempty"`
+	// Publish lists Kafka or NATS URLs, e.g. "kafka://localhost:9092/monarch"
+	// or "tls://nats.example.com:4222/monarch", that receive new
+	// transactions, balance changes and completed snapshots as versioned
+	// JSON messages on topics under the URL's path. Kafka is plaintext
+	// only, without TLS or SASL, so its broker must be on the same machine
+	// or a trusted network; NATS can use TLS with a tls:// URL.
+	Publish []string `json:"publish,omitempty"`
+	// StaleAfterDays flags accounts not refreshed by their institution for
+	// this many days. Zero uses the default of 7; negative disables the check.
+	StaleAfterDays int `json:"staleAfterDays,omitempty"`
+	// GlidePath, if it has a birth year, warns after each fetch when the
+	// equity share drifts from an age-based target.
+	GlidePath GlidePathConfig `json:"glidePath,omitzero"`
+	// Milestones are net worth amounts announced, once each, by the first
+	// fetch whose net worth reaches them.
+	Milestones MilestonesConfig `json:"milestones,omitzero"`
+}
+
+// MilestonesConfig sets the net worth milestones. The time each was first
+// reached is recorded in the history store.
+type MilestonesConfig struct {
+	// Every makes each multiple of this amount a milestone, e.g. 50000.
+	Every float64 `json:"every,omitempty"`
+	// Targets are further milestones, e.g. [0, 1000000].
+	Targets []float64 `json:"targets,omitempty"`
+}
+
+// GlidePathConfig sets the age-based allocation target checked by fetch
+// and used as the default of "monarch report glidepath".
+type GlidePathConfig struct {
+	BirthYear int `json:"birthYear,omitempty"`
+	// Rule is the target share of equities, e.g. "110-age" (the default)
+	// or a fixed percentage such as "60".
+	Rule string `json:"rule,omitempty"`
+	// Tolerance is the allowed drift in percentage points; zero uses the
+	// default of 5.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// TracingConfig configures export of OpenTelemetry traces. Tracing is also
+// enabled by the standard OTEL_EXPORTER_OTLP_* environment variables.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL, e.g. "http://localhost:4318".
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every export request, e.g. for authentication.
+	Headers     map[string]string `json:"headers,omitempty"`
+	ServiceName string            `json:"serviceName,omitempty"`
+}
+
+// CashConfig controls which holdings reports treat as cash.
+type CashConfig struct {
+	// SweepTickers lists sweep and money-market tickers counted as cash.
+	// When empty, portfolio.DefaultSweepTickers is used.
+	SweepTickers []string `json:"sweepTickers,omitempty"`
+}
+
+// Load reads the config file at path. A missing file yields an empty Config.
+func Load(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes cfg to path, creating its directory if needed.
+func Save(cfg *Config, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
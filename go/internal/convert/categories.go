@@ -0,0 +1,126 @@
+package convert
+
+// DefaultMonarchCategories are the categories a new Monarch household
+// starts with, used when the account's own list isn't fetched.
+var DefaultMonarchCategories = []string{
+	"Paychecks", "Interest", "Business Income", "Other Income",
+	"Charity", "Gifts",
+	"Auto Payment", "Public Transit", "Gas", "Auto Maintenance", "Parking & Tolls", "Taxi & Ride Shares",
+	"Mortgage", "Rent", "Home Improvement",
+	"Garbage", "Water", "Gas & Electric", "Internet & Cable", "Phone",
+	"Groceries", "Restaurants & Bars", "Coffee Shops",
+	"Travel & Vacation", "Entertainment & Recreation",
+	"Shopping", "Clothing", "Furniture & Housewares", "Electronics",
+	"Child Care", "Child Activities",
+	"Student Loans", "Education",
+	"Medical", "Dentist", "Fitness",
+	"Pets", "Pet Supplies",
+	"Insurance", "Loan Repayment", "Financial Fees", "Cash & ATM", "Financial & Legal Services", "Taxes",
+	"Advertising & Promotion", "Business Utilities & Communication", "Employee Wages & Contract Labor",
+	"Business Travel & Meals", "Business Auto Expenses", "Business Insurance", "Office Supplies & Expenses",
+	"Office Rent", "Postage & Shipping",
+	"Transfer", "Credit Card Payment", "Balance Adjustments",
+	"Uncategorized", "Check", "Miscellaneous",
+}
+
+// knownEquivalents maps lower-cased Mint and Empower category names to the
+// Monarch category with the same meaning.
+var knownEquivalents = map[string]string{
+	// Mint
+	"paycheck":               "Paychecks",
+	"income":                 "Other Income",
+	"bonus":                  "Paychecks",
+	"interest income":        "Interest",
+	"reimbursement":          "Other Income",
+	"rental income":          "Other Income",
+	"restaurants":            "Restaurants & Bars",
+	"fast food":              "Restaurants & Bars",
+	"food & dining":          "Restaurants & Bars",
+	"alcohol & bars":         "Restaurants & Bars",
+	"coffee shops":           "Coffee Shops",
+	"groceries":              "Groceries",
+	"gas & fuel":             "Gas",
+	"auto insurance":         "Insurance",
+	"auto payment":           "Auto Payment",
+	"service & parts":        "Auto Maintenance",
+	"parking":                "Parking & Tolls",
+	"public transportation":  "Public Transit",
+	"ride share":             "Taxi & Ride Shares",
+	"mortgage & rent":        "Mortgage",
+	"home improvement":       "Home Improvement",
+	"home insurance":         "Insurance",
+	"furnishings":            "Furniture & Housewares",
+	"utilities":              "Gas & Electric",
+	"internet":               "Internet & Cable",
+	"television":             "Internet & Cable",
+	"mobile phone":           "Phone",
+	"clothing":               "Clothing",
+	"electronics & software": "Electronics",
+	"shopping":               "Shopping",
+	"air travel":             "Travel & Vacation",
+	"hotel":                  "Travel & Vacation",
+	"travel":                 "Travel & Vacation",
+	"vacation":               "Travel & Vacation",
+	"entertainment":          "Entertainment & Recreation",
+	"movies & dvds":          "Entertainment & Recreation",
+	"music":                  "Entertainment & Recreation",
+	"gym":                    "Fitness",
+	"doctor":                 "Medical",
+	"pharmacy":               "Medical",
+	"health & fitness":       "Medical",
+	"dentist":                "Dentist",
+	"pet food & supplies":    "Pet Supplies",
+	"veterinary":             "Pets",
+	"babysitter & daycare":   "Child Care",
+	"tuition":                "Education",
+	"student loan":           "Student Loans",
+	"charity":                "Charity",
+	"gift":                   "Gifts",
+	"life insurance":         "Insurance",
+	"bank fee":               "Financial Fees",
+	"atm fee":                "Financial Fees",
+	"finance charge":         "Financial Fees",
+	"late fee":               "Financial Fees",
+	"cash & atm":             "Cash & ATM",
+	"federal tax":            "Taxes",
+	"state tax":              "Taxes",
+	"property tax":           "Taxes",
+	"credit card payment":    "Credit Card Payment",
+	"transfer":               "Transfer",
+	"office supplies":        "Office Supplies & Expenses",
+	"shipping":               "Postage & Shipping",
+	"check":                  "Check",
+	"uncategorized":          "Uncategorized",
+
+	// Empower / Personal Capital
+	"paychecks/salary":     "Paychecks",
+	"other income":         "Other Income",
+	"investment income":    "Interest",
+	"gasoline/fuel":        "Gas",
+	"automotive":           "Auto Maintenance",
+	"general merchandise":  "Shopping",
+	"online services":      "Internet & Cable",
+	"cable/satellite":      "Internet & Cable",
+	"telephone":            "Phone",
+	"healthcare/medical":   "Medical",
+	"personal care":        "Shopping",
+	"child/dependent":      "Child Care",
+	"education":            "Education",
+	"charitable giving":    "Charity",
+	"gifts":                "Gifts",
+	"insurance":            "Insurance",
+	"loans":                "Loan Repayment",
+	"service charges/fees": "Financial Fees",
+	"atm/cash":             "Cash & ATM",
+	"taxes":                "Taxes",
+	"credit card payments": "Credit Card Payment",
+	"transfers":            "Transfer",
+	"savings":              "Transfer",
+	"securities trades":    "Transfer",
+	"rent":                 "Rent",
+	"mortgages":            "Mortgage",
+	"home maintenance":     "Home Improvement",
+	"clothing/shoes":       "Clothing",
+	"pets/pet care":        "Pets",
+	"postage & shipping":   "Postage & Shipping",
+}
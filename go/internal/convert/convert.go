@@ -0,0 +1,172 @@
+// Package convert reads transaction exports from other personal finance
+// apps (Mint, Empower/Personal Capital) and maps their categories onto
+// Monarch's, to help migrate history and categorization rules.
+package convert
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source formats accepted by Read.
+const (
+	SourceMint    = "mint"
+	SourceEmpower = "empower"
+)
+
+// Transaction is one row of a legacy export.
+type Transaction struct {
+	Date        time.Time
+	Description string
+	Amount      float64
+	Category    string
+	Account     string
+}
+
+// columns names the CSV headers of a source format.
+type columns struct {
+	date, description, amount, category, account string
+	// debitCredit is the column marking the amount as debit or credit, for
+	// formats that don't sign amounts.
+	debitCredit string
+	dateLayouts []string
+}
+
+var formats = map[string]columns{
+	SourceMint: {
+		date: "Date", description: "Description", amount: "Amount",
+		category: "Category", account: "Account Name", debitCredit: "Transaction Type",
+		dateLayouts: []string{"1/02/2006", "01/02/2006", "1/2/2006"},
+	},
+	SourceEmpower: {
+		date: "Date", description: "Description", amount: "Amount",
+		category: "Category", account: "Account",
+		dateLayouts: []string{time.DateOnly, "01/02/2006", "1/2/2006"},
+	},
+}
+
+// Read parses a CSV export in the given source format.
+func Read(r io.Reader, source string) ([]Transaction, error) {
+	cols, ok := formats[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q: want mint or empower", source)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		idx[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+	for _, name := range []string{cols.date, cols.description, cols.amount, cols.category} {
+		if _, ok := idx[name]; !ok {
+			return nil, fmt.Errorf("not a %s export: missing %q column", source, name)
+		}
+	}
+	field := func(row []string, name string) string {
+		i, ok := idx[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var txns []Transaction
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := parseDate(field(row, cols.date), cols.dateLayouts)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		amount, err := strconv.ParseFloat(strings.NewReplacer("$", "", ",", "").Replace(field(row, cols.amount)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad amount: %w", line, err)
+		}
+		if cols.debitCredit != "" && strings.EqualFold(field(row, cols.debitCredit), "debit") {
+			amount = -amount
+		}
+		txns = append(txns, Transaction{
+			Date:        date,
+			Description: field(row, cols.description),
+			Amount:      amount,
+			Category:    field(row, cols.category),
+			Account:     field(row, cols.account),
+		})
+	}
+	return txns, nil
+}
+
+func parseDate(s string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad date %q", s)
+}
+
+// Mapping is how one legacy category maps onto Monarch.
+type Mapping struct {
+	Source string
+	// Monarch is the matched Monarch category, or "" if none was found.
+	Monarch      string
+	Transactions int
+}
+
+// MapCategories maps every category used in txns onto one of the Monarch
+// category names in monarch: first by the built-in table of known
+// equivalents, then by case-insensitive name. The result is sorted by
+// descending transaction count.
+func MapCategories(txns []Transaction, monarch []string) []Mapping {
+	byName := make(map[string]string, len(monarch))
+	for _, name := range monarch {
+		byName[strings.ToLower(name)] = name
+	}
+	counts := make(map[string]int)
+	for _, t := range txns {
+		counts[t.Category]++
+	}
+	mappings := make([]Mapping, 0, len(counts))
+	for cat, n := range counts {
+		m := Mapping{Source: cat, Transactions: n}
+		if known, ok := knownEquivalents[strings.ToLower(cat)]; ok {
+			m.Monarch = byName[strings.ToLower(known)]
+		}
+		if m.Monarch == "" {
+			m.Monarch = byName[strings.ToLower(cat)]
+		}
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].Transactions != mappings[j].Transactions {
+			return mappings[i].Transactions > mappings[j].Transactions
+		}
+		return mappings[i].Source < mappings[j].Source
+	})
+	return mappings
+}
+
+// WriteMappings writes the category mapping as CSV for review.
+func WriteMappings(w io.Writer, mappings []Mapping) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source_category", "monarch_category", "transactions"})
+	for _, m := range mappings {
+		cw.Write([]string{m.Source, m.Monarch, strconv.Itoa(m.Transactions)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
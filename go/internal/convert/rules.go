@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Rule assigns a Monarch category to transactions from a merchant.
+type Rule struct {
+	Merchant string `json:"merchant"`
+	Category string `json:"category"`
+	// Matches is how many imported transactions support the rule.
+	Matches int `json:"matches"`
+}
+
+// Rules derives one rule per merchant description seen at least minMatches
+// times, choosing the Monarch category most of its transactions map to.
+// Transactions whose category has no Monarch mapping are ignored.
+func Rules(txns []Transaction, mappings []Mapping, minMatches int) []Rule {
+	monarch := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		monarch[m.Source] = m.Monarch
+	}
+	counts := make(map[string]map[string]int)
+	names := make(map[string]string)
+	for _, t := range txns {
+		cat := monarch[t.Category]
+		key := merchantKey(t.Description)
+		if cat == "" || key == "" {
+			continue
+		}
+		if counts[key] == nil {
+			counts[key] = make(map[string]int)
+			names[key] = t.Description
+		}
+		counts[key][cat]++
+	}
+
+	var rules []Rule
+	for key, cats := range counts {
+		best := Rule{Merchant: names[key]}
+		for cat, n := range cats {
+			if n > best.Matches || (n == best.Matches && cat < best.Category) {
+				best.Category, best.Matches = cat, n
+			}
+		}
+		if best.Matches >= minMatches {
+			rules = append(rules, best)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := strings.ToLower(rules[i].Merchant), strings.ToLower(rules[j].Merchant)
+		if a != b {
+			return a < b
+		}
+		return rules[i].Merchant < rules[j].Merchant
+	})
+	return rules
+}
+
+// merchantKey normalizes a merchant name for matching: lower case, with
+// runs of spaces collapsed.
+func merchantKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// MatchesMerchant reports whether a transaction from merchant falls under the
+// rule, ignoring case and spacing.
+func (r Rule) MatchesMerchant(merchant string) bool {
+	key := merchantKey(merchant)
+	return key != "" && key == merchantKey(r.Merchant)
+}
+
+// SaveRules writes rules as JSON to path, creating its directory if needed.
+func SaveRules(rules []Rule, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// LoadRules reads rules written by SaveRules.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if r.Merchant == "" || r.Category == "" {
+			return nil, fmt.Errorf("%s: rule %d needs a merchant and a category", path, i+1)
+		}
+	}
+	return rules, nil
+}
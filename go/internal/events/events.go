@@ -0,0 +1,172 @@
+// Package events is a small synchronous event bus connecting the fetch
+// pipeline to the sinks, alerts and webhooks that react to it.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Kind identifies the type of an event.
+type Kind string
+
+const (
+	// HoldingsFetched is published with a HoldingsPayload after every
+	// fetch of the portfolio.
+	HoldingsFetched Kind = "holdings.fetched"
+	// SnapshotCreated is published with a SnapshotPayload after a fetch
+	// that recorded a snapshot or has subscribers for it; only then are
+	// the accounts fetched.
+	SnapshotCreated Kind = "snapshot.created"
+	// TransactionsUpdated is published with a TransactionsPayload when
+	// transactions have been fetched.
+	TransactionsUpdated Kind = "transactions.updated"
+	// AccountStale is published with a StalePayload for each account whose
+	// data hasn't been refreshed by its institution recently.
+	AccountStale Kind = "account.stale"
+	// AllocationDrift is published with a DriftPayload for each account
+	// type whose equity share has drifted from the glide path.
+	AllocationDrift Kind = "allocation.drift"
+	// TransactionNew is published with a NewTransactionPayload for each
+	// fetched transaction not seen by an earlier fetch.
+	TransactionNew Kind = "transaction.new"
+	// BalanceChanged is published with a BalancePayload for each account
+	// whose balance differs from the previous snapshot.
+	BalanceChanged Kind = "balance.changed"
+	// MilestoneReached is published with a MilestonePayload the first time
+	// net worth reaches a configured milestone.
+	MilestoneReached Kind = "milestone.reached"
+)
+
+// Event is a message on the bus. Webhooks receive it as JSON, e.g.
+// {"kind": "balance.changed", "time": "2025-04-01T12:00:00Z", "payload":
+// {...}}, with the payload's JSON fields. These hold the user's finances:
+// snapshot.created carries every account with its balance and every
+// holding, holdings.fetched the holdings and transactions.updated every
+// fetched transaction.
+type Event struct {
+	Kind    Kind      `json:"kind"`
+	Time    time.Time `json:"time"`
+	Payload any       `json:"payload"`
+}
+
+// HoldingsPayload accompanies HoldingsFetched.
+type HoldingsPayload struct {
+	Holdings []portfolio.HoldingRecord `json:"holdings"`
+}
+
+// SnapshotPayload accompanies SnapshotCreated. Path is empty when the
+// snapshot was not written to the history store. Previous is the latest
+// snapshot before it, if any, for comparisons; it is not sent to webhooks.
+type SnapshotPayload struct {
+	Path     string            `json:"path,omitempty"`
+	Snapshot history.Snapshot  `json:"snapshot"`
+	Previous *history.Snapshot `json:"-"`
+}
+
+// TransactionsPayload accompanies TransactionsUpdated.
+type TransactionsPayload struct {
+	From         time.Time                  `json:"from"`
+	To           time.Time                  `json:"to"`
+	Transactions []transactions.Transaction `json:"transactions"`
+}
+
+// StalePayload accompanies AccountStale.
+type StalePayload struct {
+	Account     portfolio.AccountRecord `json:"account"`
+	LastUpdated time.Time               `json:"lastUpdated"`
+}
+
+// NewTransactionPayload accompanies TransactionNew.
+type NewTransactionPayload struct {
+	Transaction transactions.Transaction `json:"transaction"`
+}
+
+// BalancePayload accompanies BalanceChanged.
+type BalancePayload struct {
+	Account  portfolio.AccountRecord `json:"account"`
+	Previous float64                 `json:"previous"`
+	Since    time.Time               `json:"since"`
+}
+
+// MilestonePayload accompanies MilestoneReached.
+type MilestonePayload struct {
+	Milestone float64   `json:"milestone"`
+	NetWorth  float64   `json:"netWorth"`
+	Reached   time.Time `json:"reached"`
+}
+
+// DriftPayload accompanies AllocationDrift.
+type DriftPayload struct {
+	Rule   string  `json:"rule"`
+	Age    int     `json:"age"`
+	Group  string  `json:"group"`
+	Equity float64 `json:"equity"`
+	Target float64 `json:"target"`
+}
+
+// Row returns the report row the payload describes.
+func (p DriftPayload) Row() report.GlideRow {
+	return report.GlideRow{Group: p.Group, Equity: p.Equity, Target: p.Target}
+}
+
+// Handler reacts to an event.
+type Handler func(Event) error
+
+// Bus delivers published events to subscribers in subscription order.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[Kind][]Handler
+	all  []Handler
+}
+
+// NewBus returns an empty Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[Kind][]Handler)}
+}
+
+// Subscribe registers h for events of the given kind.
+func (b *Bus) Subscribe(kind Kind, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[kind] = append(b.subs[kind], h)
+}
+
+// SubscribeAll registers h for every event.
+func (b *Bus) SubscribeAll(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, h)
+}
+
+// Subscribed reports whether any handler receives events of the given
+// kind.
+func (b *Bus) Subscribed(kind Kind) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs[kind]) > 0 || len(b.all) > 0
+}
+
+// Publish delivers an event of the given kind to all matching subscribers.
+// Handlers run synchronously and may publish further events. Every handler
+// runs even if an earlier one fails; their errors are joined.
+func (b *Bus) Publish(kind Kind, payload any) error {
+	e := Event{Kind: kind, Time: time.Now().UTC(), Payload: payload}
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.subs[kind]...), b.all...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+var (
+	checking = portfolio.AccountRecord{ID: "acc-chk", Name: "Checking", IsAsset: true, IncludeInNetWorth: true, Balance: 1000}
+	savings  = portfolio.AccountRecord{ID: "acc-sav", Name: "Savings", IsAsset: true, IncludeInNetWorth: true, Balance: 5000}
+	april    = time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// record returns a handler appending the kinds it receives, prefixed
+// with name, to got.
+func record(got *[]string, name string) Handler {
+	return func(e Event) error {
+		*got = append(*got, name+":"+string(e.Kind))
+		return nil
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	if bus.Subscribed(SnapshotCreated) {
+		t.Error("empty bus has subscribers")
+	}
+	var got []string
+	bus.Subscribe(SnapshotCreated, record(&got, "a"))
+	bus.SubscribeAll(record(&got, "all"))
+	bus.Subscribe(SnapshotCreated, record(&got, "b"))
+	bus.Subscribe(SnapshotCreated, func(Event) error { return errors.New("first") })
+	bus.Subscribe(SnapshotCreated, func(Event) error { return errors.New("second") })
+	bus.Subscribe(HoldingsFetched, record(&got, "c"))
+
+	err := bus.Publish(SnapshotCreated, SnapshotPayload{})
+	if err == nil || err.Error() != "first\nsecond" {
+		t.Errorf("got error %v, want both handler errors", err)
+	}
+	// Kind subscribers run in order, then those for every event.
+	want := []string{"a:snapshot.created", "b:snapshot.created", "all:snapshot.created"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !bus.Subscribed(TransactionNew) {
+		t.Error("SubscribeAll handler doesn't count as a subscriber")
+	}
+}
+
+func TestBalanceDetector(t *testing.T) {
+	bus := NewBus()
+	var got []BalancePayload
+	bus.Subscribe(BalanceChanged, func(e Event) error {
+		got = append(got, e.Payload.(BalancePayload))
+		return nil
+	})
+	bus.Subscribe(SnapshotCreated, BalanceDetector(bus))
+
+	prev := history.Snapshot{Time: april.AddDate(0, 0, -1), Accounts: []portfolio.AccountRecord{checking, savings}}
+	moved := checking
+	moved.Balance = 1250
+	brokerage := portfolio.AccountRecord{ID: "acc-brk", Name: "Brokerage", IsAsset: true, Balance: 9000}
+	snap := history.Snapshot{Time: april, Accounts: []portfolio.AccountRecord{moved, savings, brokerage}}
+
+	if err := bus.Publish(SnapshotCreated, SnapshotPayload{Snapshot: snap}); err != nil || len(got) > 0 {
+		t.Fatalf("without a previous snapshot: got %v, %v", got, err)
+	}
+	if err := bus.Publish(SnapshotCreated, SnapshotPayload{Snapshot: snap, Previous: &prev}); err != nil {
+		t.Fatal(err)
+	}
+	want := []BalancePayload{{Account: moved, Previous: 1000, Since: prev.Time}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStaleDetector(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe(AccountStale, func(e Event) error {
+		got = append(got, e.Payload.(StalePayload).Account.Name)
+		return nil
+	})
+	bus.Subscribe(SnapshotCreated, StaleDetector(bus, 7*24*time.Hour))
+
+	fresh, stale, unknown := checking, savings, checking
+	fresh.LastUpdated = april.AddDate(0, 0, -2).Format(time.RFC3339)
+	stale.LastUpdated = april.AddDate(0, 0, -10).Format(time.RFC3339)
+	unknown.Name = "Unknown"
+	snap := history.Snapshot{Time: april, Accounts: []portfolio.AccountRecord{fresh, stale, unknown}}
+	if err := bus.Publish(SnapshotCreated, SnapshotPayload{Snapshot: snap}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Savings"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMilestoneDetector(t *testing.T) {
+	store := history.Open(t.TempDir())
+	bus := NewBus()
+	var got []float64
+	bus.Subscribe(MilestoneReached, func(e Event) error {
+		got = append(got, e.Payload.(MilestonePayload).Milestone)
+		return nil
+	})
+	bus.Subscribe(SnapshotCreated, MilestoneDetector(bus, store, 5000, []float64{7500}))
+
+	snapshot := func(day int, balance float64) SnapshotPayload {
+		a := checking
+		a.Balance = balance
+		return SnapshotPayload{Snapshot: history.Snapshot{Time: april.AddDate(0, 0, day), Accounts: []portfolio.AccountRecord{a}}}
+	}
+	// The first snapshot only sets the baseline.
+	for i, tc := range []struct {
+		p    SnapshotPayload
+		want []float64
+	}{
+		{snapshot(0, 6000), nil},
+		{snapshot(1, 5500), nil},
+		{snapshot(2, 11000), []float64{7500, 10000}},
+		{snapshot(3, 11000), nil},
+	} {
+		got = nil
+		if err := bus.Publish(SnapshotCreated, tc.p); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("snapshot %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+	recorded, ok, err := store.Milestones()
+	if err != nil || !ok || len(recorded) != 3 {
+		t.Errorf("got recorded milestones %v, %t, %v, want 3", recorded, ok, err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); r.Method != http.MethodPost || ct != "application/json" {
+			t.Errorf("got %s with %s, want a JSON POST", r.Method, ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+		if strings.Contains(string(body), `"Previous"`) {
+			t.Errorf("previous snapshot sent: %s", body)
+		}
+	}))
+	defer srv.Close()
+
+	prev := history.Snapshot{Time: april.AddDate(0, 0, -1)}
+	snap := history.Snapshot{Time: april, Accounts: []portfolio.AccountRecord{checking}}
+	e := Event{Kind: SnapshotCreated, Time: april, Payload: SnapshotPayload{Path: "s.json", Snapshot: snap, Previous: &prev}}
+	if err := Webhook(srv.URL)(e); err != nil {
+		t.Fatal(err)
+	}
+	if got["kind"] != "snapshot.created" || got["time"] != "2025-04-01T12:00:00Z" {
+		t.Errorf("got kind %v, time %v", got["kind"], got["time"])
+	}
+	payload, _ := got["payload"].(map[string]any)
+	accounts, _ := payload["snapshot"].(map[string]any)["accounts"].([]any)
+	if payload["path"] != "s.json" || len(accounts) != 1 || accounts[0].(map[string]any)["balance"] != 1000.0 {
+		t.Errorf("got payload %v, want the snapshot with its balances", payload)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := Webhook(failing.URL)(e); err == nil || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Errorf("got %v, want HTTP 502", err)
+	}
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/broker"
+)
+
+// BalanceDetector publishes BalanceChanged for every account in a new
+// snapshot whose balance differs from the previous snapshot's. Accounts
+// new since then are not reported.
+func BalanceDetector(bus *Bus) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(SnapshotPayload)
+		if !ok || p.Previous == nil {
+			return nil
+		}
+		prev := map[string]float64{}
+		for _, a := range p.Previous.Accounts {
+			prev[a.ID] = a.Balance
+		}
+		var errs []error
+		for _, a := range p.Snapshot.Accounts {
+			before, ok := prev[a.ID]
+			if !ok || math.Abs(a.Balance-before) < 0.005 {
+				continue
+			}
+			if err := bus.Publish(BalanceChanged, BalancePayload{Account: a, Previous: before, Since: p.Previous.Time}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// TransactionDetector publishes TransactionNew for every fetched
+// transaction whose ID isn't in the state file at path, then records the
+// fetched IDs there. The first fetch, without a state file, only records
+// them. If a subscriber fails, the state is kept, so the transactions are
+// published again by the next fetch.
+func TransactionDetector(bus *Bus, path string) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(TransactionsPayload)
+		if !ok {
+			return nil
+		}
+		// seen maps the IDs of transactions already published to their dates.
+		seen := map[string]string{}
+		raw, err := os.ReadFile(path)
+		first := os.IsNotExist(err)
+		if err != nil && !first {
+			return err
+		}
+		if !first {
+			if err := json.Unmarshal(raw, &seen); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		var errs []error
+		for _, t := range p.Transactions {
+			if _, ok := seen[t.ID]; ok || first {
+				continue
+			}
+			if err := bus.Publish(TransactionNew, NewTransactionPayload{Transaction: t}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+
+		// Forget transactions older than the fetched range; they can't
+		// be fetched again unless the range grows.
+		from := p.From.Format(time.DateOnly)
+		for id, date := range seen {
+			if date < from {
+				delete(seen, id)
+			}
+		}
+		for _, t := range p.Transactions {
+			seen[t.ID] = t.Date
+		}
+		out, err := json.Marshal(seen)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		return os.WriteFile(path, out, 0600)
+	}
+}
+
+// SchemaVersion is the version of the messages Broker publishes. It
+// changes when a field is removed or changes meaning; fields may be added
+// without a new version.
+const SchemaVersion = 1
+
+// Topics of the messages Broker publishes, after the prefix.
+const (
+	TopicTransactionNew   = "transaction.new"
+	TopicBalanceChanged   = "balance.changed"
+	TopicSnapshotComplete = "snapshot.complete"
+)
+
+// BrokerMessage is the JSON value of a message Broker publishes.
+type BrokerMessage struct {
+	// Schema is the topic without its prefix, e.g. "transaction.new",
+	// naming the type of Data.
+	Schema  string    `json:"schema"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data"`
+}
+
+// BalanceChange is the data of a balance.changed message.
+type BalanceChange struct {
+	AccountID   string    `json:"accountId"`
+	Name        string    `json:"name"`
+	Institution string    `json:"institution"`
+	Previous    float64   `json:"previous"`
+	Balance     float64   `json:"balance"`
+	Change      float64   `json:"change"`
+	Since       time.Time `json:"since"`
+}
+
+// SnapshotSummary is the data of a snapshot.complete message.
+type SnapshotSummary struct {
+	Time     time.Time `json:"time"`
+	Path     string    `json:"path,omitempty"`
+	Accounts int       `json:"accounts"`
+	Holdings int       `json:"holdings"`
+	NetWorth float64   `json:"netWorth"`
+}
+
+// Broker publishes new transactions, balance changes and completed
+// snapshots to the topics prefix + "." + one of the Topic constants.
+// Messages are keyed by transaction ID, account ID and snapshot time.
+func Broker(pub broker.Publisher, prefix string) Handler {
+	return func(e Event) error {
+		var schema, key string
+		var data any
+		switch p := e.Payload.(type) {
+		case NewTransactionPayload:
+			schema, key, data = TopicTransactionNew, p.Transaction.ID, p.Transaction
+		case BalancePayload:
+			schema, key = TopicBalanceChanged, p.Account.ID
+			data = BalanceChange{
+				AccountID: p.Account.ID, Name: p.Account.Name, Institution: p.Account.InstitutionName,
+				Previous: p.Previous, Balance: p.Account.Balance, Change: p.Account.Balance - p.Previous, Since: p.Since,
+			}
+		case SnapshotPayload:
+			schema, key = TopicSnapshotComplete, p.Snapshot.Time.Format(time.RFC3339)
+			data = SnapshotSummary{
+				Time: p.Snapshot.Time, Path: p.Path, Accounts: len(p.Snapshot.Accounts),
+				Holdings: len(p.Snapshot.Holdings), NetWorth: p.Snapshot.NetWorth(false),
+			}
+		default:
+			return nil
+		}
+		value, err := json.Marshal(BrokerMessage{Schema: schema, Version: SchemaVersion, Time: e.Time, Data: data})
+		if err != nil {
+			return err
+		}
+		return pub.Publish(context.Background(), []broker.Message{{Topic: prefix + "." + schema, Key: []byte(key), Value: value}})
+	}
+}
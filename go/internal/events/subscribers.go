@@ -0,0 +1,239 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/notify"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+// StaleDetector publishes AccountStale for every account in a new snapshot
+// whose institution data is older than maxAge.
+func StaleDetector(bus *Bus, maxAge time.Duration) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(SnapshotPayload)
+		if !ok {
+			return nil
+		}
+		var errs []error
+		for _, a := range p.Snapshot.Accounts {
+			updated, err := time.Parse(time.RFC3339, a.LastUpdated)
+			if err != nil || p.Snapshot.Time.Sub(updated) <= maxAge {
+				continue
+			}
+			if err := bus.Publish(AccountStale, StalePayload{Account: a, LastUpdated: updated}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// StaleAlert prints a warning for each AccountStale event.
+func StaleAlert(w io.Writer) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(StalePayload)
+		if !ok {
+			return nil
+		}
+		fmt.Fprintf(w, "Warning: %s (%s) last updated %s\n",
+			p.Account.Name, p.Account.InstitutionName, p.LastUpdated.Format(time.DateOnly))
+		return nil
+	}
+}
+
+// GlideDetector publishes AllocationDrift for every account type in a new
+// snapshot whose equity share is more than tolerance percentage points
+// from the glide path rule for someone born in birthYear.
+func GlideDetector(bus *Bus, rule report.GlideRule, birthYear int, cash portfolio.CashTickers, tolerance float64) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(SnapshotPayload)
+		if !ok {
+			return nil
+		}
+		age := p.Snapshot.Time.Year() - birthYear
+		var errs []error
+		for _, r := range report.GlidePath(p.Snapshot, cash, rule.Target(age), false) {
+			if !r.Drifted(tolerance) {
+				continue
+			}
+			payload := DriftPayload{Rule: rule.String(), Age: age, Group: r.Group, Equity: r.Equity, Target: r.Target}
+			if err := bus.Publish(AllocationDrift, payload); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// DriftAlert prints a warning for each AllocationDrift event.
+func DriftAlert(w io.Writer) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(DriftPayload)
+		if !ok {
+			return nil
+		}
+		fmt.Fprintln(w, "Warning:", report.DriftSummary(p.Row()))
+		return nil
+	}
+}
+
+// MilestoneDetector publishes MilestoneReached the first time a snapshot's
+// net worth reaches a milestone, that is one of targets or a multiple of
+// every, and records when in store. Until store has milestones recorded,
+// those reached by earlier snapshots are recorded as of the first snapshot
+// reaching them and not published; without earlier snapshots, the new one
+// only sets the baseline. A milestone whose subscribers fail is published
+// again by the next fetch.
+func MilestoneDetector(bus *Bus, store *history.Store, every float64, targets []float64) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(SnapshotPayload)
+		if !ok {
+			return nil
+		}
+		recorded, ok, err := store.Milestones()
+		if err != nil {
+			return err
+		}
+		reached := map[float64]bool{}
+		for _, m := range recorded {
+			reached[m.Amount] = true
+		}
+		record := func(amount float64, t time.Time) {
+			reached[amount] = true
+			recorded = append(recorded, history.Milestone{Amount: amount, Reached: t})
+		}
+		baseline := !ok
+		if !ok {
+			snaps, err := store.List()
+			if err != nil {
+				return err
+			}
+			for _, s := range snaps {
+				if !s.Time.Before(p.Snapshot.Time) {
+					continue
+				}
+				baseline = false
+				for _, amount := range milestones(s.NetWorth(false), every, targets) {
+					if !reached[amount] {
+						record(amount, s.Time)
+					}
+				}
+			}
+		}
+
+		netWorth := p.Snapshot.NetWorth(false)
+		var errs []error
+		for _, amount := range milestones(netWorth, every, targets) {
+			if reached[amount] {
+				continue
+			}
+			if !baseline {
+				payload := MilestonePayload{Milestone: amount, NetWorth: netWorth, Reached: p.Snapshot.Time}
+				if err := bus.Publish(MilestoneReached, payload); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			record(amount, p.Snapshot.Time)
+		}
+		if err := store.SaveMilestones(recorded); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// milestones returns the milestones at or below netWorth in ascending
+// order: the multiples of every, if positive, and targets.
+func milestones(netWorth, every float64, targets []float64) []float64 {
+	var out []float64
+	if every > 0 {
+		for k := 1.0; k*every <= netWorth; k++ {
+			out = append(out, k*every)
+		}
+	}
+	for _, t := range targets {
+		if t <= netWorth && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// MilestoneAlert prints a line for each MilestoneReached event.
+func MilestoneAlert(w io.Writer) Handler {
+	return func(e Event) error {
+		p, ok := e.Payload.(MilestonePayload)
+		if !ok {
+			return nil
+		}
+		fmt.Fprintf(w, "Milestone: net worth reached %.2f for the first time (now %.2f)\n", p.Milestone, p.NetWorth)
+		return nil
+	}
+}
+
+// notifyMovers is how many top movers a snapshot notification lists.
+const notifyMovers = 3
+
+// Notify sends a notification for each new snapshot, stale account,
+// allocation drift and milestone.
+// Snapshot notifications list the top movers since the previous snapshot.
+func Notify(n notify.Notifier) Handler {
+	return func(e Event) error {
+		switch p := e.Payload.(type) {
+		case SnapshotPayload:
+			body := fmt.Sprintf("%d accounts, net worth %.2f",
+				len(p.Snapshot.Accounts), p.Snapshot.NetWorth(false))
+			if p.Previous != nil {
+				holdings, _ := report.Movers(*p.Previous, p.Snapshot, notifyMovers, false)
+				if s := report.MoversSummary(holdings); s != "" {
+					body += "\n" + s
+				}
+			}
+			return n.Notify("Monarch sync complete", body)
+		case StalePayload:
+			return n.Notify("Monarch account not updating", fmt.Sprintf("%s (%s) last updated %s",
+				p.Account.Name, p.Account.InstitutionName, p.LastUpdated.Format(time.DateOnly)))
+		case DriftPayload:
+			return n.Notify("Monarch allocation drifted", fmt.Sprintf("%s (glide path %s at age %d)",
+				report.DriftSummary(p.Row()), p.Rule, p.Age))
+		case MilestonePayload:
+			return n.Notify("Monarch milestone reached", fmt.Sprintf("Net worth reached %.2f on %s (now %.2f)",
+				p.Milestone, p.Reached.Format(time.DateOnly), p.NetWorth))
+		}
+		return nil
+	}
+}
+
+// Webhook POSTs each event as JSON to url, in the form described at
+// Event. As the payloads include balances, holdings and transactions, url
+// should use HTTPS and point at a service the user trusts.
+func Webhook(url string) Handler {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	return func(e Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("webhook %s: %w", url, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook %s: HTTP %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// fireflyColumns are the columns of the Firefly III transactions CSV, with
+// the Data Importer role each one maps to.
+var fireflyColumns = []struct{ name, role string }{
+	{"date", "date_transaction"},
+	{"description", "description"},
+	{"amount", "amount"},
+	{"account", "account-name"},
+	{"opposing_account", "opposing-name"},
+	{"category", "category-name"},
+	{"tags", "tags-comma"},
+	{"notes", "note"},
+	{"external_id", "external-id"},
+}
+
+// WriteFireflyTransactions writes transactions as CSV for the Firefly III
+// Data Importer. Amounts keep Monarch's sign: negative amounts are
+// withdrawals from the account, positive ones deposits into it. Pending
+// transactions are skipped since they may still change.
+func WriteFireflyTransactions(w io.Writer, txns []transactions.Transaction) error {
+	header := make([]string, len(fireflyColumns))
+	for i, c := range fireflyColumns {
+		header[i] = c.name
+	}
+	var rows [][]string
+	for _, t := range txns {
+		if t.Pending {
+			continue
+		}
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = tag.Name
+		}
+		description := t.Merchant.Name
+		if description == "" {
+			description = t.Category.Name
+		}
+		category := t.Category.Name
+		if t.IsUncategorized() {
+			category = ""
+		}
+		rows = append(rows, []string{
+			t.Date,
+			description,
+			formatMoney(t.Amount),
+			t.Account.DisplayName,
+			t.Merchant.Name,
+			category,
+			strings.Join(tags, ","),
+			t.Notes,
+			"monarch:" + t.ID,
+		})
+	}
+	return writeCSV(w, header, rows)
+}
+
+// WriteFireflyAccounts writes accounts as CSV listing what to create in
+// Firefly III: asset accounts with their role and liabilities with their
+// type, each with the current balance.
+func WriteFireflyAccounts(w io.Writer, accounts []portfolio.AccountRecord, currency string) error {
+	header := []string{"name", "type", "role", "currency", "balance", "institution", "account_number", "monarch_id"}
+	rows := make([][]string, len(accounts))
+	for i, a := range accounts {
+		typ, role := fireflyAccountType(a)
+		rows[i] = []string{
+			a.Name,
+			typ,
+			role,
+			currency,
+			formatMoney(a.Balance),
+			a.InstitutionName,
+			a.Mask,
+			a.ID,
+		}
+	}
+	return writeCSV(w, header, rows)
+}
+
+// fireflyAccountType maps a Monarch account onto a Firefly III account type
+// and asset role or liability type.
+func fireflyAccountType(a portfolio.AccountRecord) (typ, role string) {
+	if !a.IsAsset {
+		switch {
+		case strings.Contains(a.Subtype, "mortgage"):
+			return "liability", "mortgage"
+		case a.Type == "loan":
+			return "liability", "loan"
+		case a.Type == "credit":
+			return "asset", "ccAsset"
+		}
+		return "liability", "debt"
+	}
+	switch {
+	case strings.Contains(a.Subtype, "savings"):
+		return "asset", "savingAsset"
+	case a.Type == "depository":
+		return "asset", "defaultAsset"
+	}
+	return "asset", "sharedAsset"
+}
+
+// FireflyImportConfig returns a Firefly III Data Importer configuration
+// matching WriteFireflyTransactions, so the CSV imports without manual
+// column mapping. created is recorded as the configuration's creation time.
+func FireflyImportConfig(created time.Time) ([]byte, error) {
+	roles := make([]string, len(fireflyColumns))
+	doMapping := make([]bool, len(fireflyColumns))
+	for i, c := range fireflyColumns {
+		roles[i] = c.role
+	}
+	cfg := map[string]any{
+		"version":                    3,
+		"source":                     "monarch",
+		"created_at":                 created.UTC().Format(time.RFC3339),
+		"flow":                       "file",
+		"content_type":               "csv",
+		"delimiter":                  "comma",
+		"headers":                    true,
+		"date":                       "Y-m-d",
+		"default_account":            0,
+		"duplicate_detection_method": "cell",
+		"unique_column_index":        len(fireflyColumns) - 1,
+		"unique_column_type":         "external-id",
+		"ignore_duplicate_lines":     true,
+		"roles":                      roles,
+		"do_mapping":                 doMapping,
+		"mapping":                    map[string]any{},
+	}
+	return json.MarshalIndent(cfg, "", "    ")
+}
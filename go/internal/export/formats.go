@@ -0,0 +1,116 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Portfolio Performance transaction type names used by its CSV importer.
+var ppTradeTypes = map[TradeType]string{
+	TradeOpening: "Delivery (Inbound)",
+	TradeBuy:     "Buy",
+	TradeSell:    "Sell",
+}
+
+var ppCashTypes = map[CashFlowType]string{
+	CashDeposit:  "Deposit",
+	CashRemoval:  "Removal",
+	CashDividend: "Dividend",
+	CashInterest: "Interest",
+	CashFee:      "Fees",
+}
+
+// WritePortfolioPerformanceTrades writes trades in the column layout of
+// Portfolio Performance's "Portfolio Transactions" CSV import.
+func WritePortfolioPerformanceTrades(w io.Writer, trades []Trade, currency string) error {
+	header := []string{"Date", "Type", "Security Name", "Ticker Symbol", "Shares", "Value", "Transaction Currency", "Securities Account", "Note"}
+	rows := make([][]string, len(trades))
+	for i, t := range trades {
+		rows[i] = []string{
+			t.Date.Format(time.DateOnly),
+			ppTradeTypes[t.Type],
+			t.Name,
+			t.Ticker,
+			formatNumber(t.Quantity),
+			formatMoney(t.Value()),
+			currency,
+			t.AccountName,
+			"Derived from Monarch snapshots",
+		}
+	}
+	return writeCSV(w, header, rows)
+}
+
+// WritePortfolioPerformanceCash writes cash flows in the column layout of
+// Portfolio Performance's "Account Transactions" CSV import.
+func WritePortfolioPerformanceCash(w io.Writer, flows []CashFlow, currency string) error {
+	header := []string{"Date", "Type", "Value", "Transaction Currency", "Cash Account", "Note"}
+	rows := make([][]string, len(flows))
+	for i, f := range flows {
+		rows[i] = []string{
+			f.Date.Format(time.DateOnly),
+			ppCashTypes[f.Type],
+			formatMoney(f.Amount),
+			currency,
+			f.AccountName,
+			f.Note,
+		}
+	}
+	return writeCSV(w, header, rows)
+}
+
+var sharesightTypes = map[TradeType]string{
+	TradeOpening: "OPENING_BALANCE",
+	TradeBuy:     "BUY",
+	TradeSell:    "SELL",
+}
+
+// WriteSharesightTrades writes trades as a Sharesight trade import file.
+// Sharesight needs the listing market of each instrument; market is used
+// for all of them.
+func WriteSharesightTrades(w io.Writer, trades []Trade, market string) error {
+	header := []string{
+		"Trade Date", "Instrument Code", "Market Code", "Quantity", "Price in Dollars",
+		"Transaction Type", "Exchange Rate (optional)", "Brokerage (optional)",
+		"Brokerage Currency (optional)", "Comments (optional)",
+	}
+	var rows [][]string
+	for _, t := range trades {
+		if t.Ticker == "" {
+			// Sharesight can't match an instrument without a code.
+			continue
+		}
+		rows = append(rows, []string{
+			t.Date.Format(time.DateOnly),
+			t.Ticker,
+			market,
+			formatNumber(t.Quantity),
+			formatNumber(t.Price),
+			sharesightTypes[t.Type],
+			"", "", "",
+			t.AccountName,
+		})
+	}
+	return writeCSV(w, header, rows)
+}
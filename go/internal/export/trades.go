@@ -0,0 +1,220 @@
+// Package export converts recorded snapshots and transactions into the
+// import formats of third-party portfolio trackers.
+package export
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// TradeType classifies a Trade.
+type TradeType string
+
+const (
+	// TradeOpening is a position already held in the first snapshot.
+	TradeOpening TradeType = "opening"
+	TradeBuy     TradeType = "buy"
+	TradeSell    TradeType = "sell"
+)
+
+// quantityEpsilon ignores float noise in share counts between snapshots.
+const quantityEpsilon = 1e-6
+
+// Trade is a change in the quantity of one security in one account.
+// Monarch doesn't expose lot-level trades, so trades are derived from the
+// quantity differences between consecutive snapshots and priced at the
+// closing price of the snapshot where the change was seen.
+type Trade struct {
+	Date        time.Time
+	AccountID   string
+	AccountName string
+	Ticker      string
+	Name        string
+	Type        TradeType
+	Quantity    float64
+	Price       float64
+}
+
+// Value is the trade's quantity times price.
+func (t Trade) Value() float64 {
+	return t.Quantity * t.Price
+}
+
+type position struct {
+	record   portfolio.HoldingRecord
+	quantity float64
+}
+
+// positionKey identifies a security within an account.
+func positionKey(h portfolio.HoldingRecord) string {
+	sec := h.SecurityID
+	if sec == "" {
+		sec = strings.ToUpper(h.Ticker) + "|" + h.HoldingName
+	}
+	return h.AccountID + "|" + sec
+}
+
+func positions(snap history.Snapshot, isCash func(portfolio.HoldingRecord) bool) map[string]position {
+	pos := make(map[string]position)
+	for _, h := range snap.Holdings {
+		if isCash(h) {
+			continue
+		}
+		k := positionKey(h)
+		p := pos[k]
+		p.record = h
+		p.quantity += h.Quantity
+		pos[k] = p
+	}
+	return pos
+}
+
+// Trades derives the trades that explain the holdings in snaps, which must
+// be in chronological order. Holdings for which isCash reports true are
+// left out. The result is sorted by date, account and ticker.
+func Trades(snaps []history.Snapshot, isCash func(portfolio.HoldingRecord) bool) []Trade {
+	if len(snaps) == 0 {
+		return nil
+	}
+	var trades []Trade
+	add := func(date time.Time, h portfolio.HoldingRecord, typ TradeType, qty, price float64) {
+		ticker := h.Ticker
+		if ticker == "" {
+			ticker = h.SecurityTicker
+		}
+		name := h.SecurityName
+		if name == "" {
+			name = h.HoldingName
+		}
+		trades = append(trades, Trade{
+			Date:        date,
+			AccountID:   h.AccountID,
+			AccountName: h.AccountName,
+			Ticker:      ticker,
+			Name:        name,
+			Type:        typ,
+			Quantity:    qty,
+			Price:       price,
+		})
+	}
+
+	prev := positions(snaps[0], isCash)
+	for _, p := range prev {
+		if p.quantity > quantityEpsilon {
+			add(snaps[0].Time, p.record, TradeOpening, p.quantity, p.record.ClosingPrice)
+		}
+	}
+	for _, snap := range snaps[1:] {
+		cur := positions(snap, isCash)
+		for k, p := range cur {
+			delta := p.quantity - prev[k].quantity
+			if delta > quantityEpsilon {
+				add(snap.Time, p.record, TradeBuy, delta, p.record.ClosingPrice)
+			} else if delta < -quantityEpsilon {
+				add(snap.Time, p.record, TradeSell, -delta, p.record.ClosingPrice)
+			}
+		}
+		for k, p := range prev {
+			if _, ok := cur[k]; !ok && p.quantity > quantityEpsilon {
+				// Sold out completely; the last known price is the best guess.
+				add(snap.Time, p.record, TradeSell, p.quantity, p.record.ClosingPrice)
+			}
+		}
+		prev = cur
+	}
+
+	sort.Slice(trades, func(i, j int) bool {
+		a, b := trades[i], trades[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.AccountName != b.AccountName {
+			return a.AccountName < b.AccountName
+		}
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Ticker != b.Ticker {
+			return a.Ticker < b.Ticker
+		}
+		return a.Type < b.Type
+	})
+	return trades
+}
+
+// CashFlowType classifies a CashFlow.
+type CashFlowType string
+
+const (
+	CashDeposit  CashFlowType = "deposit"
+	CashRemoval  CashFlowType = "removal"
+	CashDividend CashFlowType = "dividend"
+	CashInterest CashFlowType = "interest"
+	CashFee      CashFlowType = "fee"
+)
+
+// CashFlow is a non-trade movement of cash in an investment account.
+type CashFlow struct {
+	Date        time.Time
+	AccountName string
+	Type        CashFlowType
+	// Amount is always positive; Type gives the direction.
+	Amount float64
+	Note   string
+}
+
+// CashFlows classifies transactions in investment accounts as deposits,
+// removals, dividends, interest or fees. Transactions that fit none of
+// these, such as the cash legs of trades, are skipped.
+func CashFlows(txns []transactions.Transaction) []CashFlow {
+	var flows []CashFlow
+	for _, t := range txns {
+		if t.Pending {
+			continue
+		}
+		cat := strings.ToLower(t.Category.Name)
+		var typ CashFlowType
+		switch {
+		case t.IsTransfer() && t.Amount > 0:
+			typ = CashDeposit
+		case t.IsTransfer():
+			typ = CashRemoval
+		case strings.Contains(cat, "dividend"):
+			typ = CashDividend
+		case strings.Contains(cat, "interest"):
+			typ = CashInterest
+		case strings.Contains(cat, "fee"):
+			typ = CashFee
+		default:
+			continue
+		}
+		note := t.Merchant.Name
+		if t.Notes != "" {
+			note = t.Notes
+		}
+		flows = append(flows, CashFlow{
+			Date:        t.Time(),
+			AccountName: t.Account.DisplayName,
+			Type:        typ,
+			Amount:      math.Abs(t.Amount),
+			Note:        note,
+		})
+	}
+	sort.SliceStable(flows, func(i, j int) bool {
+		a, b := flows[i], flows[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.AccountName != b.AccountName {
+			return a.AccountName < b.AccountName
+		}
+		return a.Type < b.Type
+	})
+	return flows
+}
@@ -0,0 +1,25 @@
+// Package filelock takes advisory locks on files shared between processes.
+package filelock
+
+import (
+	"fmt"
+	"os"
+)
+
+// Lock takes an advisory lock on the file at path, creating it if needed,
+// shared for reading and exclusive for writing, and returns a function
+// that releases it. The lock file itself is left in place.
+func Lock(path string, exclusive bool) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
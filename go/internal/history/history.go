@@ -0,0 +1,249 @@
+// Package history stores point-in-time snapshots of accounts and holdings so
+// reports can compare values across fetches.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// DefaultDir is where snapshots are written by default.
+const DefaultDir = ".mm/history"
+
+// fileTimeFormat names snapshot files so they sort chronologically.
+const fileTimeFormat = "20060102T150405Z"
+
+// Snapshot is the state of all accounts and holdings at one point in time.
+type Snapshot struct {
+	Time     time.Time                 `json:"time"`
+	Accounts []portfolio.AccountRecord `json:"accounts"`
+	Holdings []portfolio.HoldingRecord `json:"holdings"`
+}
+
+// Store is a directory of snapshot files, one JSON file per snapshot.
+type Store struct {
+	dir string
+	// key, if set, keys the manifest's chain.
+	key []byte
+}
+
+// Open returns a Store rooted at dir. The directory is created on first save.
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// OpenKeyed is Open for a store whose manifest is chained with an
+// HMAC-SHA256 under key, so that Verify detects snapshots rewritten along
+// with their manifest by anyone without the key. Keep the key outside
+// dir, e.g. with LoadKey. Saving or deleting a snapshot chains the whole
+// manifest with key.
+func OpenKeyed(dir string, key []byte) *Store {
+	return &Store{dir: dir, key: key}
+}
+
+// Dir returns the directory the store reads from and writes to.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes snap to the store, records its hash in the manifest and
+// returns the path of the new file.
+func (s *Store) Save(snap Snapshot) (string, error) {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	name := snap.Time.UTC().Format(fileTimeFormat) + ".json"
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	if err := s.record(name, data); err != nil {
+		return "", fmt.Errorf("update manifest: %w", err)
+	}
+	return path, nil
+}
+
+// Delete removes a snapshot file and its manifest entry, re-chaining the
+// remaining entries.
+func (s *Store) Delete(path string) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.forget(filepath.Base(path))
+}
+
+// List returns all snapshots in chronological order.
+func (s *Store) List() ([]Snapshot, error) {
+	names, err := s.snapshotFiles()
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, name := range names {
+		snap, err := load(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].Time.Before(snaps[j].Time)
+	})
+	return snaps, nil
+}
+
+// snapshotFiles returns the names of the snapshot files in the store in
+// chronological order, ignoring the manifest and any unrelated files.
+func (s *Store) snapshotFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, ok := fileTime(e.Name()); ok && !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// fileTime parses the time a snapshot file was taken from its name.
+func fileTime(name string) (time.Time, bool) {
+	stem, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(fileTimeFormat, stem)
+	return t, err == nil
+}
+
+// Range returns the snapshots taken between from and to (inclusive) in
+// chronological order. A zero from or to leaves that end unbounded.
+func (s *Store) Range(from, to time.Time) ([]Snapshot, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, snap := range all {
+		if !from.IsZero() && snap.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && snap.Time.After(to) {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, nil
+}
+
+func load(path string) (Snapshot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return snap, nil
+}
+
+// AccountValues returns the value of each account in the snapshot, keyed by
+// account ID. Balances are taken from the account list when present and
+// otherwise summed from holdings.
+func (snap Snapshot) AccountValues() map[string]float64 {
+	values := make(map[string]float64)
+	if len(snap.Accounts) > 0 {
+		for _, a := range snap.Accounts {
+			values[a.ID] = a.Balance
+		}
+		return values
+	}
+	for _, h := range snap.Holdings {
+		values[h.AccountID] += h.Value
+	}
+	return values
+}
+
+// Account returns the account record with the given ID, if present.
+func (snap Snapshot) Account(id string) (portfolio.AccountRecord, bool) {
+	for _, a := range snap.Accounts {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return portfolio.AccountRecord{}, false
+}
+
+// NetWorth returns assets minus liabilities across the accounts in the
+// snapshot, skipping accounts Monarch excludes from net worth unless
+// includeExcluded is set. Liability balances are subtracted regardless of
+// the sign Monarch reports them with.
+func (snap Snapshot) NetWorth(includeExcluded bool) float64 {
+	var total float64
+	for _, a := range snap.Accounts {
+		if !a.IncludeInNetWorth && !includeExcluded {
+			continue
+		}
+		if a.IsAsset {
+			total += a.Balance
+		} else {
+			total -= math.Abs(a.Balance)
+		}
+	}
+	return total
+}
+
+// ExcludedAccounts returns the IDs of accounts marked in Monarch as excluded
+// from net worth.
+func (snap Snapshot) ExcludedAccounts() map[string]bool {
+	excluded := make(map[string]bool)
+	for _, a := range snap.Accounts {
+		if !a.IncludeInNetWorth {
+			excluded[a.ID] = true
+		}
+	}
+	return excluded
+}
+
+// FilesBefore returns the paths of snapshot files taken before t, based on
+// their file names. A zero t returns every snapshot file.
+func (s *Store) FilesBefore(t time.Time) ([]string, error) {
+	names, err := s.snapshotFiles()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range names {
+		taken, _ := fileTime(name)
+		if t.IsZero() || taken.Before(t) {
+			paths = append(paths, filepath.Join(s.dir, name))
+		}
+	}
+	return paths, nil
+}
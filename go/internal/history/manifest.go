@@ -0,0 +1,293 @@
+package history
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heikofkoehler/monarch/internal/filelock"
+)
+
+// manifestFile records the hash of every snapshot in the store.
+const manifestFile = "manifest.json"
+
+// ManifestEntry is the recorded hash of one snapshot file. Chain hashes the
+// previous entry's chain with this entry's file name and hash, so removing,
+// reordering or rewriting entries is detected as well as changed files.
+// In a keyed store Chain is an HMAC, which only the key's holder can
+// recompute after editing the store.
+type ManifestEntry struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Chain  string `json:"chain"`
+}
+
+// keySize is the length of a manifest key in bytes.
+const keySize = 32
+
+// LoadKey reads the manifest key kept in the file at path, for
+// OpenKeyed. If there is none it returns nil, or with create a new
+// random key it has saved there.
+func LoadKey(path string, create bool) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		return createKey(path)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%s doesn't hold a %d-byte hex key", path, keySize)
+	}
+	return key, nil
+}
+
+func createKey(path string) ([]byte, error) {
+	key := make([]byte, keySize)
+	rand.Read(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		// Another process created it first.
+		return LoadKey(path, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) chainHash(prev, file, sum string) string {
+	msg := []byte(prev + "\n" + file + "\n" + sum)
+	if s.key == nil {
+		return hashBytes(msg)
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(msg)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Store) manifestPath() string {
+	return filepath.Join(s.dir, manifestFile)
+}
+
+// lock takes the store's lock, exclusive for changing the snapshots and
+// the manifest together and shared for reading them. A shared lock on a
+// store that doesn't exist yet is a no-op.
+func (s *Store) lock(exclusive bool) (func(), error) {
+	path := s.manifestPath() + ".lock"
+	if exclusive {
+		if err := os.MkdirAll(s.dir, 0700); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(s.dir); errors.Is(err, fs.ErrNotExist) {
+		return func() {}, nil
+	}
+	return filelock.Lock(path, exclusive)
+}
+
+func (s *Store) readManifest() ([]ManifestEntry, error) {
+	raw, err := os.ReadFile(s.manifestPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", s.manifestPath(), err)
+	}
+	return entries, nil
+}
+
+// writeManifest replaces the manifest through a temporary file, so that
+// it is never seen half written.
+func (s *Store) writeManifest(entries []ManifestEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, manifestFile+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.manifestPath())
+}
+
+// rechain recomputes the chain hashes of entries in order.
+func (s *Store) rechain(entries []ManifestEntry) {
+	prev := ""
+	for i := range entries {
+		entries[i].Chain = s.chainHash(prev, entries[i].File, entries[i].SHA256)
+		prev = entries[i].Chain
+	}
+}
+
+// record adds or replaces the manifest entry for a snapshot file. The
+// caller holds the store's exclusive lock.
+func (s *Store) record(name string, data []byte) error {
+	entries, err := s.readManifest()
+	if err != nil {
+		return err
+	}
+	entries = removeEntry(entries, name)
+	entries = append(entries, ManifestEntry{File: name, SHA256: hashBytes(data)})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].File < entries[j].File })
+	s.rechain(entries)
+	return s.writeManifest(entries)
+}
+
+// forget drops the manifest entry for a snapshot file. The caller holds
+// the store's exclusive lock.
+func (s *Store) forget(name string) error {
+	entries, err := s.readManifest()
+	if err != nil || entries == nil {
+		return err
+	}
+	entries = removeEntry(entries, name)
+	s.rechain(entries)
+	return s.writeManifest(entries)
+}
+
+func removeEntry(entries []ManifestEntry, name string) []ManifestEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		if e.File != name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Problem describes one integrity failure found by Verify.
+type Problem struct {
+	File    string
+	Message string
+}
+
+// Verify checks every snapshot against the manifest. It reports files whose
+// contents changed, files missing from disk, files not in the manifest, and
+// breaks in the hash chain. An empty result means the store is intact.
+func (s *Store) Verify() ([]Problem, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	entries, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.snapshotFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	recorded := make(map[string]bool, len(entries))
+	prev := ""
+	for _, e := range entries {
+		recorded[e.File] = true
+		if want := s.chainHash(prev, e.File, e.SHA256); !hmac.Equal([]byte(e.Chain), []byte(want)) {
+			problems = append(problems, Problem{e.File, "manifest chain broken (entries edited, removed or reordered, or chained with another key)"})
+		}
+		prev = e.Chain
+
+		data, err := os.ReadFile(filepath.Join(s.dir, e.File))
+		if os.IsNotExist(err) {
+			problems = append(problems, Problem{e.File, "missing from disk"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hashBytes(data) != e.SHA256 {
+			problems = append(problems, Problem{e.File, "contents changed since it was recorded"})
+		}
+	}
+	for _, name := range names {
+		if !recorded[name] {
+			problems = append(problems, Problem{name, "not recorded in manifest"})
+		}
+	}
+	return problems, nil
+}
+
+// Adopt records any snapshot files missing from the manifest, e.g. those
+// written before the manifest existed, and returns their names. It also
+// chains the manifest anew, e.g. with a key the store didn't have before,
+// so it trusts the recorded hashes as they are.
+func (s *Store) Adopt() ([]string, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	entries, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.snapshotFiles()
+	if err != nil {
+		return nil, err
+	}
+	recorded := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		recorded[e.File] = true
+	}
+	var adopted []string
+	for _, name := range names {
+		if recorded[name] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ManifestEntry{File: name, SHA256: hashBytes(data)})
+		adopted = append(adopted, name)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].File < entries[j].File })
+	s.rechain(entries)
+	return adopted, s.writeManifest(entries)
+}
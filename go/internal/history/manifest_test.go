@@ -0,0 +1,92 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentSaves checks that saves racing each other all end up in
+// the manifest.
+func TestConcurrentSaves(t *testing.T) {
+	store := OpenKeyed(t.TempDir(), bytes.Repeat([]byte{1}, keySize))
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Save(Snapshot{Time: start.Add(time.Duration(i) * time.Hour)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	entries, err := store.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Errorf("got %d manifest entries, want %d", len(entries), n)
+	}
+	if problems, err := store.Verify(); err != nil || len(problems) > 0 {
+		t.Errorf("got problems %v, %v", problems, err)
+	}
+}
+
+// TestKeyedManifest checks that a snapshot rewritten along with its
+// manifest entry is only missed without the key.
+func TestKeyedManifest(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(t.TempDir(), "history.key")
+	if key, err := LoadKey(keyPath, false); err != nil || key != nil {
+		t.Errorf("got %x, %v for a missing key, want none", key, err)
+	}
+	key, err := LoadKey(keyPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := LoadKey(keyPath, false); err != nil || !bytes.Equal(again, key) {
+		t.Errorf("got %x, %v reading the key back, want %x", again, err, key)
+	}
+	store := OpenKeyed(dir, key)
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range 3 {
+		path, err := store.Save(Snapshot{Time: start.AddDate(0, 0, i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	// Rewrite the middle snapshot and its manifest entry, chaining the
+	// manifest anew as anyone without the key can.
+	data, _ := json.Marshal(Snapshot{Time: start.AddDate(0, 0, 1)})
+	if err := os.WriteFile(paths[1], data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	unkeyed := Open(dir)
+	entries, _ := unkeyed.readManifest()
+	entries[1].SHA256 = hashBytes(data)
+	unkeyed.rechain(entries)
+	if err := unkeyed.writeManifest(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if problems, _ := unkeyed.Verify(); len(problems) > 0 {
+		t.Errorf("without the key: got %v, want the forgery to pass", problems)
+	}
+	problems, err := store.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 3 {
+		t.Errorf("with the key: got %v, want every entry's chain broken", problems)
+	}
+}
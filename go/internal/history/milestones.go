@@ -0,0 +1,54 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// milestonesFile records when each net worth milestone was first crossed.
+const milestonesFile = "milestones.json"
+
+// Milestone is a net worth amount and the time of the first snapshot at or
+// above it.
+type Milestone struct {
+	Amount  float64   `json:"amount"`
+	Reached time.Time `json:"reached"`
+}
+
+func (s *Store) milestonesPath() string {
+	return filepath.Join(s.dir, milestonesFile)
+}
+
+// Milestones returns the milestones recorded in the store, smallest first.
+// ok is false if none were ever recorded.
+func (s *Store) Milestones() (milestones []Milestone, ok bool, err error) {
+	raw, err := os.ReadFile(s.milestonesPath())
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(raw, &milestones); err != nil {
+		return nil, false, fmt.Errorf("decode %s: %w", s.milestonesPath(), err)
+	}
+	return milestones, true, nil
+}
+
+// SaveMilestones replaces the recorded milestones.
+func (s *Store) SaveMilestones(milestones []Milestone) error {
+	milestones = append([]Milestone{}, milestones...)
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i].Amount < milestones[j].Amount })
+	data, err := json.MarshalIndent(milestones, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.milestonesPath(), data, 0600)
+}
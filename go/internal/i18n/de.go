@@ -0,0 +1,35 @@
+package i18n
+
+var de = map[string]string{
+	"Error:":    "Fehler:",
+	"Warning:":  "Warnung:",
+	"Reminder:": "Erinnerung:",
+
+	"Logged in.":                         "Angemeldet.",
+	"Not logged in.":                     "Nicht angemeldet.",
+	"Revoked token.":                     "Token widerrufen.",
+	"Logged out.":                        "Abgemeldet.",
+	"Using saved session.":               "Gespeicherte Sitzung wird verwendet.",
+	"Session expired; logging in again.": "Sitzung abgelaufen; erneute Anmeldung.",
+	"No credentials in %s or the environment; enter them to log in.\n": "Keine Zugangsdaten in %s oder der Umgebung; zum Anmelden bitte eingeben.\n",
+	"Monarch sent a one-time code to your email address.":              "Monarch hat einen Einmalcode an Ihre E-Mail-Adresse gesendet.",
+	"Multi-factor authentication required.":                            "Multi-Faktor-Authentifizierung erforderlich.",
+	"Logged in with a backup code; it can't be used again.":            "Mit einem Backup-Code angemeldet; er kann nicht erneut verwendet werden.",
+	"Moved session from %s to %s\n":                                    "Sitzung von %s nach %s verschoben\n",
+
+	"Logged in as %s (%s)\n":           "Angemeldet als %s (%s)\n",
+	"Household:    %s\n":               "Haushalt:     %s\n",
+	"Subscription: %s\n":               "Abonnement:   %s\n",
+	"MFA enabled:  %t\n":               "MFA aktiv:    %t\n",
+	"Token:        valid":              "Token:        gültig",
+	"API quota:    %d requests left\n": "API-Limit:    noch %d Anfragen\n",
+	"Quota resets: %s\n":               "Rücksetzung:  %s\n",
+
+	"Saved portfolio to %s\n":                                 "Portfolio in %s gespeichert\n",
+	"Recorded snapshot %s\n":                                  "Snapshot %s aufgezeichnet\n",
+	"Sync complete!":                                          "Synchronisierung abgeschlossen!",
+	"Saved %d holdings to %s\n":                               "%d Positionen in %s gespeichert\n",
+	"\n=== Step 1: Fetching portfolio from Monarch Money ===": "\n=== Schritt 1: Portfolio von Monarch Money abrufen ===",
+	"\n=== Step 2: Parsing portfolio to CSV ===":              "\n=== Schritt 2: Portfolio in CSV umwandeln ===",
+	"\n=== Pipeline completed successfully ===":               "\n=== Pipeline erfolgreich abgeschlossen ===",
+}
@@ -0,0 +1,35 @@
+package i18n
+
+var es = map[string]string{
+	"Error:":    "Error:",
+	"Warning:":  "Advertencia:",
+	"Reminder:": "Recordatorio:",
+
+	"Logged in.":                         "Sesión iniciada.",
+	"Not logged in.":                     "No hay sesión iniciada.",
+	"Revoked token.":                     "Token revocado.",
+	"Logged out.":                        "Sesión cerrada.",
+	"Using saved session.":               "Usando la sesión guardada.",
+	"Session expired; logging in again.": "La sesión expiró; iniciando sesión de nuevo.",
+	"No credentials in %s or the environment; enter them to log in.\n": "No hay credenciales en %s ni en el entorno; introdúcelas para iniciar sesión.\n",
+	"Monarch sent a one-time code to your email address.":              "Monarch envió un código de un solo uso a tu correo electrónico.",
+	"Multi-factor authentication required.":                            "Se requiere autenticación multifactor.",
+	"Logged in with a backup code; it can't be used again.":            "Sesión iniciada con un código de respaldo; no se puede volver a usar.",
+	"Moved session from %s to %s\n":                                    "Sesión movida de %s a %s\n",
+
+	"Logged in as %s (%s)\n":           "Sesión iniciada como %s (%s)\n",
+	"Household:    %s\n":               "Hogar:        %s\n",
+	"Subscription: %s\n":               "Suscripción:  %s\n",
+	"MFA enabled:  %t\n":               "MFA activa:   %t\n",
+	"Token:        valid":              "Token:        válido",
+	"API quota:    %d requests left\n": "Cuota API:    quedan %d solicitudes\n",
+	"Quota resets: %s\n":               "Se reinicia:  %s\n",
+
+	"Saved portfolio to %s\n":                                 "Cartera guardada en %s\n",
+	"Recorded snapshot %s\n":                                  "Instantánea %s registrada\n",
+	"Sync complete!":                                          "¡Sincronización completa!",
+	"Saved %d holdings to %s\n":                               "%d posiciones guardadas en %s\n",
+	"\n=== Step 1: Fetching portfolio from Monarch Money ===": "\n=== Paso 1: Obteniendo la cartera de Monarch Money ===",
+	"\n=== Step 2: Parsing portfolio to CSV ===":              "\n=== Paso 2: Convirtiendo la cartera a CSV ===",
+	"\n=== Pipeline completed successfully ===":               "\n=== Proceso completado correctamente ===",
+}
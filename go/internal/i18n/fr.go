@@ -0,0 +1,35 @@
+package i18n
+
+var fr = map[string]string{
+	"Error:":    "Erreur :",
+	"Warning:":  "Avertissement :",
+	"Reminder:": "Rappel :",
+
+	"Logged in.":                         "Connecté.",
+	"Not logged in.":                     "Non connecté.",
+	"Revoked token.":                     "Jeton révoqué.",
+	"Logged out.":                        "Déconnecté.",
+	"Using saved session.":               "Utilisation de la session enregistrée.",
+	"Session expired; logging in again.": "Session expirée ; nouvelle connexion.",
+	"No credentials in %s or the environment; enter them to log in.\n": "Aucun identifiant dans %s ni dans l'environnement ; saisissez-les pour vous connecter.\n",
+	"Monarch sent a one-time code to your email address.":              "Monarch a envoyé un code à usage unique à votre adresse e-mail.",
+	"Multi-factor authentication required.":                            "Authentification multifacteur requise.",
+	"Logged in with a backup code; it can't be used again.":            "Connecté avec un code de secours ; il ne peut plus être utilisé.",
+	"Moved session from %s to %s\n":                                    "Session déplacée de %s vers %s\n",
+
+	"Logged in as %s (%s)\n":           "Connecté en tant que %s (%s)\n",
+	"Household:    %s\n":               "Foyer :       %s\n",
+	"Subscription: %s\n":               "Abonnement :  %s\n",
+	"MFA enabled:  %t\n":               "MFA activée : %t\n",
+	"Token:        valid":              "Jeton :       valide",
+	"API quota:    %d requests left\n": "Quota API :   %d requêtes restantes\n",
+	"Quota resets: %s\n":               "Remise à 0 :  %s\n",
+
+	"Saved portfolio to %s\n":                                 "Portefeuille enregistré dans %s\n",
+	"Recorded snapshot %s\n":                                  "Instantané %s enregistré\n",
+	"Sync complete!":                                          "Synchronisation terminée !",
+	"Saved %d holdings to %s\n":                               "%d positions enregistrées dans %s\n",
+	"\n=== Step 1: Fetching portfolio from Monarch Money ===": "\n=== Étape 1 : récupération du portefeuille depuis Monarch Money ===",
+	"\n=== Step 2: Parsing portfolio to CSV ===":              "\n=== Étape 2 : conversion du portefeuille en CSV ===",
+	"\n=== Pipeline completed successfully ===":               "\n=== Pipeline terminé avec succès ===",
+}
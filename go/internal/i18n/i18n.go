@@ -0,0 +1,84 @@
+// Package i18n translates the CLI's messages. Messages are looked up by
+// their English text, as with gettext, so a message missing from a catalog
+// is shown in English.
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// catalogs maps a language code to its translations.
+var catalogs = map[string]map[string]string{
+	"de": de,
+	"es": es,
+	"fr": fr,
+}
+
+// Languages lists the codes of the available translations, besides
+// English.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Printer formats messages in one language. The zero Printer prints
+// English.
+type Printer struct {
+	catalog map[string]string
+}
+
+// New returns the printer for a language such as "de" or a locale such as
+// "de_DE.UTF-8". Unknown languages, and "" or "en", give English.
+func New(lang string) Printer {
+	return Printer{catalog: catalogs[languageOf(lang)]}
+}
+
+// Detect returns the language of the environment's locale, from LC_ALL,
+// LC_MESSAGES or LANG as in POSIX.
+func Detect() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return languageOf(v)
+		}
+	}
+	return ""
+}
+
+// languageOf reduces a locale such as "fr_CA.UTF-8" to its language.
+func languageOf(locale string) string {
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang, _, _ = strings.Cut(lang, "-")
+	return strings.ToLower(lang)
+}
+
+// Text translates msg.
+func (p Printer) Text(msg string) string {
+	if t, ok := p.catalog[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Sprintf translates format and formats it with args.
+func (p Printer) Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(p.Text(format), args...)
+}
+
+// Fprintf translates format and writes it formatted with args to w.
+func (p Printer) Fprintf(w io.Writer, format string, args ...any) {
+	fmt.Fprintf(w, p.Text(format), args...)
+}
+
+// Fprintln translates msg and writes it to w followed by a newline.
+func (p Printer) Fprintln(w io.Writer, msg string) {
+	fmt.Fprintln(w, p.Text(msg))
+}
@@ -0,0 +1,190 @@
+// Package layout reshapes CSV exports: a Layout picks which fields become
+// columns, in what order and under which headers. Presets match the import
+// formats of other tools.
+package layout
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Column is one output column: the header to write and the field it shows.
+type Column struct {
+	Header string
+	Field  string
+}
+
+// Layout is an ordered list of columns.
+type Layout []Column
+
+// Parse reads a column mapping such as "Date=date,Payee=merchant,amount".
+// A bare field name uses itself as the header.
+func Parse(spec string) (Layout, error) {
+	var l Layout
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		header, field, ok := strings.Cut(part, "=")
+		if !ok {
+			field = header
+		}
+		l = append(l, Column{Header: strings.TrimSpace(header), Field: strings.TrimSpace(field)})
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("empty column mapping")
+	}
+	return l, nil
+}
+
+func (l Layout) headers() []string {
+	h := make([]string, len(l))
+	for i, c := range l {
+		h[i] = c.Header
+	}
+	return h
+}
+
+// Validate reports an error if l uses a field unknown for kind
+// ("transactions" or "holdings").
+func (l Layout) Validate(kind string) error {
+	if kind == "holdings" {
+		return l.check(fieldSet(holdingFields), "holding")
+	}
+	return l.check(fieldSet(transactionFields), "transaction")
+}
+
+func (l Layout) check(fields map[string]bool, kind string) error {
+	for _, c := range l {
+		if !fields[c.Field] {
+			return fmt.Errorf("unknown %s field %q (known: %s)", kind, c.Field, strings.Join(sortedKeys(fields), ", "))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// transactionFields extracts each named field from a transaction.
+var transactionFields = map[string]func(transactions.Transaction) string{
+	"id":       func(t transactions.Transaction) string { return t.ID },
+	"date":     func(t transactions.Transaction) string { return t.Date },
+	"amount":   func(t transactions.Transaction) string { return strconv.FormatFloat(t.Amount, 'f', 2, 64) },
+	"outflow":  func(t transactions.Transaction) string { return strconv.FormatFloat(-t.Amount, 'f', 2, 64) },
+	"merchant": func(t transactions.Transaction) string { return t.Merchant.Name },
+	"category": func(t transactions.Transaction) string { return t.Category.Name },
+	"category_group": func(t transactions.Transaction) string {
+		return t.Category.Group.Name
+	},
+	"account":    func(t transactions.Transaction) string { return t.Account.DisplayName },
+	"account_id": func(t transactions.Transaction) string { return t.Account.ID },
+	"notes":      func(t transactions.Transaction) string { return t.Notes },
+	"pending":    func(t transactions.Transaction) string { return strconv.FormatBool(t.Pending) },
+	"tags": func(t transactions.Transaction) string {
+		names := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			names[i] = tag.Name
+		}
+		return strings.Join(names, ",")
+	},
+	// month and week are the first day of the transaction's month and
+	// (Sunday-based) week, as Tiller uses for grouping.
+	"month": func(t transactions.Transaction) string {
+		d := t.Time()
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+	},
+	"week": func(t transactions.Transaction) string {
+		d := t.Time()
+		return d.AddDate(0, 0, -int(d.Weekday())).Format(time.DateOnly)
+	},
+	"empty": func(transactions.Transaction) string { return "" },
+}
+
+// holdingFields extracts each named field from a holding record; the names
+// match the columns of the default holdings CSV.
+var holdingFields = map[string]func(portfolio.HoldingRecord) string{
+	"account_id":       func(r portfolio.HoldingRecord) string { return r.AccountID },
+	"account_name":     func(r portfolio.HoldingRecord) string { return r.AccountName },
+	"account_mask":     func(r portfolio.HoldingRecord) string { return r.AccountMask },
+	"institution_name": func(r portfolio.HoldingRecord) string { return r.InstitutionName },
+	"holding_name":     func(r portfolio.HoldingRecord) string { return r.HoldingName },
+	"ticker":           func(r portfolio.HoldingRecord) string { return r.Ticker },
+	"type":             func(r portfolio.HoldingRecord) string { return r.Type },
+	"type_display":     func(r portfolio.HoldingRecord) string { return r.TypeDisplay },
+	"quantity":         func(r portfolio.HoldingRecord) string { return formatFloat(r.Quantity) },
+	"closing_price":    func(r portfolio.HoldingRecord) string { return formatFloat(r.ClosingPrice) },
+	"value":            func(r portfolio.HoldingRecord) string { return formatFloat(r.Value) },
+	"security_id":      func(r portfolio.HoldingRecord) string { return r.SecurityID },
+	"security_name":    func(r portfolio.HoldingRecord) string { return r.SecurityName },
+	"security_ticker":  func(r portfolio.HoldingRecord) string { return r.SecurityTicker },
+	"current_price":    func(r portfolio.HoldingRecord) string { return formatFloat(r.CurrentPrice) },
+	"price_updated":    func(r portfolio.HoldingRecord) string { return r.PriceUpdated },
+	"pct_portfolio":    func(r portfolio.HoldingRecord) string { return formatFloat(r.PctPortfolio) },
+	"pct_account":      func(r portfolio.HoldingRecord) string { return formatFloat(r.PctAccount) },
+	"record_id":        func(r portfolio.HoldingRecord) string { return r.RecordID() },
+	"empty":            func(portfolio.HoldingRecord) string { return "" },
+}
+
+func fieldSet[T any](m map[string]func(T) string) map[string]bool {
+	s := make(map[string]bool, len(m))
+	for k := range m {
+		s[k] = true
+	}
+	return s
+}
+
+// WriteTransactions writes txns as CSV with the columns of l.
+func WriteTransactions(w io.Writer, l Layout, txns []transactions.Transaction) error {
+	if err := l.check(fieldSet(transactionFields), "transaction"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(l.headers())
+	for _, t := range txns {
+		row := make([]string, len(l))
+		for i, c := range l {
+			row[i] = transactionFields[c.Field](t)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHoldings writes records as CSV with the columns of l.
+func WriteHoldings(w io.Writer, l Layout, records []portfolio.HoldingRecord) error {
+	if err := l.check(fieldSet(holdingFields), "holding"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(l.headers())
+	for _, r := range records {
+		row := make([]string, len(l))
+		for i, c := range l {
+			row[i] = holdingFields[c.Field](r)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset is a named pair of layouts. A nil layout means the target tool has
+// no import for that kind of data.
+type Preset struct {
+	Transactions Layout
+	Holdings     Layout
+}
+
+// DefaultTransactions is used for transaction CSVs when no preset or
+// column mapping is given.
+var DefaultTransactions = Layout{
+	{"date", "date"},
+	{"merchant", "merchant"},
+	{"category", "category"},
+	{"amount", "amount"},
+	{"account", "account"},
+	{"notes", "notes"},
+	{"tags", "tags"},
+	{"id", "id"},
+}
+
+// Presets are the built-in layouts, selected with -preset.
+var Presets = map[string]Preset{
+	// Tiller's Transactions sheet column order.
+	"tiller": {
+		Transactions: Layout{
+			{"Date", "date"},
+			{"Description", "merchant"},
+			{"Category", "category"},
+			{"Amount", "amount"},
+			{"Account", "account"},
+			{"Account #", "empty"},
+			{"Institution", "empty"},
+			{"Month", "month"},
+			{"Week", "week"},
+			{"Transaction ID", "id"},
+			{"Account ID", "account_id"},
+			{"Check Number", "empty"},
+			{"Full Description", "merchant"},
+			{"Note", "notes"},
+			{"Tags", "tags"},
+		},
+	},
+	// Lunch Money's CSV import, which treats positive amounts as expenses.
+	"lunchmoney": {
+		Transactions: Layout{
+			{"Date", "date"},
+			{"Payee", "merchant"},
+			{"Amount", "outflow"},
+			{"Category", "category"},
+			{"Notes", "notes"},
+			{"Tags", "tags"},
+		},
+	},
+}
+
+// Select returns the layout for kind ("transactions" or "holdings") from
+// either a preset name or a column mapping. Both empty yields nil, meaning
+// the default format.
+func Select(preset, columns, kind string) (Layout, error) {
+	if preset != "" && columns != "" {
+		return nil, fmt.Errorf("use either a preset or a column mapping, not both")
+	}
+	if columns != "" {
+		return Parse(columns)
+	}
+	if preset == "" {
+		return nil, nil
+	}
+	p, ok := Presets[preset]
+	if !ok {
+		names := make([]string, 0, len(Presets))
+		for name := range Presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown preset %q (known: %s)", preset, strings.Join(names, ", "))
+	}
+	l := p.Transactions
+	if kind == "holdings" {
+		l = p.Holdings
+	}
+	if l == nil {
+		return nil, fmt.Errorf("preset %q has no %s layout", preset, kind)
+	}
+	return l, nil
+}
@@ -0,0 +1,107 @@
+// Package notes keeps local notes about accounts that Monarch has no place
+// for: free-form notes, when an account was last reviewed and is next due
+// for review, and when a CD or promotional rate expires.
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// File is the name of the notes file in a profile's data directory.
+const File = "accounts.json"
+
+// Note is what is kept about one account. Dates are YYYY-MM-DD.
+type Note struct {
+	// Name is the account's name when the note was last changed, to
+	// make the file readable; notes are keyed by account ID.
+	Name     string `json:"name,omitempty"`
+	Text     string `json:"notes,omitempty"`
+	Reviewed string `json:"reviewed,omitempty"`
+	ReviewBy string `json:"reviewBy,omitempty"`
+	// Rate describes the rate that expires, e.g. "5.1% promo APY".
+	Rate        string `json:"rate,omitempty"`
+	RateExpires string `json:"rateExpires,omitempty"`
+}
+
+// Book holds the notes by account ID.
+type Book map[string]Note
+
+// Load reads the notes at path. A missing file is an empty book.
+func Load(path string) (Book, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Book{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Book
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if b == nil {
+		b = Book{}
+	}
+	return b, nil
+}
+
+// Save writes b to path, creating its directory if needed.
+func Save(b Book, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Reminder is a review or rate expiration that has come due.
+type Reminder struct {
+	AccountID string
+	Account   string
+	// What is "review" or "rate expires".
+	What string
+	// Detail is the rate for expirations.
+	Detail string
+	Due    time.Time
+}
+
+// Due returns the reminders falling due by the end of the day within days
+// of now, including overdue ones, in order of their dates. Dates that
+// don't parse are reported as errors.
+func (b Book) Due(now time.Time, within int) ([]Reminder, error) {
+	y, m, d := now.Date()
+	cutoff := time.Date(y, m, d+within, 0, 0, 0, 0, now.Location())
+	var due []Reminder
+	for id, n := range b {
+		for _, r := range []struct{ what, date, detail string }{
+			{"review", n.ReviewBy, ""},
+			{"rate expires", n.RateExpires, n.Rate},
+		} {
+			if r.date == "" {
+				continue
+			}
+			t, err := time.ParseInLocation(time.DateOnly, r.date, now.Location())
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %s date %q: want YYYY-MM-DD", id, r.what, r.date)
+			}
+			if !t.After(cutoff) {
+				due = append(due, Reminder{AccountID: id, Account: n.Name, What: r.what, Detail: r.detail, Due: t})
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Due.Equal(due[j].Due) {
+			return due[i].Due.Before(due[j].Due)
+		}
+		return due[i].AccountID < due[j].AccountID
+	})
+	return due, nil
+}
@@ -0,0 +1,219 @@
+// Package notify sends short text notifications to services addressed by
+// Apprise-style URLs, such as "pover://user@token" or "tgram://bot/chat".
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notifier delivers a notification.
+type Notifier interface {
+	Notify(title, body string) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Parse returns the Notifier for an Apprise-style URL. Supported schemes:
+//
+//	json://host/path, jsons://...       JSON POST in Apprise's format
+//	apprise://host/key, apprises://...  an Apprise API server
+//	pover://user@token                  Pushover
+//	tgram://bottoken/chat[/chat...]     Telegram
+//	discord://webhook_id/webhook_token  Discord webhook
+//	slack://tokenA/tokenB/tokenC        Slack incoming webhook
+//	ntfy://[host/]topic, ntfys://...    ntfy (ntfy.sh when host is omitted)
+//	gotify://host/token, gotifys://...  Gotify
+func Parse(raw string) (Notifier, error) {
+	// Telegram bot tokens contain a colon, which url.Parse would take for
+	// a port separator.
+	if rest, ok := strings.CutPrefix(raw, "tgram://"); ok {
+		parts := splitPath(rest)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("tgram://…: expected tgram://bottoken/chat_id")
+		}
+		return telegram{token: parts[0], chats: parts[1:]}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification URL %q", strings.SplitN(raw, "://", 2)[0]+"://…")
+	}
+	path := splitPath(u.Path)
+	switch u.Scheme {
+	case "json", "jsons":
+		return jsonPost{url: httpURL(u, "jsons") + u.Path}, nil
+	case "apprise", "apprises":
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%s: missing config key", redact(u))
+		}
+		return jsonPost{url: httpURL(u, "apprises") + "/notify/" + strings.Join(path, "/")}, nil
+	case "pover":
+		if u.User == nil || u.Host == "" {
+			return nil, fmt.Errorf("%s: expected pover://user@token", redact(u))
+		}
+		return pushover{user: u.User.Username(), token: u.Host}, nil
+	case "discord":
+		if u.Host == "" || len(path) != 1 {
+			return nil, fmt.Errorf("%s: expected discord://webhook_id/webhook_token", redact(u))
+		}
+		return webhookText{url: "https://discord.com/api/webhooks/" + u.Host + "/" + path[0], field: "content", bold: "**"}, nil
+	case "slack":
+		if u.Host == "" || len(path) != 2 {
+			return nil, fmt.Errorf("%s: expected slack://tokenA/tokenB/tokenC", redact(u))
+		}
+		return webhookText{url: "https://hooks.slack.com/services/" + u.Host + "/" + strings.Join(path, "/"), field: "text", bold: "*"}, nil
+	case "ntfy", "ntfys":
+		n := ntfy{user: u.User}
+		switch len(path) {
+		case 0:
+			n.url = "https://ntfy.sh/" + u.Host
+		case 1:
+			n.url = httpURL(u, "ntfys") + "/" + path[0]
+		default:
+			return nil, fmt.Errorf("%s: expected ntfy://[host/]topic", redact(u))
+		}
+		return n, nil
+	case "gotify", "gotifys":
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%s: expected gotify://host/token", redact(u))
+		}
+		token := path[len(path)-1]
+		prefix := strings.Join(path[:len(path)-1], "/")
+		if prefix != "" {
+			prefix = "/" + prefix
+		}
+		return gotify{url: httpURL(u, "gotifys") + prefix + "/message?token=" + url.QueryEscape(token)}, nil
+	}
+	return nil, fmt.Errorf("unsupported notification scheme %q", u.Scheme)
+}
+
+func splitPath(p string) []string {
+	var parts []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return parts
+}
+
+// httpURL maps a notification URL's host to http or https; the secure
+// variant of each scheme selects https.
+func httpURL(u *url.URL, secureScheme string) string {
+	scheme := "http"
+	if u.Scheme == secureScheme {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
+
+// redact hides the tokens that notification URLs carry in error messages.
+func redact(u *url.URL) string {
+	return u.Scheme + "://…"
+}
+
+// post sends a request and checks for a 2xx response.
+func post(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify %s: HTTP %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+func postJSON(url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return post(req)
+}
+
+// jsonPost sends Apprise's JSON notification payload.
+type jsonPost struct{ url string }
+
+func (n jsonPost) Notify(title, body string) error {
+	return postJSON(n.url, map[string]string{
+		"version": "1.0",
+		"title":   title,
+		"body":    body,
+		"type":    "info",
+	})
+}
+
+type pushover struct{ user, token string }
+
+func (n pushover) Notify(title, body string) error {
+	form := url.Values{"token": {n.token}, "user": {n.user}, "title": {title}, "message": {body}}
+	req, err := http.NewRequest(http.MethodPost, "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return post(req)
+}
+
+type telegram struct {
+	token string
+	chats []string
+}
+
+func (n telegram) Notify(title, body string) error {
+	for _, chat := range n.chats {
+		err := postJSON("https://api.telegram.org/bot"+n.token+"/sendMessage", map[string]string{
+			"chat_id": chat,
+			"text":    title + "\n" + body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookText posts the message as a single text field, as Discord and
+// Slack webhooks expect, with the title in the service's bold markup.
+type webhookText struct{ url, field, bold string }
+
+func (n webhookText) Notify(title, body string) error {
+	return postJSON(n.url, map[string]string{n.field: n.bold + title + n.bold + "\n" + body})
+}
+
+type ntfy struct {
+	url  string
+	user *url.Userinfo
+}
+
+func (n ntfy) Notify(title, body string) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	if n.user != nil {
+		pass, _ := n.user.Password()
+		req.SetBasicAuth(n.user.Username(), pass)
+	}
+	return post(req)
+}
+
+type gotify struct{ url string }
+
+func (n gotify) Notify(title, body string) error {
+	return postJSON(n.url, map[string]string{"title": title, "message": body})
+}
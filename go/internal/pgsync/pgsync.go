@@ -0,0 +1,342 @@
+// Package pgsync replicates accounts, holdings, transactions and snapshots
+// into a PostgreSQL database, in a normalized schema it creates and
+// migrates itself.
+//
+// Rows are upserted by their Monarch IDs, so syncing again updates them in
+// place. Account IDs aren't foreign keys, as snapshots and transactions
+// can name accounts that have since been closed.
+package pgsync
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	// The PostgreSQL driver.
+	_ "github.com/lib/pq"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Driver is the database/sql driver name of PostgreSQL.
+const Driver = "postgres"
+
+// migrations are the schema changes in order; migration i brings the
+// schema to version i+1. Append new ones; never change applied ones.
+var migrations = []string{
+	`CREATE TABLE accounts (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	mask text NOT NULL,
+	institution text NOT NULL,
+	type text NOT NULL,
+	subtype text NOT NULL,
+	is_asset boolean NOT NULL,
+	include_in_net_worth boolean NOT NULL,
+	balance numeric(20,2) NOT NULL,
+	last_updated timestamptz,
+	synced_at timestamptz NOT NULL
+);
+CREATE TABLE securities (
+	id text PRIMARY KEY,
+	ticker text NOT NULL,
+	name text NOT NULL,
+	type text NOT NULL
+);
+CREATE TABLE holdings (
+	id text PRIMARY KEY,
+	account_id text NOT NULL,
+	security_id text NOT NULL REFERENCES securities (id),
+	name text NOT NULL,
+	quantity numeric NOT NULL,
+	price numeric NOT NULL,
+	value numeric(20,2) NOT NULL,
+	synced_at timestamptz NOT NULL
+);
+CREATE INDEX holdings_account_id ON holdings (account_id);
+CREATE TABLE categories (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	group_name text NOT NULL,
+	group_type text NOT NULL
+);
+CREATE TABLE tags (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	color text NOT NULL
+);
+CREATE TABLE transactions (
+	id text PRIMARY KEY,
+	date date NOT NULL,
+	amount numeric(20,2) NOT NULL,
+	account_id text NOT NULL,
+	category_id text REFERENCES categories (id),
+	merchant text NOT NULL,
+	notes text NOT NULL,
+	pending boolean NOT NULL,
+	hide_from_reports boolean NOT NULL,
+	needs_review boolean NOT NULL,
+	synced_at timestamptz NOT NULL
+);
+CREATE INDEX transactions_date ON transactions (date);
+CREATE INDEX transactions_account_id ON transactions (account_id);
+CREATE TABLE transaction_tags (
+	transaction_id text NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+	tag_id text NOT NULL REFERENCES tags (id),
+	PRIMARY KEY (transaction_id, tag_id)
+);
+CREATE TABLE snapshots (
+	time timestamptz PRIMARY KEY,
+	net_worth numeric(20,2) NOT NULL
+);
+CREATE TABLE snapshot_accounts (
+	snapshot_time timestamptz NOT NULL REFERENCES snapshots (time) ON DELETE CASCADE,
+	account_id text NOT NULL,
+	name text NOT NULL,
+	balance numeric(20,2) NOT NULL,
+	PRIMARY KEY (snapshot_time, account_id)
+);
+CREATE TABLE snapshot_holdings (
+	snapshot_time timestamptz NOT NULL REFERENCES snapshots (time) ON DELETE CASCADE,
+	holding_id text NOT NULL,
+	account_id text NOT NULL,
+	security_id text NOT NULL REFERENCES securities (id),
+	quantity numeric NOT NULL,
+	price numeric NOT NULL,
+	value numeric(20,2) NOT NULL,
+	PRIMARY KEY (snapshot_time, holding_id)
+)`,
+}
+
+// Migrate applies the migrations the database lacks, each in its own
+// transaction, and returns the schema versions before and after.
+func Migrate(ctx context.Context, db *sql.DB) (from, to int, err error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS monarch_schema_migrations (
+	version integer PRIMARY KEY,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`); err != nil {
+		return 0, 0, fmt.Errorf("create migrations table: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, `SELECT coalesce(max(version), 0) FROM monarch_schema_migrations`).Scan(&from); err != nil {
+		return 0, 0, fmt.Errorf("read schema version: %w", err)
+	}
+	if from > len(migrations) {
+		return from, from, fmt.Errorf("schema version %d is newer than this monarch's %d; upgrade monarch", from, len(migrations))
+	}
+	for v := from + 1; v <= len(migrations); v++ {
+		err := inTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[v-1]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO monarch_schema_migrations (version) VALUES ($1)`, v)
+			return err
+		})
+		if err != nil {
+			return from, v - 1, fmt.Errorf("migrate to schema version %d: %w", v, err)
+		}
+	}
+	return from, len(migrations), nil
+}
+
+// Data is what Sync writes.
+type Data struct {
+	Accounts []portfolio.AccountRecord
+	// Holdings are the current holdings; others are deleted.
+	Holdings     []portfolio.HoldingRecord
+	Transactions []transactions.Transaction
+	Snapshots    []history.Snapshot
+	// Time is recorded as the rows' synced_at.
+	Time time.Time
+}
+
+// Stats counts the rows Sync wrote, by table.
+type Stats map[string]int
+
+// Sync upserts d in a single transaction. Snapshots already in the
+// database are left as they are.
+func Sync(ctx context.Context, db *sql.DB, d Data) (Stats, error) {
+	stats := Stats{}
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		exec := func(table, query string, args ...any) error {
+			res, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				stats[table] += int(n)
+			}
+			return nil
+		}
+
+		for _, a := range d.Accounts {
+			if err := exec("accounts", `INSERT INTO accounts
+	(id, name, mask, institution, type, subtype, is_asset, include_in_net_worth, balance, last_updated, synced_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, mask = excluded.mask, institution = excluded.institution,
+	type = excluded.type, subtype = excluded.subtype, is_asset = excluded.is_asset,
+	include_in_net_worth = excluded.include_in_net_worth, balance = excluded.balance,
+	last_updated = excluded.last_updated, synced_at = excluded.synced_at`,
+				a.ID, a.Name, a.Mask, a.InstitutionName, a.Type, a.Subtype, a.IsAsset, a.IncludeInNetWorth,
+				a.Balance, timestamp(a.LastUpdated), d.Time); err != nil {
+				return err
+			}
+		}
+
+		// Securities of current and past holdings, the latest first.
+		seen := map[string]bool{}
+		holdings := [][]portfolio.HoldingRecord{d.Holdings}
+		for i := len(d.Snapshots) - 1; i >= 0; i-- {
+			holdings = append(holdings, d.Snapshots[i].Holdings)
+		}
+		for _, hs := range holdings {
+			for _, h := range hs {
+				id := securityID(h)
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if err := exec("securities", `INSERT INTO securities (id, ticker, name, type) VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET ticker = excluded.ticker, name = excluded.name, type = excluded.type`,
+					id, cmp.Or(h.SecurityTicker, h.Ticker), cmp.Or(h.SecurityName, h.HoldingName), h.Type); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, h := range d.Holdings {
+			if err := exec("holdings", `INSERT INTO holdings (id, account_id, security_id, name, quantity, price, value, synced_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (id) DO UPDATE SET account_id = excluded.account_id, security_id = excluded.security_id,
+	name = excluded.name, quantity = excluded.quantity, price = excluded.price, value = excluded.value,
+	synced_at = excluded.synced_at`,
+				h.RecordID(), h.AccountID, securityID(h), h.HoldingName, h.Quantity, h.ClosingPrice, h.Value, d.Time); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM holdings WHERE synced_at < $1`, d.Time); err != nil {
+			return fmt.Errorf("holdings: %w", err)
+		}
+
+		categories, tags := map[string]bool{}, map[string]bool{}
+		for _, t := range d.Transactions {
+			if c := t.Category; c.ID != "" && !categories[c.ID] {
+				categories[c.ID] = true
+				if err := exec("categories", `INSERT INTO categories (id, name, group_name, group_type) VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, group_name = excluded.group_name, group_type = excluded.group_type`,
+					c.ID, c.Name, c.Group.Name, c.Group.Type); err != nil {
+					return err
+				}
+			}
+			for _, tag := range t.Tags {
+				if tags[tag.ID] {
+					continue
+				}
+				tags[tag.ID] = true
+				if err := exec("tags", `INSERT INTO tags (id, name, color) VALUES ($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color`,
+					tag.ID, tag.Name, tag.Color); err != nil {
+					return err
+				}
+			}
+		}
+		for _, t := range d.Transactions {
+			var category any
+			if t.Category.ID != "" {
+				category = t.Category.ID
+			}
+			if err := exec("transactions", `INSERT INTO transactions
+	(id, date, amount, account_id, category_id, merchant, notes, pending, hide_from_reports, needs_review, synced_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET date = excluded.date, amount = excluded.amount, account_id = excluded.account_id,
+	category_id = excluded.category_id, merchant = excluded.merchant, notes = excluded.notes,
+	pending = excluded.pending, hide_from_reports = excluded.hide_from_reports,
+	needs_review = excluded.needs_review, synced_at = excluded.synced_at`,
+				t.ID, t.Date, t.Amount, t.Account.ID, category, t.Merchant.Name, t.Notes, t.Pending,
+				t.HideFromReports, t.NeedsReview, d.Time); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM transaction_tags WHERE transaction_id = $1`, t.ID); err != nil {
+				return fmt.Errorf("transaction_tags: %w", err)
+			}
+			for _, tag := range t.Tags {
+				if err := exec("transaction_tags", `INSERT INTO transaction_tags (transaction_id, tag_id) VALUES ($1, $2)`,
+					t.ID, tag.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, snap := range d.Snapshots {
+			res, err := tx.ExecContext(ctx, `INSERT INTO snapshots (time, net_worth) VALUES ($1, $2) ON CONFLICT (time) DO NOTHING`,
+				snap.Time, snap.NetWorth(false))
+			if err != nil {
+				return fmt.Errorf("snapshots: %w", err)
+			}
+			if n, err := res.RowsAffected(); err == nil && n == 0 {
+				continue
+			}
+			stats["snapshots"]++
+			for _, a := range snap.Accounts {
+				if err := exec("snapshot_accounts", `INSERT INTO snapshot_accounts (snapshot_time, account_id, name, balance)
+VALUES ($1, $2, $3, $4)`, snap.Time, a.ID, a.Name, a.Balance); err != nil {
+					return err
+				}
+			}
+			// Imported snapshots can list a holding twice; keep the first.
+			held := map[string]bool{}
+			for _, h := range snap.Holdings {
+				id := h.RecordID()
+				if held[id] {
+					continue
+				}
+				held[id] = true
+				if err := exec("snapshot_holdings", `INSERT INTO snapshot_holdings
+	(snapshot_time, holding_id, account_id, security_id, quantity, price, value)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+					snap.Time, id, h.AccountID, securityID(h), h.Quantity, h.ClosingPrice, h.Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// securityID identifies the security of h, falling back to its ticker and
+// name for holdings without a Monarch security, such as manual ones.
+func securityID(h portfolio.HoldingRecord) string {
+	if h.SecurityID != "" {
+		return h.SecurityID
+	}
+	return "local-" + portfolio.StableID(h.Ticker, h.HoldingName)
+}
+
+// timestamp parses an RFC 3339 time, or returns nil for the database's
+// NULL.
+func timestamp(s string) any {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
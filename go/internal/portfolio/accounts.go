@@ -0,0 +1,87 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// --- Accounts JSON data structures ---
+
+type AccountsResponse struct {
+	Accounts []AccountNode `json:"accounts"`
+}
+
+type AccountNode struct {
+	ID                string      `json:"id"`
+	DisplayName       string      `json:"displayName"`
+	Mask              string      `json:"mask"`
+	IsAsset           bool        `json:"isAsset"`
+	IsHidden          bool        `json:"isHidden"`
+	IncludeInNetWorth bool        `json:"includeInNetWorth"`
+	CurrentBalance    float64     `json:"currentBalance"`
+	LastUpdatedAt     string      `json:"displayLastUpdatedAt"`
+	Type              TypeInfo    `json:"type"`
+	Subtype           TypeInfo    `json:"subtype"`
+	Institution       Institution `json:"institution"`
+}
+
+type TypeInfo struct {
+	Name    string `json:"name"`
+	Display string `json:"display"`
+}
+
+// AccountRecord is a flattened account with its balance at fetch time.
+type AccountRecord struct {
+	ID                string  `json:"id"`
+	Name              string  `json:"name"`
+	Mask              string  `json:"mask"`
+	InstitutionName   string  `json:"institution_name"`
+	Type              string  `json:"type"`
+	TypeDisplay       string  `json:"type_display"`
+	Subtype           string  `json:"subtype"`
+	SubtypeDisplay    string  `json:"subtype_display"`
+	IsAsset           bool    `json:"is_asset"`
+	IncludeInNetWorth bool    `json:"include_in_net_worth"`
+	Balance           float64 `json:"balance"`
+	LastUpdated       string  `json:"last_updated"`
+}
+
+// ParseAccounts decodes the data object of a GetAccounts GraphQL response.
+func ParseAccounts(data map[string]json.RawMessage) (*AccountsResponse, error) {
+	var resp AccountsResponse
+	if raw, ok := data["accounts"]; ok {
+		if err := json.Unmarshal(raw, &resp.Accounts); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
+
+// ExtractAccounts flattens an accounts response into records sorted by name,
+// then ID.
+func ExtractAccounts(resp *AccountsResponse) []AccountRecord {
+	records := make([]AccountRecord, 0, len(resp.Accounts))
+	for _, a := range resp.Accounts {
+		records = append(records, AccountRecord{
+			ID:                a.ID,
+			Name:              a.DisplayName,
+			Mask:              a.Mask,
+			InstitutionName:   a.Institution.Name,
+			Type:              a.Type.Name,
+			TypeDisplay:       a.Type.Display,
+			Subtype:           a.Subtype.Name,
+			SubtypeDisplay:    a.Subtype.Display,
+			IsAsset:           a.IsAsset,
+			IncludeInNetWorth: a.IncludeInNetWorth,
+			Balance:           a.CurrentBalance,
+			LastUpdated:       a.LastUpdatedAt,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
@@ -0,0 +1,53 @@
+package portfolio
+
+import "strings"
+
+// DefaultSweepTickers are common brokerage sweep and money-market funds
+// treated as cash when the config doesn't list its own.
+var DefaultSweepTickers = []string{
+	"SPAXX", "FDRXX", "FZFXX", "SPRXX", // Fidelity
+	"VMFXX", "VMRXX", "VUSXX", // Vanguard
+	"SWVXX", "SNVXX", "SNSXX", "SNOXX", // Schwab
+	"TTTXX", "JPMXX", // others
+	"CUR:USD",
+}
+
+// CashTickers is a set of tickers whose holdings count as cash.
+type CashTickers map[string]bool
+
+// NewCashTickers builds a CashTickers set; tickers are matched case-insensitively.
+func NewCashTickers(tickers []string) CashTickers {
+	c := make(CashTickers, len(tickers))
+	for _, t := range tickers {
+		c[strings.ToUpper(t)] = true
+	}
+	return c
+}
+
+// IsCash reports whether a holding is cash: either Monarch (or an override)
+// types it as cash, or its ticker is a configured sweep fund.
+func (c CashTickers) IsCash(r HoldingRecord) bool {
+	if r.Type == "cash" {
+		return true
+	}
+	return c[strings.ToUpper(r.Ticker)] || c[strings.ToUpper(r.SecurityTicker)]
+}
+
+// Asset classes returned by AssetClass.
+const (
+	ClassStocks = "stocks"
+	ClassBonds  = "bonds"
+	ClassCash   = "cash"
+)
+
+// AssetClass sorts a holding into cash (including sweep funds), bonds
+// (holdings typed fixed income) or stocks, which takes everything else.
+func (c CashTickers) AssetClass(r HoldingRecord) string {
+	switch {
+	case c.IsCash(r):
+		return ClassCash
+	case r.Type == "fixed_income" || r.Type == "bond":
+		return ClassBonds
+	}
+	return ClassStocks
+}
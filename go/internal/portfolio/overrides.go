@@ -0,0 +1,87 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultOverridesPath is where security type overrides are read from.
+const DefaultOverridesPath = ".mm/security_overrides.json"
+
+// SecurityOverride replaces the type Monarch reports for a security and
+// adds what Monarch doesn't know about it.
+type SecurityOverride struct {
+	Type        string `json:"type"`
+	TypeDisplay string `json:"typeDisplay"`
+	// Maturity is the YYYY-MM-DD maturity date of a CD or bond.
+	Maturity string `json:"maturity,omitempty"`
+	// Rate is its coupon or APY in percent.
+	Rate float64 `json:"rate,omitempty"`
+}
+
+// Overrides maps a security ID or ticker to its corrected type. Ticker keys
+// are matched case-insensitively; security IDs take precedence.
+type Overrides map[string]SecurityOverride
+
+// LoadOverrides reads an overrides file such as:
+//
+//	{
+//	  "VMFXX": {"type": "cash", "typeDisplay": "Money Market"},
+//	  "BND":   {"type": "fixed_income", "typeDisplay": "Bond Fund"},
+//	  "912797KX4": {"maturity": "2025-06-12", "rate": 4.2}
+//	}
+//
+// A missing file yields no overrides.
+func LoadOverrides(path string) (Overrides, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var o Overrides
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	normalized := make(Overrides, len(o))
+	for k, v := range o {
+		normalized[strings.ToUpper(k)] = v
+	}
+	return normalized, nil
+}
+
+// Lookup finds the override for a holding, by security ID then by ticker.
+func (o Overrides) Lookup(r HoldingRecord) (SecurityOverride, bool) {
+	for _, key := range []string{r.SecurityID, r.SecurityTicker, r.Ticker} {
+		if key == "" {
+			continue
+		}
+		if ov, ok := o[strings.ToUpper(key)]; ok {
+			return ov, true
+		}
+	}
+	return SecurityOverride{}, false
+}
+
+// Apply rewrites the type of each matching record in place and returns the
+// number of records changed.
+func (o Overrides) Apply(records []HoldingRecord) int {
+	n := 0
+	for i := range records {
+		ov, ok := o.Lookup(records[i])
+		if !ok || ov.Type == "" && ov.TypeDisplay == "" {
+			continue
+		}
+		if ov.Type != "" {
+			records[i].Type = ov.Type
+		}
+		if ov.TypeDisplay != "" {
+			records[i].TypeDisplay = ov.TypeDisplay
+		}
+		n++
+	}
+	return n
+}
@@ -2,10 +2,13 @@
 package portfolio
 
 import (
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"sort"
 	"strings"
@@ -70,22 +73,26 @@ type Institution struct {
 // --- Extracted flat record ---
 
 type HoldingRecord struct {
-	AccountID       string
-	AccountName     string
-	AccountMask     string
-	InstitutionName string
-	HoldingName     string
-	Ticker          string
-	Type            string
-	TypeDisplay     string
-	Quantity        float64
-	ClosingPrice    float64
-	Value           float64
-	SecurityID      string
-	SecurityName    string
-	SecurityTicker  string
-	CurrentPrice    float64
-	PriceUpdated    string
+	AccountID       string  `json:"account_id"`
+	AccountName     string  `json:"account_name"`
+	AccountMask     string  `json:"account_mask"`
+	InstitutionName string  `json:"institution_name"`
+	HoldingName     string  `json:"holding_name"`
+	Ticker          string  `json:"ticker"`
+	Type            string  `json:"type"`
+	TypeDisplay     string  `json:"type_display"`
+	Quantity        float64 `json:"quantity"`
+	ClosingPrice    float64 `json:"closing_price"`
+	Value           float64 `json:"value"`
+	SecurityID      string  `json:"security_id"`
+	SecurityName    string  `json:"security_name"`
+	SecurityTicker  string  `json:"security_ticker"`
+	CurrentPrice    float64 `json:"current_price"`
+	PriceUpdated    string  `json:"price_updated"`
+	// PctPortfolio and PctAccount are the holding's share of the value of
+	// all holdings and of its account's holdings, in percent.
+	PctPortfolio float64 `json:"pct_portfolio"`
+	PctAccount   float64 `json:"pct_account"`
 }
 
 var csvHeaders = []string{
@@ -93,7 +100,54 @@ var csvHeaders = []string{
 	"holding_name", "ticker", "type", "type_display",
 	"quantity", "closing_price", "value",
 	"security_id", "security_name", "security_ticker",
-	"current_price", "price_updated",
+	"current_price", "price_updated", "pct_portfolio", "pct_account", "record_id",
+}
+
+// DefaultPctPrecision is the number of decimal places ExtractHoldings
+// rounds percentages to.
+const DefaultPctPrecision = 2
+
+// SetPercentages fills in PctPortfolio and PctAccount for records, rounded
+// to precision decimal places. Unpriced holdings, whose value is stale,
+// count towards neither total and get zero percentages.
+func SetPercentages(records []HoldingRecord, precision int) {
+	total := PricedValue(records)
+	accounts := make(map[string]float64)
+	for _, r := range records {
+		if !r.IsUnpriced() {
+			accounts[r.AccountID] += r.Value
+		}
+	}
+	scale := math.Pow(10, float64(precision))
+	pct := func(v, of float64) float64 {
+		if of == 0 {
+			return 0
+		}
+		return math.Round(v/of*100*scale) / scale
+	}
+	for i := range records {
+		r := &records[i]
+		if r.IsUnpriced() {
+			r.PctPortfolio, r.PctAccount = 0, 0
+			continue
+		}
+		r.PctPortfolio = pct(r.Value, total)
+		r.PctAccount = pct(r.Value, accounts[r.AccountID])
+	}
+}
+
+// RecordID identifies the holding of a security in an account. It depends
+// only on those two IDs, so the same position keeps its ID across
+// snapshots and exports.
+func (r HoldingRecord) RecordID() string {
+	return StableID(r.AccountID, r.SecurityID, r.HoldingName)
+}
+
+// StableID derives a short ID from the given key parts, for records that
+// have no ID of their own.
+func StableID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:8])
 }
 
 func (r HoldingRecord) toRow() []string {
@@ -114,11 +168,15 @@ func (r HoldingRecord) toRow() []string {
 		r.SecurityTicker,
 		fmt.Sprintf("%g", r.CurrentPrice),
 		r.PriceUpdated,
+		fmt.Sprintf("%g", r.PctPortfolio),
+		fmt.Sprintf("%g", r.PctAccount),
+		r.RecordID(),
 	}
 }
 
 // ExtractHoldings parses a portfolio response and returns a flat list of holding records
-// sorted by value descending.
+// sorted by value descending, with ties broken by account, security and name
+// so the order doesn't depend on the API's.
 func ExtractHoldings(resp *Response) []HoldingRecord {
 	var records []HoldingRecord
 	for _, edge := range resp.Portfolio.AggregateHoldings.Edges {
@@ -145,8 +203,19 @@ func ExtractHoldings(resp *Response) []HoldingRecord {
 		}
 	}
 	sort.Slice(records, func(i, j int) bool {
-		return records[i].Value > records[j].Value
+		a, b := records[i], records[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.SecurityID != b.SecurityID {
+			return a.SecurityID < b.SecurityID
+		}
+		return a.HoldingName < b.HoldingName
 	})
+	SetPercentages(records, DefaultPctPrecision)
 	return records
 }
 
@@ -171,9 +240,16 @@ func WriteCSV(records []HoldingRecord, path string) error {
 	if err != nil {
 		return fmt.Errorf("create %s: %w", path, err)
 	}
-	defer f.Close()
+	if err := EncodeCSV(f, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	w := csv.NewWriter(f)
+// EncodeCSV writes holding records as CSV to out.
+func EncodeCSV(out io.Writer, records []HoldingRecord) error {
+	w := csv.NewWriter(out)
 	if err := w.Write(csvHeaders); err != nil {
 		return err
 	}
@@ -0,0 +1,48 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseDump decodes a JSON dump of Monarch data as written by the Python
+// monarchmoney library, i.e. the result of get_accounts, get_portfolio or
+// get_account_holdings saved with json.dump, or by "monarch fetch". Such
+// dumps are GraphQL data objects, optionally still wrapped in "data". It
+// returns the accounts and holdings found; either may be empty, but not
+// both.
+func ParseDump(raw []byte) ([]AccountRecord, []HoldingRecord, error) {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, err
+	}
+	if inner, ok := data["data"]; ok {
+		data = nil
+		if err := json.Unmarshal(inner, &data); err != nil {
+			return nil, nil, fmt.Errorf("data: %w", err)
+		}
+	}
+	_, hasAccounts := data["accounts"]
+	_, hasPortfolio := data["portfolio"]
+	if !hasAccounts && !hasPortfolio {
+		return nil, nil, fmt.Errorf("neither accounts nor portfolio found; expected the output of get_accounts, get_portfolio or get_account_holdings")
+	}
+
+	var accounts []AccountRecord
+	if hasAccounts {
+		resp, err := ParseAccounts(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("accounts: %w", err)
+		}
+		accounts = ExtractAccounts(resp)
+	}
+	var holdings []HoldingRecord
+	if hasPortfolio {
+		var resp Response
+		if err := json.Unmarshal(data["portfolio"], &resp.Portfolio); err != nil {
+			return nil, nil, fmt.Errorf("portfolio: %w", err)
+		}
+		holdings = ExtractHoldings(&resp)
+	}
+	return accounts, holdings, nil
+}
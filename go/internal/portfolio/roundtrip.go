@@ -0,0 +1,197 @@
+package portfolio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DecodeCSV reads holding records written by EncodeCSV. Columns are found
+// by their header, so they may be reordered; the percentage and record_id
+// columns are optional.
+func DecodeCSV(in io.Reader) ([]HoldingRecord, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty CSV")
+	}
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int, len(header))
+	for i, h := range header {
+		col[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+	for _, h := range csvHeaders {
+		if _, ok := col[h]; !ok && h != "pct_portfolio" && h != "pct_account" && h != "record_id" {
+			return nil, fmt.Errorf("no %s column; only the default holdings layout can be read", h)
+		}
+	}
+
+	var records []HoldingRecord
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		number := func(name string) (float64, error) {
+			s := field(name)
+			if s == "" {
+				return 0, nil
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: %s %q is not a number", line, name, s)
+			}
+			return v, nil
+		}
+		rec := HoldingRecord{
+			AccountID:       field("account_id"),
+			AccountName:     field("account_name"),
+			AccountMask:     field("account_mask"),
+			InstitutionName: field("institution_name"),
+			HoldingName:     field("holding_name"),
+			Ticker:          field("ticker"),
+			Type:            field("type"),
+			TypeDisplay:     field("type_display"),
+			SecurityID:      field("security_id"),
+			SecurityName:    field("security_name"),
+			SecurityTicker:  field("security_ticker"),
+			PriceUpdated:    field("price_updated"),
+		}
+		for _, f := range []struct {
+			name string
+			dst  *float64
+		}{
+			{"quantity", &rec.Quantity}, {"closing_price", &rec.ClosingPrice}, {"value", &rec.Value},
+			{"current_price", &rec.CurrentPrice}, {"pct_portfolio", &rec.PctPortfolio}, {"pct_account", &rec.PctAccount},
+		} {
+			if *f.dst, err = number(f.name); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+// Discrepancy is a difference between a holding as exported and as
+// re-read.
+type Discrepancy struct {
+	// Holding names the holding, by ticker or name and account.
+	Holding string
+	Field   string
+	// Source is the value in the portfolio, Exported the value read back;
+	// either is empty for a missing row.
+	Source, Exported string
+	Problem          string
+}
+
+// RoundTrip compares exported, the holdings read back from a CSV, with
+// source, the holdings it was written from, matching rows by account and
+// security in order. It reports missing and extra rows, numbers that lost
+// precision and text that was truncated or changed. Percentages are left
+// out, as they are rounded on purpose.
+func RoundTrip(source, exported []HoldingRecord) []Discrepancy {
+	key := func(r HoldingRecord) string { return r.AccountID + "\x1f" + r.SecurityID }
+	rows := make(map[string][]HoldingRecord)
+	for _, r := range exported {
+		rows[key(r)] = append(rows[key(r)], r)
+	}
+	var out []Discrepancy
+	for _, src := range source {
+		k := key(src)
+		if len(rows[k]) == 0 {
+			out = append(out, Discrepancy{Holding: label(src), Problem: "missing from the CSV"})
+			continue
+		}
+		got := rows[k][0]
+		rows[k] = rows[k][1:]
+		for _, f := range []struct {
+			name     string
+			src, got string
+		}{
+			{"holding_name", src.HoldingName, got.HoldingName},
+			{"account_name", src.AccountName, got.AccountName},
+			{"account_mask", src.AccountMask, got.AccountMask},
+			{"institution_name", src.InstitutionName, got.InstitutionName},
+			{"ticker", src.Ticker, got.Ticker},
+			{"type", src.Type, got.Type},
+			{"type_display", src.TypeDisplay, got.TypeDisplay},
+			{"security_name", src.SecurityName, got.SecurityName},
+			{"security_ticker", src.SecurityTicker, got.SecurityTicker},
+			{"price_updated", src.PriceUpdated, got.PriceUpdated},
+		} {
+			if f.src == f.got {
+				continue
+			}
+			problem := "changed"
+			if strings.HasPrefix(f.src, f.got) {
+				problem = "truncated"
+			}
+			out = append(out, Discrepancy{label(src), f.name, f.src, f.got, problem})
+		}
+		for _, f := range []struct {
+			name     string
+			src, got float64
+		}{
+			{"quantity", src.Quantity, got.Quantity},
+			{"closing_price", src.ClosingPrice, got.ClosingPrice},
+			{"value", src.Value, got.Value},
+			{"current_price", src.CurrentPrice, got.CurrentPrice},
+		} {
+			if f.src == f.got {
+				continue
+			}
+			problem := "changed"
+			if decimals(f.got) < decimals(f.src) && roundTo(f.src, decimals(f.got)) == f.got {
+				problem = fmt.Sprintf("rounded to %d decimals", decimals(f.got))
+			}
+			out = append(out, Discrepancy{label(src), f.name, formatNumber(f.src), formatNumber(f.got), problem})
+		}
+	}
+	for _, r := range exported {
+		if k := key(r); len(rows[k]) > 0 {
+			out = append(out, Discrepancy{Holding: label(rows[k][0]), Problem: "not in the portfolio"})
+			rows[k] = rows[k][1:]
+		}
+	}
+	return out
+}
+
+func label(r HoldingRecord) string {
+	name := r.Ticker
+	if name == "" {
+		name = r.HoldingName
+	}
+	return name + " (" + r.AccountName + ")"
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// decimals is the number of decimal places of v's shortest form.
+func decimals(v float64) int {
+	s := formatNumber(v)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+func roundTo(v float64, places int) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', places, 64), 64)
+	return r
+}
@@ -0,0 +1,63 @@
+package portfolio
+
+import (
+	"fmt"
+	"io"
+)
+
+// Warning describes a holding whose data looks unreliable.
+type Warning struct {
+	Holding HoldingRecord
+	Message string
+}
+
+// IsUnpriced reports whether the holding has shares but no current price,
+// which Monarch returns for delisted or unpriced securities.
+func (r HoldingRecord) IsUnpriced() bool {
+	return r.Quantity > 0 && r.CurrentPrice == 0
+}
+
+// Validate checks records for data problems and returns one warning per
+// suspicious holding.
+func Validate(records []HoldingRecord) []Warning {
+	var warnings []Warning
+	for _, r := range records {
+		if r.IsUnpriced() {
+			warnings = append(warnings, Warning{
+				Holding: r,
+				Message: fmt.Sprintf("no current price for %g shares; excluded from percentages", r.Quantity),
+			})
+		}
+	}
+	return warnings
+}
+
+// PricedValue sums the value of holdings that have a current price. Use it as
+// the denominator for percentage calculations so unpriced holdings don't
+// skew the shares of everything else.
+func PricedValue(records []HoldingRecord) float64 {
+	var total float64
+	for _, r := range records {
+		if !r.IsUnpriced() {
+			total += r.Value
+		}
+	}
+	return total
+}
+
+// WriteWarnings writes a "Warnings" section listing each warning. Nothing is
+// written when there are no warnings.
+func WriteWarnings(warnings []Warning, w io.Writer) {
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Warnings:")
+	for _, wn := range warnings {
+		name := wn.Holding.Ticker
+		if name == "" {
+			name = wn.Holding.HoldingName
+		}
+		fmt.Fprintf(w, "  - %s (%s): %s\n", name, wn.Holding.AccountName, wn.Message)
+	}
+}
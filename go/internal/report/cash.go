@@ -0,0 +1,78 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// CashRow is the cash held in one account.
+type CashRow struct {
+	Account     string
+	Institution string
+	Kind        string
+	Cash        float64
+}
+
+// depositoryType is Monarch's account type for checking and savings accounts.
+const depositoryType = "depository"
+
+// Cash totals cash per account in a snapshot: the balance of every bank
+// (depository) account plus the value of cash and sweep-fund holdings in
+// brokerage accounts. Rows are sorted by cash descending.
+func Cash(snap history.Snapshot, cash portfolio.CashTickers, includeExcluded bool) []CashRow {
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = snap.ExcludedAccounts()
+	}
+
+	var rows []CashRow
+	for _, a := range snap.Accounts {
+		if a.Type != depositoryType || excluded[a.ID] {
+			continue
+		}
+		rows = append(rows, CashRow{Account: a.Name, Institution: a.InstitutionName, Kind: "bank", Cash: a.Balance})
+	}
+
+	byAccount := make(map[string]*CashRow)
+	var order []string
+	for _, h := range snap.Holdings {
+		if excluded[h.AccountID] || h.IsUnpriced() || !cash.IsCash(h) {
+			continue
+		}
+		row, ok := byAccount[h.AccountID]
+		if !ok {
+			row = &CashRow{Account: h.AccountName, Institution: h.InstitutionName, Kind: "brokerage"}
+			byAccount[h.AccountID] = row
+			order = append(order, h.AccountID)
+		}
+		row.Cash += h.Value
+	}
+	for _, id := range order {
+		rows = append(rows, *byAccount[id])
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Cash != rows[j].Cash {
+			return rows[i].Cash > rows[j].Cash
+		}
+		return rows[i].Account < rows[j].Account
+	})
+	return rows
+}
+
+// WriteCash renders cash rows as a table followed by the overall total.
+func WriteCash(w io.Writer, rows []CashRow) {
+	var total float64
+	table := make([][]string, len(rows))
+	for i, r := range rows {
+		table[i] = []string{r.Account, r.Institution, r.Kind, money(r.Cash)}
+		total += r.Cash
+	}
+	WriteTable(w, []string{"account", "institution", "kind", "cash"}, table)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Total cash: %s\n", money(total))
+}
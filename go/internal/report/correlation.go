@@ -0,0 +1,242 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// minCorrelationPeriods is the fewest common returns a pair needs for its
+// correlation to be reported.
+const minCorrelationPeriods = 3
+
+// Correlation is a symmetric matrix of return correlations between
+// securities. Values are NaN where two securities have too few returns in
+// common.
+type Correlation struct {
+	Tickers []string
+	Values  [][]float64
+}
+
+// Correlations computes the pairwise correlation of price returns between
+// snapshots for the top securities by value in the latest snapshot. Prices
+// come from the holdings in each snapshot, so returns exist only between
+// snapshots that both hold a security. Cash is left out, since its price
+// doesn't move, and so are accounts excluded from net worth in Monarch
+// unless includeExcluded is set.
+func Correlations(snaps []history.Snapshot, top int, cash portfolio.CashTickers, includeExcluded bool) (Correlation, error) {
+	if len(snaps) < minCorrelationPeriods+1 {
+		return Correlation{}, fmt.Errorf("need at least %d snapshots, have %d", minCorrelationPeriods+1, len(snaps))
+	}
+	latest := snaps[len(snaps)-1]
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = latest.ExcludedAccounts()
+	}
+	tickers := topSecurities(latest, top, cash, excluded)
+	prices := make([]map[string]float64, len(snaps))
+	for i, snap := range snaps {
+		prices[i] = securityPrices(snap)
+	}
+	// returns[t][i] is the return of tickers[t] over period i, or NaN.
+	returns := make([][]float64, len(tickers))
+	for t, ticker := range tickers {
+		returns[t] = make([]float64, len(snaps)-1)
+		for i := 1; i < len(snaps); i++ {
+			p0, ok0 := prices[i-1][ticker]
+			p1, ok1 := prices[i][ticker]
+			if ok0 && ok1 && p0 > 0 {
+				returns[t][i-1] = p1/p0 - 1
+			} else {
+				returns[t][i-1] = math.NaN()
+			}
+		}
+	}
+
+	m := Correlation{Tickers: tickers, Values: make([][]float64, len(tickers))}
+	for a := range tickers {
+		m.Values[a] = make([]float64, len(tickers))
+		for b := range tickers {
+			m.Values[a][b] = pearson(returns[a], returns[b])
+		}
+	}
+	return m, nil
+}
+
+// topSecurities returns the tickers of the n most valuable non-cash
+// securities in snap, summed across accounts that aren't excluded.
+func topSecurities(snap history.Snapshot, n int, cash portfolio.CashTickers, excluded map[string]bool) []string {
+	values := make(map[string]float64)
+	for _, h := range snap.Holdings {
+		if excluded[h.AccountID] || cash.IsCash(h) || h.IsUnpriced() {
+			continue
+		}
+		values[securityKey(h)] += h.Value
+	}
+	tickers := make([]string, 0, len(values))
+	for t := range values {
+		tickers = append(tickers, t)
+	}
+	sort.Slice(tickers, func(i, j int) bool {
+		if values[tickers[i]] != values[tickers[j]] {
+			return values[tickers[i]] > values[tickers[j]]
+		}
+		return tickers[i] < tickers[j]
+	})
+	if len(tickers) > n {
+		tickers = tickers[:n]
+	}
+	return tickers
+}
+
+// securityPrices maps each security in snap to its unit price.
+func securityPrices(snap history.Snapshot) map[string]float64 {
+	prices := make(map[string]float64)
+	for _, h := range snap.Holdings {
+		if p, ok := unitPrice(h); ok {
+			prices[securityKey(h)] = p
+		}
+	}
+	return prices
+}
+
+// securityKey names a security by ticker, or by holding name if it has none.
+func securityKey(h portfolio.HoldingRecord) string {
+	if h.Ticker != "" {
+		return h.Ticker
+	}
+	return h.HoldingName
+}
+
+// pearson is the correlation of xs and ys over the periods where both are
+// known, or NaN if there are too few or either doesn't vary.
+func pearson(xs, ys []float64) float64 {
+	var a, b []float64
+	for i := range xs {
+		if !math.IsNaN(xs[i]) && !math.IsNaN(ys[i]) {
+			a = append(a, xs[i])
+			b = append(b, ys[i])
+		}
+	}
+	if len(a) < minCorrelationPeriods {
+		return math.NaN()
+	}
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= float64(len(a))
+	meanB /= float64(len(b))
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+func correlationCell(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// WriteCorrelation renders the matrix as a table.
+func WriteCorrelation(w io.Writer, m Correlation) {
+	WriteTable(w, append([]string{""}, m.Tickers...), correlationRows(m))
+}
+
+// WriteCorrelationCSV writes the matrix as CSV with the tickers as the
+// header row and first column. Unknown correlations are empty.
+func WriteCorrelationCSV(w io.Writer, m Correlation) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{""}, m.Tickers...))
+	cw.WriteAll(correlationRows(m))
+	return cw.Error()
+}
+
+func correlationRows(m Correlation) [][]string {
+	rows := make([][]string, len(m.Tickers))
+	for a, ticker := range m.Tickers {
+		rows[a] = []string{ticker}
+		for _, v := range m.Values[a] {
+			rows[a] = append(rows[a], correlationCell(v))
+		}
+	}
+	return rows
+}
+
+var correlationHTML = template.Must(template.New("correlation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: 0.4em 0.6em; text-align: center; }
+td { border: 1px solid #ddd; min-width: 3em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th></th>{{range .Tickers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Ticker}}</th>{{range .Cells}}<td style="background: {{.Color}}">{{.Text}}</td>{{end}}</tr>
+{{end}}</table>
+<p>Red cells move together, blue cells move in opposite directions. Empty cells have too few snapshots in common.</p>
+</body>
+</html>
+`))
+
+// WriteCorrelationHTML writes the matrix as a standalone HTML heatmap.
+func WriteCorrelationHTML(w io.Writer, title string, m Correlation) error {
+	type cell struct {
+		Text  string
+		Color template.CSS
+	}
+	type row struct {
+		Ticker string
+		Cells  []cell
+	}
+	data := struct {
+		Title   string
+		Tickers []string
+		Rows    []row
+	}{Title: title, Tickers: m.Tickers}
+	for a, ticker := range m.Tickers {
+		r := row{Ticker: ticker}
+		for _, v := range m.Values[a] {
+			r.Cells = append(r.Cells, cell{Text: correlationCell(v), Color: heatColor(v)})
+		}
+		data.Rows = append(data.Rows, r)
+	}
+	return correlationHTML.Execute(w, data)
+}
+
+// heatColor shades positive correlations red and negative ones blue, more
+// strongly the closer they are to ±1.
+func heatColor(v float64) template.CSS {
+	if math.IsNaN(v) {
+		return "#fff"
+	}
+	fade := int(255 * (1 - math.Min(math.Abs(v), 1)))
+	if v >= 0 {
+		return template.CSS(fmt.Sprintf("rgb(255,%d,%d)", fade, fade))
+	}
+	return template.CSS(fmt.Sprintf("rgb(%d,%d,255)", fade, fade))
+}
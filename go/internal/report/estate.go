@@ -0,0 +1,202 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+)
+
+// DefaultEstatePath is where the estate report reads institution contacts
+// and account owners from.
+const DefaultEstatePath = ".mm/estate.json"
+
+// EstateInfo is what Monarch doesn't know about the accounts but survivors
+// need: how to reach each institution and whom each account belongs to.
+type EstateInfo struct {
+	// Owner is attributed to accounts without their own.
+	Owner        string                     `json:"owner"`
+	Institutions map[string]InstitutionInfo `json:"institutions"`
+	// Accounts are keyed by account ID or name.
+	Accounts map[string]AccountInfo `json:"accounts"`
+}
+
+// InstitutionInfo holds the contact details of an institution.
+type InstitutionInfo struct {
+	Phone   string `json:"phone"`
+	Website string `json:"website"`
+	Address string `json:"address"`
+	Contact string `json:"contact"`
+}
+
+// AccountInfo holds the ownership of an account.
+type AccountInfo struct {
+	Owner         string   `json:"owner"`
+	Beneficiaries []string `json:"beneficiaries"`
+	Notes         string   `json:"notes"`
+}
+
+// LoadEstateInfo reads an estate file such as:
+//
+//	{
+//	  "owner": "Alex Doe",
+//	  "institutions": {
+//	    "Fidelity": {"phone": "800-343-3548", "website": "https://www.fidelity.com"}
+//	  },
+//	  "accounts": {
+//	    "Roth IRA": {"owner": "Sam Doe", "beneficiaries": ["Alex Doe"], "notes": "Login in the password manager"}
+//	  }
+//	}
+//
+// Institution names and account keys are matched case-insensitively. A
+// missing file yields no information.
+func LoadEstateInfo(path string) (EstateInfo, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return EstateInfo{}, nil
+	}
+	if err != nil {
+		return EstateInfo{}, err
+	}
+	var info EstateInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return EstateInfo{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	institutions := make(map[string]InstitutionInfo, len(info.Institutions))
+	for k, v := range info.Institutions {
+		institutions[strings.ToLower(k)] = v
+	}
+	accounts := make(map[string]AccountInfo, len(info.Accounts))
+	for k, v := range info.Accounts {
+		accounts[strings.ToLower(k)] = v
+	}
+	info.Institutions, info.Accounts = institutions, accounts
+	return info, nil
+}
+
+// EstateAccount is one account in the estate inventory.
+type EstateAccount struct {
+	Name    string
+	Type    string
+	Mask    string
+	Balance float64
+	IsAsset bool
+	AccountInfo
+}
+
+// EstateInstitution lists the accounts held at one institution.
+type EstateInstitution struct {
+	Name     string
+	Contact  InstitutionInfo
+	Accounts []EstateAccount
+}
+
+// Estate lists every account in snap, including those excluded from net
+// worth, grouped by institution and enriched with info. Institutions and
+// their accounts are sorted by name.
+func Estate(snap history.Snapshot, info EstateInfo) []EstateInstitution {
+	byName := make(map[string]*EstateInstitution)
+	for _, a := range snap.Accounts {
+		name := a.InstitutionName
+		if name == "" {
+			name = "Other"
+		}
+		inst, ok := byName[name]
+		if !ok {
+			inst = &EstateInstitution{Name: name, Contact: info.Institutions[strings.ToLower(name)]}
+			byName[name] = inst
+		}
+		acct, ok := info.Accounts[strings.ToLower(a.ID)]
+		if !ok {
+			acct = info.Accounts[strings.ToLower(a.Name)]
+		}
+		if acct.Owner == "" {
+			acct.Owner = info.Owner
+		}
+		inst.Accounts = append(inst.Accounts, EstateAccount{
+			Name:        a.Name,
+			Type:        a.SubtypeDisplay,
+			Mask:        a.Mask,
+			Balance:     a.Balance,
+			IsAsset:     a.IsAsset,
+			AccountInfo: acct,
+		})
+	}
+
+	insts := make([]EstateInstitution, 0, len(byName))
+	for _, inst := range byName {
+		sort.Slice(inst.Accounts, func(i, j int) bool { return inst.Accounts[i].Name < inst.Accounts[j].Name })
+		insts = append(insts, *inst)
+	}
+	sort.Slice(insts, func(i, j int) bool { return insts[i].Name < insts[j].Name })
+	return insts
+}
+
+// WriteEstate renders the inventory as a Markdown document with a section
+// per institution. Account numbers are masked and values rounded to the
+// nearest hundred, since the document is meant to be printed and shared.
+func WriteEstate(w io.Writer, asOf time.Time, insts []EstateInstitution, infoPath string) {
+	fmt.Fprintln(w, "# Estate inventory")
+	fmt.Fprintf(w, "\nAccounts as of %s. Values are approximate, rounded to the nearest 100.\n", asOf.Format(time.DateOnly))
+
+	var assets, liabilities float64
+	for _, inst := range insts {
+		fmt.Fprintf(w, "\n## %s\n\n", inst.Name)
+		c := inst.Contact
+		if c == (InstitutionInfo{}) {
+			fmt.Fprintf(w, "No contact details; add them to %s.\n", infoPath)
+		}
+		for _, field := range [][2]string{{"Phone", c.Phone}, {"Website", c.Website}, {"Address", c.Address}, {"Contact", c.Contact}} {
+			if field[1] != "" {
+				fmt.Fprintf(w, "- %s: %s\n", field[0], field[1])
+			}
+		}
+		fmt.Fprintln(w)
+
+		rows := make([][]string, len(inst.Accounts))
+		var notes []string
+		for i, a := range inst.Accounts {
+			value := approximate(a.Balance)
+			if a.IsAsset {
+				assets += a.Balance
+			} else {
+				liabilities += math.Abs(a.Balance)
+				value = "owed " + approximate(math.Abs(a.Balance))
+			}
+			rows[i] = []string{a.Name, dashIfEmpty(a.Type), maskNumber(a.Mask), dashIfEmpty(a.Owner), dashIfEmpty(strings.Join(a.Beneficiaries, ", ")), value}
+			if a.Notes != "" {
+				notes = append(notes, fmt.Sprintf("- %s: %s", a.Name, a.Notes))
+			}
+		}
+		WriteTable(w, []string{"account", "type", "number", "owner", "beneficiaries", "value"}, rows)
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "\nNotes:\n%s\n", strings.Join(notes, "\n"))
+		}
+	}
+	fmt.Fprintf(w, "\nTotal assets about %s, liabilities about %s.\n", approximate(assets), approximate(liabilities))
+}
+
+// maskNumber shows only the last digits of an account number.
+func maskNumber(mask string) string {
+	if mask == "" {
+		return "-"
+	}
+	return "****" + mask
+}
+
+func approximate(v float64) string {
+	return fmt.Sprintf("%.0f", math.Round(v/100)*100)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
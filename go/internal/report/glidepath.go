@@ -0,0 +1,148 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// DefaultDriftTolerance is how far, in percentage points, the equity share
+// may drift from the glide path before it is flagged.
+const DefaultDriftTolerance = 5.0
+
+// GlideRule is an age-based target for the share of equities, such as
+// "110-age", or a fixed percentage such as "60".
+type GlideRule struct {
+	base     float64
+	minusAge bool
+}
+
+// ParseGlideRule parses "N-age" or "N", where N is a percentage.
+func ParseGlideRule(s string) (GlideRule, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	num, minusAge := strings.CutSuffix(s, "-age")
+	base, err := strconv.ParseFloat(num, 64)
+	if err != nil || base < 0 || (!minusAge && base > 100) {
+		return GlideRule{}, fmt.Errorf("invalid glide path rule %q: want e.g. 110-age or 60", s)
+	}
+	return GlideRule{base: base, minusAge: minusAge}, nil
+}
+
+func (r GlideRule) String() string {
+	if r.minusAge {
+		return strconv.FormatFloat(r.base, 'f', -1, 64) + "-age"
+	}
+	return strconv.FormatFloat(r.base, 'f', -1, 64)
+}
+
+// Target is the equity share for age, as a fraction between 0 and 1.
+func (r GlideRule) Target(age int) float64 {
+	pct := r.base
+	if r.minusAge {
+		pct -= float64(age)
+	}
+	return math.Min(math.Max(pct, 0), 100) / 100
+}
+
+// GlideRow compares the equity share of one account type, or of all
+// investments in the "Total" row, with the target.
+type GlideRow struct {
+	Group  string
+	Value  float64
+	Equity float64
+	Target float64
+}
+
+// Drift is the equity share minus the target, as a fraction.
+func (r GlideRow) Drift() float64 {
+	return r.Equity - r.Target
+}
+
+// Drifted reports whether the equity share is more than tolerance
+// percentage points from the target.
+func (r GlideRow) Drifted(tolerance float64) bool {
+	return math.Abs(r.Drift())*100 > tolerance
+}
+
+// GlidePath compares the equity share (stocks, as sorted by
+// CashTickers.AssetClass) of each account type in snap with target.
+// Accounts excluded from net worth in Monarch are skipped unless
+// includeExcluded is set.
+func GlidePath(snap history.Snapshot, cash portfolio.CashTickers, target float64, includeExcluded bool) []GlideRow {
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = snap.ExcludedAccounts()
+	}
+	groupOf := groupFunc([]history.Snapshot{snap}, ByAccountType)
+	type sums struct{ value, equity float64 }
+	groups := make(map[string]*sums)
+	var total sums
+	for _, h := range snap.Holdings {
+		if excluded[h.AccountID] {
+			continue
+		}
+		g := groupOf(h.AccountID)
+		if groups[g] == nil {
+			groups[g] = &sums{}
+		}
+		groups[g].value += h.Value
+		total.value += h.Value
+		if cash.AssetClass(h) == portfolio.ClassStocks {
+			groups[g].equity += h.Value
+			total.equity += h.Value
+		}
+	}
+
+	row := func(name string, s sums) GlideRow {
+		r := GlideRow{Group: name, Value: s.value, Target: target}
+		if s.value > 0 {
+			r.Equity = s.equity / s.value
+		}
+		return r
+	}
+	var rows []GlideRow
+	for g, s := range groups {
+		rows = append(rows, row(g, *s))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Group < rows[j].Group })
+	return append(rows, row("Total", total))
+}
+
+// WriteGlidePath renders glide path rows as a table, followed by a warning
+// for each row that drifted more than tolerance percentage points.
+func WriteGlidePath(w io.Writer, rule GlideRule, age int, rows []GlideRow, tolerance float64) {
+	fmt.Fprintf(w, "Glide path %s at age %d: target %s equities\n\n", rule, age, percent(rows[len(rows)-1].Target))
+	table := make([][]string, len(rows))
+	for i, r := range rows {
+		table[i] = []string{r.Group, money(r.Value), percent(r.Equity), percent(r.Target), signedPercent(r.Drift())}
+	}
+	WriteTable(w, []string{"group", "value", "equity", "target", "drift"}, table)
+
+	var drifted bool
+	for _, r := range rows {
+		if r.Drifted(tolerance) {
+			if !drifted {
+				fmt.Fprintln(w)
+				drifted = true
+			}
+			fmt.Fprintln(w, "Warning:", DriftSummary(r))
+		}
+	}
+}
+
+// DriftSummary describes a drifted row in one line for warnings and
+// notifications.
+func DriftSummary(r GlideRow) string {
+	dir := "above"
+	if r.Drift() < 0 {
+		dir = "below"
+	}
+	return fmt.Sprintf("%s holds %s equities, %.1f points %s the %s target",
+		r.Group, percent(r.Equity), math.Abs(r.Drift())*100, dir, percent(r.Target))
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// GrowthRow splits the net-worth change over one period into money saved
+// (income minus spending) and everything else, which is market growth.
+type GrowthRow struct {
+	Period        string
+	StartNetWorth float64
+	EndNetWorth   float64
+	Change        float64
+	Contributions float64
+	MarketGrowth  float64
+}
+
+// MonthlySnapshots keeps the last snapshot of each calendar month, plus the
+// very first snapshot so the first month has a starting point.
+func MonthlySnapshots(snaps []history.Snapshot) []history.Snapshot {
+	if len(snaps) == 0 {
+		return nil
+	}
+	out := []history.Snapshot{snaps[0]}
+	for i := 1; i < len(snaps); i++ {
+		last := i == len(snaps)-1
+		if last || monthKey(snaps[i].Time) != monthKey(snaps[i+1].Time) {
+			out = append(out, snaps[i])
+		}
+	}
+	return out
+}
+
+func monthKey(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Growth decomposes the net-worth change between consecutive snapshots.
+// Contributions are the sum of income and spending transactions in each
+// period; transfers between accounts net to zero and are ignored. Accounts
+// excluded from net worth in Monarch, and their transactions, are left out
+// unless includeExcluded is set. A "Total" row spanning the first and last
+// snapshot is appended.
+func Growth(snaps []history.Snapshot, txns []transactions.Transaction, includeExcluded bool) ([]GrowthRow, error) {
+	if len(snaps) < 2 {
+		return nil, fmt.Errorf("need at least two snapshots, have %d", len(snaps))
+	}
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = snaps[len(snaps)-1].ExcludedAccounts()
+	}
+	var rows []GrowthRow
+	for i := 1; i < len(snaps); i++ {
+		prev, cur := snaps[i-1], snaps[i]
+		rows = append(rows, growthRow(
+			prev.Time.Format(time.DateOnly)+" → "+cur.Time.Format(time.DateOnly),
+			prev, cur, txns, excluded, includeExcluded,
+		))
+	}
+	rows = append(rows, growthRow("Total", snaps[0], snaps[len(snaps)-1], txns, excluded, includeExcluded))
+	return rows, nil
+}
+
+func growthRow(label string, prev, cur history.Snapshot, txns []transactions.Transaction, excluded map[string]bool, includeExcluded bool) GrowthRow {
+	row := GrowthRow{
+		Period:        label,
+		StartNetWorth: prev.NetWorth(includeExcluded),
+		EndNetWorth:   cur.NetWorth(includeExcluded),
+	}
+	row.Change = row.EndNetWorth - row.StartNetWorth
+	for _, t := range txns {
+		if t.IsTransfer() || t.HideFromReports || t.Pending || excluded[t.Account.ID] {
+			continue
+		}
+		d := t.Time()
+		if d.After(prev.Time) && !d.After(cur.Time) {
+			row.Contributions += t.Amount
+		}
+	}
+	row.MarketGrowth = row.Change - row.Contributions
+	return row
+}
+
+// WriteGrowth renders growth rows as a table.
+func WriteGrowth(w io.Writer, rows []GrowthRow) {
+	table := make([][]string, len(rows))
+	for i, r := range rows {
+		table[i] = []string{
+			r.Period, money(r.StartNetWorth), money(r.EndNetWorth),
+			money(r.Change), money(r.Contributions), money(r.MarketGrowth),
+		}
+	}
+	WriteTable(w, []string{"period", "start_net_worth", "end_net_worth", "change", "contributions", "market_growth"}, table)
+
+	if len(rows) > 0 {
+		total := rows[len(rows)-1]
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Net worth changed by %s: %s saved, %s from market growth.\n",
+			money(total.Change), money(total.Contributions), money(total.MarketGrowth))
+	}
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// ParseMonths parses a window of whole months such as "6m" or "1y".
+func ParseMonths(s string) (int, error) {
+	if len(s) >= 2 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil && n > 0 {
+			switch s[len(s)-1] {
+			case 'm':
+				return n, nil
+			case 'y':
+				return 12 * n, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid window %q: want e.g. 6m or 1y", s)
+}
+
+// IncomeMonth is one month's earned income.
+type IncomeMonth struct {
+	Month  time.Time
+	Income float64
+	// Rolling is the average income of the window ending with this month.
+	Rolling float64
+}
+
+// IncomeSmoothing is what a variable income allows paying oneself each
+// month.
+type IncomeSmoothing struct {
+	// Window is the number of months asked for; Months may hold fewer if
+	// the transactions start later.
+	Window int
+	Months []IncomeMonth
+	Mean   float64
+	// StdDev is the sample standard deviation of the monthly income.
+	StdDev float64
+	// Baseline is the recommended monthly salary: the mean less one
+	// standard deviation, which most months cover.
+	Baseline float64
+	// Buffer is the reserve that paying the baseline every month of the
+	// window would have drawn on at most.
+	Buffer float64
+}
+
+// Variation is the standard deviation as a share of the mean.
+func (s IncomeSmoothing) Variation() float64 {
+	if s.Mean == 0 {
+		return 0
+	}
+	return s.StdDev / s.Mean
+}
+
+// BuildIncomeSmoothing sums the earned income of each of the window months
+// ending with the month of last, and the rolling average of the window
+// months ending with each of them. txns should reach back twice the
+// window, for the rolling averages; months before the first transaction
+// are left out rather than counted as months without income.
+func BuildIncomeSmoothing(txns []transactions.Transaction, last time.Time, window int) IncomeSmoothing {
+	s := IncomeSmoothing{Window: window}
+	end := monthOf(last)
+	start := end.AddDate(0, 1-2*window, 0)
+	var first time.Time
+	for _, t := range txns {
+		if d := t.Time(); !d.IsZero() && (first.IsZero() || d.Before(first)) {
+			first = d
+		}
+	}
+	if first.IsZero() || monthOf(first).After(end) {
+		return s
+	}
+	if monthOf(first).After(start) {
+		start = monthOf(first)
+	}
+
+	var series []IncomeMonth
+	index := map[time.Time]int{}
+	for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+		index[m] = len(series)
+		series = append(series, IncomeMonth{Month: m})
+	}
+	for _, t := range txns {
+		if i, ok := index[monthOf(t.Time())]; ok && t.IsEarnedIncome() {
+			series[i].Income += t.Amount
+		}
+	}
+	for i := range series {
+		from := max(0, i-window+1)
+		var sum float64
+		for _, m := range series[from : i+1] {
+			sum += m.Income
+		}
+		series[i].Rolling = sum / float64(i+1-from)
+	}
+	s.Months = series[max(0, len(series)-window):]
+
+	incomes := make([]float64, len(s.Months))
+	for i, m := range s.Months {
+		incomes[i] = m.Income
+		s.Mean += m.Income
+	}
+	s.Mean /= float64(len(incomes))
+	if len(incomes) > 1 {
+		s.StdDev = stddev(incomes)
+	}
+	s.Baseline = math.Max(s.Mean-s.StdDev, 0)
+	var balance float64
+	for _, income := range incomes {
+		balance += income - s.Baseline
+		s.Buffer = math.Max(s.Buffer, -balance)
+	}
+	return s
+}
+
+// WriteIncomeSmoothing renders the monthly income and the recommended
+// baseline salary.
+func WriteIncomeSmoothing(w io.Writer, s IncomeSmoothing) {
+	if len(s.Months) == 0 {
+		fmt.Fprintln(w, "No transactions in the window.")
+		return
+	}
+	first, last := s.Months[0].Month, s.Months[len(s.Months)-1].Month
+	fmt.Fprintf(w, "Earned income over %d months, %s to %s\n", len(s.Months), first.Format("2006-01"), last.Format("2006-01"))
+	if len(s.Months) < s.Window {
+		fmt.Fprintf(w, "Transactions start in %s; the %d-month window isn't full.\n", first.Format("2006-01"), s.Window)
+	}
+	fmt.Fprintln(w)
+
+	var rows [][]string
+	for _, m := range s.Months {
+		rows = append(rows, []string{m.Month.Format("2006-01"), money(m.Income), money(m.Rolling), signedMoney(m.Income - s.Baseline)})
+	}
+	WriteTable(w, []string{"month", "income", "rolling average", "vs. baseline"}, rows)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Average    %s per month\n", money(s.Mean))
+	fmt.Fprintf(w, "Std. dev.  %s (%s of the average)\n", money(s.StdDev), percent(s.Variation()))
+	fmt.Fprintf(w, "Baseline   %s per month, the average less one standard deviation\n", money(s.Baseline))
+	fmt.Fprintf(w, "Buffer     %s, the most paying the baseline would have drawn on savings\n", money(s.Buffer))
+}
@@ -0,0 +1,224 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// LadderHolding is a CD, treasury or bond with a known maturity.
+type LadderHolding struct {
+	Name     string
+	Account  string
+	Maturity time.Time
+	// Rate is the coupon or APY in percent, or zero if unknown.
+	Rate  float64
+	Value float64
+}
+
+// LadderRung is what matures in one month.
+type LadderRung struct {
+	Month    time.Time
+	Holdings []LadderHolding
+	Value    float64
+}
+
+// Ladder is the maturity ladder of a snapshot.
+type Ladder struct {
+	AsOf time.Time
+	// Holdings are sorted by maturity.
+	Holdings []LadderHolding
+	// Rungs run from the first maturity month to the last, including
+	// months in which nothing matures.
+	Rungs []LadderRung
+	// Unknown are fixed-income holdings whose maturity wasn't found.
+	Unknown []portfolio.HoldingRecord
+}
+
+// maturityDates match the dates in holding names such as "US TREASURY
+// BILL 06/12/2025", "CD 4.5% DUE 09/15/26" or "CD 2025-09-15".
+var maturityDates = []struct {
+	re     *regexp.Regexp
+	layout string
+}{
+	{regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})\b`), "2006-01-02"},
+	{regexp.MustCompile(`\b(\d{1,2}/\d{1,2}/\d{4})\b`), "1/2/2006"},
+	{regexp.MustCompile(`\b(\d{1,2}/\d{1,2}/\d{2})\b`), "1/2/06"},
+	{regexp.MustCompile(`(?i)\b((?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]* \d{1,2},? \d{4})\b`), "Jan 2 2006"},
+}
+
+// rateInName matches a rate such as "4.5%" in a holding name.
+var rateInName = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?%`)
+
+// isFixedIncome reports whether a holding is a CD, treasury or bond, whose
+// name is worth searching for a maturity date.
+func isFixedIncome(h portfolio.HoldingRecord) bool {
+	kind := strings.ToLower(h.Type + " " + h.TypeDisplay)
+	for _, s := range []string{"fixed", "bond", "treasur", "certificate", "cd"} {
+		if strings.Contains(kind, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// maturityInName returns the last date in name, as issue dates come
+// before maturities.
+func maturityInName(name string) (time.Time, bool) {
+	var found time.Time
+	pos := -1
+	for _, d := range maturityDates {
+		for _, m := range d.re.FindAllStringSubmatchIndex(name, -1) {
+			s := name[m[2]:m[3]]
+			if d.layout == "Jan 2 2006" {
+				// "SEPTEMBER 15, 2025" becomes "Sep 15 2025".
+				f := strings.Fields(strings.ReplaceAll(s, ",", ""))
+				s = strings.ToUpper(f[0][:1]) + strings.ToLower(f[0][1:3]) + " " + f[1] + " " + f[2]
+			}
+			t, err := time.Parse(d.layout, s)
+			if err == nil && m[2] > pos {
+				found, pos = t, m[2]
+			}
+		}
+	}
+	return found, pos >= 0
+}
+
+// rateIn returns the first percentage in name.
+func rateIn(name string) float64 {
+	if m := rateInName.FindStringSubmatch(name); m != nil {
+		r, _ := strconv.ParseFloat(m[1], 64)
+		return r
+	}
+	return 0
+}
+
+// BuildLadder finds the maturity of each holding in snap, from overrides
+// or else, for fixed-income holdings, from the holding's name, and groups
+// them by the month they mature in.
+func BuildLadder(snap history.Snapshot, overrides portfolio.Overrides) (Ladder, error) {
+	l := Ladder{AsOf: snap.Time}
+	for _, h := range snap.Holdings {
+		name := cmp.Or(h.HoldingName, h.SecurityName)
+		lh := LadderHolding{Name: name, Account: h.AccountName, Value: h.Value}
+		if ov, ok := overrides.Lookup(h); ok && ov.Maturity != "" {
+			t, err := time.Parse(time.DateOnly, ov.Maturity)
+			if err != nil {
+				return Ladder{}, fmt.Errorf("override for %s: maturity %q: want YYYY-MM-DD", name, ov.Maturity)
+			}
+			lh.Maturity, lh.Rate = t, ov.Rate
+			if lh.Rate == 0 {
+				lh.Rate = rateIn(name)
+			}
+			l.Holdings = append(l.Holdings, lh)
+			continue
+		}
+		if !isFixedIncome(h) {
+			continue
+		}
+		t, ok := maturityInName(name)
+		if !ok && h.SecurityName != name {
+			t, ok = maturityInName(h.SecurityName)
+		}
+		if !ok {
+			l.Unknown = append(l.Unknown, h)
+			continue
+		}
+		lh.Maturity, lh.Rate = t, rateIn(name)
+		l.Holdings = append(l.Holdings, lh)
+	}
+	sort.SliceStable(l.Holdings, func(i, j int) bool {
+		return l.Holdings[i].Maturity.Before(l.Holdings[j].Maturity)
+	})
+	if len(l.Holdings) == 0 {
+		return l, nil
+	}
+
+	first, last := monthOf(l.Holdings[0].Maturity), monthOf(l.Holdings[len(l.Holdings)-1].Maturity)
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		l.Rungs = append(l.Rungs, LadderRung{Month: m})
+	}
+	for _, h := range l.Holdings {
+		m := monthOf(h.Maturity)
+		i := (m.Year()-first.Year())*12 + int(m.Month()-first.Month())
+		l.Rungs[i].Holdings = append(l.Rungs[i].Holdings, h)
+		l.Rungs[i].Value += h.Value
+	}
+	return l, nil
+}
+
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// WriteLadder renders the ladder by month and by holding, followed by
+// reminders to reinvest what matured before today or matures within
+// remindDays of it.
+func WriteLadder(w io.Writer, l Ladder, today time.Time, remindDays int) {
+	fmt.Fprintf(w, "Maturity ladder as of %s\n\n", l.AsOf.Format(time.DateOnly))
+	if len(l.Holdings) == 0 {
+		fmt.Fprintln(w, "No CDs, treasuries or bonds with a maturity date found.")
+	} else {
+		var total float64
+		for _, h := range l.Holdings {
+			total += h.Value
+		}
+		var rows [][]string
+		for _, r := range l.Rungs {
+			names := make([]string, len(r.Holdings))
+			for i, h := range r.Holdings {
+				names[i] = h.Name
+			}
+			share := "-"
+			if total != 0 && r.Value != 0 {
+				share = fmt.Sprintf("%.1f%%", r.Value/total*100)
+			}
+			rows = append(rows, []string{r.Month.Format("2006-01"), money(r.Value), share, strings.Join(names, "; ")})
+		}
+		WriteTable(w, []string{"month", "value", "share", "maturing"}, rows)
+
+		fmt.Fprintln(w)
+		rows = nil
+		for _, h := range l.Holdings {
+			rate := "-"
+			if h.Rate != 0 {
+				rate = strconv.FormatFloat(h.Rate, 'f', -1, 64) + "%"
+			}
+			rows = append(rows, []string{h.Maturity.Format(time.DateOnly), h.Name, h.Account, rate, money(h.Value)})
+		}
+		WriteTable(w, []string{"maturity", "holding", "account", "rate", "value"}, rows)
+	}
+
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	cutoff := day.AddDate(0, 0, remindDays)
+	var reminders []string
+	for _, h := range l.Holdings {
+		switch {
+		case h.Maturity.Before(day):
+			reminders = append(reminders, fmt.Sprintf("Reinvest %s in %s: matured %s (%s)", h.Name, h.Account, h.Maturity.Format(time.DateOnly), money(h.Value)))
+		case !h.Maturity.After(cutoff):
+			days := int(h.Maturity.Sub(day).Hours() / 24)
+			reminders = append(reminders, fmt.Sprintf("Reinvest %s in %s: matures %s, in %d days (%s)", h.Name, h.Account, h.Maturity.Format(time.DateOnly), days, money(h.Value)))
+		}
+	}
+	if len(reminders) > 0 {
+		fmt.Fprintln(w)
+		for _, r := range reminders {
+			fmt.Fprintln(w, r)
+		}
+	}
+	if len(l.Unknown) > 0 {
+		fmt.Fprintln(w, "\nNo maturity date found for these fixed-income holdings; add a \"maturity\" to their security overrides:")
+		for _, h := range l.Unknown {
+			fmt.Fprintf(w, "  %s (%s) in %s\n", cmp.Or(h.HoldingName, h.SecurityName), cmp.Or(h.SecurityID, h.Ticker), h.AccountName)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+)
+
+// Mover is the change in value of one holding or account between two
+// snapshots. Before is zero for new positions and After for closed ones.
+type Mover struct {
+	Name   string
+	Before float64
+	After  float64
+}
+
+// Change is the difference in value.
+func (m Mover) Change() float64 {
+	return m.After - m.Before
+}
+
+// PctChange is the relative change, or NaN if there was no value before.
+func (m Mover) PctChange() float64 {
+	if m.Before == 0 {
+		return math.NaN()
+	}
+	return m.Change() / math.Abs(m.Before)
+}
+
+// MoverSet holds the largest movers ranked two ways.
+type MoverSet struct {
+	ByChange  []Mover
+	ByPercent []Mover
+}
+
+// Movers finds the n holdings and n accounts whose value changed most
+// between prev and cur, by absolute amount and by percentage. Holdings and
+// accounts that didn't change are left out, as are new ones from the
+// percentage ranking. Accounts excluded from net worth in Monarch are
+// skipped unless includeExcluded is set.
+func Movers(prev, cur history.Snapshot, n int, includeExcluded bool) (holdings, accounts MoverSet) {
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = cur.ExcludedAccounts()
+	}
+
+	byHolding := make(map[string]*Mover)
+	addHoldings := func(snap history.Snapshot, after bool) {
+		for _, h := range snap.Holdings {
+			if excluded[h.AccountID] {
+				continue
+			}
+			id := h.RecordID()
+			m, ok := byHolding[id]
+			if !ok {
+				name := h.Ticker
+				if name == "" {
+					name = h.HoldingName
+				}
+				m = &Mover{Name: name + " (" + h.AccountName + ")"}
+				byHolding[id] = m
+			}
+			if after {
+				m.After += h.Value
+			} else {
+				m.Before += h.Value
+			}
+		}
+	}
+	addHoldings(prev, false)
+	addHoldings(cur, true)
+
+	byAccount := make(map[string]*Mover)
+	names := make(map[string]string)
+	for _, a := range prev.Accounts {
+		names[a.ID] = a.Name
+	}
+	for _, a := range cur.Accounts {
+		names[a.ID] = a.Name
+	}
+	for id, v := range prev.AccountValues() {
+		if !excluded[id] {
+			byAccount[id] = &Mover{Name: names[id], Before: v}
+		}
+	}
+	for id, v := range cur.AccountValues() {
+		if excluded[id] {
+			continue
+		}
+		if m, ok := byAccount[id]; ok {
+			m.After = v
+		} else {
+			byAccount[id] = &Mover{Name: names[id], After: v}
+		}
+	}
+	return rankMovers(byHolding, n), rankMovers(byAccount, n)
+}
+
+// changeEpsilon ignores changes below a cent.
+const changeEpsilon = 0.005
+
+func rankMovers(movers map[string]*Mover, n int) MoverSet {
+	var all []Mover
+	for _, m := range movers {
+		if math.Abs(m.Change()) >= changeEpsilon {
+			all = append(all, *m)
+		}
+	}
+	var set MoverSet
+	set.ByChange = topMovers(all, n, func(m Mover) float64 { return math.Abs(m.Change()) })
+	var priced []Mover
+	for _, m := range all {
+		if !math.IsNaN(m.PctChange()) {
+			priced = append(priced, m)
+		}
+	}
+	set.ByPercent = topMovers(priced, n, func(m Mover) float64 { return math.Abs(m.PctChange()) })
+	return set
+}
+
+// topMovers returns the n movers with the largest key, ties broken by name.
+func topMovers(movers []Mover, n int, key func(Mover) float64) []Mover {
+	sorted := append([]Mover(nil), movers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		ki, kj := key(sorted[i]), key(sorted[j])
+		if ki != kj {
+			return ki > kj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// WriteMovers renders the holding and account movers between two snapshot
+// times as tables.
+func WriteMovers(w io.Writer, from, to time.Time, holdings, accounts MoverSet) {
+	fmt.Fprintf(w, "Top movers %s → %s\n", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	sections := []struct {
+		title  string
+		movers []Mover
+	}{
+		{"Holdings by change", holdings.ByChange},
+		{"Holdings by percent", holdings.ByPercent},
+		{"Accounts by change", accounts.ByChange},
+		{"Accounts by percent", accounts.ByPercent},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(w, "\n%s\n\n", s.title)
+		if len(s.movers) == 0 {
+			fmt.Fprintln(w, "No changes.")
+			continue
+		}
+		table := make([][]string, len(s.movers))
+		for i, m := range s.movers {
+			table[i] = []string{m.Name, money(m.Before), money(m.After), signedMoney(m.Change()), signedPercent(m.PctChange())}
+		}
+		WriteTable(w, []string{"name", "before", "after", "change", "change_pct"}, table)
+	}
+}
+
+// MoversSummary describes the largest holding movers in one line for
+// notifications, e.g. "Top movers: AAPL (Brokerage) +950.00 (+19.87%)".
+// It is empty if nothing moved.
+func MoversSummary(holdings MoverSet) string {
+	if len(holdings.ByChange) == 0 {
+		return ""
+	}
+	parts := make([]string, len(holdings.ByChange))
+	for i, m := range holdings.ByChange {
+		parts[i] = fmt.Sprintf("%s %s", m.Name, signedMoney(m.Change()))
+		if pct := m.PctChange(); !math.IsNaN(pct) {
+			parts[i] += " (" + signedPercent(pct) + ")"
+		}
+	}
+	return "Top movers: " + strings.Join(parts, ", ")
+}
+
+func signedMoney(v float64) string {
+	return fmt.Sprintf("%+.2f", v)
+}
+
+func signedPercent(v float64) string {
+	if math.IsNaN(v) {
+		return "new"
+	}
+	return fmt.Sprintf("%+.2f%%", v*100)
+}
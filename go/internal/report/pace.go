@@ -0,0 +1,194 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/budget"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// minPaceShare is the share of a usual month's spending below which the
+// month to date says too little to scale it up; the rest of the month is
+// then expected to go as usual instead.
+const minPaceShare = 0.2
+
+// PaceRow is one expense category's spending this month so far and where
+// it is heading.
+type PaceRow struct {
+	CategoryID string
+	Category   string
+	Group      string
+	Spent      float64
+	// Share is the part of a month's spending that, in past months, had
+	// been spent by the same day of the month. It is zero without history.
+	Share float64
+	// Usual is the average spending of a past month.
+	Usual     float64
+	Projected float64
+	// Budget is zero for categories without a budget.
+	Budget float64
+}
+
+// OverBudget reports whether the category is on pace to exceed its budget.
+func (r PaceRow) OverBudget() bool {
+	return r.Budget > 0 && r.Projected > r.Budget+0.005
+}
+
+// Pace projects a month's spending per category.
+type Pace struct {
+	AsOf time.Time
+	// Day is AsOf's day of the month and Days the month's length.
+	Day, Days int
+	// Months is the number of past months the spending curves were
+	// learned from.
+	Months int
+	Rows   []PaceRow
+}
+
+// BuildPace projects the spending of the month containing asOf in each
+// expense category, from what was spent up to asOf and from how spending
+// was spread over the month in earlier months. txns may reach back any
+// number of whole months; later ones are ignored. Once the month to date
+// usually accounts for a fair part of a month's spending, the projection
+// scales it up by that share; before, it adds the usual rest of the month.
+// Expense categories budgeted in budgets are listed even if nothing was
+// spent.
+func BuildPace(txns []transactions.Transaction, asOf time.Time, budgets []budget.Line) Pace {
+	month := monthOf(asOf)
+	p := Pace{AsOf: asOf, Day: asOf.Day(), Days: daysIn(month)}
+
+	type spending struct {
+		row PaceRow
+		// total and byNow are by past month.
+		total, byNow map[time.Time]float64
+	}
+	cats := map[string]*spending{}
+	category := func(t transactions.Transaction) *spending {
+		s, ok := cats[t.Category.ID]
+		if !ok {
+			s = &spending{
+				row:   PaceRow{CategoryID: t.Category.ID, Category: t.Category.Name, Group: t.Category.Group.Name},
+				total: map[time.Time]float64{}, byNow: map[time.Time]float64{},
+			}
+			cats[t.Category.ID] = s
+		}
+		return s
+	}
+	months := map[time.Time]bool{}
+	for _, t := range txns {
+		if t.Category.Group.Type != transactions.GroupExpense || t.HideFromReports {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, t.Date)
+		if err != nil || date.After(asOf) {
+			continue
+		}
+		s := category(t)
+		m := monthOf(date)
+		if m.Equal(month) {
+			s.row.Spent -= t.Amount
+			continue
+		}
+		months[m] = true
+		s.total[m] -= t.Amount
+		// The same point of a shorter or longer month.
+		if date.Day() <= int(math.Round(float64(p.Day*daysIn(m))/float64(p.Days))) {
+			s.byNow[m] -= t.Amount
+		}
+	}
+	p.Months = len(months)
+
+	for _, l := range budgets {
+		if l.GroupType != transactions.GroupExpense || l.Planned <= 0 {
+			continue
+		}
+		s, ok := cats[l.CategoryID]
+		if !ok {
+			s = &spending{row: PaceRow{CategoryID: l.CategoryID, Category: l.CategoryName, Group: l.GroupName}}
+			cats[l.CategoryID] = s
+		}
+		s.row.Budget = l.Planned
+	}
+	for _, s := range cats {
+		r := s.row
+		var total, byNow float64
+		for m := range months {
+			total += s.total[m]
+			byNow += s.byNow[m]
+		}
+		if p.Months > 0 {
+			r.Usual = total / float64(p.Months)
+		}
+		if total > 0 {
+			r.Share = min(max(byNow/total, 0), 1)
+		}
+		if r.Share >= minPaceShare {
+			r.Projected = r.Spent / r.Share
+		} else {
+			r.Projected = r.Spent + (1-r.Share)*r.Usual
+		}
+		p.Rows = append(p.Rows, r)
+	}
+	sort.Slice(p.Rows, func(i, j int) bool {
+		if p.Rows[i].Projected != p.Rows[j].Projected {
+			return p.Rows[i].Projected > p.Rows[j].Projected
+		}
+		return p.Rows[i].Category < p.Rows[j].Category
+	})
+	return p
+}
+
+func daysIn(month time.Time) int {
+	return monthOf(month).AddDate(0, 1, -1).Day()
+}
+
+// WritePace renders the projection per category, then the categories on
+// pace to exceed their budget.
+func WritePace(w io.Writer, p Pace) {
+	fmt.Fprintf(w, "Spending pace for %s, day %d of %d", p.AsOf.Format("January 2006"), p.Day, p.Days)
+	if p.Months > 0 {
+		fmt.Fprintf(w, ", against %d earlier months", p.Months)
+	}
+	fmt.Fprint(w, "\n\n")
+	if len(p.Rows) == 0 {
+		fmt.Fprintln(w, "No spending this month or in earlier months.")
+		return
+	}
+
+	var rows [][]string
+	var spent, projected, budgeted float64
+	var over []PaceRow
+	for _, r := range p.Rows {
+		share, planned, status := "-", "-", ""
+		if r.Usual > 0 {
+			share = fmt.Sprintf("%.0f%%", r.Share*100)
+		}
+		if r.Budget > 0 {
+			planned = money(r.Budget)
+			budgeted += r.Budget
+		}
+		if r.OverBudget() {
+			status = "over budget"
+			over = append(over, r)
+		}
+		spent += r.Spent
+		projected += r.Projected
+		rows = append(rows, []string{r.Category, money(r.Spent), share, money(r.Projected), planned, status})
+	}
+	rows = append(rows, []string{"Total", money(spent), "", money(projected), money(budgeted), ""})
+	WriteTable(w, []string{"category", "spent", "usual by now", "projected", "budget", ""}, rows)
+
+	if len(over) == 0 {
+		fmt.Fprintln(w, "\nNo category is on pace to exceed its budget.")
+		return
+	}
+	fmt.Fprintln(w)
+	for _, r := range over {
+		fmt.Fprintf(w, "Warning: %s is on pace to spend %s of its %s budget (%s over)\n",
+			r.Category, money(r.Projected), money(r.Budget), money(r.Projected-r.Budget))
+	}
+}
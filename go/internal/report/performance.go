@@ -0,0 +1,198 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Grouping keys accepted by Performance.
+const (
+	ByAccount     = "account"
+	ByAccountType = "account-type"
+)
+
+// PerformanceRow is the time-weighted return of one account or account group.
+type PerformanceRow struct {
+	Group      string
+	StartValue float64
+	EndValue   float64
+	NetFlows   float64
+	Gain       float64
+	TWR        float64
+}
+
+// InvestmentAccountIDs returns the IDs of accounts that held securities in
+// any of the snapshots. Accounts excluded from net worth in Monarch are
+// skipped unless includeExcluded is set.
+func InvestmentAccountIDs(snaps []history.Snapshot, includeExcluded bool) []string {
+	seen := make(map[string]bool)
+	if !includeExcluded && len(snaps) > 0 {
+		// Marking excluded accounts as seen keeps them out of the result.
+		for id := range snaps[len(snaps)-1].ExcludedAccounts() {
+			seen[id] = true
+		}
+	}
+	var ids []string
+	for _, snap := range snaps {
+		for _, h := range snap.Holdings {
+			if !seen[h.AccountID] {
+				seen[h.AccountID] = true
+				ids = append(ids, h.AccountID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Performance computes time-weighted returns for investment accounts across
+// consecutive snapshots, grouped by account or account type. Each period's
+// return uses the Modified Dietz method so that contributions and
+// withdrawals (transfer transactions) are not counted as gains. A "Total"
+// row covering all investment accounts is appended.
+func Performance(snaps []history.Snapshot, txns []transactions.Transaction, by string, includeExcluded bool) ([]PerformanceRow, error) {
+	if by != ByAccount && by != ByAccountType {
+		return nil, fmt.Errorf("unknown grouping %q: want %s or %s", by, ByAccount, ByAccountType)
+	}
+	if len(snaps) < 2 {
+		return nil, fmt.Errorf("need at least two snapshots, have %d", len(snaps))
+	}
+
+	groupOf := groupFunc(snaps, by)
+	ids := InvestmentAccountIDs(snaps, includeExcluded)
+
+	var flows []transactions.Transaction
+	for _, t := range txns {
+		if t.IsTransfer() && !t.Pending {
+			flows = append(flows, t)
+		}
+	}
+
+	groups := make(map[string][]string)
+	var names []string
+	for _, id := range ids {
+		g := groupOf(id)
+		if _, ok := groups[g]; !ok {
+			names = append(names, g)
+		}
+		groups[g] = append(groups[g], id)
+	}
+	sort.Strings(names)
+
+	var rows []PerformanceRow
+	for _, name := range names {
+		rows = append(rows, groupPerformance(name, groups[name], snaps, flows))
+	}
+	rows = append(rows, groupPerformance("Total", ids, snaps, flows))
+	return rows, nil
+}
+
+// groupPerformance chains per-period Modified Dietz returns for a set of
+// accounts. Only accounts present in both snapshots of a period contribute
+// to it, so newly linked accounts don't register as gains.
+func groupPerformance(name string, ids []string, snaps []history.Snapshot, flows []transactions.Transaction) PerformanceRow {
+	member := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		member[id] = true
+	}
+	row := PerformanceRow{Group: name}
+	for id, v := range snaps[0].AccountValues() {
+		if member[id] {
+			row.StartValue += v
+		}
+	}
+	for id, v := range snaps[len(snaps)-1].AccountValues() {
+		if member[id] {
+			row.EndValue += v
+		}
+	}
+
+	growth := 1.0
+	for i := 1; i < len(snaps); i++ {
+		prev, cur := snaps[i-1], snaps[i]
+		v0s, v1s := prev.AccountValues(), cur.AccountValues()
+		var v0, v1 float64
+		active := make(map[string]bool)
+		for id := range member {
+			a, okA := v0s[id]
+			b, okB := v1s[id]
+			if okA && okB {
+				v0 += a
+				v1 += b
+				active[id] = true
+			}
+		}
+
+		span := cur.Time.Sub(prev.Time).Seconds()
+		var net, weighted float64
+		for _, f := range flows {
+			if !active[f.Account.ID] {
+				continue
+			}
+			t := f.Time()
+			if !t.After(prev.Time) || t.After(cur.Time) {
+				continue
+			}
+			w := 1.0
+			if span > 0 {
+				w = cur.Time.Sub(t).Seconds() / span
+			}
+			net += f.Amount
+			weighted += w * f.Amount
+		}
+		row.NetFlows += net
+
+		denom := v0 + weighted
+		if denom <= 0 {
+			continue
+		}
+		growth *= 1 + (v1-v0-net)/denom
+	}
+	row.TWR = growth - 1
+	row.Gain = row.EndValue - row.StartValue - row.NetFlows
+	return row
+}
+
+// groupFunc maps an account ID to its display group, using the most recent
+// snapshot that knows about the account.
+func groupFunc(snaps []history.Snapshot, by string) func(string) string {
+	return func(id string) string {
+		for i := len(snaps) - 1; i >= 0; i-- {
+			if a, ok := snaps[i].Account(id); ok {
+				if by == ByAccount {
+					return a.Name
+				}
+				if a.SubtypeDisplay != "" {
+					return a.SubtypeDisplay
+				}
+				return a.TypeDisplay
+			}
+		}
+		if by == ByAccount {
+			for i := len(snaps) - 1; i >= 0; i-- {
+				for _, h := range snaps[i].Holdings {
+					if h.AccountID == id {
+						return h.AccountName
+					}
+				}
+			}
+			return id
+		}
+		return "Unknown"
+	}
+}
+
+// WritePerformance renders performance rows as a table.
+func WritePerformance(w io.Writer, rows []PerformanceRow, from, to time.Time) {
+	fmt.Fprintf(w, "Performance %s → %s\n\n", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	table := make([][]string, len(rows))
+	for i, r := range rows {
+		table[i] = []string{r.Group, money(r.StartValue), money(r.EndValue), money(r.NetFlows), money(r.Gain), percent(r.TWR)}
+	}
+	WriteTable(w, []string{"group", "start_value", "end_value", "net_flows", "gain", "twr"}, table)
+}
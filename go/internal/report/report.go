@@ -0,0 +1,75 @@
+// Package report computes analyses over stored snapshots and transactions and
+// renders them as plain-text tables.
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteTable writes rows as a Markdown table with the given headers.
+func WriteTable(w io.Writer, headers []string, rows [][]string) {
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	printRow := func(cells []string) {
+		fmt.Fprint(w, "|")
+		for i, cell := range cells {
+			fmt.Fprintf(w, " %-*s |", widths[i], cell)
+		}
+		fmt.Fprintln(w)
+	}
+
+	printRow(headers)
+	fmt.Fprint(w, "|")
+	for _, width := range widths {
+		fmt.Fprintf(w, " %s |", strings.Repeat("-", width))
+	}
+	fmt.Fprintln(w)
+	for _, row := range rows {
+		printRow(row)
+	}
+}
+
+// ParseRange parses a lookback like "30d", "12m", "2y" or "all" and returns
+// the start time relative to now. "all" returns the zero time.
+func ParseRange(s string, now time.Time) (time.Time, error) {
+	if s == "" || s == "all" {
+		return time.Time{}, nil
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return time.Time{}, fmt.Errorf("invalid range %q: want e.g. 30d, 12m, 2y or all", s)
+	}
+	switch s[len(s)-1] {
+	case 'd':
+		return now.AddDate(0, 0, -n), nil
+	case 'w':
+		return now.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return now.AddDate(0, -n, 0), nil
+	case 'y':
+		return now.AddDate(-n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid range %q: want e.g. 30d, 12m, 2y or all", s)
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
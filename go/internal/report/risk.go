@@ -0,0 +1,154 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+)
+
+// RiskRow holds the risk statistics of one account, or of all investment
+// accounts in the "Total" row.
+type RiskRow struct {
+	Group string
+	// Periods is the number of snapshot-to-snapshot returns used.
+	Periods int
+	// Volatility is the annualized standard deviation of period returns,
+	// or NaN with fewer than two periods.
+	Volatility float64
+	// MaxDrawdown and CurrentDrawdown are the largest and the latest fall
+	// from a previous peak, as positive fractions.
+	MaxDrawdown     float64
+	CurrentDrawdown float64
+}
+
+// Risk computes realized volatility and drawdowns per investment account
+// and in total across consecutive snapshots. Returns come from price
+// changes of the positions held at the start of each period, so deposits,
+// withdrawals and trades don't count as gains or losses. Accounts excluded
+// from net worth in Monarch are skipped unless includeExcluded is set.
+func Risk(snaps []history.Snapshot, includeExcluded bool) ([]RiskRow, error) {
+	if len(snaps) < 2 {
+		return nil, fmt.Errorf("need at least two snapshots, have %d", len(snaps))
+	}
+	ids := InvestmentAccountIDs(snaps, includeExcluded)
+	groupOf := groupFunc(snaps, ByAccount)
+	periodsPerYear := periodsPerYear(snaps)
+
+	var rows []RiskRow
+	for _, id := range ids {
+		rows = append(rows, riskRow(groupOf(id), []string{id}, snaps, periodsPerYear))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Group < rows[j].Group })
+	rows = append(rows, riskRow("Total", ids, snaps, periodsPerYear))
+	return rows, nil
+}
+
+func riskRow(name string, ids []string, snaps []history.Snapshot, periodsPerYear float64) RiskRow {
+	member := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		member[id] = true
+	}
+	var returns []float64
+	for i := 1; i < len(snaps); i++ {
+		if r, ok := priceReturn(snaps[i-1], snaps[i], member); ok {
+			returns = append(returns, r)
+		}
+	}
+
+	row := RiskRow{Group: name, Periods: len(returns), Volatility: math.NaN()}
+	if len(returns) >= 2 {
+		row.Volatility = stddev(returns) * math.Sqrt(periodsPerYear)
+	}
+	index, peak := 1.0, 1.0
+	for _, r := range returns {
+		index *= 1 + r
+		peak = math.Max(peak, index)
+		row.CurrentDrawdown = 1 - index/peak
+		row.MaxDrawdown = math.Max(row.MaxDrawdown, row.CurrentDrawdown)
+	}
+	return row
+}
+
+// priceReturn is the return of the member accounts' positions at the start
+// of a period, valued at the prices of its end. Positions that are unpriced
+// or gone by the end are left out. It reports false if nothing was held.
+func priceReturn(prev, cur history.Snapshot, member map[string]bool) (float64, bool) {
+	prices := make(map[string]float64)
+	for _, h := range cur.Holdings {
+		if member[h.AccountID] {
+			if p, ok := unitPrice(h); ok {
+				prices[h.RecordID()] = p
+			}
+		}
+	}
+	var start, end float64
+	for _, h := range prev.Holdings {
+		if !member[h.AccountID] {
+			continue
+		}
+		p0, ok0 := unitPrice(h)
+		p1, ok1 := prices[h.RecordID()]
+		if !ok0 || !ok1 {
+			continue
+		}
+		start += h.Quantity * p0
+		end += h.Quantity * p1
+	}
+	if start <= 0 {
+		return 0, false
+	}
+	return end/start - 1, true
+}
+
+// unitPrice is the price per share the holding was valued at.
+func unitPrice(h portfolio.HoldingRecord) (float64, bool) {
+	if h.Quantity == 0 || h.Value == 0 {
+		return 0, false
+	}
+	return h.Value / h.Quantity, true
+}
+
+// periodsPerYear estimates how many snapshot periods make a year from the
+// average spacing of the snapshots.
+func periodsPerYear(snaps []history.Snapshot) float64 {
+	span := snaps[len(snaps)-1].Time.Sub(snaps[0].Time)
+	if span <= 0 {
+		return 1
+	}
+	avg := span / time.Duration(len(snaps)-1)
+	return 365.25 * 24 * float64(time.Hour) / float64(avg)
+}
+
+// stddev is the sample standard deviation of xs.
+func stddev(xs []float64) float64 {
+	var mean float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// WriteRisk renders risk rows for the window label as a table.
+func WriteRisk(w io.Writer, label string, from, to time.Time, rows []RiskRow) {
+	fmt.Fprintf(w, "Risk over %s (%s → %s)\n\n", label, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	table := make([][]string, len(rows))
+	for i, r := range rows {
+		vol := "n/a"
+		if !math.IsNaN(r.Volatility) {
+			vol = percent(r.Volatility)
+		}
+		table[i] = []string{r.Group, strconv.Itoa(r.Periods), vol, percent(r.MaxDrawdown), percent(r.CurrentDrawdown)}
+	}
+	WriteTable(w, []string{"group", "periods", "volatility", "max_drawdown", "current_drawdown"}, table)
+}
@@ -0,0 +1,170 @@
+// Package secrets reads the login credentials from a secrets manager, for
+// server deployments that keep no credentials on disk. The source is a URL:
+// "vault://mount/path" reads a HashiCorp Vault KV secret and
+// "awssm://name" an AWS Secrets Manager secret.
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/awsauth"
+)
+
+// Provider fetches a secret holding the credentials JSON object.
+type Provider interface {
+	Fetch() ([]byte, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Open returns the provider for source.
+func Open(source string) (Provider, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("credentials source: %w", err)
+	}
+	name := strings.Trim(u.Host+u.Path, "/")
+	switch u.Scheme {
+	case "vault":
+		mount, path, _ := strings.Cut(name, "/")
+		if mount == "" || path == "" {
+			return nil, fmt.Errorf("credentials source %s: want vault://mount/path", source)
+		}
+		return newVault(mount, path, u.Query().Get("kv") == "1")
+	case "awssm":
+		if name == "" {
+			return nil, fmt.Errorf("credentials source %s: want awssm://secret-name", source)
+		}
+		return newAWSSecretsManager(name)
+	}
+	return nil, fmt.Errorf("unknown credentials source %q: want vault:// or awssm://", source)
+}
+
+// vault reads a secret from a HashiCorp Vault KV engine at VAULT_ADDR with
+// VAULT_TOKEN, and VAULT_NAMESPACE on Vault Enterprise. The secret's keys
+// are the credentials' fields. Version 2 of the engine is assumed unless
+// the source ends in ?kv=1.
+type vault struct {
+	url       string
+	token     string
+	namespace string
+	v1        bool
+}
+
+func newVault(mount, path string, v1 bool) (*vault, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("vault credentials need VAULT_ADDR and VAULT_TOKEN")
+	}
+	u := strings.TrimRight(addr, "/") + "/v1/" + mount + "/"
+	if !v1 {
+		u += "data/"
+	}
+	return &vault{url: u + path, token: token, namespace: os.Getenv("VAULT_NAMESPACE"), v1: v1}, nil
+}
+
+func (v *vault) Fetch() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	body, err := do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", v.url, err)
+	}
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("vault %s: %w", v.url, err)
+	}
+	if v.v1 {
+		return resp.Data, nil
+	}
+	var kv2 struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Data, &kv2); err != nil {
+		return nil, fmt.Errorf("vault %s: %w", v.url, err)
+	}
+	return kv2.Data, nil
+}
+
+// awsSecretsManager reads the string value of a secret from AWS Secrets
+// Manager, with credentials and region from the standard AWS_* variables.
+// AWS_ENDPOINT_URL_SECRETS_MANAGER selects another endpoint, such as
+// LocalStack.
+type awsSecretsManager struct {
+	name     string
+	endpoint string
+	creds    awsauth.Credentials
+}
+
+func newAWSSecretsManager(name string) (*awsSecretsManager, error) {
+	creds, err := awsauth.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("AWS Secrets Manager %w", err)
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + creds.Region + ".amazonaws.com/"
+	}
+	return &awsSecretsManager{name: name, endpoint: endpoint, creds: creds}, nil
+}
+
+func (s *awsSecretsManager) Fetch() ([]byte, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": s.name})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	s.creds.Sign(req, payload, "secretsmanager", time.Now().UTC())
+	body, err := do(req)
+	if err != nil {
+		return nil, fmt.Errorf("AWS Secrets Manager %s: %w", s.name, err)
+	}
+	var resp struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("AWS Secrets Manager %s: %w", s.name, err)
+	}
+	if resp.SecretString == nil {
+		return nil, fmt.Errorf("AWS Secrets Manager %s: secret has no string value", s.name)
+	}
+	return []byte(*resp.SecretString), nil
+}
+
+// do sends req and returns the body of a 2xx response.
+func do(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,137 @@
+// Package sign makes detached signatures for exported files, so that
+// recipients can check that files came unmodified from the signer. SSH keys
+// produce signatures verifiable with "ssh-keygen -Y verify"; minisign keys
+// are handed to the minisign tool.
+package sign
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Namespace is the SSH signature namespace, as passed to
+// "ssh-keygen -Y verify -n".
+const Namespace = "file"
+
+// Signer makes detached signatures.
+type Signer interface {
+	// Sign returns the signature of data.
+	Sign(data []byte) ([]byte, error)
+	// Ext is the suffix of signature files, e.g. ".sig".
+	Ext() string
+}
+
+// Load reads the secret key at path. An encrypted SSH key is unlocked with
+// the passphrase in MONARCH_SIGN_PASSPHRASE.
+func Load(path string) (Signer, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(key, []byte("minisign")) {
+		return minisign{key: path}, nil
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		pass := os.Getenv("MONARCH_SIGN_PASSPHRASE")
+		if pass == "" {
+			return nil, fmt.Errorf("%s is encrypted; set MONARCH_SIGN_PASSPHRASE", path)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(pass))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+	return sshSigner{signer}, nil
+}
+
+// sshSigner makes SSHSIG signatures, the format of "ssh-keygen -Y sign".
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+func (s sshSigner) Ext() string { return ".sig" }
+
+func (s sshSigner) Sign(data []byte) ([]byte, error) {
+	const hashAlg = "sha512"
+	digest := sha512.Sum512(data)
+	signed := []byte("SSHSIG")
+	signed = appendString(signed, []byte(Namespace))
+	signed = appendString(signed, nil)
+	signed = appendString(signed, []byte(hashAlg))
+	signed = appendString(signed, digest[:])
+
+	var sig *ssh.Signature
+	var err error
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, signed, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, signed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blob := []byte("SSHSIG")
+	blob = binary.BigEndian.AppendUint32(blob, 1)
+	blob = appendString(blob, s.signer.PublicKey().Marshal())
+	blob = appendString(blob, []byte(Namespace))
+	blob = appendString(blob, nil)
+	blob = appendString(blob, []byte(hashAlg))
+	blob = appendString(blob, ssh.Marshal(sig))
+
+	var out strings.Builder
+	out.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	enc := base64.StdEncoding.EncodeToString(blob)
+	for len(enc) > 70 {
+		out.WriteString(enc[:70] + "\n")
+		enc = enc[70:]
+	}
+	out.WriteString(enc + "\n-----END SSH SIGNATURE-----\n")
+	return []byte(out.String()), nil
+}
+
+func appendString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// minisign signs with the minisign tool, which prompts for the key's
+// password itself.
+type minisign struct {
+	key string
+}
+
+func (m minisign) Ext() string { return ".minisig" }
+
+func (m minisign) Sign(data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "monarch-sign")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "data")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("minisign", "-S", "-s", m.key, "-m", file, "-x", file+".minisig")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("minisign: %w", err)
+	}
+	return os.ReadFile(file + ".minisig")
+}
@@ -0,0 +1,177 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// TestSSHSign signs with each kind of SSH key, then parses and verifies the
+// signature, and has ssh-keygen check it if it is installed.
+func TestSSHSign(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("date,account,value\n2025-03-31,Brokerage,1000.00\n")
+	for _, tc := range []struct {
+		name, passphrase string
+		key              crypto.PrivateKey
+		format           string
+	}{
+		{"ed25519", "", edKey, ssh.KeyAlgoED25519},
+		{"ecdsa", "", ecKey, ssh.KeyAlgoECDSA256},
+		{"rsa", "", rsaKey, ssh.KeyAlgoRSASHA512},
+		{"encrypted", "open sesame", edKey, ssh.KeyAlgoED25519},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var block *pem.Block
+			var err error
+			if tc.passphrase != "" {
+				block, err = ssh.MarshalPrivateKeyWithPassphrase(tc.key, "", []byte(tc.passphrase))
+			} else {
+				block, err = ssh.MarshalPrivateKey(tc.key, "")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "id")
+			if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("MONARCH_SIGN_PASSPHRASE", tc.passphrase)
+			s, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			armored, err := s.Sign(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub := verifySSHSIG(t, armored, data, tc.format)
+			if signer, _ := ssh.NewSignerFromKey(tc.key); !bytes.Equal(pub.Marshal(), signer.PublicKey().Marshal()) {
+				t.Error("signature carries another public key")
+			}
+			if err := verifySSHSIGError(armored, []byte("tampered")); err == nil {
+				t.Error("signature verifies other data")
+			}
+
+			keygen, err := exec.LookPath("ssh-keygen")
+			if err != nil {
+				return
+			}
+			sigPath := filepath.Join(dir, "data.sig")
+			if err := os.WriteFile(sigPath, armored, 0600); err != nil {
+				t.Fatal(err)
+			}
+			check := func(data []byte) ([]byte, error) {
+				cmd := exec.Command(keygen, "-Y", "check-novalidate", "-n", Namespace, "-s", sigPath)
+				cmd.Stdin = bytes.NewReader(data)
+				return cmd.CombinedOutput()
+			}
+			if out, err := check(data); err != nil {
+				t.Errorf("ssh-keygen -Y check-novalidate: %v\n%s", err, out)
+			}
+			if _, err := check([]byte("tampered")); err == nil {
+				t.Error("ssh-keygen accepted the signature for other data")
+			}
+		})
+	}
+}
+
+// sshsig is the blob of an SSH signature, following its "SSHSIG" magic.
+type sshsig struct {
+	Version   uint32
+	PublicKey []byte
+	Namespace string
+	Reserved  string
+	HashAlg   string
+	Signature []byte
+}
+
+// verifySSHSIG parses an armored SSH signature, checks it is a signature
+// of data in Namespace with the given format, and returns its public key.
+func verifySSHSIG(t *testing.T, armored, data []byte, format string) ssh.PublicKey {
+	t.Helper()
+	blob, sig, pub, err := parseSSHSIG(armored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.Version != 1 || blob.Namespace != Namespace || blob.Reserved != "" || blob.HashAlg != "sha512" {
+		t.Errorf("got version %d, namespace %q, reserved %q, hash %q", blob.Version, blob.Namespace, blob.Reserved, blob.HashAlg)
+	}
+	if sig.Format != format {
+		t.Errorf("got signature format %s, want %s", sig.Format, format)
+	}
+	if err := verifySSHSIGError(armored, data); err != nil {
+		t.Error(err)
+	}
+	return pub
+}
+
+// verifySSHSIGError verifies an armored SSH signature of data.
+func verifySSHSIGError(armored, data []byte) error {
+	blob, sig, pub, err := parseSSHSIG(armored)
+	if err != nil {
+		return err
+	}
+	digest := sha512.Sum512(data)
+	signed := ssh.Marshal(struct {
+		Namespace, Reserved, HashAlg string
+		Digest                       []byte
+	}{blob.Namespace, blob.Reserved, blob.HashAlg, digest[:]})
+	return pub.Verify(append([]byte("SSHSIG"), signed...), sig)
+}
+
+func parseSSHSIG(armored []byte) (sshsig, *ssh.Signature, ssh.PublicKey, error) {
+	var blob sshsig
+	text := strings.TrimSpace(string(armored))
+	body, ok := strings.CutPrefix(text, "-----BEGIN SSH SIGNATURE-----\n")
+	body, ok2 := strings.CutSuffix(body, "\n-----END SSH SIGNATURE-----")
+	if !ok || !ok2 {
+		return blob, nil, nil, fmt.Errorf("not an armored SSH signature:\n%s", armored)
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if len(line) > 70 {
+			return blob, nil, nil, fmt.Errorf("armor line of %d characters", len(line))
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\n", ""))
+	if err != nil {
+		return blob, nil, nil, err
+	}
+	rest, ok := bytes.CutPrefix(raw, []byte("SSHSIG"))
+	if !ok {
+		return blob, nil, nil, fmt.Errorf("no SSHSIG magic")
+	}
+	if err := ssh.Unmarshal(rest, &blob); err != nil {
+		return blob, nil, nil, err
+	}
+	pub, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return blob, nil, nil, err
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(blob.Signature, &sig); err != nil {
+		return blob, nil, nil, err
+	}
+	return blob, &sig, pub, nil
+}
@@ -0,0 +1,255 @@
+// Package simulate projects the portfolio forward under random market
+// returns.
+package simulate
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/report"
+)
+
+// Asset classes of the allocation.
+const (
+	Stocks = portfolio.ClassStocks
+	Bonds  = portfolio.ClassBonds
+	Cash   = portfolio.ClassCash
+)
+
+// Classes lists the asset classes in display order.
+var Classes = []string{Stocks, Bonds, Cash}
+
+// Assumption is the expected annual real (after inflation) return of an
+// asset class and its standard deviation, as fractions.
+type Assumption struct {
+	Return     float64
+	Volatility float64
+}
+
+// DefaultAssumptions are long-run historical figures for US markets since
+// 1926, after inflation.
+var DefaultAssumptions = map[string]Assumption{
+	Stocks: {Return: 0.07, Volatility: 0.17},
+	Bonds:  {Return: 0.025, Volatility: 0.06},
+	Cash:   {Return: 0.005, Volatility: 0.01},
+}
+
+// Allocation is the value held in each asset class.
+type Allocation map[string]float64
+
+// Total is the value across all classes.
+func (a Allocation) Total() float64 {
+	var total float64
+	for _, v := range a {
+		total += v
+	}
+	return total
+}
+
+// CurrentAllocation splits the holdings of snap into asset classes, as
+// sorted by CashTickers.AssetClass. Accounts excluded from net worth in Monarch are skipped unless
+// includeExcluded is set.
+func CurrentAllocation(snap history.Snapshot, cash portfolio.CashTickers, includeExcluded bool) Allocation {
+	excluded := map[string]bool{}
+	if !includeExcluded {
+		excluded = snap.ExcludedAccounts()
+	}
+	alloc := Allocation{}
+	for _, h := range snap.Holdings {
+		if excluded[h.AccountID] {
+			continue
+		}
+		alloc[cash.AssetClass(h)] += h.Value
+	}
+	return alloc
+}
+
+// Income is a yearly income besides the portfolio, such as Social
+// Security or a pension, that reduces the withdrawals while it is paid.
+type Income struct {
+	Name   string
+	Amount float64
+	// Start and End are the first and last calendar years paid; zero
+	// means already paying and for life, respectively.
+	Start, End int
+	// Indexed incomes keep their value in today's money; others are fixed
+	// nominal amounts that lose value to inflation.
+	Indexed bool
+}
+
+// Retirement describes a retirement to simulate.
+type Retirement struct {
+	Allocation  Allocation
+	Assumptions map[string]Assumption
+	// Spend is withdrawn at the start of each year, in today's money.
+	Spend float64
+	Years int
+	Runs  int
+	Seed  uint64
+	// Income is paid towards the spending from Year, the calendar year of
+	// the first simulated year; Inflation devalues the incomes that are not
+	// indexed.
+	Income    []Income
+	Year      int
+	Inflation float64
+}
+
+// incomeIn is the income paid in the simulated year i (0 for the first),
+// in today's money.
+func (r Retirement) incomeIn(i int) float64 {
+	year := r.Year + i
+	var total float64
+	for _, inc := range r.Income {
+		if (inc.Start > 0 && year < inc.Start) || (inc.End > 0 && year > inc.End) {
+			continue
+		}
+		if inc.Indexed {
+			total += inc.Amount
+		} else {
+			total += inc.Amount / math.Pow(1+r.Inflation, float64(i))
+		}
+	}
+	return total
+}
+
+// Percentiles of the ending balance reported in a Result.
+var Percentiles = []int{10, 25, 50, 75, 90}
+
+// Result summarizes the runs of a simulation.
+type Result struct {
+	Runs int
+	// Success is the fraction of runs whose money lasted all years.
+	Success float64
+	// Ending holds the ending balance, in today's money, at each of
+	// Percentiles; depleted runs end at zero.
+	Ending []float64
+	// MedianLasted is the median number of years the failed runs could
+	// pay for, if any failed.
+	MedianLasted int
+}
+
+// Run simulates r.Runs retirements. Each year the spending less any income
+// is withdrawn (or income beyond it invested), every asset class earns a normally distributed return drawn
+// independently from its assumption, and the portfolio is rebalanced to
+// the starting allocation. A run fails in the year the portfolio can't
+// cover the spending. The same seed gives the same result.
+func (r Retirement) Run() (Result, error) {
+	start := r.Allocation.Total()
+	if start <= 0 {
+		return Result{}, fmt.Errorf("nothing to simulate: the portfolio is empty")
+	}
+	if r.Years < 1 || r.Runs < 1 {
+		return Result{}, fmt.Errorf("years and runs must be at least 1")
+	}
+	weights := make(map[string]float64, len(r.Allocation))
+	for class, v := range r.Allocation {
+		if _, ok := r.Assumptions[class]; !ok {
+			return Result{}, fmt.Errorf("no return assumption for %s", class)
+		}
+		weights[class] = v / start
+	}
+	classes := make([]string, 0, len(weights))
+	for _, class := range Classes {
+		if _, ok := weights[class]; ok {
+			classes = append(classes, class)
+		}
+	}
+
+	withdrawals := make([]float64, r.Years)
+	for i := range withdrawals {
+		withdrawals[i] = r.Spend - r.incomeIn(i)
+	}
+
+	rng := rand.New(rand.NewPCG(r.Seed, r.Seed))
+	endings := make([]float64, r.Runs)
+	var depletions []int
+	for run := range endings {
+		balance := start
+		for year := 1; year <= r.Years; year++ {
+			if balance < withdrawals[year-1] {
+				balance = 0
+				depletions = append(depletions, year-1)
+				break
+			}
+			balance -= withdrawals[year-1]
+			var growth float64
+			for _, class := range classes {
+				a := r.Assumptions[class]
+				ret := math.Max(a.Return+a.Volatility*rng.NormFloat64(), -1)
+				growth += weights[class] * ret
+			}
+			balance *= 1 + growth
+		}
+		endings[run] = balance
+	}
+
+	sort.Float64s(endings)
+	res := Result{Runs: r.Runs, Success: 1 - float64(len(depletions))/float64(r.Runs)}
+	for _, p := range Percentiles {
+		res.Ending = append(res.Ending, endings[percentileIndex(p, len(endings))])
+	}
+	if len(depletions) > 0 {
+		sort.Ints(depletions)
+		res.MedianLasted = depletions[percentileIndex(50, len(depletions))]
+	}
+	return res, nil
+}
+
+// percentileIndex is the index of the p-th percentile in n sorted values.
+func percentileIndex(p, n int) int {
+	return min(p*n/100, n-1)
+}
+
+// WriteRetirement renders the setup and result of a simulation.
+func WriteRetirement(w io.Writer, r Retirement, res Result) {
+	total := r.Allocation.Total()
+	fmt.Fprintf(w, "Retirement simulation: %d runs over %d years\n\n", res.Runs, r.Years)
+	fmt.Fprintf(w, "Starting balance %.2f, spending %.2f a year (in today's money)\n\n", total, r.Spend)
+	var rows [][]string
+	for _, class := range Classes {
+		v, ok := r.Allocation[class]
+		if !ok {
+			continue
+		}
+		a := r.Assumptions[class]
+		rows = append(rows, []string{class, fmt.Sprintf("%.2f", v), pct(v / total), pct(a.Return), pct(a.Volatility)})
+	}
+	report.WriteTable(w, []string{"class", "value", "weight", "real_return", "volatility"}, rows)
+
+	if len(r.Income) > 0 {
+		fmt.Fprintf(w, "\nIncome (fixed amounts lose %s a year to inflation):\n\n", pct(r.Inflation))
+		rows = nil
+		for _, inc := range r.Income {
+			rows = append(rows, []string{inc.Name, fmt.Sprintf("%.2f", inc.Amount), yearOrDash(inc.Start), yearOrDash(inc.End), strconv.FormatBool(inc.Indexed)})
+		}
+		report.WriteTable(w, []string{"income", "amount", "from", "until", "indexed"}, rows)
+	}
+
+	fmt.Fprintf(w, "\nSuccess probability: %s\n", pct(res.Success))
+	if res.Success < 1 {
+		fmt.Fprintf(w, "Failed runs paid for a median of %d years.\n", res.MedianLasted)
+	}
+	fmt.Fprintln(w)
+	rows = nil
+	for i, p := range Percentiles {
+		rows = append(rows, []string{strconv.Itoa(p) + "th", fmt.Sprintf("%.2f", res.Ending[i])})
+	}
+	report.WriteTable(w, []string{"percentile", "ending_balance"}, rows)
+}
+
+func yearOrDash(year int) string {
+	if year == 0 {
+		return "-"
+	}
+	return strconv.Itoa(year)
+}
+
+func pct(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
@@ -0,0 +1,199 @@
+// Package sinking tracks sinking funds: money set aside month by month for
+// large expenses that come due once or twice a year, such as insurance
+// premiums or property taxes.
+package sinking
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/heikofkoehler/monarch/internal/report"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Item is an irregular expense saved up for.
+type Item struct {
+	Name   string  `yaml:"name"`
+	Amount float64 `yaml:"amount"`
+	// Every is how often the expense is due: annual, semiannual,
+	// quarterly or monthly. The default is annual.
+	Every string `yaml:"every"`
+	// Due is a date the expense is due, YYYY-MM-DD; later due dates
+	// follow from Every.
+	Due string `yaml:"due"`
+	// Tag marks the transfers setting money aside for the expense. The
+	// default is the item's name.
+	Tag string `yaml:"tag"`
+}
+
+// Plan is a sinking fund configuration.
+type Plan struct {
+	Items []Item `yaml:"items"`
+}
+
+// periods maps the choices of Item.Every to months.
+var periods = map[string]int{"annual": 12, "yearly": 12, "semiannual": 6, "quarterly": 3, "monthly": 1}
+
+// Load reads and checks the plan at path.
+func Load(path string) (Plan, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, err
+	}
+	var p Plan
+	if err := yaml.Unmarshal(raw, &p); err != nil {
+		return Plan{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(p.Items) == 0 {
+		return Plan{}, fmt.Errorf("%s: no items", path)
+	}
+	for i, it := range p.Items {
+		if it.Name == "" {
+			return Plan{}, fmt.Errorf("%s: item %d has no name", path, i+1)
+		}
+		if it.Amount <= 0 {
+			return Plan{}, fmt.Errorf("%s: %s: amount must be positive", path, it.Name)
+		}
+		if _, ok := periods[strings.ToLower(it.Every)]; !ok && it.Every != "" {
+			return Plan{}, fmt.Errorf("%s: %s: every %q: want annual, semiannual, quarterly or monthly", path, it.Name, it.Every)
+		}
+		if _, err := time.Parse(time.DateOnly, it.Due); err != nil {
+			return Plan{}, fmt.Errorf("%s: %s: due %q: want YYYY-MM-DD", path, it.Name, it.Due)
+		}
+	}
+	return p, nil
+}
+
+// Months is the number of months between due dates.
+func (it Item) Months() int {
+	if m, ok := periods[strings.ToLower(it.Every)]; ok {
+		return m
+	}
+	return 12
+}
+
+// Status is the progress of an item in its current period.
+type Status struct {
+	Item Item
+	// Start is the previous due date, from which set-asides count, and
+	// Due the next one on or after today.
+	Start, Due time.Time
+	Saved      float64
+	// Target is what would be set aside by today at an even pace.
+	Target float64
+	// Monthly is what is still needed per month until Due.
+	Monthly float64
+}
+
+// Funded is the share of the amount saved, in percent.
+func (s Status) Funded() float64 {
+	return s.Saved / s.Item.Amount * 100
+}
+
+// Since returns the first day of the items' current periods, from which
+// transactions are needed.
+func (p Plan) Since(today time.Time) time.Time {
+	var first time.Time
+	for _, s := range p.periods(day(today)) {
+		if first.IsZero() || s.Start.Before(first) {
+			first = s.Start
+		}
+	}
+	return first
+}
+
+// periods returns the current period of each item as of today.
+func (p Plan) periods(today time.Time) []Status {
+	out := make([]Status, len(p.Items))
+	for i, it := range p.Items {
+		due, _ := time.Parse(time.DateOnly, it.Due)
+		months := it.Months()
+		for due.Before(today) {
+			due = due.AddDate(0, months, 0)
+		}
+		out[i] = Status{Item: it, Start: due.AddDate(0, -months, 0), Due: due}
+	}
+	return out
+}
+
+// Progress returns the status of each item of p as of today. Money set
+// aside is the deposits, that is the positive amounts, of txns tagged
+// with the item's tag since its previous due date; tag the receiving
+// side of each transfer to the savings account.
+func (p Plan) Progress(txns []transactions.Transaction, today time.Time) []Status {
+	today = day(today)
+	statuses := p.periods(today)
+	for i := range statuses {
+		s := &statuses[i]
+		tag := s.Item.Tag
+		if tag == "" {
+			tag = s.Item.Name
+		}
+		for _, t := range txns {
+			d, err := time.Parse(time.DateOnly, t.Date)
+			if err != nil || t.Amount <= 0 || d.Before(s.Start) || d.After(today) || !hasTag(t, tag) {
+				continue
+			}
+			s.Saved += t.Amount
+		}
+		elapsed := today.Sub(s.Start).Hours() / s.Due.Sub(s.Start).Hours()
+		s.Target = s.Item.Amount * min(max(elapsed, 0), 1)
+		left := 1
+		for today.AddDate(0, left, 0).Before(s.Due) {
+			left++
+		}
+		s.Monthly = max(s.Item.Amount-s.Saved, 0) / float64(left)
+	}
+	return statuses
+}
+
+func hasTag(t transactions.Transaction, name string) bool {
+	for _, tag := range t.Tags {
+		if strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Write renders statuses as a table with totals.
+func Write(w io.Writer, statuses []Status, today time.Time) {
+	fmt.Fprintf(w, "Sinking funds as of %s\n\n", today.Format(time.DateOnly))
+	var rows [][]string
+	var amount, saved, target, monthly float64
+	for _, s := range statuses {
+		rows = append(rows, []string{
+			s.Item.Name,
+			fmt.Sprintf("%.2f", s.Item.Amount),
+			every(s.Item),
+			s.Due.Format(time.DateOnly),
+			fmt.Sprintf("%.2f", s.Saved),
+			fmt.Sprintf("%.1f%%", s.Funded()),
+			fmt.Sprintf("%+.2f", s.Saved-s.Target),
+			fmt.Sprintf("%.2f", s.Monthly),
+		})
+		amount += s.Item.Amount
+		saved += s.Saved
+		target += s.Target
+		monthly += s.Monthly
+	}
+	rows = append(rows, []string{"Total", fmt.Sprintf("%.2f", amount), "", "", fmt.Sprintf("%.2f", saved),
+		fmt.Sprintf("%.1f%%", saved/amount*100), fmt.Sprintf("%+.2f", saved-target), fmt.Sprintf("%.2f", monthly)})
+	report.WriteTable(w, []string{"item", "amount", "every", "due", "saved", "funded", "vs_pace", "monthly"}, rows)
+}
+
+func every(it Item) string {
+	if it.Every == "" {
+		return "annual"
+	}
+	return strings.ToLower(it.Every)
+}
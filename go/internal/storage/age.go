@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+)
+
+// ParseRecipient parses an age recipient: an "age1..." public key, an SSH
+// public key ("ssh-ed25519 ..." or "ssh-rsa ...") or the path of a file
+// listing recipients one per line.
+func ParseRecipient(s string) ([]age.Recipient, error) {
+	if strings.HasPrefix(s, "age1") || strings.HasPrefix(s, "ssh-") {
+		r, err := parseRecipient(s)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Recipient{r}, nil
+	}
+	f, err := os.Open(s)
+	if err != nil {
+		return nil, fmt.Errorf("recipient %q is not a key or a readable file: %w", s, err)
+	}
+	defer f.Close()
+	var recipients []age.Recipient
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s, n, err)
+		}
+		recipients = append(recipients, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%s: no recipients", s)
+	}
+	return recipients, nil
+}
+
+func parseRecipient(s string) (age.Recipient, error) {
+	if strings.HasPrefix(s, "ssh-") {
+		return agessh.ParseRecipient(s)
+	}
+	return age.ParseX25519Recipient(s)
+}
+
+// Encrypt wraps w so that what is written to it is encrypted to recipients
+// with age. Closing the result finishes the encryption and closes w;
+// aborting it aborts w.
+func Encrypt(w Writer, recipients []age.Recipient) (Writer, error) {
+	enc, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return &encrypted{enc: enc, dst: w}, nil
+}
+
+type encrypted struct {
+	enc io.WriteCloser
+	dst Writer
+}
+
+func (e *encrypted) Write(p []byte) (int, error) {
+	return e.enc.Write(p)
+}
+
+func (e *encrypted) Close() error {
+	if err := e.enc.Close(); err != nil {
+		e.dst.Abort()
+		return err
+	}
+	return e.dst.Close()
+}
+
+func (e *encrypted) Abort() error {
+	return e.dst.Abort()
+}
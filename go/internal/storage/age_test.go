@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEncrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshIdentity, err := agessh.NewEd25519Identity(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	list := filepath.Join(dir, "recipients.txt")
+	contents := "# the household\n" + identity.Recipient().String() + "\n\n" + string(ssh.MarshalAuthorizedKey(sshPub))
+	if err := os.WriteFile(list, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := ParseRecipient(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(recipients))
+	}
+
+	dest := filepath.Join(dir, "holdings.csv.age")
+	data := bytes.Repeat([]byte("2025-03-31,Brokerage,VTI,1000.00\n"), 4096)
+	f, err := Create(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Encrypt(f, recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Each recipient can decrypt the file.
+	for _, id := range []age.Identity{identity, sshIdentity} {
+		got, err := decryptFile(dest, id)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("decrypted %d bytes, %v, want %d", len(got), err, len(data))
+		}
+	}
+	other, _ := age.GenerateX25519Identity()
+	if _, err := decryptFile(dest, other); err == nil {
+		t.Error("decrypted with a key that isn't a recipient")
+	}
+
+	// An aborted encryption leaves the earlier file.
+	f, err = Create(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err = Encrypt(f, recipients[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("partial"))
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decryptFile(dest, identity); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("after an aborted write decrypted %d bytes, %v, want the earlier file", len(got), err)
+	}
+}
+
+func TestParseRecipient(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	if r, err := ParseRecipient(identity.Recipient().String()); err != nil || len(r) != 1 {
+		t.Errorf("got %v, %v, want the key", r, err)
+	}
+	for _, bad := range []string{"age1notakey", "ssh-ed25519 AAAA", filepath.Join(t.TempDir(), "missing")} {
+		if _, err := ParseRecipient(bad); err == nil {
+			t.Errorf("%s: parsed", bad)
+		}
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	os.WriteFile(empty, []byte("# nobody\n"), 0600)
+	if _, err := ParseRecipient(empty); err == nil || !strings.Contains(err.Error(), "no recipients") {
+		t.Errorf("got %v, want no recipients", err)
+	}
+}
+
+func decryptFile(path string, id age.Identity) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := age.Decrypt(f, id)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
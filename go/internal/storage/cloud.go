@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// dropbox uploads with the Dropbox HTTP API using an access token from
+// DROPBOX_TOKEN, overwriting existing files.
+type dropbox struct{ token string }
+
+func newDropbox() (*dropbox, error) {
+	token := os.Getenv("DROPBOX_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("Dropbox upload needs DROPBOX_TOKEN")
+	}
+	return &dropbox{token: token}, nil
+}
+
+func (d *dropbox) Put(path string, data []byte) error {
+	arg, err := json.Marshal(map[string]any{"path": path, "mode": "overwrite", "mute": true})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://content.dropboxapi.com/2/files/upload", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+	return do(req)
+}
+
+// gdrive uploads to Google Drive using an OAuth access token from
+// GDRIVE_TOKEN. The key is "name" or "folderID/name"; each upload creates
+// a new file.
+type gdrive struct{ token string }
+
+func newGDrive() (*gdrive, error) {
+	token := os.Getenv("GDRIVE_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("Google Drive upload needs an access token in GDRIVE_TOKEN")
+	}
+	return &gdrive{token: token}, nil
+}
+
+func (g *gdrive) Put(key string, data []byte) error {
+	meta := map[string]any{"name": key}
+	if folder, name, ok := strings.Cut(key, "/"); ok {
+		meta = map[string]any{"name": name, "parents": []string{folder}}
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	part.Write(metaJSON)
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType(key)}})
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://www.googleapis.com/upload/drive/v3/files?uploadType=multipart", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	return do(req)
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// received is a request as the test server saw it.
+type received struct {
+	method, host, path, query string
+	header                    http.Header
+	body                      []byte
+}
+
+// serveUploads points httpClient at a test server, whatever host a request
+// names, and returns the requests it received. status is its response.
+func serveUploads(t *testing.T, status int) *[]received {
+	t.Helper()
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, received{r.Method, r.Host, r.URL.Path, r.URL.RawQuery, r.Header, body})
+		w.WriteHeader(status)
+		if status/100 != 2 {
+			io.WriteString(w, `{"error": "denied"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	orig := httpClient
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Host = req.URL.Host
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	t.Cleanup(func() { httpClient = orig })
+	return &got
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestS3Put(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	got := serveUploads(t, http.StatusOK)
+
+	if err := put(t, "s3://my-bucket/exports/2025 Q1/holdings.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	r := (*got)[0]
+	if r.method != http.MethodPut || r.host != "my-bucket.s3.eu-central-1.amazonaws.com" || r.path != "/exports/2025 Q1/holdings.csv" {
+		t.Errorf("got %s %s%s, want a PUT of the key to the bucket's host", r.method, r.host, r.path)
+	}
+	sum := sha256.Sum256([]byte("x"))
+	if h := r.header.Get("X-Amz-Content-Sha256"); h != hex.EncodeToString(sum[:]) {
+		t.Errorf("got X-Amz-Content-Sha256 %s, want the body's hash", h)
+	}
+	auth := r.header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/eu-central-1/s3/aws4_request") {
+		t.Errorf("got Authorization %s", auth)
+	}
+	if ct := r.header.Get("Content-Type"); ct != "text/csv" || string(r.body) != "x" {
+		t.Errorf("got %s %q", ct, r.body)
+	}
+
+	// A compatible service gets path-style URLs.
+	t.Setenv("AWS_ENDPOINT_URL", "http://minio.local:9000/")
+	if err := put(t, "s3://my-bucket/a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if r := (*got)[1]; r.host != "minio.local:9000" || r.path != "/my-bucket/a.json" {
+		t.Errorf("got %s%s, want a path-style URL", r.host, r.path)
+	}
+
+	if err := put(t, "s3://my-bucket"); err == nil {
+		t.Error("upload without a key succeeded")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if err := put(t, "s3://my-bucket/a.json"); err == nil || !strings.Contains(err.Error(), "AWS_ACCESS_KEY_ID") {
+		t.Errorf("got %v, want missing credentials", err)
+	}
+}
+
+func TestDropboxPut(t *testing.T) {
+	t.Setenv("DROPBOX_TOKEN", "dbx-token")
+	got := serveUploads(t, http.StatusOK)
+	if err := put(t, "dropbox:/Finance/holdings.csv"); err != nil {
+		t.Fatal(err)
+	}
+	r := (*got)[0]
+	if r.method != http.MethodPost || r.host != "content.dropboxapi.com" || r.path != "/2/files/upload" {
+		t.Errorf("got %s %s%s", r.method, r.host, r.path)
+	}
+	if a := r.header.Get("Authorization"); a != "Bearer dbx-token" {
+		t.Errorf("got Authorization %s", a)
+	}
+	var arg map[string]any
+	if err := json.Unmarshal([]byte(r.header.Get("Dropbox-API-Arg")), &arg); err != nil || arg["path"] != "/Finance/holdings.csv" || arg["mode"] != "overwrite" {
+		t.Errorf("got Dropbox-API-Arg %v, %v", arg, err)
+	}
+	if string(r.body) != "x" {
+		t.Errorf("got body %q", r.body)
+	}
+
+	serveUploads(t, http.StatusUnauthorized)
+	if err := put(t, "dropbox:/a.csv"); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("got %v, want HTTP 401", err)
+	}
+	t.Setenv("DROPBOX_TOKEN", "")
+	if err := put(t, "dropbox:/a.csv"); err == nil || !strings.Contains(err.Error(), "DROPBOX_TOKEN") {
+		t.Errorf("got %v, want a missing token", err)
+	}
+}
+
+func TestGDrivePut(t *testing.T) {
+	t.Setenv("GDRIVE_TOKEN", "drive-token")
+	got := serveUploads(t, http.StatusOK)
+	if err := put(t, "gdrive:folder123/holdings.json"); err != nil {
+		t.Fatal(err)
+	}
+	r := (*got)[0]
+	if r.method != http.MethodPost || r.host != "www.googleapis.com" || r.path != "/upload/drive/v3/files" || r.query != "uploadType=multipart" {
+		t.Errorf("got %s %s%s?%s", r.method, r.host, r.path, r.query)
+	}
+	if a := r.header.Get("Authorization"); a != "Bearer drive-token" {
+		t.Errorf("got Authorization %s", a)
+	}
+	mediaType, params, err := mime.ParseMediaType(r.header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" {
+		t.Fatalf("got Content-Type %s, %v", r.header.Get("Content-Type"), err)
+	}
+	mr := multipart.NewReader(strings.NewReader(string(r.body)), params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(p)
+		parts = append(parts, p.Header.Get("Content-Type")+" "+string(data))
+	}
+	want := []string{`application/json; charset=UTF-8 {"name":"holdings.json","parents":["folder123"]}`, "application/json x"}
+	if strings.Join(parts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got parts %q, want %q", parts, want)
+	}
+
+	serveUploads(t, http.StatusForbidden)
+	if err := put(t, "gdrive:a.csv"); err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+		t.Errorf("got %v, want HTTP 403", err)
+	}
+}
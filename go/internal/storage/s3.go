@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/awsauth"
+)
+
+// s3 uploads to Amazon S3 or a compatible service with Signature Version 4
+// signed PUT requests. Credentials come from the standard AWS_* variables;
+// AWS_ENDPOINT_URL selects a compatible service such as MinIO, addressed
+// with path-style URLs.
+type s3 struct {
+	bucket   string
+	endpoint string
+	creds    awsauth.Credentials
+}
+
+func newS3(bucket string) (*s3, error) {
+	creds, err := awsauth.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("S3 upload %w", err)
+	}
+	return &s3{bucket: bucket, endpoint: os.Getenv("AWS_ENDPOINT_URL"), creds: creds}, nil
+}
+
+func (s *s3) url(key string) string {
+	escaped := (&url.URL{Path: "/" + key}).EscapedPath()
+	if s.endpoint != "" {
+		return strings.TrimRight(s.endpoint, "/") + "/" + s.bucket + escaped
+	}
+	return "https://" + s.bucket + ".s3." + s.creds.Region + ".amazonaws.com" + escaped
+}
+
+func (s *s3) Put(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, s.url(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(key))
+	s.creds.Sign(req, data, "s3", time.Now().UTC())
+	return do(req)
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpStore uploads over SFTP to "sftp://user@host[:port]/path". It
+// authenticates with the SSH agent, unencrypted keys in ~/.ssh, or a
+// password from the URL or MONARCH_SFTP_PASSWORD, and checks the host key
+// against ~/.ssh/known_hosts. Only the small part of the SFTP protocol
+// (version 3) needed to write a file is implemented.
+type sftpStore struct {
+	addr   string
+	config ssh.ClientConfig
+	// agentSock is the SSH agent's socket, connected to for each upload.
+	agentSock string
+}
+
+func newSFTP(u *url.URL) (*sftpStore, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("SFTP host keys: %w", err)
+	}
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	var auth []ssh.AuthMethod
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		key, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(key); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		password = os.Getenv("MONARCH_SFTP_PASSWORD")
+	}
+	if password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	return &sftpStore{
+		addr: addr,
+		config: ssh.ClientConfig{
+			User:            user,
+			Auth:            auth,
+			HostKeyCallback: hostKeys,
+		},
+		agentSock: os.Getenv("SSH_AUTH_SOCK"),
+	}, nil
+}
+
+func (s *sftpStore) Put(name string, data []byte) error {
+	config := s.config
+	if s.agentSock != "" {
+		if agentConn, err := net.Dial("unix", s.agentSock); err == nil {
+			defer agentConn.Close()
+			config.Auth = append([]ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers)}, config.Auth...)
+		}
+	}
+	conn, err := ssh.Dial("tcp", s.addr, &config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf("start sftp: %w", err)
+	}
+
+	c := &sftpConn{w: w, r: r}
+	if err := c.init(); err != nil {
+		return err
+	}
+	// Create missing parent directories; MKDIR fails harmlessly for ones
+	// that already exist.
+	dir := path.Dir(name)
+	var parents []string
+	for ; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		parents = append(parents, dir)
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		c.mkdir(parents[i])
+	}
+	return c.writeFile(name, data)
+}
+
+// SFTP version 3 packet types and flags.
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpWrite   = 6
+	sftpMkdir   = 14
+	sftpStatus  = 101
+	sftpHandle  = 102
+
+	sftpWriteFlag = 0x02
+	sftpCreat     = 0x08
+	sftpTrunc     = 0x10
+	sftpAttrPerm  = 0x04
+
+	sftpChunk = 32 * 1024
+)
+
+type sftpConn struct {
+	w  io.Writer
+	r  io.Reader
+	id uint32
+}
+
+// packet builds an SFTP payload.
+type packet []byte
+
+func (p packet) uint32(v uint32) packet { return binary.BigEndian.AppendUint32(p, v) }
+func (p packet) uint64(v uint64) packet { return binary.BigEndian.AppendUint64(p, v) }
+func (p packet) string(s []byte) packet { return append(p.uint32(uint32(len(s))), s...) }
+
+func (c *sftpConn) send(typ byte, body packet) error {
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(body)+1))
+	msg = append(msg, typ)
+	_, err := c.w.Write(append(msg, body...))
+	return err
+}
+
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	body := make([]byte, n-1)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], body, nil
+}
+
+// call sends a request and returns the response payload after its ID.
+func (c *sftpConn) call(typ byte, body packet) (byte, []byte, error) {
+	c.id++
+	if err := c.send(typ, packet(nil).uint32(c.id).append(body)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, resp, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != c.id {
+		return 0, nil, errors.New("sftp: unexpected response")
+	}
+	return rtyp, resp[4:], nil
+}
+
+func (p packet) append(q packet) packet { return append(p, q...) }
+
+// status converts a STATUS response to an error, nil for success.
+func status(typ byte, resp []byte) error {
+	if typ != sftpStatus || len(resp) < 4 {
+		return fmt.Errorf("sftp: unexpected response type %d", typ)
+	}
+	code := binary.BigEndian.Uint32(resp)
+	if code == 0 {
+		return nil
+	}
+	msg := ""
+	if len(resp) >= 8 {
+		n := binary.BigEndian.Uint32(resp[4:])
+		if int(n) <= len(resp)-8 {
+			msg = string(resp[8 : 8+n])
+		}
+	}
+	return fmt.Errorf("sftp: status %d: %s", code, msg)
+}
+
+func (c *sftpConn) init() error {
+	if err := c.send(sftpInit, packet(nil).uint32(3)); err != nil {
+		return err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if typ != sftpVersion {
+		return fmt.Errorf("sftp: unexpected response type %d to init", typ)
+	}
+	return nil
+}
+
+func (c *sftpConn) mkdir(dir string) error {
+	typ, resp, err := c.call(sftpMkdir, packet(nil).string([]byte(dir)).uint32(0))
+	if err != nil {
+		return err
+	}
+	return status(typ, resp)
+}
+
+func (c *sftpConn) writeFile(name string, data []byte) error {
+	flags := uint32(sftpWriteFlag | sftpCreat | sftpTrunc)
+	typ, resp, err := c.call(sftpOpen, packet(nil).string([]byte(name)).uint32(flags).uint32(sftpAttrPerm).uint32(0600))
+	if err != nil {
+		return err
+	}
+	if typ != sftpHandle {
+		return status(typ, resp)
+	}
+	if len(resp) < 4 || int(binary.BigEndian.Uint32(resp)) > len(resp)-4 {
+		return errors.New("sftp: bad handle")
+	}
+	handle := resp[4 : 4+binary.BigEndian.Uint32(resp)]
+
+	for off := 0; off < len(data); off += sftpChunk {
+		chunk := data[off:min(off+sftpChunk, len(data))]
+		typ, resp, err := c.call(sftpWrite, packet(nil).string(handle).uint64(uint64(off)).string(chunk))
+		if err != nil {
+			return err
+		}
+		if err := status(typ, resp); err != nil {
+			return err
+		}
+	}
+	typ, resp, err = c.call(sftpClose, packet(nil).string(handle))
+	if err != nil {
+		return err
+	}
+	return status(typ, resp)
+}
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpServer is an in-process SSH server with just enough of SFTP version
+// 3 to create directories and write files, kept in memory.
+type sftpServer struct {
+	addr   string
+	config *ssh.ServerConfig
+
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+	// readOnly directories refuse new entries.
+	readOnly map[string]bool
+}
+
+// newSFTPServer starts a server accepting password and the public key
+// userKey, if not nil, and records its host key in $HOME/.ssh/known_hosts
+// under a temporary HOME.
+func newSFTPServer(t *testing.T, password string, userKey ssh.PublicKey) *sftpServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sftpServer{
+		dirs:     map[string]bool{"/": true},
+		files:    map[string][]byte{},
+		readOnly: map[string]bool{},
+		config: &ssh.ServerConfig{
+			PasswordCallback: func(_ ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+				if string(pw) != password {
+					return nil, io.EOF
+				}
+				return nil, nil
+			},
+			PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if userKey == nil || !bytes.Equal(key.Marshal(), userKey.Marshal()) {
+					return nil, io.EOF
+				}
+				return nil, nil
+			},
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.addr = ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, hostKey.PublicKey())
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *sftpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						defer ch.Close()
+						s.sftp(ch)
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// sftp answers SFTP requests on ch until it is closed.
+func (s *sftpServer) sftp(ch io.ReadWriter) {
+	open := map[string]*bytes.Buffer{}
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(ch, hdr[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(ch, body); err != nil {
+			return
+		}
+		if hdr[4] == sftpInit {
+			ch.Write(packet(nil).uint32(5).append(packet{sftpVersion}).uint32(3))
+			continue
+		}
+		r := &sftpReader{b: body}
+		id := r.uint32()
+		reply := func(typ byte, p packet) {
+			msg := packet(nil).uint32(id).append(p)
+			ch.Write(packet(nil).uint32(uint32(len(msg) + 1)).append(packet{typ}).append(msg))
+		}
+		status := func(code uint32, msg string) {
+			reply(sftpStatus, packet(nil).uint32(code).string([]byte(msg)).string(nil))
+		}
+
+		s.mu.Lock()
+		switch hdr[4] {
+		case sftpMkdir:
+			dir := r.string()
+			switch {
+			case s.dirs[dir]:
+				status(4, "exists")
+			case !s.dirs[path.Dir(dir)] || s.readOnly[path.Dir(dir)]:
+				status(3, "permission denied")
+			default:
+				s.dirs[dir] = true
+				status(0, "")
+			}
+		case sftpOpen:
+			name := r.string()
+			if !s.dirs[path.Dir(name)] {
+				status(2, "no such file")
+				break
+			}
+			open[name] = &bytes.Buffer{}
+			reply(sftpHandle, packet(nil).string([]byte(name)))
+		case sftpWrite:
+			handle, off, data := r.string(), r.uint64(), r.string()
+			buf := open[handle]
+			if buf == nil || off != uint64(buf.Len()) {
+				status(4, "bad write")
+				break
+			}
+			buf.WriteString(data)
+			status(0, "")
+		case sftpClose:
+			handle := r.string()
+			s.files[handle] = open[handle].Bytes()
+			delete(open, handle)
+			status(0, "")
+		default:
+			status(8, "unsupported")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *sftpServer) file(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	return data, ok
+}
+
+// sftpReader decodes the fields of a request.
+type sftpReader struct{ b []byte }
+
+func (r *sftpReader) uint32() uint32 {
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *sftpReader) string() string {
+	n := r.uint32()
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func TestSFTPPut(t *testing.T) {
+	srv := newSFTPServer(t, "hunter2", nil)
+	srv.dirs["/backups"] = true
+	// More than one write request.
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	w, err := Create("sftp://alice:hunter2@" + srv.addr + "/backups/2025/04/holdings.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := srv.file("/backups/2025/04/holdings.csv"); !ok || !bytes.Equal(got, data) {
+		t.Errorf("uploaded %d bytes, want %d", len(got), len(data))
+	}
+
+	// A directory that can't be created fails the upload.
+	srv.readOnly["/backups"] = true
+	err = put(t, "sftp://alice:hunter2@"+srv.addr+"/backups/new/x.csv")
+	if err == nil || !strings.Contains(err.Error(), "no such file") || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("got %v, want a redacted no such file error", err)
+	}
+
+	if err := put(t, "sftp://alice:wrong@"+srv.addr+"/backups/x.csv"); err == nil {
+		t.Error("upload with a wrong password succeeded")
+	}
+}
+
+// TestSFTPAgent checks that uploads authenticate with the SSH agent and
+// close their connection to it.
+func TestSFTPAgent(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newSFTPServer(t, "", signer.PublicKey())
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	closed := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				agent.ServeAgent(keyring, conn)
+				closed <- struct{}{}
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	if err := put(t, "sftp://alice@"+srv.addr+"/x.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.file("/x.csv"); !ok {
+		t.Error("file not uploaded")
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("agent connection left open")
+	}
+}
+
+// put uploads a small file to dest.
+func put(t *testing.T, dest string) error {
+	t.Helper()
+	w, err := Create(dest)
+	if err != nil {
+		return err
+	}
+	w.Write([]byte("x"))
+	return w.Close()
+}
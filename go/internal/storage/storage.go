@@ -0,0 +1,191 @@
+// Package storage writes export files either to the local filesystem or to
+// remote storage, chosen by the destination: "s3://bucket/key",
+// "dropbox:/path", "gdrive:[folderID/]name", "sftp://user@host/path" and
+// "davs://user@host/path" (WebDAV) are uploaded, anything else is a local
+// path.
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend uploads a finished file to remote storage.
+type Backend interface {
+	Put(key string, data []byte) error
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// remote returns the backend and key for dest, or a nil backend when dest
+// is a local path.
+func remote(dest string) (Backend, string, error) {
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(dest, "s3://"), "/")
+		if bucket == "" || key == "" {
+			return nil, "", fmt.Errorf("%s: want s3://bucket/key", dest)
+		}
+		b, err := newS3(bucket)
+		return b, key, err
+	case strings.HasPrefix(dest, "dropbox:"):
+		b, err := newDropbox()
+		return b, "/" + strings.TrimLeft(strings.TrimPrefix(dest, "dropbox:"), "/"), err
+	case strings.HasPrefix(dest, "gdrive:"):
+		b, err := newGDrive()
+		return b, strings.TrimPrefix(dest, "gdrive:"), err
+	case strings.HasPrefix(dest, "sftp://"), strings.HasPrefix(dest, "dav://"), strings.HasPrefix(dest, "davs://"):
+		u, err := url.Parse(dest)
+		if err != nil {
+			return nil, "", err
+		}
+		if u.Host == "" || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+			return nil, "", fmt.Errorf("%s: want %s://[user@]host/path/file", u.Redacted(), u.Scheme)
+		}
+		if u.Scheme == "sftp" {
+			b, err := newSFTP(u)
+			return b, u.Path, err
+		}
+		b, err := newWebDAV(u)
+		return b, u.Path, err
+	}
+	return nil, "", nil
+}
+
+// IsRemote reports whether dest names cloud storage rather than a local path.
+func IsRemote(dest string) bool {
+	for _, prefix := range []string{"s3://", "dropbox:", "gdrive:", "sftp://", "dav://", "davs://"} {
+		if strings.HasPrefix(dest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Join appends name to the directory dir, which may be a remote destination.
+func Join(dir, name string) string {
+	if IsRemote(dir) {
+		if strings.HasSuffix(dir, "/") || strings.HasSuffix(dir, ":") {
+			return dir + name
+		}
+		return dir + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
+
+// Writer is a file being written by Create. Close finishes it; Abort
+// discards what was written instead, leaving any earlier file at the
+// destination as it was.
+type Writer interface {
+	io.WriteCloser
+	Abort() error
+}
+
+// Create opens dest for writing. Local files are written to a temporary
+// file in their directory (created if need be) and renamed into place on
+// Close; remote files are buffered and uploaded on Close.
+func Create(dest string) (Writer, error) {
+	b, key, err := remote(dest)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		dir := filepath.Dir(dest)
+		if dir != "." {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, err
+			}
+		}
+		f, err := os.CreateTemp(dir, filepath.Base(dest)+".tmp*")
+		if err != nil {
+			return nil, err
+		}
+		return &localFile{File: f, dest: dest}, nil
+	}
+	if u, err := url.Parse(dest); err == nil {
+		dest = u.Redacted()
+	}
+	return &upload{backend: b, key: key, dest: dest}, nil
+}
+
+// localFile is a temporary file renamed to dest on Close.
+type localFile struct {
+	*os.File
+	dest string
+}
+
+func (f *localFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.dest); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *localFile) Abort() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+type upload struct {
+	backend Backend
+	key     string
+	dest    string
+	buf     bytes.Buffer
+}
+
+func (u *upload) Write(p []byte) (int, error) {
+	return u.buf.Write(p)
+}
+
+// Abort drops the buffered file without uploading it.
+func (u *upload) Abort() error {
+	u.buf.Reset()
+	return nil
+}
+
+func (u *upload) Close() error {
+	if err := u.backend.Put(u.key, u.buf.Bytes()); err != nil {
+		return fmt.Errorf("upload %s: %w", u.dest, err)
+	}
+	return nil
+}
+
+// do sends req and checks for a 2xx response.
+func do(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// contentType guesses the MIME type of an export from its name.
+func contentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return "text/csv"
+	case ".json":
+		return "application/json"
+	case ".md":
+		return "text/markdown"
+	}
+	return "application/octet-stream"
+}
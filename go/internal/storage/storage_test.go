@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeBackend records the files put to it.
+type fakeBackend map[string][]byte
+
+func (b fakeBackend) Put(key string, data []byte) error {
+	b[key] = data
+	return nil
+}
+
+func TestCreateLocal(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "sub", "out.csv")
+	write := func(data string, abort bool) {
+		t.Helper()
+		w, err := Create(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if abort {
+			err = w.Abort()
+		} else {
+			err = w.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("first", false)
+	write("half", true)
+	if got, err := os.ReadFile(dest); err != nil || string(got) != "first" {
+		t.Errorf("after an aborted write got %q, %v, want the earlier file", got, err)
+	}
+	write("second", false)
+	if got, err := os.ReadFile(dest); err != nil || string(got) != "second" {
+		t.Errorf("got %q, %v, want second", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %v, %v, want only out.csv", entries, err)
+	}
+}
+
+func TestUploadAbort(t *testing.T) {
+	b := fakeBackend{}
+	w := &upload{backend: b, key: "kept", dest: "s3://bucket/kept"}
+	w.Write([]byte("data"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w = &upload{backend: b, key: "aborted", dest: "s3://bucket/aborted"}
+	w.Write([]byte("half"))
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 1 || string(b["kept"]) != "data" {
+		t.Errorf("got uploads %q, want only kept", b)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// webdav uploads with HTTP PUT to a WebDAV server such as Nextcloud:
+// "davs://user@host/remote.php/dav/files/user/path" uses HTTPS and
+// "dav://" plain HTTP, which sends the password and the file unencrypted
+// and so needs MONARCH_WEBDAV_INSECURE=1. The password comes from the URL
+// or MONARCH_WEBDAV_PASSWORD. Missing parent collections are created.
+type webdav struct {
+	base     url.URL
+	user     string
+	password string
+}
+
+func newWebDAV(u *url.URL) (*webdav, error) {
+	d := &webdav{base: *u, user: u.User.Username()}
+	d.base.Scheme = "https"
+	if u.Scheme == "dav" {
+		if os.Getenv("MONARCH_WEBDAV_INSECURE") != "1" {
+			return nil, fmt.Errorf("%s: dav:// sends the password and file over plain HTTP; use davs://, or set MONARCH_WEBDAV_INSECURE=1", u.Redacted())
+		}
+		d.base.Scheme = "http"
+	}
+	d.base.User = nil
+	d.base.Path = ""
+	var ok bool
+	if d.password, ok = u.User.Password(); !ok {
+		d.password = os.Getenv("MONARCH_WEBDAV_PASSWORD")
+	}
+	return d, nil
+}
+
+func (d *webdav) request(method, p string, body []byte) (*http.Request, error) {
+	u := d.base
+	u.Path = p
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if d.user != "" {
+		req.SetBasicAuth(d.user, d.password)
+	}
+	return req, nil
+}
+
+func (d *webdav) Put(p string, data []byte) error {
+	dirs := strings.Split(strings.Trim(path.Dir(p), "/"), "/")
+	for i := range dirs {
+		if dirs[i] == "" {
+			continue
+		}
+		if err := d.mkcol("/" + strings.Join(dirs[:i+1], "/") + "/"); err != nil {
+			return err
+		}
+	}
+	req, err := d.request(http.MethodPut, p, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(p))
+	return do(req)
+}
+
+// mkcol creates the collection at p. Servers answer 405 Method Not Allowed
+// for collections that exist, which is not an error.
+func (d *webdav) mkcol(p string) error {
+	req, err := d.request("MKCOL", p, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusMethodNotAllowed {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("create collection %s: HTTP %d: %s", p, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
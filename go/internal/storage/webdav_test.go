@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWebDAVPut(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	files := map[string]string{}
+	collections := map[string]bool{"/files/": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if user, pw, _ := r.BasicAuth(); user != "alice" || pw != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "MKCOL" && collections[r.URL.Path]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == "MKCOL" && strings.HasPrefix(r.URL.Path, "/locked/"):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case r.Method == "MKCOL":
+			collections[r.URL.Path] = true
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv("MONARCH_WEBDAV_PASSWORD", "secret")
+
+	t.Setenv("MONARCH_WEBDAV_INSECURE", "")
+	if _, err := Create("dav://alice@" + host + "/files/x.csv"); err == nil || !strings.Contains(err.Error(), "MONARCH_WEBDAV_INSECURE") {
+		t.Fatalf("got %v, want dav:// refused without the opt-in", err)
+	}
+
+	t.Setenv("MONARCH_WEBDAV_INSECURE", "1")
+	if err := put(t, "dav://alice@"+host+"/files/2025/x.csv"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"MKCOL /files/", "MKCOL /files/2025/", "PUT /files/2025/x.csv"}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+	if files["/files/2025/x.csv"] != "x" {
+		t.Errorf("got files %q", files)
+	}
+
+	err := put(t, "dav://alice@"+host+"/locked/x.csv")
+	if err == nil || !strings.Contains(err.Error(), "create collection /locked/: HTTP 403") {
+		t.Errorf("got %v, want the MKCOL failure", err)
+	}
+	if _, ok := files["/locked/x.csv"]; ok {
+		t.Error("uploaded after MKCOL failed")
+	}
+}
@@ -0,0 +1,137 @@
+package transactions
+
+import (
+	"fmt"
+	"math"
+)
+
+// Split is one part of a split transaction.
+type Split struct {
+	Amount     float64 `json:"amount"`
+	CategoryID string  `json:"categoryId"`
+	Merchant   string  `json:"merchantName"`
+}
+
+// Edit is a pending change to one transaction. Nil fields are left unchanged.
+type Edit struct {
+	CategoryID *string
+	TagIDs     []string
+	SetTags    bool
+	Splits     []Split
+	Reviewed   bool
+}
+
+// Mutator applies edits to Monarch. It is implemented on top of the API
+// client by the CLI.
+type Mutator interface {
+	SetCategory(transactionID, categoryID string) error
+	SetTags(transactionID string, tagIDs []string) error
+	SplitTransaction(transactionID string, splits []Split) error
+	MarkReviewed(transactionID string) error
+}
+
+// Review collects edits to transactions so they can be staged interactively
+// and applied together.
+type Review struct {
+	edits map[string]*Edit
+	order []string
+}
+
+// NewReview returns an empty Review.
+func NewReview() *Review {
+	return &Review{edits: make(map[string]*Edit)}
+}
+
+func (r *Review) edit(id string) *Edit {
+	e, ok := r.edits[id]
+	if !ok {
+		e = &Edit{}
+		r.edits[id] = e
+		r.order = append(r.order, id)
+	}
+	return e
+}
+
+// Pending reports whether the transaction has staged edits.
+func (r *Review) Pending(id string) bool {
+	_, ok := r.edits[id]
+	return ok
+}
+
+// Len returns the number of transactions with staged edits.
+func (r *Review) Len() int {
+	return len(r.order)
+}
+
+// SetCategory stages a category change.
+func (r *Review) SetCategory(id, categoryID string) {
+	r.edit(id).CategoryID = &categoryID
+}
+
+// SetTags stages replacing the transaction's tags.
+func (r *Review) SetTags(id string, tagIDs []string) {
+	e := r.edit(id)
+	e.TagIDs = tagIDs
+	e.SetTags = true
+}
+
+// Split stages splitting t into parts. The parts must add up to the
+// transaction amount.
+func (r *Review) Split(t Transaction, splits []Split) error {
+	var sum float64
+	for _, s := range splits {
+		sum += s.Amount
+	}
+	if math.Abs(sum-t.Amount) > 0.005 {
+		return fmt.Errorf("splits add up to %.2f, transaction is %.2f", sum, t.Amount)
+	}
+	r.edit(t.ID).Splits = splits
+	return nil
+}
+
+// MarkReviewed stages clearing the transaction's needs-review flag.
+func (r *Review) MarkReviewed(id string) {
+	r.edit(id).Reviewed = true
+}
+
+// Apply sends all staged edits through m in the order they were made.
+// Edits that succeed are cleared; the first failure stops processing and is
+// returned along with the number of transactions updated.
+func (r *Review) Apply(m Mutator) (int, error) {
+	done := 0
+	for len(r.order) > 0 {
+		id := r.order[0]
+		e := r.edits[id]
+		if err := applyEdit(m, id, e); err != nil {
+			return done, fmt.Errorf("transaction %s: %w", id, err)
+		}
+		delete(r.edits, id)
+		r.order = r.order[1:]
+		done++
+	}
+	return done, nil
+}
+
+func applyEdit(m Mutator, id string, e *Edit) error {
+	if e.CategoryID != nil {
+		if err := m.SetCategory(id, *e.CategoryID); err != nil {
+			return err
+		}
+	}
+	if e.SetTags {
+		if err := m.SetTags(id, e.TagIDs); err != nil {
+			return err
+		}
+	}
+	if len(e.Splits) > 0 {
+		if err := m.SplitTransaction(id, e.Splits); err != nil {
+			return err
+		}
+	}
+	if e.Reviewed {
+		if err := m.MarkReviewed(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package transactions provides data structures for Monarch Money transactions.
+package transactions
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+// --- JSON data structures ---
+
+type Page struct {
+	TotalCount int           `json:"totalCount"`
+	Results    []Transaction `json:"results"`
+}
+
+type Transaction struct {
+	ID              string   `json:"id"`
+	Date            string   `json:"date"`
+	Amount          float64  `json:"amount"`
+	Pending         bool     `json:"pending"`
+	HideFromReports bool     `json:"hideFromReports"`
+	NeedsReview     bool     `json:"needsReview"`
+	Notes           string   `json:"notes"`
+	Category        Category `json:"category"`
+	Merchant        Merchant `json:"merchant"`
+	Account         Account  `json:"account"`
+	Tags            []Tag    `json:"tags"`
+}
+
+type Category struct {
+	ID    string        `json:"id"`
+	Name  string        `json:"name"`
+	Group CategoryGroup `json:"group"`
+}
+
+type CategoryGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type Tag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type Merchant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Account struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// Category group types used by Monarch.
+const (
+	GroupIncome   = "income"
+	GroupExpense  = "expense"
+	GroupTransfer = "transfer"
+)
+
+// ParsePage decodes the data object of a GetTransactionsList GraphQL response.
+func ParsePage(data map[string]json.RawMessage) (*Page, error) {
+	var page Page
+	if raw, ok := data["allTransactions"]; ok {
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, err
+		}
+	}
+	return &page, nil
+}
+
+// ParseCategories decodes the data object of a GetCategories GraphQL response.
+func ParseCategories(data map[string]json.RawMessage) ([]Category, error) {
+	var cats []Category
+	if raw, ok := data["categories"]; ok {
+		if err := json.Unmarshal(raw, &cats); err != nil {
+			return nil, err
+		}
+	}
+	return cats, nil
+}
+
+// ParseTags decodes the data object of a GetHouseholdTransactionTags GraphQL response.
+func ParseTags(data map[string]json.RawMessage) ([]Tag, error) {
+	var tags []Tag
+	if raw, ok := data["householdTransactionTags"]; ok {
+		if err := json.Unmarshal(raw, &tags); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// IsUncategorized reports whether the transaction still needs a category.
+func (t Transaction) IsUncategorized() bool {
+	return t.Category.ID == "" || t.Category.Name == "Uncategorized"
+}
+
+// Time returns the transaction date as a time in UTC.
+func (t Transaction) Time() time.Time {
+	d, _ := time.Parse(time.DateOnly, t.Date)
+	return d
+}
+
+// Sort orders txns by date, oldest first, then by account and ID, so exports
+// of the same transactions are identical whatever order the API used.
+func Sort(txns []Transaction) {
+	sort.Slice(txns, func(i, j int) bool {
+		a, b := txns[i], txns[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Account.ID != b.Account.ID {
+			return a.Account.ID < b.Account.ID
+		}
+		return a.ID < b.ID
+	})
+}
+
+// IsTransfer reports whether the transaction moves money between accounts
+// rather than being income or spending.
+func (t Transaction) IsTransfer() bool {
+	return t.Category.Group.Type == GroupTransfer
+}
+
+// passiveIncome are the income categories that aren't pay for work.
+var passiveIncome = []string{"Interest", "Dividends & Capital Gains"}
+
+// payrollMerchant matches the merchant names of payroll deposits and of
+// payouts from the platforms freelancers are paid through.
+var payrollMerchant = regexp.MustCompile(`(?i)payroll|direct dep|salary|\badp\b|gusto|paychex|\bdeel\b|upwork|fiverr|stripe`)
+
+// IsEarnedIncome reports whether the transaction is pay for work: a
+// deposit in an income category other than interest and dividends, or an
+// uncategorized deposit from a payroll provider or freelance platform.
+func (t Transaction) IsEarnedIncome() bool {
+	if t.Amount <= 0 || t.HideFromReports || t.IsTransfer() {
+		return false
+	}
+	if t.Category.Group.Type == GroupIncome {
+		return !slices.Contains(passiveIncome, t.Category.Name)
+	}
+	return t.IsUncategorized() && payrollMerchant.MatchString(t.Merchant.Name)
+}
@@ -0,0 +1,368 @@
+// Package tui implements a small keyboard-driven terminal UI for browsing
+// Monarch data.
+package tui
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// View is one screen in the UI. Views are kept on a stack: opening a view
+// pushes it and going back pops it.
+type View interface {
+	// Title is shown in the header line.
+	Title() string
+	// Render draws the view body into at most height lines of width cols.
+	Render(w io.Writer, height, cols int)
+	// Handle processes a key. It returns a view to push, or back=true to pop.
+	Handle(key Key, ui *UI) (next View, back bool, err error)
+}
+
+// quitter is implemented by views that may hold changes that quitting
+// would lose.
+type quitter interface {
+	// ConfirmQuit reports whether the UI may quit, asking the user first
+	// if need be.
+	ConfirmQuit(ui *UI) bool
+}
+
+// helper is implemented by views with keys of their own to list in the
+// footer.
+type helper interface {
+	Help() string
+}
+
+// UI runs views on the terminal.
+type UI struct {
+	term   *terminal
+	status string
+}
+
+// Run shows root and processes keys until the user quits.
+func Run(root View) error {
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.close()
+
+	ui := &UI{term: term}
+	stack := []View{root}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		ui.draw(top, len(stack) > 1)
+
+		key, err := term.readKey()
+		if err != nil {
+			return err
+		}
+		if key.Code == CodeCtrlC {
+			return nil
+		}
+		if key.Code == CodeRune && key.Rune == 'q' {
+			if confirmQuit(stack, ui) {
+				return nil
+			}
+			continue
+		}
+		ui.status = ""
+		next, back, err := top.Handle(key, ui)
+		switch {
+		case err != nil:
+			ui.status = "Error: " + err.Error()
+		case next != nil:
+			stack = append(stack, next)
+		case back:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return nil
+}
+
+// confirmQuit asks each view on the stack, topmost first, whether the UI
+// may quit.
+func confirmQuit(stack []View, ui *UI) bool {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if q, ok := stack[i].(quitter); ok && !q.ConfirmQuit(ui) {
+			return false
+		}
+	}
+	return true
+}
+
+// Confirm asks a yes or no question in the footer; anything but y is no.
+func (ui *UI) Confirm(question string) bool {
+	answer, ok := ui.Prompt(question + " (y/N) ")
+	return ok && strings.EqualFold(strings.TrimSpace(answer), "y")
+}
+
+// SetStatus shows a message in the footer until the next key.
+func (ui *UI) SetStatus(format string, args ...any) {
+	ui.status = fmt.Sprintf(format, args...)
+}
+
+// Prompt reads a line of text in the footer. ok is false if the user cancelled.
+func (ui *UI) Prompt(label string) (string, bool) {
+	rows, _ := ui.term.size()
+	fmt.Printf("\x1b[%d;1H\x1b[2K", rows)
+	return ui.term.readLine(label)
+}
+
+func (ui *UI) draw(v View, canGoBack bool) {
+	rows, cols := ui.term.size()
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "\x1b[1m%s\x1b[0m\r\n\r\n", truncate(v.Title(), cols))
+
+	body := &crlfWriter{w: &b}
+	v.Render(body, rows-4, cols)
+
+	help := "↑/↓ move  enter open  q quit"
+	if canGoBack {
+		help = "↑/↓ move  enter open  ← back  q quit"
+	}
+	if h, ok := v.(helper); ok && h.Help() != "" {
+		help = h.Help() + "  " + help
+	}
+	if ui.status != "" {
+		help = ui.status
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[7m%-*s\x1b[0m", rows, cols, truncate(help, cols))
+	os.Stdout.WriteString(b.String())
+}
+
+// crlfWriter translates "\n" to "\r\n", which raw mode needs.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(c.w, strings.ReplaceAll(string(p), "\n", "\r\n"))
+	return len(p), err
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// isBack reports whether key navigates back.
+func isBack(key Key) bool {
+	return key.Code == CodeLeft || key.Code == CodeBackspace || (key.Code == CodeRune && key.Rune == 'h')
+}
+
+// List is a selectable list of items. Typing / filters the items by a text
+// in their labels. A list with Columns can also be sorted by each of them
+// in turn with o, and its items as shown exported as CSV with x.
+type List struct {
+	Heading string
+	Header  string
+	Items   []Item
+	// Columns name the Fields of the items.
+	Columns []string
+	cursor  int
+	offset  int
+	filter  string
+	// sortBy is the column sorted by plus one; zero keeps the items' order.
+	sortBy int
+	// shown are the indexes of the items shown, filtered and sorted.
+	shown []int
+}
+
+// Item is one row of a List. Open, when set, builds the view shown on enter.
+type Item struct {
+	Label string
+	// Fields are the item's values in the list's Columns.
+	Fields []string
+	Open   func() (View, error)
+}
+
+func (l *List) Title() string {
+	if l.filter != "" {
+		return fmt.Sprintf("%s — matching %q", l.Heading, l.filter)
+	}
+	return l.Heading
+}
+
+func (l *List) Help() string {
+	if len(l.Columns) == 0 {
+		return "/ filter"
+	}
+	return "/ filter  o sort  x export"
+}
+
+// update recomputes the items shown after the items, filter or sort order
+// changed.
+func (l *List) update() {
+	l.shown = l.shown[:0]
+	filter := strings.ToLower(l.filter)
+	for i, item := range l.Items {
+		if strings.Contains(strings.ToLower(item.Label), filter) {
+			l.shown = append(l.shown, i)
+		}
+	}
+	if col := l.sortBy - 1; col >= 0 {
+		slices.SortStableFunc(l.shown, func(a, b int) int {
+			return compareFields(field(l.Items[a], col), field(l.Items[b], col))
+		})
+	}
+	if l.cursor >= len(l.shown) {
+		l.cursor = max(0, len(l.shown)-1)
+	}
+}
+
+func field(item Item, col int) string {
+	if col < len(item.Fields) {
+		return item.Fields[col]
+	}
+	return ""
+}
+
+// compareFields orders numbers largest first and text alphabetically.
+func compareFields(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return cmp.Compare(y, x)
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// Selected returns the index in Items of the selected item, or -1 if no
+// item is shown.
+func (l *List) Selected() int {
+	l.update()
+	if l.cursor >= len(l.shown) {
+		return -1
+	}
+	return l.shown[l.cursor]
+}
+
+func (l *List) Render(w io.Writer, height, cols int) {
+	l.update()
+	if l.Header != "" {
+		fmt.Fprintf(w, "  \x1b[4m%s\x1b[0m\n", truncate(l.Header, cols-2))
+		height--
+	}
+	if len(l.shown) == 0 {
+		fmt.Fprintln(w, "  (nothing to show)")
+		return
+	}
+	if l.cursor < l.offset {
+		l.offset = l.cursor
+	}
+	if l.cursor >= l.offset+height {
+		l.offset = l.cursor - height + 1
+	}
+	for i := l.offset; i < len(l.shown) && i < l.offset+height; i++ {
+		line := truncate(l.Items[l.shown[i]].Label, cols-2)
+		if i == l.cursor {
+			fmt.Fprintf(w, "\x1b[7m> %-*s\x1b[0m\n", cols-2, line)
+		} else {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
+
+func (l *List) Handle(key Key, ui *UI) (View, bool, error) {
+	l.update()
+	isRune := func(r rune) bool { return key.Code == CodeRune && key.Rune == r }
+	switch {
+	case key.Code == CodeUp || isRune('k'):
+		if l.cursor > 0 {
+			l.cursor--
+		}
+	case key.Code == CodeDown || isRune('j'):
+		if l.cursor < len(l.shown)-1 {
+			l.cursor++
+		}
+	case key.Code == CodeEnter || key.Code == CodeRight || isRune('l'):
+		if i := l.Selected(); i >= 0 && l.Items[i].Open != nil {
+			v, err := l.Items[i].Open()
+			return v, false, err
+		}
+	case isRune('/'):
+		if filter, ok := ui.Prompt("Filter (empty shows all): "); ok {
+			l.filter = strings.TrimSpace(filter)
+			l.cursor, l.offset = 0, 0
+		}
+	case isRune('o') && len(l.Columns) > 0:
+		l.sortBy = (l.sortBy + 1) % (len(l.Columns) + 1)
+		l.cursor, l.offset = 0, 0
+		if l.sortBy == 0 {
+			ui.SetStatus("Original order")
+		} else {
+			ui.SetStatus("Sorted by %s", l.Columns[l.sortBy-1])
+		}
+	case isRune('x') && len(l.Columns) > 0:
+		path, ok := ui.Prompt("Export to CSV file: ")
+		if path = strings.TrimSpace(path); !ok || path == "" {
+			return nil, false, nil
+		}
+		if err := l.export(path); err != nil {
+			return nil, false, err
+		}
+		ui.SetStatus("Exported %d rows to %s", len(l.shown), path)
+	case isBack(key):
+		return nil, true, nil
+	}
+	return nil, false, nil
+}
+
+// export writes the items shown, as filtered and sorted, to a CSV file.
+func (l *List) export(path string) error {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(l.Columns)
+	for _, i := range l.shown {
+		w.Write(l.Items[i].Fields)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// Text is a scrollable block of text.
+type Text struct {
+	Heading string
+	Lines   []string
+	offset  int
+}
+
+func (t *Text) Title() string { return t.Heading }
+
+func (t *Text) Render(w io.Writer, height, cols int) {
+	for i := t.offset; i < len(t.Lines) && i < t.offset+height; i++ {
+		fmt.Fprintln(w, truncate(t.Lines[i], cols))
+	}
+}
+
+func (t *Text) Handle(key Key, ui *UI) (View, bool, error) {
+	switch {
+	case key.Code == CodeUp || (key.Code == CodeRune && key.Rune == 'k'):
+		if t.offset > 0 {
+			t.offset--
+		}
+	case key.Code == CodeDown || (key.Code == CodeRune && key.Rune == 'j'):
+		if t.offset < len(t.Lines)-1 {
+			t.offset++
+		}
+	case isBack(key):
+		return nil, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListSortFilterExport(t *testing.T) {
+	l := &List{Columns: []string{"Ticker", "Value"}}
+	for _, f := range [][]string{{"VTI", "900.00"}, {"AAPL", "5700.00"}, {"BND", "n/a"}, {"VXUS", "1200.50"}} {
+		l.Items = append(l.Items, Item{Label: f[0] + " " + f[1], Fields: f})
+	}
+	labels := func() []string {
+		l.update()
+		var out []string
+		for _, i := range l.shown {
+			out = append(out, l.Items[i].Fields[0])
+		}
+		return out
+	}
+	ui := &UI{}
+	sortKey := Key{Code: CodeRune, Rune: 'o'}
+
+	l.Handle(sortKey, ui)
+	if got, want := labels(), []string{"AAPL", "BND", "VTI", "VXUS"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by ticker: got %v, want %v", got, want)
+	}
+	l.Handle(sortKey, ui)
+	if got, want := labels(), []string{"AAPL", "VXUS", "VTI", "BND"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by value: got %v, want %v", got, want)
+	}
+	if ui.status != "Sorted by Value" {
+		t.Errorf("got status %q", ui.status)
+	}
+
+	l.filter = "v"
+	if got, want := labels(), []string{"VXUS", "VTI"}; !slices.Equal(got, want) {
+		t.Errorf("filtered: got %v, want %v", got, want)
+	}
+	l.Handle(Key{Code: CodeDown}, ui)
+	if got := l.Items[l.Selected()].Fields[0]; got != "VTI" {
+		t.Errorf("selected %s, want VTI", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.csv")
+	if err := l.export(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Ticker,Value\nVXUS,1200.50\nVTI,900.00\n"; string(got) != want {
+		t.Errorf("exported:\n%s\nwant:\n%s", got, want)
+	}
+
+	l.Handle(sortKey, ui)
+	l.filter = ""
+	if got, want := labels(), []string{"VTI", "AAPL", "BND", "VXUS"}; !slices.Equal(got, want) {
+		t.Errorf("original order: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/budget"
+)
+
+// Budget shows each category's budget against actual spending for a month
+// and lets the user adjust budgeted amounts. Changes are saved immediately.
+type Budget struct {
+	// Load fetches budget data for the month containing its argument.
+	Load   func(month time.Time) (*budget.Data, error)
+	Setter budget.Setter
+
+	month time.Time
+	lines []budget.Line
+	list  List
+}
+
+// NewBudget creates a budget screen showing month.
+func NewBudget(load func(time.Time) (*budget.Data, error), setter budget.Setter, month time.Time) (*Budget, error) {
+	b := &Budget{Load: load, Setter: setter}
+	if err := b.show(budget.MonthStart(month)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Budget) show(month time.Time) error {
+	data, err := b.Load(month)
+	if err != nil {
+		return err
+	}
+	b.month = month
+	b.lines = data.Lines(month)
+	return nil
+}
+
+func (b *Budget) Title() string {
+	return "Budget — " + b.month.Format("January 2006")
+}
+
+func (b *Budget) sync() {
+	b.list.Header = fmt.Sprintf("%-20s %-26s %12s %12s %12s", "Group", "Category", "Budget", "Actual", "Remaining")
+	b.list.Items = b.list.Items[:0]
+	for _, l := range b.lines {
+		b.list.Items = append(b.list.Items, Item{Label: fmt.Sprintf("%-20s %-26s %12.2f %12.2f %12.2f",
+			truncate(l.GroupName, 20), truncate(l.CategoryName, 26), l.Planned, l.Actual, l.Remaining)})
+	}
+}
+
+func (b *Budget) Render(w io.Writer, height, cols int) {
+	b.sync()
+	fmt.Fprintln(w, "  e set budget  E set for this and future months  [ ] previous/next month")
+	b.list.Render(w, height-3, cols)
+
+	var planned, actual float64
+	for _, l := range b.lines {
+		if l.GroupType == "expense" {
+			planned += l.Planned
+			actual += l.Actual
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Expenses: budget %.2f, actual %.2f, remaining %.2f\n", planned, actual, planned-actual)
+}
+
+func (b *Budget) Handle(key Key, ui *UI) (View, bool, error) {
+	if key.Code == CodeRune {
+		switch key.Rune {
+		case '[':
+			return nil, false, b.show(b.month.AddDate(0, -1, 0))
+		case ']':
+			return nil, false, b.show(b.month.AddDate(0, 1, 0))
+		case 'e', 'E':
+			return nil, false, b.edit(ui, key.Rune == 'E')
+		}
+	}
+	b.sync()
+	return b.list.Handle(key, ui)
+}
+
+func (b *Budget) edit(ui *UI, applyToFuture bool) error {
+	i := b.list.Selected()
+	if i < 0 || i >= len(b.lines) {
+		return nil
+	}
+	l := &b.lines[i]
+	input, ok := ui.Prompt(fmt.Sprintf("Budget for %s (currently %.2f): ", l.CategoryName, l.Planned))
+	input = strings.TrimSpace(input)
+	if !ok || input == "" {
+		return nil
+	}
+	amount, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", input)
+	}
+	if err := b.Setter.SetBudget(l.CategoryID, b.month, amount, applyToFuture); err != nil {
+		return err
+	}
+	l.Remaining += amount - l.Planned
+	l.Planned = amount
+	ui.SetStatus("Budget for %s set to %.2f", l.CategoryName, amount)
+	return nil
+}
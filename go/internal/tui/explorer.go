@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heikofkoehler/monarch/internal/history"
+	"github.com/heikofkoehler/monarch/internal/portfolio"
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// recentTransactionLimit caps the transactions shown on a security's detail.
+const recentTransactionLimit = 10
+
+// Explorer holds the data the holdings explorer drills into.
+type Explorer struct {
+	// Snapshots in chronological order; the last one is shown.
+	Snapshots []history.Snapshot
+	// Transactions for investment accounts, used for the detail view.
+	Transactions []transactions.Transaction
+}
+
+// AccountsView lists investment accounts in the latest snapshot.
+func (e *Explorer) AccountsView() View {
+	latest := e.Snapshots[len(e.Snapshots)-1]
+	totals := make(map[string]float64)
+	names := make(map[string]string)
+	var ids []string
+	for _, h := range latest.Holdings {
+		if _, ok := names[h.AccountID]; !ok {
+			names[h.AccountID] = h.AccountName + " (" + h.InstitutionName + ")"
+			ids = append(ids, h.AccountID)
+		}
+		totals[h.AccountID] += h.Value
+	}
+	sort.Slice(ids, func(i, j int) bool { return totals[ids[i]] > totals[ids[j]] })
+
+	list := &List{
+		Heading: "Investment accounts — as of " + latest.Time.Format(time.DateOnly),
+		Header:  fmt.Sprintf("%-44s %14s", "Account", "Value"),
+		Columns: []string{"Account", "Value"},
+	}
+	for _, id := range ids {
+		id := id
+		list.Items = append(list.Items, Item{
+			Label:  fmt.Sprintf("%-44s %14.2f", names[id], totals[id]),
+			Fields: []string{names[id], fmt.Sprintf("%.2f", totals[id])},
+			Open:   func() (View, error) { return e.holdingsView(id, names[id]), nil },
+		})
+	}
+	return list
+}
+
+func (e *Explorer) holdingsView(accountID, accountName string) View {
+	latest := e.Snapshots[len(e.Snapshots)-1]
+	list := &List{
+		Heading: accountName,
+		Header:  fmt.Sprintf("%-8s %-32s %12s %10s %14s", "Ticker", "Name", "Quantity", "Price", "Value"),
+		Columns: []string{"Ticker", "Name", "Quantity", "Price", "Value"},
+	}
+	for _, h := range latest.Holdings {
+		if h.AccountID != accountID {
+			continue
+		}
+		h := h
+		price := fmt.Sprintf("%.2f", h.CurrentPrice)
+		if h.IsUnpriced() {
+			price = "n/a"
+		}
+		list.Items = append(list.Items, Item{
+			Label:  fmt.Sprintf("%-8s %-32s %12.4f %10s %14.2f", h.Ticker, truncate(h.HoldingName, 32), h.Quantity, price, h.Value),
+			Fields: []string{h.Ticker, h.HoldingName, fmt.Sprintf("%.4f", h.Quantity), price, fmt.Sprintf("%.2f", h.Value)},
+			Open:   func() (View, error) { return e.securityView(h), nil },
+		})
+	}
+	return list
+}
+
+func (e *Explorer) securityView(h portfolio.HoldingRecord) View {
+	latest := e.Snapshots[len(e.Snapshots)-1]
+	lines := []string{
+		fmt.Sprintf("Security:  %s (%s)", h.SecurityName, h.SecurityTicker),
+		fmt.Sprintf("Type:      %s", h.TypeDisplay),
+		fmt.Sprintf("Price:     %.2f (updated %s)", h.CurrentPrice, h.PriceUpdated),
+		"",
+		"Price history",
+	}
+
+	dates, prices := e.priceHistory(h.SecurityID)
+	if len(prices) < 2 {
+		lines = append(lines, "  not enough snapshots yet")
+	} else {
+		lo, hi := prices[0], prices[0]
+		for _, p := range prices {
+			lo, hi = min(lo, p), max(hi, p)
+		}
+		lines = append(lines,
+			"  "+sparkline(prices, 60),
+			fmt.Sprintf("  %s → %s   low %.2f  high %.2f", dates[0], dates[len(dates)-1], lo, hi),
+		)
+	}
+
+	// Monarch's API doesn't expose tax lots, so show the position held in
+	// each account instead.
+	lines = append(lines, "", "Positions")
+	for _, p := range latest.Holdings {
+		if p.SecurityID != h.SecurityID {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %-40s %12.4f sh %14.2f", p.AccountName, p.Quantity, p.Value))
+	}
+
+	lines = append(lines, "", "Recent investment transactions")
+	recent := e.securityTransactions(h)
+	if len(recent) == 0 {
+		lines = append(lines, "  none found")
+	}
+	for _, t := range recent {
+		lines = append(lines, fmt.Sprintf("  %s  %-40s %12.2f", t.Date, truncate(t.Merchant.Name, 40), t.Amount))
+	}
+
+	return &Text{Heading: h.HoldingName, Lines: lines}
+}
+
+// priceHistory returns one price per snapshot day for the security.
+func (e *Explorer) priceHistory(securityID string) (dates []string, prices []float64) {
+	for _, snap := range e.Snapshots {
+		day := snap.Time.Format(time.DateOnly)
+		for _, h := range snap.Holdings {
+			if h.SecurityID != securityID || h.CurrentPrice == 0 {
+				continue
+			}
+			if len(dates) > 0 && dates[len(dates)-1] == day {
+				prices[len(prices)-1] = h.CurrentPrice
+			} else {
+				dates = append(dates, day)
+				prices = append(prices, h.CurrentPrice)
+			}
+			break
+		}
+	}
+	return dates, prices
+}
+
+// securityTransactions returns the most recent transactions in the holding's
+// account whose description mentions the security's ticker or name.
+func (e *Explorer) securityTransactions(h portfolio.HoldingRecord) []transactions.Transaction {
+	var needles []string
+	for _, s := range []string{h.Ticker, h.SecurityTicker, h.SecurityName} {
+		if s != "" {
+			needles = append(needles, strings.ToLower(s))
+		}
+	}
+	var out []transactions.Transaction
+	for _, t := range e.Transactions {
+		if t.Account.ID != h.AccountID {
+			continue
+		}
+		text := strings.ToLower(t.Merchant.Name + " " + t.Notes)
+		for _, n := range needles {
+			if strings.Contains(text, n) {
+				out = append(out, t)
+				break
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date > out[j].Date })
+	if len(out) > recentTransactionLimit {
+		out = out[:recentTransactionLimit]
+	}
+	return out
+}
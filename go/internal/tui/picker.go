@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+)
+
+// Picker lets the user choose one option, or several when Multi is set.
+// In multi mode space toggles an option and enter confirms the selection.
+type Picker struct {
+	Heading  string
+	Options  []string
+	Multi    bool
+	Selected map[int]bool
+	// OnPick is called with the chosen indexes when the user confirms.
+	OnPick func(chosen []int) error
+	list   List
+}
+
+func (p *Picker) Title() string { return p.Heading }
+
+func (p *Picker) sync() {
+	p.list.Items = p.list.Items[:0]
+	for i, opt := range p.Options {
+		label := opt
+		if p.Multi {
+			mark := "[ ]"
+			if p.Selected[i] {
+				mark = "[x]"
+			}
+			label = mark + " " + opt
+		}
+		p.list.Items = append(p.list.Items, Item{Label: label})
+	}
+}
+
+func (p *Picker) Render(w io.Writer, height, cols int) {
+	p.sync()
+	if p.Multi {
+		fmt.Fprintln(w, "  space toggle  enter confirm")
+		height--
+	}
+	p.list.Render(w, height, cols)
+}
+
+func (p *Picker) Handle(key Key, ui *UI) (View, bool, error) {
+	if p.Selected == nil {
+		p.Selected = make(map[int]bool)
+	}
+	switch {
+	case p.Multi && key.Code == CodeRune && key.Rune == ' ':
+		if i := p.list.Selected(); i >= 0 {
+			p.Selected[i] = !p.Selected[i]
+		}
+		return nil, false, nil
+	case key.Code == CodeEnter:
+		if len(p.Options) == 0 {
+			return nil, true, nil
+		}
+		chosen := []int{p.list.Selected()}
+		if !p.Multi && chosen[0] < 0 {
+			return nil, false, nil
+		}
+		if p.Multi {
+			chosen = chosen[:0]
+			for i := range p.Options {
+				if p.Selected[i] {
+					chosen = append(chosen, i)
+				}
+			}
+		}
+		return nil, true, p.OnPick(chosen)
+	}
+	p.sync()
+	return p.list.Handle(key, ui)
+}
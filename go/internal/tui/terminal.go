@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Key is a decoded keypress.
+type Key struct {
+	Code Code
+	Rune rune
+}
+
+// Code identifies special keys; printable keys use CodeRune.
+type Code int
+
+const (
+	CodeRune Code = iota
+	CodeUp
+	CodeDown
+	CodeLeft
+	CodeRight
+	CodeEnter
+	CodeBackspace
+	CodeCtrlC
+)
+
+// terminal switches the controlling terminal into raw mode and reads keys.
+// Raw mode is set with stty, so it works on macOS and Linux terminals.
+type terminal struct {
+	saved string
+	in    *bufio.Reader
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal (stty: %w)", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("enter raw mode: %w", err)
+	}
+	fmt.Print("\x1b[?25l") // hide cursor
+	return &terminal{saved: saved, in: bufio.NewReader(os.Stdin)}, nil
+}
+
+func (t *terminal) close() {
+	fmt.Print("\x1b[?25h\x1b[H\x1b[2J")
+	_, _ = stty(t.saved)
+}
+
+// size returns the terminal height and width, defaulting to 24x80.
+func (t *terminal) size() (rows, cols int) {
+	out, err := stty("size")
+	if err == nil {
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+func (t *terminal) readKey() (Key, error) {
+	r, _, err := t.in.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+	switch r {
+	case 3:
+		return Key{Code: CodeCtrlC}, nil
+	case '\r', '\n':
+		return Key{Code: CodeEnter}, nil
+	case 127, 8:
+		return Key{Code: CodeBackspace}, nil
+	case 0x1b:
+		if next, _ := t.in.ReadByte(); next != '[' {
+			return Key{Code: CodeRune, Rune: rune(next)}, nil
+		}
+		switch b, _ := t.in.ReadByte(); b {
+		case 'A':
+			return Key{Code: CodeUp}, nil
+		case 'B':
+			return Key{Code: CodeDown}, nil
+		case 'C':
+			return Key{Code: CodeRight}, nil
+		case 'D':
+			return Key{Code: CodeLeft}, nil
+		}
+		return t.readKey()
+	}
+	return Key{Code: CodeRune, Rune: r}, nil
+}
+
+// readLine reads a line of input in raw mode, echoing as the user types.
+func (t *terminal) readLine(label string) (string, bool) {
+	var buf []rune
+	for {
+		fmt.Printf("\r\x1b[2K%s%s", label, string(buf))
+		k, err := t.readKey()
+		if err != nil {
+			return "", false
+		}
+		switch k.Code {
+		case CodeEnter:
+			return string(buf), true
+		case CodeCtrlC:
+			return "", false
+		case CodeBackspace:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		case CodeRune:
+			buf = append(buf, k.Rune)
+		}
+	}
+}
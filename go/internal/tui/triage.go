@@ -0,0 +1,249 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+// Triage lists recent transactions for categorizing, tagging and splitting.
+// Changes are staged in a transactions.Review and sent on save.
+type Triage struct {
+	Transactions []transactions.Transaction
+	Categories   []transactions.Category
+	Tags         []transactions.Tag
+	Mutator      transactions.Mutator
+
+	review        *transactions.Review
+	uncategorized bool
+	list          List
+	shown         []int
+}
+
+func (t *Triage) Title() string {
+	title := fmt.Sprintf("Transactions — %d staged", t.staged().Len())
+	if t.uncategorized {
+		title += " — uncategorized only"
+	}
+	return title
+}
+
+func (t *Triage) staged() *transactions.Review {
+	if t.review == nil {
+		t.review = transactions.NewReview()
+	}
+	return t.review
+}
+
+func (t *Triage) sync() {
+	t.shown = t.shown[:0]
+	for i, tx := range t.Transactions {
+		if t.uncategorized && !tx.IsUncategorized() && !tx.NeedsReview {
+			continue
+		}
+		t.shown = append(t.shown, i)
+	}
+	sort.SliceStable(t.shown, func(a, b int) bool {
+		return t.Transactions[t.shown[a]].Date > t.Transactions[t.shown[b]].Date
+	})
+	t.list.Header = fmt.Sprintf("  %-10s %-28s %11s %-22s %s", "Date", "Merchant", "Amount", "Category", "Tags")
+	t.list.Items = t.list.Items[:0]
+	for _, i := range t.shown {
+		tx := t.Transactions[i]
+		mark := " "
+		if t.staged().Pending(tx.ID) {
+			mark = "*"
+		} else if tx.NeedsReview {
+			mark = "!"
+		}
+		var tags []string
+		for _, tg := range tx.Tags {
+			tags = append(tags, tg.Name)
+		}
+		t.list.Items = append(t.list.Items, Item{Label: fmt.Sprintf("%s %-10s %-28s %11.2f %-22s %s",
+			mark, tx.Date, truncate(tx.Merchant.Name, 28), tx.Amount, truncate(tx.Category.Name, 22), strings.Join(tags, ","))})
+	}
+}
+
+func (t *Triage) Render(w io.Writer, height, cols int) {
+	t.sync()
+	fmt.Fprintln(w, "  c category  t tags  s split  r reviewed  u uncategorized  / filter  w save")
+	t.list.Render(w, height-1, cols)
+}
+
+// current returns the selected transaction.
+func (t *Triage) current() (*transactions.Transaction, bool) {
+	i := t.list.Selected()
+	if i < 0 {
+		return nil, false
+	}
+	return &t.Transactions[t.shown[i]], true
+}
+
+// ConfirmQuit asks before quitting with edits not yet saved.
+func (t *Triage) ConfirmQuit(ui *UI) bool {
+	n := t.staged().Len()
+	return n == 0 || ui.Confirm(fmt.Sprintf("Discard %d unsaved edits?", n))
+}
+
+func (t *Triage) Handle(key Key, ui *UI) (View, bool, error) {
+	if isBack(key) {
+		return nil, t.ConfirmQuit(ui), nil
+	}
+	if key.Code != CodeRune {
+		t.sync()
+		return t.list.Handle(key, ui)
+	}
+	switch key.Rune {
+	case 'u':
+		t.uncategorized = !t.uncategorized
+		t.list.cursor = 0
+		return nil, false, nil
+	case 'w':
+		if t.staged().Len() == 0 {
+			ui.SetStatus("Nothing to save")
+			return nil, false, nil
+		}
+		n, err := t.staged().Apply(t.Mutator)
+		if err != nil {
+			return nil, false, fmt.Errorf("saved %d, then: %w", n, err)
+		}
+		ui.SetStatus("Saved %d transactions", n)
+		return nil, false, nil
+	}
+
+	tx, ok := t.current()
+	if !ok {
+		return nil, false, nil
+	}
+	switch key.Rune {
+	case 'c':
+		return t.categoryPicker(tx), false, nil
+	case 't':
+		return t.tagPicker(tx), false, nil
+	case 's':
+		return nil, false, t.split(tx, ui)
+	case 'r':
+		t.staged().MarkReviewed(tx.ID)
+		tx.NeedsReview = false
+	default:
+		t.sync()
+		return t.list.Handle(key, ui)
+	}
+	return nil, false, nil
+}
+
+func (t *Triage) categoryPicker(tx *transactions.Transaction) View {
+	p := &Picker{Heading: "Category for " + tx.Merchant.Name}
+	for _, c := range t.Categories {
+		p.Options = append(p.Options, c.Name)
+	}
+	p.OnPick = func(chosen []int) error {
+		c := t.Categories[chosen[0]]
+		t.staged().SetCategory(tx.ID, c.ID)
+		tx.Category = c
+		return nil
+	}
+	return p
+}
+
+func (t *Triage) tagPicker(tx *transactions.Transaction) View {
+	p := &Picker{Heading: "Tags for " + tx.Merchant.Name, Multi: true, Selected: make(map[int]bool)}
+	for i, tg := range t.Tags {
+		p.Options = append(p.Options, tg.Name)
+		for _, have := range tx.Tags {
+			if have.ID == tg.ID {
+				p.Selected[i] = true
+			}
+		}
+	}
+	p.OnPick = func(chosen []int) error {
+		ids := []string{}
+		var tags []transactions.Tag
+		for _, i := range chosen {
+			ids = append(ids, t.Tags[i].ID)
+			tags = append(tags, t.Tags[i])
+		}
+		t.staged().SetTags(tx.ID, ids)
+		tx.Tags = tags
+		return nil
+	}
+	return p
+}
+
+// split prompts for a split of tx and stages it.
+func (t *Triage) split(tx *transactions.Transaction, ui *UI) error {
+	input, ok := ui.Prompt(fmt.Sprintf("Split %.2f as \"amount category; ...\": ", tx.Amount))
+	if !ok || strings.TrimSpace(input) == "" {
+		return nil
+	}
+	splits, err := t.parseSplit(*tx, input)
+	if err != nil {
+		return err
+	}
+	if err := t.staged().Split(*tx, splits); err != nil {
+		return err
+	}
+	ui.SetStatus("Staged %d-way split", len(splits))
+	return nil
+}
+
+// parseSplit parses "amount category; amount category" into splits of tx.
+// Amounts take the transaction's sign, so an expense of -100.00 splits as
+// "30 groceries; 70 dining". The last part may omit its amount to take
+// whatever remains; a category left out keeps the transaction's.
+func (t *Triage) parseSplit(tx transactions.Transaction, input string) ([]transactions.Split, error) {
+	var splits []transactions.Split
+	remaining := tx.Amount
+	parts := strings.Split(input, ";")
+	for i, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		amount, err := strconv.ParseFloat(fields[0], 64)
+		amount = math.Copysign(amount, tx.Amount)
+		name := strings.Join(fields[1:], " ")
+		if err != nil {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("invalid amount %q", fields[0])
+			}
+			if math.Abs(remaining) < 0.005 || math.Signbit(remaining) != math.Signbit(tx.Amount) {
+				return nil, fmt.Errorf("nothing remains of %.2f for %q", tx.Amount, strings.Join(fields, " "))
+			}
+			amount, name = remaining, strings.Join(fields, " ")
+		}
+		cat, err := t.findCategory(name, tx.Category)
+		if err != nil {
+			return nil, err
+		}
+		splits = append(splits, transactions.Split{Amount: amount, CategoryID: cat.ID, Merchant: tx.Merchant.Name})
+		remaining -= amount
+	}
+	return splits, nil
+}
+
+// findCategory matches a category by case-insensitive name prefix; an empty
+// name keeps fallback.
+func (t *Triage) findCategory(name string, fallback transactions.Category) (transactions.Category, error) {
+	if name == "" {
+		return fallback, nil
+	}
+	name = strings.ToLower(name)
+	for _, c := range t.Categories {
+		if strings.ToLower(c.Name) == name {
+			return c, nil
+		}
+	}
+	for _, c := range t.Categories {
+		if strings.HasPrefix(strings.ToLower(c.Name), name) {
+			return c, nil
+		}
+	}
+	return transactions.Category{}, fmt.Errorf("no category matching %q", name)
+}
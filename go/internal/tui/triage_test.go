@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/heikofkoehler/monarch/internal/transactions"
+)
+
+func TestParseSplit(t *testing.T) {
+	tr := &Triage{Categories: []transactions.Category{
+		{ID: "c1", Name: "Groceries"}, {ID: "c2", Name: "Dining Out"}, {ID: "c3", Name: "Paychecks"},
+	}}
+	expense := transactions.Transaction{Amount: -100, Category: transactions.Category{ID: "c1", Name: "Groceries"}}
+	income := transactions.Transaction{Amount: 250, Category: transactions.Category{ID: "c3", Name: "Paychecks"}}
+	for _, tc := range []struct {
+		tx    transactions.Transaction
+		input string
+		want  string
+	}{
+		{expense, "30 groceries; 70 dining", "[{-30 c1} {-70 c2}]"},
+		{expense, "-30 groceries; -70 dining", "[{-30 c1} {-70 c2}]"},
+		{expense, "30 groceries; dining", "[{-30 c1} {-70 c2}]"},
+		{expense, "25.5; dining out", "[{-25.5 c1} {-74.5 c2}]"},
+		{income, "200 paychecks; groc", "[{200 c3} {50 c1}]"},
+		{expense, "100 groceries; dining", `error: nothing remains of -100.00 for "dining"`},
+		{expense, "120 groceries; dining", `error: nothing remains of -100.00 for "dining"`},
+		{expense, "lots groceries; 70 dining", `error: invalid amount "lots"`},
+		{expense, "30 travel; dining", `error: no category matching "travel"`},
+	} {
+		splits, err := tr.parseSplit(tc.tx, tc.input)
+		got := "error: " + fmt.Sprint(err)
+		if err == nil {
+			var parts []string
+			for _, s := range splits {
+				parts = append(parts, fmt.Sprintf("{%g %s}", s.Amount, s.CategoryID))
+			}
+			got = fmt.Sprint(parts)
+		}
+		if got != tc.want {
+			t.Errorf("split %.2f as %q: got %s, want %s", tc.tx.Amount, tc.input, got, tc.want)
+		}
+	}
+}
+
+// typing returns a UI reading input as if typed.
+func typing(input string) *UI {
+	return &UI{term: &terminal{in: bufio.NewReader(strings.NewReader(input))}}
+}
+
+func TestTriageConfirmQuit(t *testing.T) {
+	tr := &Triage{}
+	if !tr.ConfirmQuit(&UI{}) {
+		t.Error("asked to confirm quitting without staged edits")
+	}
+	tr.staged().MarkReviewed("t1")
+	// The picker opened from the triage screen doesn't hide its edits.
+	stack := []View{&List{}, tr, &Picker{}}
+	if confirmQuit(stack, typing("n\r")) {
+		t.Error("quit with staged edits after answering n")
+	}
+	if !confirmQuit(stack, typing("y\r")) {
+		t.Error("didn't quit after answering y")
+	}
+	if _, back, _ := tr.Handle(Key{Code: CodeLeft}, typing("\r")); back {
+		t.Error("left the triage screen with staged edits without confirmation")
+	}
+}
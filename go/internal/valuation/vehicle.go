@@ -0,0 +1,84 @@
+// Package valuation estimates the current value of illiquid assets held in
+// manual accounts.
+package valuation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDepreciation is the yearly loss in value of a typical car, in
+// percent, for each year of ownership; the last rate applies to all later
+// years.
+var DefaultDepreciation = []float64{20, 15, 12, 10, 8}
+
+// Vehicle describes a car to value.
+type Vehicle struct {
+	PurchasePrice float64
+	Purchased     time.Time
+	// Curve overrides DefaultDepreciation.
+	Curve   []float64
+	VIN     string
+	Mileage int
+}
+
+// Depreciated is the value of v at t along its depreciation curve, with
+// partial years prorated.
+func (v Vehicle) Depreciated(t time.Time) float64 {
+	curve := v.Curve
+	if len(curve) == 0 {
+		curve = DefaultDepreciation
+	}
+	years := max(t.Sub(v.Purchased).Hours()/24/365.25, 0)
+	value := v.PurchasePrice
+	for year := 0; years > 0; year++ {
+		rate := curve[min(year, len(curve)-1)] / 100
+		value *= math.Pow(1-rate, math.Min(years, 1))
+		years--
+	}
+	return math.Round(value*100) / 100
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Quote asks a valuation service for the value of v. source is a URL in
+// which {vin} and {mileage} are replaced with the vehicle's; the service
+// answers with a JSON object holding the value in "value".
+func (v Vehicle) Quote(ctx context.Context, source string) (float64, error) {
+	u := strings.NewReplacer(
+		"{vin}", url.QueryEscape(v.VIN),
+		"{mileage}", strconv.Itoa(v.Mileage),
+	).Replace(source)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("valuation request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("valuation response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("valuation HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var quote struct {
+		Value *float64 `json:"value"`
+	}
+	if err := json.Unmarshal(body, &quote); err != nil || quote.Value == nil {
+		return 0, fmt.Errorf("valuation response has no value: %s", strings.TrimSpace(string(body)))
+	}
+	return *quote.Value, nil
+}